@@ -0,0 +1,133 @@
+package signed
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// abuseHTTPClient is used to deliver abuse reports
+var abuseHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// AbuseReport is the JSON payload posted to AbuseWebhookURL
+type AbuseReport struct {
+	// WindowStart is when the window in which the threshold was exceeded began
+	WindowStart time.Time `json:"windowStart"`
+
+	// Window is the length of the window in seconds
+	Window int64 `json:"window"`
+
+	// Rejections is the number of rejected requests in the window so far
+	Rejections int `json:"rejections"`
+
+	// Reasons counts rejections in the window by error message
+	Reasons map[string]int `json:"reasons"`
+}
+
+// abuseMonitor counts rejections per window and reports the first time the
+// threshold is exceeded in each
+type abuseMonitor struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	reasons     map[string]int
+	rejections  int
+	reported    bool
+}
+
+// reset clears the current window
+func (a *abuseMonitor) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.windowStart = time.Time{}
+	a.reasons = nil
+	a.rejections = 0
+	a.reported = false
+}
+
+// record counts a rejected request and posts a report if it takes the window
+// over AbuseThreshold
+func (a *abuseMonitor) record(cfg *instance, err error) {
+	if cfg.AbuseWebhookURL == "" {
+		return
+	}
+
+	a.mu.Lock()
+	now := timeNow()
+	if now.Sub(a.windowStart) >= cfg.AbuseWindow {
+		a.windowStart, a.reasons, a.rejections, a.reported = now, make(map[string]int), 0, false
+	}
+
+	a.rejections++
+	a.reasons[err.Error()]++
+
+	var report *AbuseReport
+	if a.rejections > cfg.AbuseThreshold && !a.reported {
+		a.reported = true
+		report = &AbuseReport{
+			WindowStart: a.windowStart,
+			Window:      int64(cfg.AbuseWindow / time.Second),
+			Rejections:  a.rejections,
+			Reasons:     make(map[string]int, len(a.reasons)),
+		}
+		for reason, n := range a.reasons {
+			report.Reasons[reason] = n
+		}
+	}
+	a.mu.Unlock()
+
+	if report != nil {
+		var key string
+		if cfg.GetAbuseWebhookKeyFunc != nil {
+			key = cfg.GetAbuseWebhookKeyFunc()
+		}
+		go postAbuseReport(cfg.AbuseWebhookURL, key, report)
+	}
+}
+
+// postAbuseReport delivers report to url, signing the body with key when set
+func postAbuseReport(url, key string, report *AbuseReport) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("fiber-signed: cannot encode abuse report: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("fiber-signed: cannot send abuse report: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(AbuseSignatureHeader, SignAbuseReport(key, body))
+	}
+
+	resp, err := abuseHTTPClient.Do(req)
+	if err != nil {
+		log.Printf("fiber-signed: cannot send abuse report: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Printf("fiber-signed: abuse webhook responded with status %d", resp.StatusCode)
+	}
+}
+
+// AbuseSignatureHeader is the header carrying the HMAC of abuse reports
+const AbuseSignatureHeader = "X-Fiber-Signed-Signature"
+
+// SignAbuseReport returns the AbuseSignatureHeader value for body, so webhook
+// receivers can verify reports with the shared key
+func SignAbuseReport(key string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%x", mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package signed
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestAbuseWebhook(t *testing.T) {
+	// Receive reports on a channel
+	reports := make(chan *http.Request, 2)
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reports <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:      func() string { return "secret" },
+		AbuseWebhookURL:        server.URL,
+		GetAbuseWebhookKeyFunc: func() string { return "webhook secret" },
+		AbuseThreshold:         3,
+		AbuseWindow:            time.Minute,
+	}))
+
+	t.Run("it should post a signed report once the threshold is exceeded", func(t *testing.T) {
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=x&expires=1"))
+
+		// Reaching the threshold is not exceeding it
+		select {
+		case <-reports:
+			t.Fatal("expected no abuse report at the threshold")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		select {
+		case r := <-reports:
+			body := <-bodies
+
+			var report AbuseReport
+			json.Unmarshal(body, &report)
+
+			utils.AssertEqual(t, SignAbuseReport("webhook secret", body), r.Header.Get(AbuseSignatureHeader))
+			utils.AssertEqual(t, 4, report.Rejections)
+			utils.AssertEqual(t, map[string]int{"invalid signature": 3, "url signature has expired": 1}, report.Reasons)
+		case <-time.After(time.Second):
+			t.Fatal("expected an abuse report")
+		}
+	})
+
+	t.Run("it should report at most once per window", func(t *testing.T) {
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		select {
+		case <-reports:
+			t.Fatal("expected no further abuse report")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package signed
+
+import (
+	"fmt"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+	"github.com/gofiber/fiber/v2"
+)
+
+// getAlgorithmID returns the identifier of alg embedded in URLs
+func getAlgorithmID(alg Algorithm) string {
+	if id, ok := canon.AlgorithmIDs[string(alg)]; ok {
+		return id
+	}
+	return canon.AlgorithmIDs[canon.AlgorithmSHA1]
+}
+
+// getURLAlgorithm returns the algorithm named in the request URL when
+// EmbedAlgorithm is set, reporting whether one was named. Only algorithms in
+// the allowlist are returned, so URLs can't downgrade verification.
+func (cfg *instance) getURLAlgorithm(c *fiber.Ctx) (Algorithm, bool, error) {
+	if !cfg.EmbedAlgorithm {
+		return cfg.Algorithm, false, nil
+	}
+
+	id := c.Query(cfg.AlgorithmQueryKey)
+	if id == "" {
+		return cfg.Algorithm, false, nil
+	}
+
+	for alg, algID := range canon.AlgorithmIDs {
+		if algID == id && cfg.isAllowedAlgorithm(Algorithm(alg)) {
+			return Algorithm(alg), true, nil
+		}
+	}
+
+	return "", true, fmt.Errorf("%s value is not an allowed algorithm", cfg.AlgorithmQueryKey)
+}
+
+// isAllowedAlgorithm reports whether URLs may name alg. Without
+// AllowedAlgorithms, Algorithm and PreviousAlgorithm (while it is still
+// accepted) are allowed.
+func (cfg *instance) isAllowedAlgorithm(alg Algorithm) bool {
+	if cfg.AllowedAlgorithms != nil {
+		for _, allowed := range cfg.AllowedAlgorithms {
+			if alg == allowed {
+				return true
+			}
+		}
+		return false
+	}
+
+	if alg == cfg.Algorithm {
+		return true
+	}
+
+	return alg == cfg.PreviousAlgorithm &&
+		(cfg.PreviousAlgorithmUntil.IsZero() || timeNow().Before(cfg.PreviousAlgorithmUntil))
+}
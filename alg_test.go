@@ -0,0 +1,65 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestEmbedAlgorithm(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmHMACSHA256,
+		EmbedAlgorithm:    true,
+		AllowedAlgorithms: []Algorithm{AlgorithmHMACSHA256, AlgorithmSHA256},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	// signWith signs the path with alg, naming it in the URL
+	signWith := func(alg Algorithm) string {
+		originalURL := "/?alg=" + getAlgorithmID(alg)
+		signature, _ := current().getSignatureFor(alg, "secret", "", http.MethodGet, "http://example.com", originalURL, nil)
+		return originalURL + "&signature=" + signature
+	}
+
+	t.Run("it should name the algorithm in signed urls", func(t *testing.T) {
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.Contains(signedURL, "alg=hs256"))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should verify urls with the allowed algorithm they name", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signWith(AlgorithmSHA256)))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject urls naming algorithms outside the allowlist", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signWith(AlgorithmMD5)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "alg value is not an allowed algorithm", string(body))
+	})
+
+	t.Run("it should not accept other algorithms than the one named", func(t *testing.T) {
+		signedURL := strings.Replace(signWith(AlgorithmHMACSHA256), "alg=hs256", "alg=sha256", 1)
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,67 @@
+package signed
+
+import "strings"
+
+// normalizeHostAliases returns the groups of HostAliases with every host
+// normalized, dropping hosts which can't be
+func normalizeHostAliases(groups [][]string) [][]string {
+	var normalized [][]string
+	for _, group := range groups {
+		var hosts []string
+		for _, host := range group {
+			if h, err := NormalizeHost(host); err == nil {
+				hosts = append(hosts, h)
+			}
+		}
+		if len(hosts) > 1 {
+			normalized = append(normalized, hosts)
+		}
+	}
+
+	return normalized
+}
+
+// getAliasBaseURLs returns baseURL followed by the same base URL for every
+// alias of its host, so URLs signed for any host in a group validate on all
+// of them
+func (cfg *instance) getAliasBaseURLs(baseURL string) []string {
+	baseURLs := []string{baseURL}
+	if len(cfg.HostAliases) == 0 {
+		return baseURLs
+	}
+
+	split := strings.Index(baseURL, "://")
+	if split < 0 {
+		return baseURLs
+	}
+	scheme, host := baseURL[:split+3], baseURL[split+3:]
+	host, err := NormalizeHost(host)
+	if err != nil {
+		return baseURLs
+	}
+
+	seen := map[string]bool{host: true}
+	for _, group := range cfg.HostAliases {
+		if !containsHost(group, host) {
+			continue
+		}
+		for _, alias := range group {
+			if !seen[alias] {
+				seen[alias] = true
+				baseURLs = append(baseURLs, scheme+alias)
+			}
+		}
+	}
+
+	return baseURLs
+}
+
+// containsHost reports whether hosts contains host
+func containsHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestHostAliases(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		HostAliases: [][]string{
+			{"cdn.example.com", "Assets.example.com"},
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://cdn.example.com/", nil))
+
+	t.Run("it should validate urls on aliases of the host they were signed for", func(t *testing.T) {
+		for _, host := range []string{"cdn.example.com", "assets.example.com"} {
+			req := newTestRequest(http.MethodGet, signedURL)
+			req.Host = host
+			resp, _ := app.Test(req)
+
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode, host)
+		}
+	})
+
+	t.Run("it should not validate urls on other hosts", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Host = "example.com"
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
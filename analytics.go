@@ -0,0 +1,186 @@
+package signed
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AnalyticsCount is the number of validated requests for a signed URL on a
+// route, with the purpose it was signed for
+type AnalyticsCount struct {
+	// Signature identifies the signed URL, as passed to BytesServed
+	Signature string
+
+	// Purpose is the purpose signed into the URL, if any
+	Purpose string
+
+	// Route is the path of the route which served the requests
+	Route string
+
+	// Count is the number of requests validated since the last flush
+	Count int
+}
+
+// luaAddCount adds ARGV[1] to the count at KEYS[1], keeping it for ARGV[2]
+// milliseconds (or without an expiry if zero)
+const luaAddCount = `redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1`
+
+// analyticsKey identifies the counts of an analyticsAggregator
+type analyticsKey struct {
+	signature, purpose, route string
+}
+
+// id returns the Storage ID of the totals of k
+func (k analyticsKey) id() string {
+	return k.route + "|" + k.purpose + "|" + k.signature
+}
+
+// analyticsAggregator counts validated requests, flushing them every
+// AnalyticsInterval
+type analyticsAggregator struct {
+	mu        sync.Mutex
+	lastFlush time.Time
+	counts    map[analyticsKey]int
+}
+
+// reset drops the counts not flushed yet
+func (a *analyticsAggregator) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastFlush = timeNow()
+	a.counts = nil
+}
+
+// record counts a validated request, flushing the counts if AnalyticsInterval
+// has passed since the last flush
+func (a *analyticsAggregator) record(cfg *instance, c *fiber.Ctx) {
+	if !cfg.Analytics {
+		return
+	}
+
+	key := analyticsKey{
+		signature: cfg.getSignatureID(c),
+		purpose:   cfg.getClaim(c, ClaimPurpose),
+		route:     c.Route().Path,
+	}
+
+	a.mu.Lock()
+	if a.counts == nil {
+		a.counts = make(map[analyticsKey]int)
+	}
+	a.counts[key]++
+	due := timeNow().Sub(a.lastFlush) >= cfg.AnalyticsInterval
+	a.mu.Unlock()
+
+	if due {
+		go a.flusher(cfg)()
+	}
+}
+
+// flusher takes the counts not flushed yet, returning a func handing them to
+// AnalyticsFlushed or adding them to the totals in Storage. The config is read
+// up front, so the func can run in the background.
+func (a *analyticsAggregator) flusher(cfg *instance) func() error {
+	a.mu.Lock()
+	counts := a.counts
+	a.counts = nil
+	a.lastFlush = timeNow()
+	a.mu.Unlock()
+
+	if len(counts) == 0 {
+		return func() error { return nil }
+	}
+
+	if flushed := cfg.AnalyticsFlushed; flushed != nil {
+		list := make([]AnalyticsCount, 0, len(counts))
+		for key, n := range counts {
+			list = append(list, AnalyticsCount{Signature: key.signature, Purpose: key.purpose, Route: key.route, Count: n})
+		}
+		return func() error {
+			flushed(list)
+			return nil
+		}
+	}
+
+	if cfg.Storage == nil {
+		return func() error { return nil }
+	}
+
+	storage := cfg.getStorage(context.Background())
+	ttl := cfg.storageTTL(StorageKindAnalytics, cfg.AnalyticsTTL)
+	totals := make(map[string]int, len(counts))
+	for key, n := range counts {
+		totals[cfg.storageKey(StorageKindAnalytics, key.id())] = n
+	}
+
+	return func() error {
+		for storageID, n := range totals {
+			if err := addCount(storage, storageID, n, ttl); err != nil {
+				log.Printf("fiber-signed: cannot flush analytics: %v", err)
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addCount adds n to the integer stored at key, keeping it for ttl. It is
+// atomic across every node sharing storage if it implements ScriptRunner, and
+// within this process otherwise.
+func addCount(storage fiber.Storage, key string, n int, ttl time.Duration) error {
+	if runner, ok := storage.(ScriptRunner); ok {
+		_, err := evalScript(runner, luaAddCount, []string{key}, n, ttl.Milliseconds())
+		return err
+	}
+
+	defer storageLocks.lock(key)()
+
+	total, err := getCount(storage, key)
+	if err != nil {
+		return err
+	}
+
+	return storage.Set(key, []byte(strconv.Itoa(total+n)), ttl)
+}
+
+// getCount returns the integer stored at key, or zero if unset
+func getCount(storage fiber.Storage, key string) (int, error) {
+	b, err := storage.Get(key)
+	if err != nil || len(b) == 0 {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(b))
+}
+
+// FlushAnalytics flushes the counts of validated requests not flushed yet,
+// eg. before shutting down
+func FlushAnalytics() error {
+	cfg := current()
+
+	return cfg.analytics.flusher(cfg)()
+}
+
+// GetAnalyticsCount returns the number of validated requests for the signed
+// URL identified by signature (as passed to BytesServed), with purpose, on
+// route, which have been flushed to Storage
+func GetAnalyticsCount(signature, purpose, route string) (int, error) {
+	cfg := current()
+
+	if cfg.Storage == nil {
+		return 0, nil
+	}
+
+	key := analyticsKey{signature: signature, purpose: purpose, route: route}
+	return getCount(cfg.getStorage(context.Background()), cfg.storageKey(StorageKindAnalytics, key.id()))
+}
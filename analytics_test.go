@@ -0,0 +1,112 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestAnalytics(t *testing.T) {
+	t.Run("it should hand validation counts to the flush hook", func(t *testing.T) {
+		// Initalize config
+		var flushed []AnalyticsCount
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Analytics:         true,
+			AnalyticsFlushed: func(counts []AnalyticsCount) {
+				flushed = append(flushed, counts...)
+			},
+		}))
+
+		app.Get("/files/:name", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		a, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/files/a?purpose=share", nil))
+		b, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/files/b", nil))
+
+		for _, signedURL := range []string{a, a, b, a + "x"} {
+			app.Test(newTestRequest(http.MethodGet, signedURL))
+		}
+		utils.AssertEqual(t, nil, FlushAnalytics())
+
+		sort.Slice(flushed, func(i, j int) bool { return flushed[i].Count > flushed[j].Count })
+		utils.AssertEqual(t, 2, len(flushed))
+		utils.AssertEqual(t, "share", flushed[0].Purpose)
+		utils.AssertEqual(t, "/files/:name", flushed[0].Route)
+		utils.AssertEqual(t, 2, flushed[0].Count)
+		utils.AssertEqual(t, 1, flushed[1].Count)
+	})
+
+	t.Run("it should add validation counts to totals in storage", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+			Analytics:         true,
+		}))
+
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		signature := httptest.NewRequest(http.MethodGet, signedURL, nil).URL.Query().Get("signature")
+
+		for i := 0; i < 2; i++ {
+			app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, nil, FlushAnalytics())
+		}
+
+		count, err := GetAnalyticsCount(signature, "", "/")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 2, count)
+	})
+
+	t.Run("it should add to totals atomically and keep them for AnalyticsTTL", func(t *testing.T) {
+		// Initalize config
+		storage := &scriptStorage{testStorage: newTestStorage()}
+		var ttl time.Duration
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			Analytics:         true,
+			AnalyticsTTL:      time.Hour,
+			StorageTTL: func(kind string, d time.Duration) time.Duration {
+				if kind == StorageKindAnalytics {
+					ttl = d
+				}
+				return d
+			},
+		}))
+
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		signature := httptest.NewRequest(http.MethodGet, signedURL, nil).URL.Query().Get("signature")
+
+		for i := 0; i < 2; i++ {
+			app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, nil, FlushAnalytics())
+		}
+
+		count, err := GetAnalyticsCount(signature, "", "/")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 2, count)
+		utils.AssertEqual(t, 2, storage.evals)
+		utils.AssertEqual(t, time.Hour, ttl)
+	})
+}
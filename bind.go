@@ -0,0 +1,57 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getBinding returns the value of the BindLocal local for the request, which
+// signatures are bound to. Requests without the local set only validate
+// unbound signatures.
+func (cfg *instance) getBinding(c *fiber.Ctx) (string, error) {
+	if cfg.BindLocal == "" {
+		return "", nil
+	}
+
+	// The bound value must come from the auth middleware, not the URL
+	if c.Query(cfg.BindLocal) != "" {
+		return "", fmt.Errorf("%s is a reserved query parameter for a signed URL route", cfg.BindLocal)
+	}
+
+	value := c.Locals(cfg.BindLocal)
+	if value == nil {
+		return "", nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// GetBoundSignedURLFromHTTPRequest takes an instance of *http.Request and
+// returns full URL with calculated signature, valid only for requests where
+// the BindLocal local (eg. the authenticated user's ID) has value
+func GetBoundSignedURLFromHTTPRequest(r *http.Request, value string) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if cfg.BindLocal == "" {
+		return "", errors.New("BindLocal must be configured to bind signed URLs")
+	}
+	if value == "" {
+		return "", errors.New("cannot bind signed URL to an empty value")
+	}
+
+	// Delegation grants would change the key used to validate the signature
+	if err := cfg.checkSigningParams(urlQuery(r.URL), cfg.DelegationQueryKey); err != nil {
+		return "", err
+	}
+
+	privateKey, err := cfg.getPrivateKey(r.Context())
+	if err != nil {
+		return "", err
+	}
+	events.observeKey(privateKey)
+
+	return cfg.signHTTPRequest(r, privateKey, value)
+}
@@ -0,0 +1,82 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestBindLocal(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	// Stand-in for auth middleware
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-User"); user != "" {
+			c.Locals("userID", user)
+		}
+		return c.Next()
+	})
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		BindLocal:         "userID",
+	}))
+
+	app.Get("/invoices/1", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	bound, err := GetBoundSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/invoices/1", nil), "alice")
+	utils.AssertEqual(t, nil, err)
+	unbound, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/invoices/1", nil))
+
+	tests := []struct {
+		name     string
+		target   string
+		user     string
+		status   int
+		expected string
+	}{
+		{"it should succeed for the user the url was issued to", bound, "alice", fiber.StatusOK, "Hello, world!"},
+		{"it should reject another user", bound, "mallory", fiber.StatusForbidden, "invalid signature"},
+		{"it should reject an anonymous request", bound, "", fiber.StatusForbidden, "invalid signature"},
+		{"it should reject a user supplied in the query", bound + "&userID=alice", "", fiber.StatusForbidden, "userID is a reserved query parameter for a signed URL route"},
+		{"it should succeed for an unbound url without a user", unbound, "", fiber.StatusOK, "Hello, world!"},
+		{"it should succeed for an unbound url with a user", unbound, "alice", fiber.StatusOK, "Hello, world!"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestRequest(http.MethodGet, tt.target)
+			if tt.user != "" {
+				req.Header.Set("X-User", tt.user)
+			}
+
+			resp, _ := app.Test(req)
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, tt.status, resp.StatusCode)
+			utils.AssertEqual(t, tt.expected, string(body))
+		})
+	}
+
+	t.Run("it should not sign a url binding the local in the query", func(t *testing.T) {
+		_, err := GetBoundSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/invoices/1?userID=alice", nil), "alice")
+
+		utils.AssertEqual(t, "userID is a reserved query parameter when generating signed routes", err.Error())
+	})
+
+	t.Run("it should require BindLocal to be configured", func(t *testing.T) {
+		New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+
+		_, err := GetBoundSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/invoices/1", nil), "alice")
+
+		utils.AssertEqual(t, "BindLocal must be configured to bind signed URLs", err.Error())
+	})
+}
@@ -0,0 +1,128 @@
+package signed
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// bloomFilter is a fixed size Bloom filter
+type bloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes int
+}
+
+// newBloomFilter sizes a filter to hold capacity items at the given false
+// positive rate
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	hashes := int(math.Ceil(float64(m) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, hashes: hashes}
+}
+
+// locations returns the bit positions for item using double hashing
+func (f *bloomFilter) locations(item string) []uint64 {
+	sum := sha256.Sum256([]byte(item))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16])
+
+	locations := make([]uint64, f.hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % f.m
+	}
+
+	return locations
+}
+
+// test reports whether item may have been added
+func (f *bloomFilter) test(item string) bool {
+	for _, l := range f.locations(item) {
+		if f.bits[l/64]&(1<<(l%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add records item
+func (f *bloomFilter) add(item string) {
+	for _, l := range f.locations(item) {
+		f.bits[l/64] |= 1 << (l % 64)
+	}
+}
+
+// BloomReplayCache is an in-memory ReplayCache made of two Bloom filters which
+// rotate every interval, so nonces are remembered for between one and two
+// intervals. It needs no external storage but is local to the process, and a
+// fresh nonce is wrongly reported as used at roughly the configured false
+// positive rate.
+type BloomReplayCache struct {
+	mu                sync.Mutex
+	capacity          int
+	falsePositiveRate float64
+	interval          time.Duration
+	rotated           time.Time
+	current           *bloomFilter
+	previous          *bloomFilter
+}
+
+// NewBloomReplayCache creates a BloomReplayCache holding up to capacity
+// nonces per interval at the given false positive rate. Signed URLs using it
+// should expire within interval, as the ttl passed to CheckAndAdd is not
+// honoured beyond that. capacity and interval must be positive, and
+// falsePositiveRate between 0 and 1 exclusive.
+func NewBloomReplayCache(capacity int, falsePositiveRate float64, interval time.Duration) (*BloomReplayCache, error) {
+	if capacity <= 0 {
+		return nil, errors.New("bloom replay cache capacity must be positive")
+	}
+	if !(falsePositiveRate > 0 && falsePositiveRate < 1) {
+		return nil, errors.New("bloom replay cache false positive rate must be between 0 and 1")
+	}
+	if interval <= 0 {
+		return nil, errors.New("bloom replay cache interval must be positive")
+	}
+
+	return &BloomReplayCache{
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+		interval:          interval,
+		rotated:           time.Now(),
+		current:           newBloomFilter(capacity, falsePositiveRate),
+		previous:          newBloomFilter(capacity, falsePositiveRate),
+	}, nil
+}
+
+// CheckAndAdd implements ReplayCache
+func (b *BloomReplayCache) CheckAndAdd(nonce string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Rotate filters, dropping everything older than two intervals
+	if elapsed := time.Since(b.rotated); elapsed >= b.interval {
+		b.previous = b.current
+		if elapsed >= 2*b.interval {
+			b.previous = newBloomFilter(b.capacity, b.falsePositiveRate)
+		}
+		b.current = newBloomFilter(b.capacity, b.falsePositiveRate)
+		b.rotated = time.Now()
+	}
+
+	if b.current.test(nonce) || b.previous.test(nonce) {
+		return true, nil
+	}
+	b.current.add(nonce)
+
+	return false, nil
+}
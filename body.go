@@ -0,0 +1,83 @@
+package signed
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BodyCanonicalizer returns the form of a request body which is hashed into
+// signatures, eg. with protocol-specific fields dropped or sorted. It must
+// return the same bytes for the body on the signing and verifying sides.
+type BodyCanonicalizer func(body []byte) ([]byte, error)
+
+// bodyCanonicalizerRegistry holds the BodyCanonicalizer of each content type
+type bodyCanonicalizerRegistry struct {
+	mu      sync.RWMutex
+	byMedia map[string]BodyCanonicalizer
+}
+
+var bodyCanonicalizers = &bodyCanonicalizerRegistry{byMedia: make(map[string]BodyCanonicalizer)}
+
+// RegisterBodyCanonicalizer sets fn as the canonicalizer of request bodies
+// with contentType (a media type without parameters, eg.
+// "application/json-rpc"), replacing any registered before. Registering nil
+// removes it. Registered canonicalizers take precedence over
+// CanonicalizeGraphQL.
+func RegisterBodyCanonicalizer(contentType string, fn BodyCanonicalizer) {
+	bodyCanonicalizers.mu.Lock()
+	defer bodyCanonicalizers.mu.Unlock()
+
+	contentType = strings.ToLower(contentType)
+	if fn == nil {
+		delete(bodyCanonicalizers.byMedia, contentType)
+		return
+	}
+	bodyCanonicalizers.byMedia[contentType] = fn
+}
+
+// lookup returns the BodyCanonicalizer registered for mediaType, if any
+func (r *bodyCanonicalizerRegistry) lookup(mediaType string) BodyCanonicalizer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.byMedia[mediaType]
+}
+
+// canonicalBody returns the form of a request body with contentType which is
+// hashed into signatures, normalizing bodies of protocols where equivalent
+// requests may be encoded differently. Bodies of GET and HEAD requests are
+// handled according to GetHeadBodyPolicy.
+func (cfg *instance) canonicalBody(method, contentType string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	if method == http.MethodGet || method == http.MethodHead {
+		switch cfg.GetHeadBodyPolicy {
+		case BodyIgnore:
+			return nil, nil
+		case BodyReject:
+			return nil, fmt.Errorf("%s requests must not have a body", method)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if fn := bodyCanonicalizers.lookup(mediaType); fn != nil {
+		return fn(body)
+	}
+
+	if cfg.CanonicalizeGraphQL {
+		switch mediaType {
+		case "application/json":
+			return canonicalGraphQLRequest(body)
+		case "application/graphql":
+			return canonicalGraphQLDocument(body)
+		}
+	}
+
+	return body, nil
+}
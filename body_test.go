@@ -0,0 +1,124 @@
+package signed
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestRegisterBodyCanonicalizer(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Post("/rpc", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	// Sign JSON-RPC calls without their request ID
+	RegisterBodyCanonicalizer("application/json-rpc", func(body []byte) ([]byte, error) {
+		var call map[string]interface{}
+		if err := json.Unmarshal(body, &call); err != nil {
+			return nil, err
+		}
+		delete(call, "id")
+		return json.Marshal(call)
+	})
+	defer RegisterBodyCanonicalizer("application/json-rpc", nil)
+
+	newRequest := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.RequestURI = ""
+		req.Header.Set(fiber.HeaderContentType, "application/json-rpc; charset=utf-8")
+		return req
+	}
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/rpc", `{"id":1,"method":"sum","params":[1,2]}`))
+
+	t.Run("it should hash bodies in their canonical form", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, `{"params":[1,2],"method":"sum","id":2}`))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject bodies with a different canonical form", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, `{"id":1,"method":"sum","params":[2,2]}`))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestGetHeadBodyPolicy(t *testing.T) {
+	newRequest := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RequestURI = ""
+		return req
+	}
+
+	newApp := func(policy BodyPolicy) *fiber.App {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			GetHeadBodyPolicy: policy,
+		}))
+
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		return app
+	}
+
+	t.Run("it should hash get bodies by default", func(t *testing.T) {
+		app := newApp(BodyHash)
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest(http.MethodGet, "http://example.com/", "body"))
+
+		resp, _ := app.Test(newRequest(http.MethodGet, signedURL, "body"))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newRequest(http.MethodGet, signedURL, ""))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should ignore get bodies", func(t *testing.T) {
+		app := newApp(BodyIgnore)
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest(http.MethodGet, "http://example.com/", "body"))
+
+		resp, _ := app.Test(newRequest(http.MethodGet, signedURL, ""))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newRequest(http.MethodGet, signedURL, "other"))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject get bodies when signing and validating", func(t *testing.T) {
+		app := newApp(BodyReject)
+		_, err := GetSignedURLFromHTTPRequest(newRequest(http.MethodGet, "http://example.com/", "body"))
+		utils.AssertEqual(t, "GET requests must not have a body", err.Error())
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest(http.MethodGet, "http://example.com/", ""))
+		resp, _ := app.Test(newRequest(http.MethodGet, signedURL, "body"))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should still hash bodies of other methods", func(t *testing.T) {
+		app := newApp(BodyIgnore)
+		app.Post("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest(http.MethodPost, "http://example.com/", "body"))
+
+		resp, _ := app.Test(newRequest(http.MethodPost, signedURL, "other"))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,172 @@
+package signed
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrTimeout is returned in place of the result of a call to the key provider
+// or Storage which took longer than KeyTimeout or StorageTimeout
+var ErrTimeout = errors.New("call to external dependency timed out")
+
+// ErrCircuitOpen is returned in place of calling the key provider or Storage
+// while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker fails calls to an external dependency fast once
+// BreakerThreshold calls in a row have failed, until BreakerCooldown has
+// passed. A single call is then let through, closing the breaker again if it
+// succeeds.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// reset closes the breaker
+func (b *circuitBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures, b.openUntil = 0, time.Time{}
+}
+
+// allow returns ErrCircuitOpen if calls should fail fast, holding further
+// calls back while a trial call is made once the cooldown has passed
+func (b *circuitBreaker) allow(threshold int, cooldown time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if threshold <= 0 || b.failures < threshold {
+		return nil
+	}
+	if timeNow().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.openUntil = timeNow().Add(cooldown)
+
+	return nil
+}
+
+// record counts the result of a call, opening the breaker after threshold
+// failures in a row
+func (b *circuitBreaker) record(err error, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if threshold > 0 && b.failures >= threshold {
+		b.openUntil = timeNow().Add(cooldown)
+	}
+}
+
+// call runs fn unless the breaker is open, giving up on it after timeout if
+// not zero. fn must not touch state the caller reads once it has timed out.
+func (b *circuitBreaker) call(cfg *instance, timeout time.Duration, fn func() error) error {
+	threshold, cooldown := cfg.BreakerThreshold, cfg.BreakerCooldown
+	if err := b.allow(threshold, cooldown); err != nil {
+		return err
+	}
+
+	err := callWithTimeout(timeout, fn)
+	b.record(err, threshold, cooldown)
+
+	return err
+}
+
+// callWithTimeout runs fn, returning ErrTimeout if it takes longer than
+// timeout. fn is left to finish in the background.
+func callWithTimeout(timeout time.Duration, fn func() error) error {
+	if timeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrTimeout
+	}
+}
+
+// guardStorage wraps storage to guard its operations with StorageTimeout and
+// the circuit breaker, when either is configured
+func (cfg *instance) guardStorage(storage fiber.Storage) fiber.Storage {
+	if storage == nil || (cfg.StorageTimeout <= 0 && cfg.BreakerThreshold <= 0) {
+		return storage
+	}
+
+	s := &guardedStorage{Storage: storage, cfg: cfg, timeout: cfg.StorageTimeout}
+	if runner, ok := storage.(ScriptRunner); ok {
+		return &guardedScriptStorage{guardedStorage: s, runner: runner}
+	}
+
+	return s
+}
+
+// guardedStorage wraps a Storage, guarding its operations
+type guardedStorage struct {
+	fiber.Storage
+	cfg     *instance
+	timeout time.Duration
+}
+
+func (s *guardedStorage) Get(key string) ([]byte, error) {
+	var val []byte
+	err := s.cfg.storageBreaker.call(s.cfg, s.timeout, func() error {
+		var err error
+		val, err = s.Storage.Get(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (s *guardedStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.cfg.storageBreaker.call(s.cfg, s.timeout, func() error {
+		return s.Storage.Set(key, val, exp)
+	})
+}
+
+func (s *guardedStorage) Delete(key string) error {
+	return s.cfg.storageBreaker.call(s.cfg, s.timeout, func() error {
+		return s.Storage.Delete(key)
+	})
+}
+
+// guardedScriptStorage wraps a Storage implementing ScriptRunner
+type guardedScriptStorage struct {
+	*guardedStorage
+	runner ScriptRunner
+}
+
+func (s *guardedScriptStorage) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	var result interface{}
+	err := s.cfg.storageBreaker.call(s.cfg, s.timeout, func() error {
+		var err error
+		result, err = s.runner.Eval(script, keys, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestTimeouts(t *testing.T) {
+	// Initalize config
+	var delay int64
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyContextFunc: func(ctx context.Context) (string, error) {
+			time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+			return "secret", nil
+		},
+		KeyTimeout:     50 * time.Millisecond,
+		StorageTimeout: 50 * time.Millisecond,
+		Storage:        newTestStorage(),
+		Revocations:    true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	t.Run("it should give up on slow storage", func(t *testing.T) {
+		restore := InjectFaults(Faults{StorageDelay: 200 * time.Millisecond})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, ErrTimeout.Error(), string(body))
+	})
+
+	t.Run("it should give up on slow key providers", func(t *testing.T) {
+		atomic.StoreInt64(&delay, int64(200*time.Millisecond))
+		defer atomic.StoreInt64(&delay, 0)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		Revocations:       true,
+		BreakerThreshold:  2,
+		BreakerCooldown:   time.Minute,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	request := func() string {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("it should open after consecutive failures", func(t *testing.T) {
+		restore := InjectFaults(Faults{StorageErr: errors.New("connection refused")})
+		utils.AssertEqual(t, "connection refused", request())
+		utils.AssertEqual(t, "connection refused", request())
+		restore()
+
+		utils.AssertEqual(t, ErrCircuitOpen.Error(), request())
+	})
+
+	t.Run("it should close once a trial call succeeds after the cooldown", func(t *testing.T) {
+		restore := InjectFaults(Faults{ClockSkew: 2 * time.Minute})
+		defer restore()
+
+		utils.AssertEqual(t, "Hello, world!", request())
+		utils.AssertEqual(t, "Hello, world!", request())
+	})
+}
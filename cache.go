@@ -0,0 +1,107 @@
+package signed
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cache"
+)
+
+// CacheKey returns a key for Fiber's cache middleware (its KeyGenerator)
+// identifying the resource requested by the path and query of the request,
+// without the params of the middleware, which only grant access. Requests for
+// one resource with different signatures then share a cache entry. Register
+// the cache middleware after this one, so requests are verified before a
+// cached response is served.
+func CacheKey(c *fiber.Ctx) string {
+	cfg := instanceOf(c)
+
+	q, _ := parseQuery(string(c.Request().URI().QueryString()))
+	for _, key := range cfg.accessParamKeys() {
+		q.Del(key)
+	}
+
+	if len(q) == 0 {
+		return c.Path()
+	}
+
+	return c.Path() + "?" + q.Encode()
+}
+
+// Immutable returns a handler for routes serving immutable assets through
+// signed URLs. It caches responses for maxAge under CacheKey, the canonical
+// unsigned URL, so an asset requested with different signatures is rendered
+// once, and marks them cacheable by clients for maxAge. Register it on the
+// asset routes, after the middleware.
+func Immutable(maxAge time.Duration) fiber.Handler {
+	store := cache.New(cache.Config{
+		Expiration:   maxAge,
+		KeyGenerator: CacheKey,
+	})
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds())) + ", immutable"
+
+	return func(c *fiber.Ctx) error {
+		if err := store(c); err != nil {
+			return err
+		}
+
+		// The cache restores bodies only, so set headers on every response
+		if c.Response().StatusCode() == fiber.StatusOK {
+			c.Set(fiber.HeaderCacheControl, cacheControl)
+		}
+
+		return nil
+	}
+}
+
+// accessParamKeys returns the query keys of the params the middleware reads,
+// which only grant access to the resource rather than select it
+func (cfg *instance) accessParamKeys() []string {
+	return []string{
+		cfg.SignatureQueryKey,
+		cfg.PrivateKeyQueryKey,
+		cfg.ExpiresQueryKey,
+		cfg.BodyHashQueryKey,
+		cfg.PolicyQueryKey,
+		cfg.TemplateQueryKey,
+		cfg.CaveatQueryKey,
+		cfg.DelegationQueryKey,
+		cfg.TokenQueryKey,
+		cfg.RateLimitQueryKey,
+		cfg.ConcurrencyQueryKey,
+		cfg.NonceQueryKey,
+		cfg.MaxUsesQueryKey,
+		cfg.SourceIPRangeQueryKey,
+		cfg.CountriesQueryKey,
+		cfg.OriginQueryKey,
+		cfg.ClientCertQueryKey,
+		cfg.IssuedQueryKey,
+		cfg.AlgorithmQueryKey,
+		cfg.SoftExpiresQueryKey,
+		cfg.ExpiresInQueryKey,
+		cfg.ScheduleQueryKey,
+		cfg.ETagQueryKey,
+		cfg.ProbeQueryKey,
+		cfg.NginxMD5QueryKey,
+	}
+}
+
+// getPrivateCacheControl returns the Cache-Control value of a verified
+// response, marked private so shared caches don't serve it to other requests
+func getPrivateCacheControl(value string) string {
+	directives := []string{"private"}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		switch strings.ToLower(directive) {
+		case "no-store", "private":
+			return value
+		case "", "public":
+		default:
+			directives = append(directives, directive)
+		}
+	}
+
+	return strings.Join(directives, ", ")
+}
@@ -0,0 +1,144 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cache"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestCacheCoexistence(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:   func() string { return "secret" },
+		PrivateCacheControl: true,
+	}))
+	app.Use(cache.New(cache.Config{
+		KeyGenerator: CacheKey,
+	}))
+
+	renders := 0
+	app.Get("/report", func(c *fiber.Ctx) error {
+		renders++
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+		return c.SendString("report " + c.Query("v"))
+	})
+
+	sign := func(target string, expires time.Duration) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, target+"&expires="+strconv.FormatInt(time.Now().Add(expires).Unix(), 10)))
+		return signedURL
+	}
+
+	t.Run("it should share cache entries between signatures of one resource", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/report?v=1", time.Hour)))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, sign("http://example.com/report?v=1", 2*time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "report 1", string(body))
+		utils.AssertEqual(t, 1, renders)
+	})
+
+	t.Run("it should keep params selecting the resource in the key", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/report?v=2", time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "report 2", string(body))
+		utils.AssertEqual(t, 2, renders)
+	})
+
+	t.Run("it should verify requests before serving cached responses", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/report?v=1"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should mark verified responses private", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/report?v=3", time.Hour)))
+
+		utils.AssertEqual(t, "private, max-age=60", resp.Header.Get(fiber.HeaderCacheControl))
+	})
+}
+
+func TestImmutable(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	renders := 0
+	app.Get("/assets/:name", Immutable(24*time.Hour), func(c *fiber.Ctx) error {
+		renders++
+		return c.SendString("asset " + c.Params("name"))
+	})
+	app.Get("/missing", Immutable(24*time.Hour), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNotFound)
+	})
+
+	sign := func(target string, expires time.Duration) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, target+"?expires="+strconv.FormatInt(time.Now().Add(expires).Unix(), 10)))
+		return signedURL
+	}
+
+	t.Run("it should set long-lived cache headers", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/assets/logo.png", time.Hour)))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "public, max-age=86400, immutable", resp.Header.Get(fiber.HeaderCacheControl))
+		utils.AssertEqual(t, 1, renders)
+	})
+
+	t.Run("it should serve other signatures from the same cache entry", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/assets/logo.png", 2*time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "asset logo.png", string(body))
+		utils.AssertEqual(t, "public, max-age=86400, immutable", resp.Header.Get(fiber.HeaderCacheControl))
+		utils.AssertEqual(t, 1, renders)
+	})
+
+	t.Run("it should cache other assets separately", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/assets/icon.png", time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "asset icon.png", string(body))
+		utils.AssertEqual(t, 2, renders)
+	})
+
+	t.Run("it should not serve cached assets to unsigned requests", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/assets/logo.png"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should not mark errors cacheable", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/missing", time.Hour)))
+
+		utils.AssertEqual(t, fiber.StatusNotFound, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderCacheControl))
+	})
+}
+
+func TestPrivateCacheControl(t *testing.T) {
+	t.Run("it should keep other directives", func(t *testing.T) {
+		utils.AssertEqual(t, "private", getPrivateCacheControl(""))
+		utils.AssertEqual(t, "private, max-age=60", getPrivateCacheControl("public, max-age=60"))
+		utils.AssertEqual(t, "private, no-cache", getPrivateCacheControl("no-cache"))
+	})
+
+	t.Run("it should leave private and no-store responses alone", func(t *testing.T) {
+		utils.AssertEqual(t, "private, max-age=10", getPrivateCacheControl("private, max-age=10"))
+		utils.AssertEqual(t, "no-store", getPrivateCacheControl("no-store"))
+	})
+}
@@ -0,0 +1,146 @@
+package signed
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Caveat prefixes recognized by the middleware
+const (
+	caveatExpires = "expires"
+	caveatPath    = "path"
+)
+
+// ExpiresCaveat returns a caveat restricting a signed URL to requests made
+// before t
+func ExpiresCaveat(t time.Time) string {
+	return fmt.Sprintf("%s:%d", caveatExpires, t.Unix())
+}
+
+// PathCaveat returns a caveat restricting a signed URL to request paths
+// matching pattern, where "*" matches any sequence of characters
+func PathCaveat(pattern string) string {
+	return fmt.Sprintf("%s:%s", caveatPath, pattern)
+}
+
+// AddCaveat appends a restricting caveat to an already signed URL. The
+// signature is replaced with an HMAC of the caveat keyed by the previous
+// signature, so caveats can be added by any holder of the URL without the
+// private key but can never be removed.
+func AddCaveat(signedURL string, caveat string) (string, error) {
+	cfg := current()
+
+	if _, _, err := parseCaveat(caveat); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return "", errors.New("cannot parse provided URL")
+	}
+
+	q := urlQuery(u)
+	signature := q.Get(cfg.SignatureQueryKey)
+	if signature == "" {
+		return "", fmt.Errorf("%s is a required query param for a signed URL route", cfg.SignatureQueryKey)
+	}
+
+	// Chain caveat onto the existing signature
+	q.Add(cfg.CaveatQueryKey, caveat)
+	q.Set(cfg.SignatureQueryKey, cfg.chainCaveats(signature, []string{caveat}))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// chainCaveats folds each caveat into signature in order using HMAC
+func (cfg *instance) chainCaveats(signature string, caveats []string) string {
+	return cfg.chainCaveatsFor(cfg.Algorithm, signature, caveats)
+}
+
+// chainCaveatsFor is chainCaveats with alg rather than the algorithm set in
+// the config
+func (cfg *instance) chainCaveatsFor(alg Algorithm, signature string, caveats []string) string {
+	for _, caveat := range caveats {
+		mac := hmac.New(getHashFuncFor(alg), []byte(signature))
+		mac.Write([]byte(caveat))
+		signature = cfg.encodeSignature(mac.Sum(nil))
+	}
+
+	return signature
+}
+
+// signatureChainLocal is the fiber.Ctx local holding the signatures of a
+// verified request, from its root signature to the one it carries after each
+// caveat
+const signatureChainLocal = "fiber-signed:signature-chain"
+
+// getSignatureChain returns root followed by the signature after each caveat
+// is folded in, ending with the signature of the URL carrying all of them
+func (cfg *instance) getSignatureChain(alg Algorithm, root string, caveats []string) []string {
+	chain := []string{root}
+	for _, caveat := range caveats {
+		chain = append(chain, cfg.chainCaveatsFor(alg, chain[len(chain)-1], []string{caveat}))
+	}
+
+	return chain
+}
+
+// getVerifiedSignatures returns the signatures of the request, as recorded
+// when its signature was verified, or just the one it carries otherwise
+func (cfg *instance) getVerifiedSignatures(c *fiber.Ctx) []string {
+	if chain, ok := c.Locals(signatureChainLocal).([]string); ok && len(chain) > 0 {
+		return chain
+	}
+
+	return []string{c.Query(cfg.SignatureQueryKey)}
+}
+
+// parseCaveat splits a caveat into its name and value
+func parseCaveat(caveat string) (string, string, error) {
+	split := strings.SplitN(caveat, ":", 2)
+	if len(split) != 2 {
+		return "", "", fmt.Errorf("unrecognized caveat %q", caveat)
+	}
+
+	switch split[0] {
+	case caveatExpires:
+		if _, err := strconv.ParseInt(split[1], 10, 64); err != nil {
+			return "", "", fmt.Errorf("unrecognized caveat %q", caveat)
+		}
+	case caveatPath:
+	default:
+		return "", "", fmt.Errorf("unrecognized caveat %q", caveat)
+	}
+
+	return split[0], split[1], nil
+}
+
+// validateCaveat enforces a single caveat against the inbound request
+func validateCaveat(c *fiber.Ctx, caveat string) error {
+	name, value, err := parseCaveat(caveat)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case caveatExpires:
+		i, _ := strconv.ParseInt(value, 10, 64)
+		if !timeNow().Before(time.Unix(i, 0)) {
+			return errors.New("url signature has expired")
+		}
+	case caveatPath:
+		if !matchWildcard(value, c.Path()) {
+			return errors.New("url caveat does not permit this path")
+		}
+	}
+
+	return nil
+}
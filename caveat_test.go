@@ -0,0 +1,86 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestCaveats(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/files/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/files/a.txt", nil)
+	signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+	t.Run("it should succeed with caveats that are satisfied", func(t *testing.T) {
+		attenuated, err := AddCaveat(signedURL, ExpiresCaveat(time.Now().Add(time.Hour)))
+		utils.AssertEqual(t, nil, err)
+		attenuated, err = AddCaveat(attenuated, PathCaveat("/files/*"))
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, attenuated))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	t.Run("it should reject a request after an expires caveat has passed", func(t *testing.T) {
+		attenuated, _ := AddCaveat(signedURL, ExpiresCaveat(time.Now().Add(-time.Hour)))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, attenuated))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should reject a request outside a path caveat", func(t *testing.T) {
+		attenuated, _ := AddCaveat(signedURL, PathCaveat("/files/b.txt"))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, attenuated))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url caveat does not permit this path", string(body))
+	})
+
+	t.Run("it should reject a request with a caveat removed", func(t *testing.T) {
+		attenuated, _ := AddCaveat(signedURL, PathCaveat("/files/b.txt"))
+
+		u, _ := http.NewRequest(http.MethodGet, attenuated, nil)
+		q := u.URL.Query()
+		q.Del("caveat")
+		u.URL.RawQuery = q.Encode()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, u.URL.String()))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "invalid signature", string(body))
+	})
+
+	t.Run("it should not add an unrecognized caveat", func(t *testing.T) {
+		_, err := AddCaveat(signedURL, "user:1")
+		utils.AssertEqual(t, `unrecognized caveat "user:1"`, err.Error())
+	})
+
+	t.Run("it should not add a caveat to an unsigned URL", func(t *testing.T) {
+		_, err := AddCaveat("http://example.com/files/a.txt", PathCaveat("/files/*"))
+		utils.AssertEqual(t, "signature is a required query param for a signed URL route", err.Error())
+	})
+}
@@ -0,0 +1,80 @@
+package signed
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Claims defines the set of claims carried by a signed URL
+type Claims map[string]interface{}
+
+// Registered claim names used by the middleware
+const (
+	ClaimExpires = "exp"
+	ClaimPurpose = "purpose"
+	ClaimURLHash = "urlHash"
+)
+
+// getQueryClaims returns the signed query params of a request as claims.
+// Params with a single value map to a string, repeated params to a []string.
+func (cfg *instance) getQueryClaims(c *fiber.Ctx) Claims {
+	claims := make(Claims)
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		k := string(key)
+		if k == cfg.SignatureQueryKey || k == cfg.CaveatQueryKey || k == cfg.TokenQueryKey {
+			return
+		}
+
+		switch v := claims[k].(type) {
+		case nil:
+			claims[k] = string(value)
+		case string:
+			claims[k] = []string{v, string(value)}
+		case []string:
+			claims[k] = append(v, string(value))
+		}
+	})
+
+	return claims
+}
+
+// validateClaims runs the configured claim validators in order
+func (cfg *instance) validateClaims(c *fiber.Ctx, claims Claims) error {
+	for _, validator := range cfg.ClaimValidators {
+		if err := validator(c, claims); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getClaim returns the claim name signed into the URL of the request, from
+// its query params or the claims of its verified token
+func (cfg *instance) getClaim(c *fiber.Ctx, name string) string {
+	if c.Query(cfg.TokenQueryKey) != "" {
+		claim := getTokenClaims(c)[name]
+		if claim == nil {
+			return ""
+		}
+		return fmt.Sprint(claim)
+	}
+
+	return c.Query(cfg.getClaimQueryKey(name))
+}
+
+// getClaimQueryKey returns the query param carrying the claim name in signed
+// URLs, as configured for the claims the middleware reads
+func (cfg *instance) getClaimQueryKey(name string) string {
+	switch name {
+	case ClaimPurpose:
+		return cfg.PurposeQueryKey
+	case ClaimUser:
+		return cfg.UserQueryKey
+	case ClaimRequestID:
+		return cfg.RequestIDQueryKey
+	}
+
+	return name
+}
@@ -0,0 +1,92 @@
+package signed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestClaimValidators(t *testing.T) {
+	// Initalize config
+	var seen Claims
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ClaimValidators: []func(c *fiber.Ctx, claims Claims) error{
+			func(c *fiber.Ctx, claims Claims) error {
+				seen = claims
+				return nil
+			},
+			func(c *fiber.Ctx, claims Claims) error {
+				if claims["user"] == "banned" {
+					return errors.New("user is no longer active")
+				}
+				return nil
+			},
+			func(c *fiber.Ctx, claims Claims) error {
+				if claims["file"] == "deleted" {
+					return fiber.NewError(fiber.StatusGone, "file no longer exists")
+				}
+				return nil
+			},
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		return signedURL
+	}
+
+	t.Run("it should pass signed query params to validators as claims", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?user=1&tag=a&tag=b")))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, Claims{"user": "1", "tag": []string{"a", "b"}}, seen)
+	})
+
+	t.Run("it should reject a request when a validator fails", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?user=banned")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "user is no longer active", string(body))
+	})
+
+	t.Run("it should use the status code of a validator's fiber.Error", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?file=deleted")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusGone, resp.StatusCode)
+		utils.AssertEqual(t, "file no longer exists", string(body))
+	})
+
+	t.Run("it should not run validators before the signature is verified", func(t *testing.T) {
+		seen = nil
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/?user=1&signature=wrong"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, Claims(nil), seen)
+	})
+
+	t.Run("it should pass token claims to validators in token mode", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, _ := GetSignedTokenURLFromHTTPRequest(req, Claims{"user": "banned"})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "user is no longer active", string(body))
+	})
+}
@@ -0,0 +1,40 @@
+package signed
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientCertificateFingerprint returns the SHA-256 fingerprint of cert as hex,
+// for binding signed URLs to a client identity in mTLS deployments
+func ClientCertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkClientCertificate rejects requests whose TLS client certificate does
+// not match the fingerprint signed into the URL
+func (cfg *instance) checkClientCertificate(c *fiber.Ctx) error {
+	expected := strings.ToLower(c.Query(cfg.ClientCertQueryKey))
+	if expected == "" {
+		return nil
+	}
+
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errors.New("url signature requires a client certificate")
+	}
+
+	fingerprint := ClientCertificateFingerprint(state.PeerCertificates[0])
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(expected)) != 1 {
+		return errors.New("url signature does not permit this client certificate")
+	}
+
+	return nil
+}
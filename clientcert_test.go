@@ -0,0 +1,101 @@
+package signed
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// tlsTestConn reports a fixed TLS connection state, which is all fasthttp
+// needs to expose client certificates to handlers
+type tlsTestConn struct {
+	net.Conn
+	state tls.ConnectionState
+}
+
+func (c *tlsTestConn) Handshake() error                     { return nil }
+func (c *tlsTestConn) ConnectionState() tls.ConnectionState { return c.state }
+
+func newTestCertificate(t *testing.T, name string) *x509.Certificate {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	utils.AssertEqual(t, nil, err)
+	cert, err := x509.ParseCertificate(der)
+	utils.AssertEqual(t, nil, err)
+
+	return cert
+}
+
+func TestClientCertificateBinding(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/reports/42", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	alice := newTestCertificate(t, "alice")
+	mallory := newTestCertificate(t, "mallory")
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/reports/42?clientCert="+ClientCertificateFingerprint(alice), nil)
+	signedURL, _ := GetSignedURLFromHTTPRequest(req)
+	signed, _ := url.Parse(signedURL)
+
+	serve := func(certs ...*x509.Certificate) *fasthttp.RequestCtx {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init2(&tlsTestConn{Conn: server, state: tls.ConnectionState{PeerCertificates: certs}}, nil, false)
+		ctx.Request.SetRequestURI(signed.RequestURI())
+		ctx.Request.SetHost(signed.Host)
+
+		app.Handler()(ctx)
+		return ctx
+	}
+
+	t.Run("it should succeed with the bound client certificate", func(t *testing.T) {
+		ctx := serve(alice)
+
+		utils.AssertEqual(t, fiber.StatusOK, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "Hello, world!", string(ctx.Response.Body()))
+	})
+
+	t.Run("it should reject a different client certificate", func(t *testing.T) {
+		ctx := serve(mallory)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "url signature does not permit this client certificate", string(ctx.Response.Body()))
+	})
+
+	t.Run("it should reject a request without a client certificate", func(t *testing.T) {
+		ctx := serve()
+
+		utils.AssertEqual(t, fiber.StatusForbidden, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "url signature requires a client certificate", string(ctx.Response.Body()))
+	})
+}
@@ -0,0 +1,32 @@
+package signed
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errExpired rejects requests for URLs past their expiry
+var errExpired = errors.New("url signature has expired")
+
+// clockSkewLocal holds how long ago the URL of a request rejected as probably
+// due to clock skew expired, for its event
+const clockSkewLocal = "fiber-signed:clock-skew"
+
+// getClockSkew returns how long ago expires passed, reporting whether it is
+// within ClockSkewThreshold, so the rejection is probably due to clock skew
+// between signers and validators rather than a genuinely expired URL
+func (cfg *instance) getClockSkew(expires time.Time) (time.Duration, bool) {
+	skew := timeNow().Sub(expires)
+	return skew, cfg.ClockSkewThreshold > 0 && skew <= cfg.ClockSkewThreshold
+}
+
+// reportClockSkew reports a request whose otherwise valid URL expired skew ago
+// to ClockSkewDetected and its event
+func (cfg *instance) reportClockSkew(c *fiber.Ctx, skew time.Duration) {
+	c.Locals(clockSkewLocal, skew)
+	if cfg.ClockSkewDetected != nil {
+		cfg.ClockSkewDetected(c, skew)
+	}
+}
@@ -0,0 +1,89 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestClockSkew(t *testing.T) {
+	var detected []time.Duration
+
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		ClockSkewThreshold: 5 * time.Second,
+		ClockSkewDetected: func(c *fiber.Ctx, skew time.Duration) {
+			detected = append(detected, skew)
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	var rejected Event
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Type == EventRejected {
+			rejected = e
+		}
+	})
+	defer unsubscribe()
+
+	expiring := func(d time.Duration) string {
+		target := fmt.Sprintf("http://example.com/?expires=%d", time.Now().Add(d).Unix())
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	t.Run("it should report urls expired within the threshold", func(t *testing.T) {
+		detected, rejected = nil, Event{}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, expiring(-2*time.Second)))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+		utils.AssertEqual(t, 1, len(detected))
+		utils.AssertEqual(t, true, detected[0] >= 2*time.Second && detected[0] <= 5*time.Second)
+		utils.AssertEqual(t, detected[0], rejected.ClockSkew)
+	})
+
+	t.Run("it should not report genuinely expired urls", func(t *testing.T) {
+		detected, rejected = nil, Event{}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, expiring(-time.Hour)))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, 0, len(detected))
+		utils.AssertEqual(t, time.Duration(0), rejected.ClockSkew)
+	})
+
+	t.Run("it should not report urls with invalid signatures", func(t *testing.T) {
+		detected = nil
+		tampered := strings.Replace(expiring(-2*time.Second), "signature=", "signature=x", 1)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, tampered))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+		utils.AssertEqual(t, 0, len(detected))
+	})
+
+	t.Run("it should report tokens expired within the threshold", func(t *testing.T) {
+		detected = nil
+		signedURL, _ := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), Claims{
+			ClaimExpires: time.Now().Add(-2 * time.Second).Unix(),
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, 1, len(detected))
+	})
+}
@@ -0,0 +1,113 @@
+// Command fiber-signed provides helpers for operating the fiber-signed
+// middleware.
+//
+// Usage:
+//
+//	fiber-signed keygen [-type secret|ed25519] [-bytes n]
+//	fiber-signed probe-token -path pattern [-ttl duration]
+//
+// probe-token reads the private key from the FIBER_SIGNED_PRIVATE_KEY
+// environment variable.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	signed "github.com/bsandusky/fiber-signed"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "probe-token":
+		err = probeToken(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fiber-signed keygen [-type secret|ed25519] [-bytes n]")
+	fmt.Fprintln(os.Stderr, "       fiber-signed probe-token -path pattern [-ttl duration]")
+	os.Exit(2)
+}
+
+// keygen prints a new secret or Ed25519 key pair to stdout
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	keyType := fs.String("type", "secret", "type of key to generate: secret or ed25519")
+	bytes := fs.Int("bytes", 32, "size of generated secrets in bytes")
+	fs.Parse(args)
+
+	switch *keyType {
+	case "secret":
+		secret, err := signed.GenerateSecret(*bytes)
+		if err != nil {
+			return err
+		}
+		fmt.Println(secret)
+	case "ed25519":
+		publicKey, privateKey, err := signed.GenerateEd25519KeyPair()
+		if err != nil {
+			return err
+		}
+		privatePEM, err := signed.EncodePrivateKeyPEM(privateKey)
+		if err != nil {
+			return err
+		}
+		publicPEM, err := signed.EncodePublicKeyPEM(publicKey)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(privatePEM), string(publicPEM))
+	default:
+		return fmt.Errorf("unknown key type %q", *keyType)
+	}
+
+	return nil
+}
+
+// probeToken prints a probe token minted with the private key in
+// FIBER_SIGNED_PRIVATE_KEY to stdout, for pasting into monitoring config
+func probeToken(args []string) error {
+	fs := flag.NewFlagSet("probe-token", flag.ExitOnError)
+	path := fs.String("path", "", "the path (or * pattern) the token may probe")
+	ttl := fs.Duration("ttl", time.Hour, "how long the token is valid for")
+	fs.Parse(args)
+
+	if *path == "" {
+		return errors.New("-path must be set")
+	}
+
+	privateKey := os.Getenv("FIBER_SIGNED_PRIVATE_KEY")
+	if privateKey == "" {
+		return errors.New("FIBER_SIGNED_PRIVATE_KEY must be set")
+	}
+
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return privateKey },
+	})
+
+	token, err := signed.MintProbeToken(*path, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package signed
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignComponents takes an instance of *http.Request and returns the values of
+// its signature, expires and nonce params separately, for callers that place
+// them themselves, eg. as hidden form fields, rather than appending them to a
+// URL. expiresAt is signed into the request if not zero, and it carries a nonce
+// from NonceFunc if singleUse is set; expires or nonce is empty otherwise. The
+// values must reach the middleware in the SignatureQueryKey, ExpiresQueryKey
+// and NonceQueryKey params, alongside any other params of r.
+func SignComponents(r *http.Request, expiresAt time.Time, singleUse bool) (sig, expires, nonce string, err error) {
+	cfg := instanceFor(r.Context())
+
+	if !expiresAt.IsZero() {
+		q := urlQuery(r.URL)
+		if err := cfg.checkSigningParams(q, cfg.ExpiresQueryKey); err != nil {
+			return "", "", "", err
+		}
+		q.Set(cfg.ExpiresQueryKey, strconv.FormatInt(expiresAt.Unix(), 10))
+		r.URL.RawQuery = q.Encode()
+	}
+
+	var signedURL string
+	if singleUse {
+		signedURL, err = GetSingleUseSignedURLFromHTTPRequest(r)
+	} else {
+		// Components aren't handed out as a URL, so MaxURLLength doesn't apply
+		signedURL, err = cfg.signURL(r)
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return "", "", "", err
+	}
+	q := urlQuery(u)
+
+	return q.Get(cfg.SignatureQueryKey), q.Get(cfg.ExpiresQueryKey), q.Get(cfg.NonceQueryKey), nil
+}
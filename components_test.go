@@ -0,0 +1,70 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSignComponents(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+	}))
+
+	app.Get("/submit", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("it should return values which validate once placed in the query", func(t *testing.T) {
+		sig, expires, nonce, err := SignComponents(httptest.NewRequest(http.MethodGet, "http://example.com/submit?form=contact", nil), expiresAt, false)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, fmt.Sprint(expiresAt.Unix()), expires)
+		utils.AssertEqual(t, "", nonce)
+
+		q := url.Values{"form": {"contact"}, "expires": {expires}, "signature": {sig}}
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/submit?"+q.Encode()))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should return a nonce for single-use values", func(t *testing.T) {
+		sig, expires, nonce, err := SignComponents(httptest.NewRequest(http.MethodGet, "http://example.com/submit", nil), expiresAt, true)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, nonce != "")
+
+		q := url.Values{"expires": {expires}, "nonce": {nonce}, "signature": {sig}}
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/submit?"+q.Encode()))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, "http://example.com/submit?"+q.Encode()))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has already been used", string(body))
+	})
+
+	t.Run("it should leave out expires without an expiry", func(t *testing.T) {
+		sig, expires, _, err := SignComponents(httptest.NewRequest(http.MethodGet, "http://example.com/submit", nil), time.Time{}, false)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "", expires)
+		utils.AssertEqual(t, true, sig != "")
+	})
+
+	t.Run("it should reject requests which already carry an expiry", func(t *testing.T) {
+		_, _, _, err := SignComponents(httptest.NewRequest(http.MethodGet, "http://example.com/submit?expires=1", nil), expiresAt, false)
+
+		utils.AssertEqual(t, true, err != nil)
+	})
+}
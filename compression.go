@@ -0,0 +1,90 @@
+package signed
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+)
+
+// decodeBody returns body with the content codings of encoding, a
+// Content-Encoding header value, undone in reverse order. Bodies are hashed
+// as decoded content on both sides, so a middleware decompressing request
+// bodies (and dropping their Content-Encoding) may run before or after this
+// one.
+func (cfg *instance) decodeBody(encoding string, body []byte) ([]byte, error) {
+	body, err := canon.DecodeBody(encoding, body, cfg.MaxDecodedBodyBytes)
+	if err == canon.ErrDecodedBodyTooLarge {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	}
+
+	return body, err
+}
+
+// linkSigners holds the code pointers of the handlers NewLinkSigner returns,
+// guarded by handlersMu
+var linkSigners = map[uintptr]bool{}
+
+// registerLinkSigner records h as a handler of NewLinkSigner for CheckOrder
+func registerLinkSigner(h fiber.Handler) fiber.Handler {
+	handlersMu.Lock()
+	linkSigners[reflect.ValueOf(h).Pointer()] = true
+	handlersMu.Unlock()
+
+	return h
+}
+
+// isLinkSigner reports whether h is a handler of NewLinkSigner
+func isLinkSigner(h fiber.Handler) bool {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	return linkSigners[reflect.ValueOf(h).Pointer()]
+}
+
+// CheckOrder returns an error naming the first route of app on which Fiber's
+// compress middleware runs after this middleware or after NewLinkSigner, so
+// they would see compressed responses rather than those handlers wrote. Call
+// it at startup, once every route is registered, and fail on errors rather
+// than serve misordered routes. Handlers are identified like Protected does.
+func CheckOrder(app *fiber.App) error {
+	caseSensitive := app.Config().CaseSensitive
+	compressor := reflect.ValueOf(compress.New()).Pointer()
+
+	for _, routes := range app.Stack() {
+		// Handlers mounted with app.Use so far, with their paths
+		var mounted []*fiber.Route
+
+		for _, route := range routes {
+			if isUse(route) {
+				mounted = append(mounted, route)
+				continue
+			}
+
+			// Handlers run in the order they were registered
+			var chain []fiber.Handler
+			for _, mount := range mounted {
+				if mountMatches(mount.Path, route.Path, caseSensitive) {
+					chain = append(chain, mount.Handlers...)
+				}
+			}
+			chain = append(chain, route.Handlers...)
+
+			var before string
+			for _, h := range chain {
+				switch {
+				case before == "" && isMiddleware(h):
+					before = "the signed URL middleware"
+				case before == "" && isLinkSigner(h):
+					before = "NewLinkSigner"
+				case before != "" && reflect.ValueOf(h).Pointer() == compressor:
+					return fmt.Errorf("compress middleware runs after %s on %s %s, register it before", before, route.Method, route.Path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package signed
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCompressedBodies(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	// Decompress bodies of requests to /decoded before the middleware
+	app.Use("/decoded", func(c *fiber.Ctx) error {
+		body, err := c.Request().BodyGunzip()
+		if err != nil {
+			return err
+		}
+		c.Request().SetBody(body)
+		c.Request().Header.Del(fiber.HeaderContentEncoding)
+		return c.Next()
+	})
+	app.Use(New(Config{
+		GetPrivateKeyFunc:   func() string { return "secret" },
+		MaxDecodedBodyBytes: 1024,
+	}))
+
+	app.Post("/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	gzipped := func(body string) []byte {
+		return fasthttp.AppendGzipBytes(nil, []byte(body))
+	}
+	newRequest := func(target string, body []byte, encoding string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		req.RequestURI = ""
+		if encoding != "" {
+			req.Header.Set(fiber.HeaderContentEncoding, encoding)
+		}
+		return req
+	}
+
+	t.Run("it should hash compressed bodies as decoded content", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/raw", []byte("hello"), ""))
+		resp, _ := app.Test(newRequest(signedURL, gzipped("hello"), "gzip"))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should verify bodies decompressed before the middleware", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/decoded", gzipped("hello"), "gzip"))
+		resp, _ := app.Test(newRequest(signedURL, gzipped("hello"), "gzip"))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject other decoded content", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/raw", gzipped("hello"), "gzip"))
+		resp, _ := app.Test(newRequest(signedURL, gzipped("goodbye"), "gzip"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject bodies which do not decode", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/raw", []byte("hello"), ""))
+		resp, _ := app.Test(newRequest(signedURL, []byte("hello"), "gzip"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject bodies decoding larger than MaxDecodedBodyBytes", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/raw", []byte("hello"), ""))
+		resp, _ := app.Test(newRequest(signedURL, gzipped(strings.Repeat("a", 2048)), "gzip"))
+
+		utils.AssertEqual(t, fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
+
+func TestCheckOrder(t *testing.T) {
+	handler := func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	}
+
+	t.Run("it should accept compression before the middleware", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use(compress.New())
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		}))
+		app.Get("/", handler)
+
+		utils.AssertEqual(t, nil, CheckOrder(app))
+	})
+
+	t.Run("it should reject compression after the middleware", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use("/private", New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		}))
+		app.Use(compress.New(compress.Config{Level: compress.LevelBestSpeed}))
+		app.Get("/public", handler)
+		app.Get("/private/report", handler)
+
+		err := CheckOrder(app)
+		utils.AssertEqual(t, true, err != nil)
+		utils.AssertEqual(t, "compress middleware runs after the signed URL middleware on GET /private/report, register it before", err.Error())
+	})
+
+	t.Run("it should reject compression after the link signer", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		app.Get("/", NewLinkSigner(LinkSignerConfig{}), compress.New(), handler)
+
+		err := CheckOrder(app)
+		utils.AssertEqual(t, true, err != nil)
+		utils.AssertEqual(t, "compress middleware runs after NewLinkSigner on GET /, register it before", err.Error())
+	})
+}
@@ -0,0 +1,185 @@
+package signed
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// leaseLocal is the fiber.Ctx local holding the lease of a request, for body
+// streams of the middleware to take over
+const leaseLocal = "fiber-signed:lease"
+
+// lease is a concurrent use slot held by a request, renewed every LeaseTTL/3
+// until it ends
+type lease struct {
+	once sync.Once
+	done chan struct{}
+	free func()
+	held bool
+}
+
+// release ends the lease, freeing its slot
+func (l *lease) release() {
+	l.once.Do(func() {
+		close(l.done)
+		l.free()
+	})
+}
+
+// abandon ends the lease, leaving its slot taken until LeaseTTL passes
+func (l *lease) abandon() {
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+// end ends the lease once the stack is done with the request. Body streams of
+// the middleware hold the lease until they are sent, while other streams
+// outlive the handler unseen, so their lease is left to expire.
+func (l *lease) end(c *fiber.Ctx) {
+	switch {
+	case !c.Response().IsBodyStream():
+		l.release()
+	case !l.held:
+		l.abandon()
+	}
+}
+
+// holdLease takes the lease of the request over from the middleware, for a
+// body stream to release once it is sent. It returns nil if the request
+// holds none.
+func holdLease(c *fiber.Ctx) *lease {
+	l, _ := c.Locals(leaseLocal).(*lease)
+	if l != nil {
+		l.held = true
+	}
+
+	return l
+}
+
+// acquireLease takes one of the concurrent use slots signed into the request
+// URL. The returned lease must be ended once the request is done; until then
+// it is renewed every LeaseTTL/3.
+func (cfg *instance) acquireLease(c *fiber.Ctx) (*lease, error) {
+	value := c.Query(cfg.ConcurrencyQueryKey)
+	if value == "" {
+		return nil, nil
+	}
+
+	max, err := strconv.Atoi(value)
+	if err != nil || max < 1 {
+		return nil, fmt.Errorf("%s value must be a positive integer", cfg.ConcurrencyQueryKey)
+	}
+
+	if cfg.Storage == nil {
+		return nil, errors.New("url signature concurrent use limit cannot be enforced without Storage")
+	}
+
+	// The heartbeat outlives this call, so don't read the config from it
+	storage, ttl := cfg.getStorage(requestContext(c)), cfg.LeaseTTL
+	storeTTL := cfg.storageTTL(StorageKindLeases, ttl)
+	key := cfg.storageKey(StorageKindLeases, cfg.getHash(cfg.getSignatureID(c)))
+	leaseID, err := newLeaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := updateLeases(storage, key, storeTTL, func(leases map[string]int64) bool {
+		if len(leases) >= max {
+			return false
+		}
+		leases[leaseID] = timeNow().Add(ttl).UnixNano()
+		return true
+	})
+	if err != nil {
+		return nil, &storeError{err}
+	}
+	if !acquired {
+		return nil, fiber.NewError(fiber.StatusTooManyRequests, "url signature concurrent use limit exceeded")
+	}
+
+	l := &lease{
+		done: make(chan struct{}),
+		free: func() {
+			_, _ = updateLeases(storage, key, storeTTL, func(leases map[string]int64) bool {
+				delete(leases, leaseID)
+				return true
+			})
+		},
+	}
+	c.Locals(leaseLocal, l)
+
+	// Heartbeat keeps the lease alive for long running handlers and streams
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				_, _ = updateLeases(storage, key, storeTTL, func(leases map[string]int64) bool {
+					leases[leaseID] = timeNow().Add(ttl).UnixNano()
+					return true
+				})
+			}
+		}
+	}()
+
+	return l, nil
+}
+
+// updateLeases loads the unexpired leases stored under key, applies fn and
+// saves the result for ttl if fn returns true
+func updateLeases(storage fiber.Storage, key string, ttl time.Duration, fn func(leases map[string]int64) bool) (bool, error) {
+	defer storageLocks.lock(key)()
+
+	leases := make(map[string]int64)
+	b, err := storage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if len(b) > 0 {
+		_ = json.Unmarshal(b, &leases)
+	}
+
+	// Drop leases whose holders stopped sending heartbeats
+	now := timeNow().UnixNano()
+	for id, expires := range leases {
+		if expires <= now {
+			delete(leases, id)
+		}
+	}
+
+	if !fn(leases) {
+		return false, nil
+	}
+
+	if len(leases) == 0 {
+		return true, storage.Delete(key)
+	}
+
+	b, err = json.Marshal(leases)
+	if err != nil {
+		return false, err
+	}
+
+	return true, storage.Set(key, b, ttl)
+}
+
+// newLeaseID returns a random identifier for a lease
+func newLeaseID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,136 @@
+package signed
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	// Initalize config
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		LeaseTTL:          time.Minute,
+	}))
+
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		entered <- struct{}{}
+		<-unblock
+		return c.SendString("Hello, world!")
+	})
+
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/slow?maxConcurrent=1", nil)
+	slowURL, _ := GetSignedURLFromHTTPRequest(req)
+
+	t.Run("it should reject requests beyond the concurrent use limit", func(t *testing.T) {
+		done := make(chan int)
+		go func() {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, slowURL), 5000)
+			done <- resp.StatusCode
+		}()
+		<-entered
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, slowURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		utils.AssertEqual(t, "url signature concurrent use limit exceeded", string(body))
+
+		close(unblock)
+		utils.AssertEqual(t, fiber.StatusOK, <-done)
+	})
+
+	t.Run("it should release the lease when the request completes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/fast?maxConcurrent=1", nil)
+		fastURL, _ := GetSignedURLFromHTTPRequest(req)
+
+		for i := 0; i < 2; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, fastURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("it should reject a malformed concurrent use limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/fast?maxConcurrent=0", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "maxConcurrent value must be a positive integer", string(body))
+	})
+}
+
+func TestConcurrencyLimitStreams(t *testing.T) {
+	// Initalize config
+	unblock := make(chan struct{})
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		LeaseTTL:          300 * time.Millisecond,
+	}))
+
+	app.Get("/events", func(c *fiber.Ctx) error {
+		StreamUntilExpired(c, func(w *bufio.Writer, expired <-chan struct{}) {
+			<-unblock
+			fmt.Fprint(w, "Hello, world!")
+		})
+		return nil
+	})
+
+	app.Get("/download", func(c *fiber.Ctx) error {
+		return SendStream(c, strings.NewReader("Hello, world!"))
+	})
+
+	status := func(signedURL string) int {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL), 5000)
+		return resp.StatusCode
+	}
+
+	t.Run("it should hold and renew the lease until the stream is sent", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/events?maxConcurrent=1", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+		done := make(chan int)
+		go func() {
+			done <- status(signedURL)
+		}()
+
+		// Outlast the lease TTL while streaming
+		time.Sleep(500 * time.Millisecond)
+		utils.AssertEqual(t, fiber.StatusTooManyRequests, status(signedURL))
+
+		close(unblock)
+		utils.AssertEqual(t, fiber.StatusOK, <-done)
+		utils.AssertEqual(t, fiber.StatusOK, status(signedURL))
+	})
+
+	t.Run("it should release the lease once a sent stream is done", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/download?maxConcurrent=1", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+		for i := 0; i < 2; i++ {
+			utils.AssertEqual(t, fiber.StatusOK, status(signedURL))
+		}
+	})
+}
@@ -1,8 +1,16 @@
 package signed
 
 import (
+	"context"
+	"crypto/ed25519"
+	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/bsandusky/fiber-signed/internal/canon"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -14,6 +22,48 @@ const (
 	AlgorithmSHA1   Algorithm = "SHA-1"
 	AlgorithmSHA256 Algorithm = "SHA-256"
 	AlgorithmMD5    Algorithm = "MD-5"
+
+	AlgorithmHMACSHA256 Algorithm = "HMAC-SHA-256"
+)
+
+// MultiValuePolicy defines how query params repeated in a URL are signed
+type MultiValuePolicy int
+
+// Multi-value param policy values
+const (
+	// MultiValueSort signs the values of a repeated param in sorted order, so
+	// a=2&a=1 and a=1&a=2 are the same URL
+	MultiValueSort MultiValuePolicy = iota
+	// MultiValuePreserve signs the values of a repeated param in the order
+	// they appear, for apps where that order is meaningful
+	MultiValuePreserve
+	// MultiValueReject rejects URLs repeating app params, when signing and
+	// validating
+	MultiValueReject
+)
+
+// BodyPolicy defines how bodies of GET and HEAD requests are signed
+type BodyPolicy int
+
+// GET and HEAD body policy values
+const (
+	// BodyHash hashes bodies into signatures like those of any other method
+	BodyHash BodyPolicy = iota
+	// BodyIgnore leaves bodies out of signatures, so intermediaries which
+	// strip them don't break verification, at the cost of leaving them
+	// unprotected
+	BodyIgnore
+	// BodyReject rejects requests with bodies, when signing and validating
+	BodyReject
+)
+
+// TokenAlgorithm type defines options for signing tokens in token mode
+type TokenAlgorithm string
+
+// Token signing algorithm option values
+const (
+	TokenAlgorithmHS256 TokenAlgorithm = "HS256"
+	TokenAlgorithmEdDSA TokenAlgorithm = "EdDSA"
 )
 
 // Config defines the config for middleware.
@@ -24,11 +74,51 @@ type Config struct {
 	Next func(c *fiber.Ctx) bool
 
 	// Algorithm defines the hash function used to create signatures. Options
-	// are AlgorithmSHA1, AlgorithmSHA256, AlgorithmMD5.
+	// are AlgorithmSHA1, AlgorithmSHA256, AlgorithmMD5, and
+	// AlgorithmHMACSHA256 which keys an HMAC with the private key rather
+	// than hashing it along with the URL.
 	//
 	// Optional. Default: SHA-1
 	Algorithm Algorithm
 
+	// PreviousAlgorithm is also accepted when verifying signatures until
+	// PreviousAlgorithmUntil, while URLs are always signed with Algorithm, so
+	// issued links keep working while migrating between algorithms. See
+	// GetMigrationStats for how many requests still use it.
+	//
+	// Optional. Default: ""
+	PreviousAlgorithm Algorithm
+
+	// PreviousAlgorithmUntil defines when PreviousAlgorithm stops being
+	// accepted. Zero accepts it indefinitely.
+	//
+	// Optional. Default: time.Time{}
+	PreviousAlgorithmUntil time.Time
+
+	// SignatureBits truncates signatures to their first SignatureBits bits,
+	// base64url encoded, for length constrained channels like SMS: 128 bits
+	// take 22 characters, against 40 for a hex SHA-1 signature. Each bit
+	// removed halves the work of guessing a signature, so values below 64 are
+	// raised to 64, and guessing should be throttled eg. by a limiter.
+	//
+	// Optional. Default: 0 (untruncated, hex encoded)
+	SignatureBits int
+
+	// EmbedAlgorithm adds the identifier of Algorithm (eg. alg=hs256) to
+	// signed URLs, and verifies URLs naming one with that algorithm only, so
+	// mixed fleets can verify URLs signed with different algorithms.
+	//
+	// Optional. Default: false
+	EmbedAlgorithm bool
+
+	// AllowedAlgorithms defines the algorithms URLs may name when
+	// EmbedAlgorithm is set. URLs naming any other are rejected, so they
+	// can't downgrade verification to a weaker algorithm. When nil, Algorithm
+	// and PreviousAlgorithm (while it is accepted) are allowed.
+	//
+	// Optional. Default: nil
+	AllowedAlgorithms []Algorithm
+
 	// GetPrivateKeyFunc defines a function to obtain a string value for use as
 	// the private key in hash functions.
 	//
@@ -36,6 +126,624 @@ type Config struct {
 	// os.Getenv("FIBER_SIGNED_PRIVATE_KEY") }
 	GetPrivateKeyFunc func() string
 
+	// GetPrivateKeyContextFunc defines a function to obtain the private key
+	// with the context of the request it is needed for (see
+	// UserContextLocal), so key providers making external calls respect its
+	// cancellation and deadline, and carry its tracing context. When set, it
+	// is used in place of GetPrivateKeyFunc, which defaults to calling it with
+	// context.Background().
+	//
+	// Optional. Default: nil
+	GetPrivateKeyContextFunc func(ctx context.Context) (string, error)
+
+	// GetPepperFunc defines a function to obtain a pepper, kept apart from
+	// the private key (eg. in an environment variable while keys are in a
+	// secret store), which is mixed into every key signatures are computed
+	// with, so the secret store alone is not enough to forge signatures.
+	// Changing it invalidates every URL signed before.
+	//
+	// Optional. Default: nil
+	GetPepperFunc func() string
+
+	// GetPrivateKeyByIDFunc defines a function to obtain the private key for
+	// a given key ID. An empty string is treated as an unknown key.
+	//
+	// Optional. Default: nil
+	GetPrivateKeyByIDFunc func(keyID string) string
+
+	// RequiredSignatures sets the number of distinct keys which must have
+	// co-signed a URL for it to validate. When greater than zero, signature
+	// values are expected in the form "<key ID>:<signature>" as generated by
+	// GetCoSignedURLFromHTTPRequest.
+	//
+	// Optional. Default: 0
+	RequiredSignatures int
+
+	// TokenAlgorithm defines the JWT algorithm used in token mode. Options
+	// are TokenAlgorithmHS256 (keyed with GetPrivateKeyFunc) and
+	// TokenAlgorithmEdDSA (keyed with GetSigningKeyFunc and
+	// GetPublicKeyFunc).
+	//
+	// Optional. Default: HS256
+	TokenAlgorithm TokenAlgorithm
+
+	// GetSigningKeyFunc defines a function to obtain the Ed25519 private key
+	// used to sign tokens with TokenAlgorithmEdDSA.
+	//
+	// Optional. Default: nil
+	GetSigningKeyFunc func() ed25519.PrivateKey
+
+	// GetPublicKeyFunc defines a function to obtain the Ed25519 public key
+	// used to verify tokens with TokenAlgorithmEdDSA.
+	//
+	// Optional. Default: nil
+	GetPublicKeyFunc func() ed25519.PublicKey
+
+	// KeyID is included as the "kid" header of issued tokens so validators
+	// can select the matching key from a JWKS.
+	//
+	// Optional. Default: ""
+	KeyID string
+
+	// JWKSURL defines a URL from which Ed25519 public keys are fetched to
+	// verify TokenAlgorithmEdDSA tokens by their "kid" header. Takes
+	// precedence over GetPublicKeyFunc when set.
+	//
+	// Optional. Default: ""
+	JWKSURL string
+
+	// JWKSRefreshInterval defines how long keys fetched from JWKSURL are
+	// cached before being fetched again. Tokens with an unknown "kid" also
+	// trigger a refresh to pick up rotated keys.
+	//
+	// Optional. Default: 1 * time.Hour
+	JWKSRefreshInterval time.Duration
+
+	// ClaimValidators are run in order once a request's signature has been
+	// verified, and reject the request with the first error returned. In
+	// token mode claims are those of the token; otherwise they are the signed
+	// query params. Return a *fiber.Error to choose the status code.
+	//
+	// Optional. Default: nil
+	ClaimValidators []func(c *fiber.Ctx, claims Claims) error
+
+	// BytesServed is called after the rest of the stack has handled a
+	// validated request, with the signature (or token) of its URL and the
+	// number of response body bytes, for quota enforcement and billing.
+	//
+	// Optional. Default: nil
+	BytesServed func(c *fiber.Ctx, signature string, bytes int)
+
+	// Receipts returns a receipt token in ReceiptHeader once the rest of the
+	// stack has handled a validated request, covering the signature (or
+	// token) of its URL, the number of response body bytes and the time, so
+	// clients can present it as proof of download. See VerifyReceipt.
+	//
+	// Optional. Default: false
+	Receipts bool
+
+	// PrivateCacheControl marks responses to validated requests private in
+	// their Cache-Control header (keeping their other directives), so shared
+	// caches and CDNs don't serve them to requests with other signatures, or
+	// none. Responses already marked private or no-store are left as is.
+	//
+	// Optional. Default: false
+	PrivateCacheControl bool
+
+	// Revocations rejects signed URLs revoked with Revoke or RevokeURL,
+	// consulting the revocation index in Storage on every request. URLs can
+	// be revoked individually, or by the UserQueryKey or PurposeQueryKey params
+	// signed into them, or their path.
+	//
+	// Optional. Default: false
+	Revocations bool
+
+	// FirstUsed is called once for each signed URL, the first time it is
+	// successfully used, eg. to notify its recipient that a download link was
+	// opened or detect leaked links. Uses are tracked in Storage until the
+	// URL expires (or indefinitely), so it requires Storage. It is called
+	// before the rest of the stack, and should hand off slow work.
+	//
+	// Optional. Default: nil
+	FirstUsed func(c *fiber.Ctx, signature string)
+
+	// AbuseWebhookURL defines a URL to which an AbuseReport is posted when
+	// more than AbuseThreshold requests are rejected within AbuseWindow
+	//
+	// Optional. Default: ""
+	AbuseWebhookURL string
+
+	// GetAbuseWebhookKeyFunc defines a function to obtain the key used to
+	// sign abuse reports (see SignAbuseReport). Reports are unsigned without
+	// it.
+	//
+	// Optional. Default: nil
+	GetAbuseWebhookKeyFunc func() string
+
+	// AbuseThreshold defines the number of rejections within AbuseWindow
+	// beyond which an abuse report is posted
+	//
+	// Optional. Default: 100
+	AbuseThreshold int
+
+	// AbuseWindow defines the length of the window in which rejections are
+	// counted towards AbuseThreshold
+	//
+	// Optional. Default: 1 * time.Minute
+	AbuseWindow time.Duration
+
+	// Analytics counts validated requests per signed URL, purpose and route,
+	// flushing the counts every AnalyticsInterval to AnalyticsFlushed or, if
+	// it is nil, to totals in Storage (see GetAnalyticsCount).
+	//
+	// Optional. Default: false
+	Analytics bool
+
+	// AnalyticsInterval defines how often validation counts are flushed
+	//
+	// Optional. Default: 1 * time.Minute
+	AnalyticsInterval time.Duration
+
+	// AnalyticsFlushed is called with the validation counts since the last
+	// flush, in place of adding them to Storage
+	//
+	// Optional. Default: nil
+	AnalyticsFlushed func(counts []AnalyticsCount)
+
+	// AnalyticsTTL defines how long totals in Storage are kept after the
+	// last flush adding to them
+	//
+	// Optional. Default: 30 * 24 * time.Hour
+	AnalyticsTTL time.Duration
+
+	// CountryResolver defines a function resolving a client IP address to an
+	// ISO 3166 country code, used to enforce country restrictions signed into
+	// URLs
+	//
+	// Optional. Default: nil
+	CountryResolver func(ip string) (string, error)
+
+	// AllowMissingOrigin accepts requests without Origin or Referer headers
+	// on URLs bound to an origin. Browsers omit them under some referrer
+	// policies, but allowing it weakens hotlinking protection.
+	//
+	// Optional. Default: false
+	AllowMissingOrigin bool
+
+	// BindLocal is the key of a local (eg. "userID", set by auth middleware)
+	// whose value is included in the signature at verify time, so URLs
+	// signed with GetBoundSignedURLFromHTTPRequest are only valid for the
+	// user they were issued to
+	//
+	// Optional. Default: ""
+	BindLocal string
+
+	// EscrowHeader is the request header carrying the requester's key
+	// fragment. When set, signatures are verified with a key combining the
+	// private key and the fragment, so URLs signed with
+	// GetEscrowedSignedURLFromHTTPRequest are useless without it, eg. if the
+	// database storing them leaks. Requests without the header are rejected.
+	//
+	// Optional. Default: ""
+	EscrowHeader string
+
+	// StrictFraming rejects requests with conflicting or repeated
+	// Content-Length and Transfer-Encoding headers, transfer encodings other
+	// than chunked, folded header lines or whitespace before header colons,
+	// with 400 - Bad Request, since signatures only cover the body if every
+	// server on the way agrees where it ends
+	//
+	// Optional. Default: false
+	StrictFraming bool
+
+	// MaxHeaderBytes rejects requests whose header block is larger, with 431
+	// - Request Header Fields Too Large, on top of the limits of Fiber. Zero
+	// disables the check.
+	//
+	// Optional. Default: 0
+	MaxHeaderBytes int
+
+	// MaxDecodedBodyBytes rejects requests whose body, once its
+	// Content-Encoding is decoded for hashing, is larger, with 413 - Request
+	// Entity Too Large, so small compressed bodies can't expand without bound
+	//
+	// Optional. Default: 4 * 1024 * 1024
+	MaxDecodedBodyBytes int
+
+	// Debug adds the canonical string computed for rejected requests, with
+	// the private key redacted, to their responses in DebugCanonicalHeader.
+	// It reveals how URLs are signed, so never enable it in production.
+	//
+	// Optional. Default: false
+	Debug bool
+
+	// Diagnostics adds metadata about the signature of accepted requests (the
+	// algorithm, key fingerprint or ID and seconds until expiry) to their
+	// responses in DiagnosticsHeader
+	//
+	// Optional. Default: false
+	Diagnostics bool
+
+	// Messages translates rejection messages for end users, by language tag
+	// (eg. "de" or "pt-BR") and then by message (eg. "url signature has
+	// expired"), with "" translating any other message. The language best
+	// matching the Accept-Language header of a request is used, and messages
+	// without a translation are sent as is.
+	//
+	// Optional. Default: nil
+	Messages map[string]map[string]string
+
+	// RejectionView is rendered, in place of the rejection message, for
+	// rejected requests from browsers (those listing HTML in their Accept
+	// header), eg. for emailed links opened after they expired. It names a
+	// view of the Views engine of the app, or a template file without one,
+	// and is rendered with a RejectionPage. Other requests are rejected with
+	// the message as usual.
+	//
+	// Optional. Default: ""
+	RejectionView string
+
+	// RenewalURLFunc returns where a fresh link for the rejected request can
+	// be requested, as the RenewalURL of the RejectionPage
+	//
+	// Optional. Default: nil
+	RenewalURLFunc func(c *fiber.Ctx) string
+
+	// Storage is used to store the state of the middleware, eg. when keys
+	// were first seen. Storage implementing ScriptRunner (eg. Redis) makes
+	// single-use and max-uses consumption atomic across validators.
+	//
+	// Optional. Default: nil
+	Storage fiber.Storage
+
+	// StoragePrefix is prepended to the key of every entry written to
+	// Storage, eg. "fiber-signed:nonces:<nonce>", so Storage can be shared
+	// with other middlewares and cleaned up with SCAN prefix*
+	//
+	// Optional. Default: "fiber-signed:"
+	StoragePrefix string
+
+	// StorageTTL returns the TTL of an entry of kind (see StorageKindNonces
+	// and friends) written to Storage, given the TTL the package would use.
+	// Zero means the entry doesn't expire. Shortening TTLs of nonces or uses
+	// allows URLs to be used again once their entry expires.
+	//
+	// Optional. Default: nil
+	StorageTTL func(kind string, ttl time.Duration) time.Duration
+
+	// StoreFailurePolicy defines how requests are handled when Storage or the
+	// ReplayCache fail while checking nonces, uses, leases or rate limits.
+	// StoreFailOpen skips the failed check, which allows replays during an
+	// outage, so prefer StoreFailOpenWithAlert if availability matters more.
+	//
+	// Optional. Default: StoreFailClosed
+	StoreFailurePolicy StoreFailurePolicy
+
+	// StoreFailed is called with the store errors of checks skipped under
+	// StoreFailOpenWithAlert
+	//
+	// Optional. Default: func(err error) {
+	//   log.Printf(...)
+	// }
+	StoreFailed func(err error)
+
+	// KeyTimeout bounds each call to the key provider, failing the request
+	// with ErrTimeout if it takes longer, so a slow secrets backend can't
+	// stall every request. Zero means no timeout.
+	//
+	// Optional. Default: 0
+	KeyTimeout time.Duration
+
+	// StorageTimeout bounds each Storage operation, failing it with
+	// ErrTimeout if it takes longer. Failures are handled according to
+	// StoreFailurePolicy. Zero means no timeout.
+	//
+	// Optional. Default: 0
+	StorageTimeout time.Duration
+
+	// BreakerThreshold opens a circuit breaker around the key provider or
+	// Storage once that many calls to it in a row have failed (or timed
+	// out), failing further calls with ErrCircuitOpen until BreakerCooldown
+	// has passed. Zero disables the circuit breakers.
+	//
+	// Optional. Default: 0
+	BreakerThreshold int
+
+	// BreakerCooldown defines how long an open circuit breaker fails calls
+	// before letting a trial call through
+	//
+	// Optional. Default: 30 * time.Second
+	BreakerCooldown time.Duration
+
+	// KeyCacheTTL keeps the private key in memory, refreshing it from the
+	// key provider in the background that often, so requests never wait on
+	// key retrieval. Failed refreshes keep the last key fetched. Zero looks
+	// the key up on every request.
+	//
+	// Optional. Default: 0
+	KeyCacheTTL time.Duration
+
+	// KeyRotationGrace defines how long URLs signed with the previous key
+	// keep validating once KeyCacheTTL picks up a new one
+	//
+	// Optional. Default: 0
+	KeyRotationGrace time.Duration
+
+	// ValidationCacheTTL keeps the result of verifying the signature of a
+	// request that long, so automatic retries of the same request (same
+	// URL, body and client) skip canonicalizing and signing the body again.
+	// Expiry, revocation and stateful checks, eg. single use, still run on
+	// every request. Results outlive key rotations by up to this long, so
+	// keep it to a few seconds. Zero disables the cache.
+	//
+	// Optional. Default: 0
+	ValidationCacheTTL time.Duration
+
+	// MaxKeyAge defines how long a private key may be in use before
+	// KeyAgeExceeded is called. Keys are identified by fingerprint and their
+	// first use is recorded in Storage when set. Zero disables the check.
+	//
+	// Optional. Default: 0
+	MaxKeyAge time.Duration
+
+	// KeyAgeExceeded is called with the fingerprint and age of the active key
+	// when it exceeds MaxKeyAge, at most once an hour per key
+	//
+	// Optional. Default: func(fingerprint string, age time.Duration) {
+	//   log.Printf(...)
+	// }
+	KeyAgeExceeded func(fingerprint string, age time.Duration)
+
+	// DuplicateRegistration is called with ErrDuplicateRegistration when a
+	// request passes through handlers returned by separate calls to New, eg.
+	// app.Use(signed.New(a)) and a route with signed.New(b), as only the
+	// config of the last call is in effect. Returning an error rejects the
+	// request with 500 - Internal Server Error. Requests passing through the
+	// same handler twice are only verified once.
+	//
+	// Optional. Default: func(err error) error {
+	//   log.Printf(...) // once
+	//   return nil
+	// }
+	DuplicateRegistration func(err error) error
+
+	// StampIssued adds the time URLs are signed at to them, in IssuedQueryKey
+	//
+	// Optional. Default: false
+	StampIssued bool
+
+	// RequestIDFunc returns the ID of the request or trace a URL is signed in,
+	// eg. from the tracing span in the context of the signed *http.Request, to
+	// sign into the URL as RequestIDQueryKey unless it carries one already. The
+	// ID is stored in RequestIDLocal and reported in events when the URL is
+	// used, so a link can be traced from issuance to use.
+	//
+	// Optional. Default: nil
+	RequestIDFunc func(ctx context.Context) string
+
+	// MintLimit caps how many URLs may be signed per MintWindow for each key
+	// MintKeyFunc returns, guarding against bugs or abuse minting unbounded
+	// numbers of URLs. Signing functions return ErrMintLimitExceeded beyond
+	// it. Counters are kept in Storage if set, and in memory otherwise. Zero
+	// disables the limit.
+	//
+	// Optional. Default: 0
+	MintLimit int
+
+	// MintWindow is the fixed window MintLimit applies to, in whole seconds
+	//
+	// Optional. Default: 1 * time.Minute
+	MintWindow time.Duration
+
+	// MintKeyFunc returns the key URLs signed for r are counted under for
+	// MintLimit, eg. the tenant or user they are signed for. Nil counts every
+	// URL under one key.
+	//
+	// Optional. Default: nil
+	MintKeyFunc func(r *http.Request) string
+
+	// MaxAge rejects URLs issued longer ago than MaxAge, regardless of their
+	// expiry, and URLs without an issued time. Zero disables the check.
+	//
+	// Optional. Default: 0
+	MaxAge time.Duration
+
+	// TTLPolicies maps purposes (ClaimPurpose) to the lifetime of their URLs.
+	// URLs signed for a purpose in the table without an expiry expire after
+	// its TTL, and signing or verifying URLs for it which expire later is
+	// refused. The "" entry governs URLs without a purpose.
+	//
+	// Optional. Default: nil
+	TTLPolicies map[string]time.Duration
+
+	// AllowProbes lets GET and HEAD requests carrying a token from
+	// MintProbeToken (in ProbeHeader or ProbeQueryKey) for their path through
+	// signed routes without a signature, for uptime checkers and other probes
+	// which can't sign requests. ProbeLocal is set for them.
+	//
+	// Optional. Default: false
+	AllowProbes bool
+
+	// ReplayWindow rejects requests issued more than ReplayWindow before or
+	// after the current time, and URLs without an issued time. With clients
+	// signing each request freshly (see StampIssued), it limits replays to a
+	// small window without storing nonces, eg. for API-to-API calls. Zero
+	// disables the check.
+	//
+	// Optional. Default: 0
+	ReplayWindow time.Duration
+
+	// ExpiryParams are additional query params expiries are read from, eg.
+	// to accept URLs signed with the expiry names and formats of another
+	// signing scheme while migrating. Requests are rejected once any of the
+	// expiries present have passed.
+	//
+	// Optional. Default: nil
+	ExpiryParams []ExpiryParam
+
+	// SoftExpired is called for requests after the soft expiry of their URL,
+	// which are still accepted until it expires, eg. to prompt renewal. The
+	// SoftExpiredLocal local is set for them either way.
+	//
+	// Optional. Default: nil
+	SoftExpired func(c *fiber.Ctx)
+
+	// ClockSkewThreshold is how long past their expiry URLs may be for their
+	// rejection to be reported as probably due to clock skew between signers
+	// and validators, eg. NTP drift, rather than genuine expiry. Such URLs are
+	// still rejected, once their signature is verified. Zero disables the
+	// detection.
+	//
+	// Optional. Default: 0
+	ClockSkewThreshold time.Duration
+
+	// ClockSkewDetected is called for requests rejected only for having
+	// expired within ClockSkewThreshold, with how long ago their URL expired,
+	// eg. to count them separately from genuinely expired URLs per host
+	//
+	// Optional. Default: nil
+	ClockSkewDetected func(c *fiber.Ctx, skew time.Duration)
+
+	// ETagFunc returns the current ETag of the resource requested, which URLs
+	// signed with GetSignedURLForETagFromHTTPRequest must match, so they only
+	// serve the content version they were issued for. It runs before the
+	// handler, so should be cheap (eg. read from metadata, not hashed).
+	//
+	// Optional. Default: nil
+	ETagFunc func(c *fiber.Ctx) (string, error)
+
+	// RevalidateInterval defines how often the expiry of signed URLs is
+	// re-checked while streaming responses with StreamUntilExpired
+	//
+	// Optional. Default: 1 * time.Second
+	RevalidateInterval time.Duration
+
+	// ReplayCache records the nonces of used URLs so they can't be replayed.
+	// When nil, Storage is used if set. See NewBloomReplayCache for a
+	// dependency free alternative.
+	//
+	// Optional. Default: nil
+	ReplayCache ReplayCache
+
+	// NonceTTL defines how long the nonce of a URL without an expiry is
+	// remembered
+	//
+	// Optional. Default: 24 * time.Hour
+	NonceTTL time.Duration
+
+	// NonceFunc generates the nonces of URLs signed with
+	// GetSingleUseSignedURLFromHTTPRequest, eg. deterministically in tests,
+	// or as ULIDs for traceability. Signing fails with the error it returns.
+	//
+	// Optional. Default: 16 random bytes from crypto/rand, hex encoded
+	NonceFunc func() (string, error)
+
+	// RequireNonce rejects URLs which don't carry both a nonce and an expiry,
+	// so every URL accepted is single-use and time-limited, and refuses to
+	// sign them. Nonces are remembered in ReplayCache or Storage, one of
+	// which must be set.
+	//
+	// Optional. Default: false
+	RequireNonce bool
+
+	// LeaseTTL defines how long a concurrent use lease lasts without a
+	// heartbeat. Leases of responses streamed with StreamUntilExpired or
+	// SendStream are renewed until the stream is sent, and leases of other
+	// streamed responses held for LeaseTTL after the handler returns.
+	//
+	// Optional. Default: 30 * time.Second
+	LeaseTTL time.Duration
+
+	// MultiValuePolicy defines how query params repeated in a URL are signed.
+	// Params owned by the middleware which may repeat, like caveats, are not
+	// affected.
+	//
+	// Optional. Default: MultiValueSort
+	MultiValuePolicy MultiValuePolicy
+
+	// QueryEncoderFunc builds the query string hashed into signatures from
+	// the query params signatures cover (including those the middleware adds,
+	// like the private key and body hash), eg. to match legacy clients which
+	// serialize queries in their own way, like PHP-style arr[]= params in the
+	// order sent. It must build the same string when signing and verifying.
+	//
+	// Optional. Default: params sorted by key and value (as MultiValuePolicy
+	// allows), joined as key=value with "&"
+	QueryEncoderFunc func(q url.Values) string
+
+	// GetHeadBodyPolicy defines how bodies of GET and HEAD requests are
+	// signed. Intermediaries may strip them after signing, so apps which
+	// don't expect them can ignore or reject them instead.
+	//
+	// Optional. Default: BodyHash
+	GetHeadBodyPolicy BodyPolicy
+
+	// CanonicalizeGraphQL hashes GraphQL-over-POST bodies (application/json
+	// and application/graphql) with the query document normalized and
+	// variables sorted, rather than as sent, so signatures don't depend on
+	// whitespace, comments or key order. Other JSON bodies are rejected.
+	//
+	// Optional. Default: false
+	CanonicalizeGraphQL bool
+
+	// ContentDigest signs the RFC 9530 Content-Digest (or Repr-Digest)
+	// header of requests carrying one in place of their body, so signers
+	// can sign large uploads from their digest alone. The middleware checks
+	// the body against every SHA-256 and SHA-512 digest in the header.
+	//
+	// Optional. Default: false
+	ContentDigest bool
+
+	// ForceScheme replaces the scheme of URLs when signing and verifying them,
+	// so signatures stay stable where scheme detection is inconsistent, eg.
+	// with traffic arriving both directly and through a TLS terminating load
+	// balancer.
+	//
+	// Optional. Default: ""
+	ForceScheme string
+
+	// RequireHTTPS rejects requests to signed routes not made over HTTPS, as
+	// reported by fiber's Protocol (including the X-Forwarded-Proto header).
+	//
+	// Optional. Default: false
+	RequireHTTPS bool
+
+	// HostAliases declares groups of hosts which are interchangeable, eg.
+	// {{"cdn.example.com", "assets.example.com"}}, so a URL signed for any
+	// host in a group validates on the others. Hosts include their port, if
+	// any. This supports CDN cutovers and vanity domains without re-issuing
+	// outstanding links.
+	//
+	// Optional. Default: nil
+	HostAliases [][]string
+
+	// NginxSecureLinkMD5 is the expression of an nginx secure_link_md5
+	// directive, eg. "$secure_link_expires$uri $secret", with $secret standing
+	// for the private key. When set, requests carrying NginxMD5QueryKey are
+	// validated as nginx secure links, so links generated by nginx-based
+	// infrastructure keep working during migrations. $secure_link_expires,
+	// $uri and $remote_addr are supported.
+	//
+	// Optional. Default: ""
+	NginxSecureLinkMD5 string
+
+	// Transport carries the signature params of signed URLs (signature,
+	// expires, issued, nonce and so on) somewhere other than the query, eg.
+	// HeaderTransport, CookieTransport, FormTransport or PathTransport. Params
+	// in the query are still accepted. Sign requests for it with SignRequest.
+	//
+	// Optional. Default: QueryTransport{}
+	Transport Transport
+
+	// QueryKeyPrefix namespaces the query params owned by the middleware, so
+	// apps already using eg. signature or expires as their own params can
+	// adopt it. It is prepended to the default name of each, capitalized, so
+	// "X-Sig-" gives X-Sig-Signature, X-Sig-Expires and so on. Query keys set
+	// explicitly are used as is.
+	//
+	// Optional. Default: ""
+	QueryKeyPrefix string
+
 	// SignatureQueryKey accepts a string value to use in URL query params for
 	// the signature value
 	//
@@ -59,17 +767,322 @@ type Config struct {
 	//
 	// Optional. Default: "bodyHash"
 	BodyHashQueryKey string
+
+	// PolicyQueryKey accepts a string value to use in URL query params for the
+	// encoded policy document
+	//
+	// Optional. Default: "policy"
+	PolicyQueryKey string
+
+	// TemplateQueryKey accepts a string value to use in URL query params for
+	// the path template and placeholder constraints of template URLs
+	//
+	// Optional. Default: "template"
+	TemplateQueryKey string
+
+	// CaveatQueryKey accepts a string value to use in URL query params for
+	// caveats appended to a signed URL with AddCaveat
+	//
+	// Optional. Default: "caveat"
+	CaveatQueryKey string
+
+	// DelegationQueryKey accepts a string value to use in URL query params for
+	// delegation grants
+	//
+	// Optional. Default: "delegation"
+	DelegationQueryKey string
+
+	// TokenQueryKey accepts a string value to use in URL query params for the
+	// JWT in token mode
+	//
+	// Optional. Default: "token"
+	TokenQueryKey string
+
+	// RateLimitQueryKey accepts a string value to use in URL query params for
+	// the per-signature rate limit, in the form "<requests>/<seconds>".
+	// Enforcing it requires Storage.
+	//
+	// Optional. Default: "rateLimit"
+	RateLimitQueryKey string
+
+	// ConcurrencyQueryKey accepts a string value to use in URL query params
+	// for the maximum number of in-flight requests per signature. Enforcing it
+	// requires Storage.
+	//
+	// Optional. Default: "maxConcurrent"
+	ConcurrencyQueryKey string
+
+	// NonceQueryKey accepts a string value to use in URL query params for the
+	// nonce of a single-use URL
+	//
+	// Optional. Default: "nonce"
+	NonceQueryKey string
+
+	// MaxUsesQueryKey accepts a string value to use in URL query params for
+	// the number of times a URL may be used. Enforcing it requires Storage.
+	//
+	// Optional. Default: "maxUses"
+	MaxUsesQueryKey string
+
+	// SourceIPRangeQueryKey accepts a string value to use in URL query params
+	// for the comma separated CIDR ranges requests must originate from
+	//
+	// Optional. Default: "sourceIpRange"
+	SourceIPRangeQueryKey string
+
+	// CountriesQueryKey accepts a string value to use in URL query params for
+	// the comma separated country codes requests must originate from.
+	// Enforcing it requires CountryResolver.
+	//
+	// Optional. Default: "countries"
+	CountriesQueryKey string
+
+	// OriginQueryKey accepts a string value to use in URL query params for
+	// the comma separated origin patterns (eg. "https://*.example.com")
+	// requests must come from, according to their Origin or Referer header
+	//
+	// Optional. Default: "origin"
+	OriginQueryKey string
+
+	// ClientCertQueryKey accepts a string value to use in URL query params for
+	// the SHA-256 fingerprint of the TLS client certificate requests must be
+	// made with (see ClientCertificateFingerprint)
+	//
+	// Optional. Default: "clientCert"
+	ClientCertQueryKey string
+
+	// IssuedQueryKey accepts a string value to use in URL query params for the
+	// unix timestamp URLs were signed at
+	//
+	// Optional. Default: "issued"
+	IssuedQueryKey string
+
+	// AlgorithmQueryKey accepts a string value to use in URL query params for
+	// the algorithm of signed URLs when EmbedAlgorithm is set
+	//
+	// Optional. Default: "alg"
+	AlgorithmQueryKey string
+
+	// SoftExpiresQueryKey accepts a string value to use in URL query params
+	// for the unix timestamp after which requests are flagged for renewal,
+	// but still accepted until the URL expires
+	//
+	// Optional. Default: "softExpires"
+	SoftExpiresQueryKey string
+
+	// ExpiresInQueryKey accepts a string value to use in URL query params for
+	// the number of seconds after its issued time a URL expires
+	//
+	// Optional. Default: "expiresIn"
+	ExpiresInQueryKey string
+
+	// ScheduleQueryKey accepts a string value to use in URL query params for
+	// the recurring windows a URL is valid in, as formatted by Schedule
+	//
+	// Optional. Default: "schedule"
+	ScheduleQueryKey string
+
+	// ETagQueryKey accepts a string value to use in URL query params for the
+	// ETag of the content version a URL was issued for
+	//
+	// Optional. Default: "etag"
+	ETagQueryKey string
+
+	// ProbeQueryKey accepts a string value to use in URL query params for
+	// probe tokens
+	//
+	// Optional. Default: "probe"
+	ProbeQueryKey string
+
+	// NginxMD5QueryKey accepts a string value to use in URL query params for
+	// the hash of nginx secure links
+	//
+	// Optional. Default: "md5"
+	NginxMD5QueryKey string
+
+	// PurposeQueryKey accepts a string value to use in URL query params for
+	// the purpose (ClaimPurpose) of URLs
+	//
+	// Optional. Default: "purpose"
+	PurposeQueryKey string
+
+	// UserQueryKey accepts a string value to use in URL query params for the
+	// user (ClaimUser) URLs are signed for
+	//
+	// Optional. Default: "user"
+	UserQueryKey string
+
+	// RequestIDQueryKey accepts a string value to use in URL query params for
+	// the ID of the request URLs are issued in (ClaimRequestID)
+	//
+	// Optional. Default: "rid"
+	RequestIDQueryKey string
+
+	// ShortURLs enables GetShortSignedURLFromHTTPRequest and resolving the
+	// short URLs it returns under ShortURLPrefix, which takes the prefix over
+	// from app routes. Requires Storage.
+	//
+	// Optional. Default: false
+	ShortURLs bool
+
+	// ShortURLPrefix is the path prefix of short URLs generated with
+	// GetShortSignedURLFromHTTPRequest
+	//
+	// Optional. Default: "/r/"
+	ShortURLPrefix string
+
+	// ShortURLTTL defines how long short URLs for signed URLs without an
+	// expiry are kept. Short URLs for expiring URLs are kept until they
+	// expire.
+	//
+	// Optional. Default: 30 * 24 * time.Hour
+	ShortURLTTL time.Duration
+
+	// MaxURLLength is the longest URL GetSignedURLFromHTTPRequest returns, eg.
+	// to fit SMS or mail clients. Longer URLs are signed again with
+	// URLLengthFallbacks until one fits, or refused with ErrURLTooLong. Zero
+	// disables the limit.
+	//
+	// Optional. Default: 0
+	MaxURLLength int
+
+	// URLLengthFallbacks are tried in order when signed URLs exceed
+	// MaxURLLength. Nil tries FallbackToken, then FallbackShortURL, and an
+	// empty slice refuses long URLs outright.
+	//
+	// Optional. Default: nil
+	URLLengthFallbacks []URLLengthFallback
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next:               nil,
-	Algorithm:          AlgorithmSHA1,
-	GetPrivateKeyFunc:  func() string { return os.Getenv("FIBER_SIGNED_PRIVATE_KEY") },
-	SignatureQueryKey:  "signature",
-	PrivateKeyQueryKey: "privateKey",
-	ExpiresQueryKey:    "expires",
-	BodyHashQueryKey:   "bodyHash",
+	Next:                     nil,
+	Algorithm:                AlgorithmSHA1,
+	PreviousAlgorithm:        "",
+	PreviousAlgorithmUntil:   time.Time{},
+	SignatureBits:            0,
+	EmbedAlgorithm:           false,
+	AllowedAlgorithms:        nil,
+	GetPrivateKeyFunc:        func() string { return os.Getenv("FIBER_SIGNED_PRIVATE_KEY") },
+	GetPrivateKeyContextFunc: nil,
+	GetPepperFunc:            nil,
+	GetPrivateKeyByIDFunc:    nil,
+	RequiredSignatures:       0,
+	TokenAlgorithm:           TokenAlgorithmHS256,
+	GetSigningKeyFunc:        nil,
+	GetPublicKeyFunc:         nil,
+	KeyID:                    "",
+	JWKSURL:                  "",
+	JWKSRefreshInterval:      1 * time.Hour,
+	ClaimValidators:          nil,
+	BytesServed:              nil,
+	Receipts:                 false,
+	PrivateCacheControl:      false,
+	Revocations:              false,
+	FirstUsed:                nil,
+	AbuseWebhookURL:          "",
+	GetAbuseWebhookKeyFunc:   nil,
+	AbuseThreshold:           100,
+	AbuseWindow:              1 * time.Minute,
+	Analytics:                false,
+	AnalyticsInterval:        1 * time.Minute,
+	AnalyticsFlushed:         nil,
+	AnalyticsTTL:             30 * 24 * time.Hour,
+	CountryResolver:          nil,
+	AllowMissingOrigin:       false,
+	BindLocal:                "",
+	EscrowHeader:             "",
+	StrictFraming:            false,
+	MaxHeaderBytes:           0,
+	MaxDecodedBodyBytes:      4 * 1024 * 1024,
+	Debug:                    false,
+	Diagnostics:              false,
+	Messages:                 nil,
+	RejectionView:            "",
+	RenewalURLFunc:           nil,
+	Storage:                  nil,
+	StoragePrefix:            "fiber-signed:",
+	StorageTTL:               nil,
+	StoreFailurePolicy:       StoreFailClosed,
+	StoreFailed: func(err error) {
+		log.Printf("fiber-signed: storage failed, skipping check: %v", err)
+	},
+	KeyTimeout:         0,
+	StorageTimeout:     0,
+	BreakerThreshold:   0,
+	BreakerCooldown:    30 * time.Second,
+	KeyCacheTTL:        0,
+	KeyRotationGrace:   0,
+	ValidationCacheTTL: 0,
+	MaxKeyAge:          0,
+	KeyAgeExceeded: func(fingerprint string, age time.Duration) {
+		log.Printf("fiber-signed: private key %s has been in use for %s, consider rotating it", fingerprint, age.Round(time.Hour))
+	},
+	DuplicateRegistration: warnDuplicateRegistration,
+	StampIssued:           false,
+	RequestIDFunc:         nil,
+	MintLimit:             0,
+	MintWindow:            1 * time.Minute,
+	MintKeyFunc:           nil,
+	MaxAge:                0,
+	TTLPolicies:           nil,
+	AllowProbes:           false,
+	ReplayWindow:          0,
+	ExpiryParams:          nil,
+	SoftExpired:           nil,
+	ClockSkewThreshold:    0,
+	ClockSkewDetected:     nil,
+	ETagFunc:              nil,
+	RevalidateInterval:    1 * time.Second,
+	ReplayCache:           nil,
+	NonceTTL:              24 * time.Hour,
+	NonceFunc:             newNonce,
+	RequireNonce:          false,
+	LeaseTTL:              30 * time.Second,
+	MultiValuePolicy:      MultiValueSort,
+	QueryEncoderFunc:      nil,
+	GetHeadBodyPolicy:     BodyHash,
+	CanonicalizeGraphQL:   false,
+	ContentDigest:         false,
+	ForceScheme:           "",
+	RequireHTTPS:          false,
+	HostAliases:           nil,
+	NginxSecureLinkMD5:    "",
+	Transport:             QueryTransport{},
+	QueryKeyPrefix:        "",
+	SignatureQueryKey:     "signature",
+	PrivateKeyQueryKey:    "privateKey",
+	ExpiresQueryKey:       "expires",
+	BodyHashQueryKey:      "bodyHash",
+	PolicyQueryKey:        "policy",
+	TemplateQueryKey:      "template",
+	CaveatQueryKey:        "caveat",
+	DelegationQueryKey:    "delegation",
+	TokenQueryKey:         "token",
+	RateLimitQueryKey:     "rateLimit",
+	ConcurrencyQueryKey:   "maxConcurrent",
+	NonceQueryKey:         "nonce",
+	MaxUsesQueryKey:       "maxUses",
+	SourceIPRangeQueryKey: "sourceIpRange",
+	CountriesQueryKey:     "countries",
+	OriginQueryKey:        "origin",
+	ClientCertQueryKey:    "clientCert",
+	IssuedQueryKey:        "issued",
+	AlgorithmQueryKey:     "alg",
+	SoftExpiresQueryKey:   "softExpires",
+	ExpiresInQueryKey:     "expiresIn",
+	ScheduleQueryKey:      "schedule",
+	ETagQueryKey:          "etag",
+	ProbeQueryKey:         "probe",
+	NginxMD5QueryKey:      "md5",
+	PurposeQueryKey:       "purpose",
+	UserQueryKey:          "user",
+	RequestIDQueryKey:     "rid",
+	ShortURLs:             false,
+	ShortURLPrefix:        "/r/",
+	ShortURLTTL:           30 * 24 * time.Hour,
+	MaxURLLength:          0,
+	URLLengthFallbacks:    nil,
 }
 
 // Helper function to set default values
@@ -91,25 +1104,221 @@ func configDefault(config ...Config) Config {
 		cfg.Algorithm = ConfigDefault.Algorithm
 	}
 
+	if cfg.GetPrivateKeyFunc == nil && cfg.GetPrivateKeyContextFunc != nil {
+		getPrivateKeyContext := cfg.GetPrivateKeyContextFunc
+		cfg.GetPrivateKeyFunc = func() string {
+			privateKey, _ := getPrivateKeyContext(context.Background())
+			return privateKey
+		}
+	}
+
 	if cfg.GetPrivateKeyFunc == nil {
 		cfg.GetPrivateKeyFunc = ConfigDefault.GetPrivateKeyFunc
 	}
 
+	if cfg.SignatureBits > 0 && cfg.SignatureBits < minSignatureBits {
+		cfg.SignatureBits = minSignatureBits
+	}
+
+	if cfg.StoreFailed == nil {
+		cfg.StoreFailed = ConfigDefault.StoreFailed
+	}
+
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = ConfigDefault.BreakerCooldown
+	}
+
+	if cfg.StoragePrefix == "" {
+		cfg.StoragePrefix = ConfigDefault.StoragePrefix
+	}
+
+	if cfg.TokenAlgorithm == "" {
+		cfg.TokenAlgorithm = ConfigDefault.TokenAlgorithm
+	}
+
+	if cfg.KeyAgeExceeded == nil {
+		cfg.KeyAgeExceeded = ConfigDefault.KeyAgeExceeded
+	}
+
+	if cfg.DuplicateRegistration == nil {
+		cfg.DuplicateRegistration = ConfigDefault.DuplicateRegistration
+	}
+
+	if cfg.AbuseThreshold <= 0 {
+		cfg.AbuseThreshold = ConfigDefault.AbuseThreshold
+	}
+
+	if cfg.AbuseWindow <= 0 {
+		cfg.AbuseWindow = ConfigDefault.AbuseWindow
+	}
+
+	if cfg.AnalyticsInterval <= 0 {
+		cfg.AnalyticsInterval = ConfigDefault.AnalyticsInterval
+	}
+
+	if cfg.AnalyticsTTL <= 0 {
+		cfg.AnalyticsTTL = ConfigDefault.AnalyticsTTL
+	}
+
+	if cfg.RevalidateInterval <= 0 {
+		cfg.RevalidateInterval = ConfigDefault.RevalidateInterval
+	}
+
+	if cfg.NonceTTL <= 0 {
+		cfg.NonceTTL = ConfigDefault.NonceTTL
+	}
+
+	if cfg.NonceFunc == nil {
+		cfg.NonceFunc = ConfigDefault.NonceFunc
+	}
+
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = ConfigDefault.LeaseTTL
+	}
+
+	if cfg.MintWindow < time.Second {
+		cfg.MintWindow = ConfigDefault.MintWindow
+	}
+
+	if cfg.MaxDecodedBodyBytes <= 0 {
+		cfg.MaxDecodedBodyBytes = ConfigDefault.MaxDecodedBodyBytes
+	}
+
+	if cfg.JWKSRefreshInterval <= 0 {
+		cfg.JWKSRefreshInterval = ConfigDefault.JWKSRefreshInterval
+	}
+
 	if cfg.SignatureQueryKey == "" {
-		cfg.SignatureQueryKey = ConfigDefault.SignatureQueryKey
+		cfg.SignatureQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.SignatureQueryKey)
 	}
 
 	if cfg.PrivateKeyQueryKey == "" {
-		cfg.PrivateKeyQueryKey = ConfigDefault.PrivateKeyQueryKey
+		cfg.PrivateKeyQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.PrivateKeyQueryKey)
 	}
 
 	if cfg.ExpiresQueryKey == "" {
-		cfg.ExpiresQueryKey = ConfigDefault.ExpiresQueryKey
+		cfg.ExpiresQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ExpiresQueryKey)
 	}
 
 	if cfg.BodyHashQueryKey == "" {
-		cfg.BodyHashQueryKey = ConfigDefault.BodyHashQueryKey
+		cfg.BodyHashQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.BodyHashQueryKey)
+	}
+
+	if cfg.PolicyQueryKey == "" {
+		cfg.PolicyQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.PolicyQueryKey)
+	}
+
+	if cfg.TemplateQueryKey == "" {
+		cfg.TemplateQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.TemplateQueryKey)
+	}
+
+	if cfg.CaveatQueryKey == "" {
+		cfg.CaveatQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.CaveatQueryKey)
+	}
+
+	if cfg.DelegationQueryKey == "" {
+		cfg.DelegationQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.DelegationQueryKey)
+	}
+
+	if cfg.TokenQueryKey == "" {
+		cfg.TokenQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.TokenQueryKey)
+	}
+
+	if cfg.RateLimitQueryKey == "" {
+		cfg.RateLimitQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.RateLimitQueryKey)
+	}
+
+	if cfg.ConcurrencyQueryKey == "" {
+		cfg.ConcurrencyQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ConcurrencyQueryKey)
+	}
+
+	if cfg.NonceQueryKey == "" {
+		cfg.NonceQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.NonceQueryKey)
+	}
+
+	if cfg.MaxUsesQueryKey == "" {
+		cfg.MaxUsesQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.MaxUsesQueryKey)
+	}
+
+	if cfg.SourceIPRangeQueryKey == "" {
+		cfg.SourceIPRangeQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.SourceIPRangeQueryKey)
+	}
+
+	if cfg.CountriesQueryKey == "" {
+		cfg.CountriesQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.CountriesQueryKey)
+	}
+
+	if cfg.OriginQueryKey == "" {
+		cfg.OriginQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.OriginQueryKey)
+	}
+
+	if cfg.ClientCertQueryKey == "" {
+		cfg.ClientCertQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ClientCertQueryKey)
+	}
+
+	if cfg.IssuedQueryKey == "" {
+		cfg.IssuedQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.IssuedQueryKey)
+	}
+
+	if cfg.AlgorithmQueryKey == "" {
+		cfg.AlgorithmQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.AlgorithmQueryKey)
+	}
+
+	if cfg.SoftExpiresQueryKey == "" {
+		cfg.SoftExpiresQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.SoftExpiresQueryKey)
+	}
+
+	if cfg.ExpiresInQueryKey == "" {
+		cfg.ExpiresInQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ExpiresInQueryKey)
+	}
+
+	if cfg.ScheduleQueryKey == "" {
+		cfg.ScheduleQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ScheduleQueryKey)
+	}
+
+	if cfg.ETagQueryKey == "" {
+		cfg.ETagQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ETagQueryKey)
+	}
+
+	if cfg.ProbeQueryKey == "" {
+		cfg.ProbeQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.ProbeQueryKey)
+	}
+
+	if cfg.NginxMD5QueryKey == "" {
+		cfg.NginxMD5QueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.NginxMD5QueryKey)
+	}
+
+	if cfg.PurposeQueryKey == "" {
+		cfg.PurposeQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.PurposeQueryKey)
+	}
+
+	if cfg.UserQueryKey == "" {
+		cfg.UserQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.UserQueryKey)
+	}
+
+	if cfg.RequestIDQueryKey == "" {
+		cfg.RequestIDQueryKey = prefixQueryKey(cfg.QueryKeyPrefix, ConfigDefault.RequestIDQueryKey)
+	}
+
+	if cfg.ShortURLPrefix == "" {
+		cfg.ShortURLPrefix = ConfigDefault.ShortURLPrefix
+	}
+	if cfg.ShortURLTTL <= 0 {
+		cfg.ShortURLTTL = ConfigDefault.ShortURLTTL
+	}
+
+	cfg.ForceScheme = strings.ToLower(cfg.ForceScheme)
+	cfg.HostAliases = normalizeHostAliases(cfg.HostAliases)
+
+	if cfg.Transport == nil {
+		cfg.Transport = ConfigDefault.Transport
 	}
 
 	return cfg
 }
+
+// prefixQueryKey returns the default query key name with prefix prepended,
+// capitalizing name when there is a prefix
+func prefixQueryKey(prefix, name string) string {
+	return canon.PrefixQueryKey(prefix, name)
+}
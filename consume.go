@@ -0,0 +1,139 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScriptRunner is implemented by Storage backends which can evaluate Lua
+// scripts atomically, eg. a Redis storage wrapped to expose its client's
+// EVAL. When Storage implements it, single-use and max-uses consumption is
+// atomic across every validator sharing the store rather than within one
+// process.
+type ScriptRunner interface {
+	// Eval runs script with keys and args and returns its integer result
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Lua scripts used with ScriptRunner. Both return 1 on success and 0 when the
+// URL can't be used.
+const (
+	// luaSetNonce records KEYS[1] for ARGV[1] milliseconds (or without an
+	// expiry if zero) unless it exists
+	luaSetNonce = `local set
+if tonumber(ARGV[1]) > 0 then
+  set = redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1])
+else
+  set = redis.call('SET', KEYS[1], '1', 'NX')
+end
+if set then return 1 end
+return 0`
+
+	// luaConsumeUse initializes KEYS[1] to ARGV[1] remaining uses expiring
+	// after ARGV[2] milliseconds, then decrements it if any uses remain
+	luaConsumeUse = `local remaining = redis.call('GET', KEYS[1])
+if not remaining then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  remaining = tonumber(ARGV[1])
+else
+  remaining = tonumber(remaining)
+end
+if remaining <= 0 then return 0 end
+redis.call('DECR', KEYS[1])
+return 1`
+)
+
+// evalScript runs script with runner and interprets its integer result
+func evalScript(runner ScriptRunner, script string, keys []string, args ...interface{}) (bool, error) {
+	result, err := runner.Eval(script, keys, args...)
+	if err != nil {
+		return false, err
+	}
+
+	switch n := result.(type) {
+	case int64:
+		return n == 1, nil
+	case int:
+		return n == 1, nil
+	default:
+		return false, fmt.Errorf("unexpected script result %v", result)
+	}
+}
+
+// getUseTTL returns how long state about the URL of a request must be kept:
+// until the earliest of its expiries, including relative, token and caveat
+// expiries, or NonceTTL without an expiry
+func (cfg *instance) getUseTTL(c *fiber.Ctx) time.Duration {
+	expires, _ := cfg.getURLLifetime(c)
+	for _, caveat := range c.Context().QueryArgs().PeekMulti(cfg.CaveatQueryKey) {
+		name, value, err := parseCaveat(string(caveat))
+		if err != nil || name != caveatExpires {
+			continue
+		}
+		i, _ := strconv.ParseInt(value, 10, 64)
+		if when := time.Unix(i, 0); expires.IsZero() || when.Before(expires) {
+			expires = when
+		}
+	}
+
+	if expires.IsZero() {
+		return cfg.NonceTTL
+	}
+
+	return expires.Sub(timeNow())
+}
+
+// consumeUse takes one of the uses signed into the URL with maxUses
+func (cfg *instance) consumeUse(c *fiber.Ctx) error {
+	maxUses := c.Query(cfg.MaxUsesQueryKey)
+	if maxUses == "" {
+		return nil
+	}
+
+	max, err := strconv.Atoi(maxUses)
+	if err != nil || max < 1 {
+		return fmt.Errorf("%s value must be a positive integer", cfg.MaxUsesQueryKey)
+	}
+
+	if cfg.Storage == nil {
+		return errors.New("url signature max uses cannot be enforced without Storage")
+	}
+
+	key := cfg.storageKey(StorageKindUses, cfg.getHash(cfg.getSignatureID(c)))
+	ttl := cfg.storageTTL(StorageKindUses, cfg.getUseTTL(c))
+
+	if runner, ok := cfg.getStorage(requestContext(c)).(ScriptRunner); ok {
+		consumed, err := evalScript(runner, luaConsumeUse, []string{key}, max, ttl.Milliseconds())
+		if err != nil {
+			return &storeError{err}
+		}
+		if !consumed {
+			return errors.New("url signature has no uses remaining")
+		}
+		return nil
+	}
+
+	defer storageLocks.lock(key)()
+
+	remaining := max
+	b, err := cfg.getStorage(requestContext(c)).Get(key)
+	if err != nil {
+		return &storeError{err}
+	}
+	if len(b) > 0 {
+		remaining, _ = strconv.Atoi(string(b))
+	}
+	if remaining <= 0 {
+		return errors.New("url signature has no uses remaining")
+	}
+
+	if err := cfg.getStorage(requestContext(c)).Set(key, []byte(strconv.Itoa(remaining-1)), ttl); err != nil {
+		return &storeError{err}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// scriptStorage emulates the Lua scripts of a Redis storage
+type scriptStorage struct {
+	*testStorage
+	evals int
+}
+
+func (s *scriptStorage) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	s.evals++
+	b, _ := s.Get(keys[0])
+
+	switch script {
+	case luaSetNonce:
+		if len(b) > 0 {
+			return int64(0), nil
+		}
+		s.Set(keys[0], []byte("1"), 0)
+		return int64(1), nil
+	case luaConsumeUse:
+		remaining := args[0].(int)
+		if len(b) > 0 {
+			remaining, _ = strconv.Atoi(string(b))
+		}
+		if remaining <= 0 {
+			return int64(0), nil
+		}
+		s.Set(keys[0], []byte(strconv.Itoa(remaining-1)), 0)
+		return int64(1), nil
+	case luaAddCount:
+		total, _ := strconv.Atoi(string(b))
+		s.Set(keys[0], []byte(strconv.Itoa(total+args[0].(int))), 0)
+		return int64(1), nil
+	case luaAppendLog:
+		log := string(b)
+		for _, arg := range args {
+			if !strings.Contains("\n"+log, "\n"+arg.(string)+"\n") {
+				log += arg.(string) + "\n"
+			}
+		}
+		s.Set(keys[0], []byte(log), 0)
+		return int64(1), nil
+	case luaPruneLog:
+		log := "\n" + string(b)
+		for i, arg := range args {
+			if record, _ := s.Get(keys[i+1]); len(record) == 0 {
+				log = strings.Replace(log, "\n"+arg.(string)+"\n", "\n", 1)
+			}
+		}
+		s.Set(keys[0], []byte(log[1:]), 0)
+		return int64(1), nil
+	}
+
+	return nil, nil
+}
+
+func TestMaxUses(t *testing.T) {
+
+	newApp := func(storage fiber.Storage) *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	sign := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		return signedURL
+	}
+
+	t.Run("it should reject a URL once its uses are consumed", func(t *testing.T) {
+		app := newApp(newTestStorage())
+		signedURL := sign("http://example.com/?maxUses=2")
+
+		for i := 0; i < 2; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has no uses remaining", string(body))
+	})
+
+	t.Run("it should count uses of caveated copies against the url", func(t *testing.T) {
+		app := newApp(newTestStorage())
+		signedURL := sign("http://example.com/?maxUses=1")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		caveated, err := AddCaveat(signedURL, PathCaveat("/*"))
+		utils.AssertEqual(t, nil, err)
+		resp, _ = app.Test(newTestRequest(http.MethodGet, caveated))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should consume uses and nonces with scripts when Storage supports them", func(t *testing.T) {
+		storage := &scriptStorage{testStorage: newTestStorage()}
+		app := newApp(storage)
+		signedURL := sign("http://example.com/?maxUses=1&nonce=abc")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, 2, storage.evals)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?maxUses=1")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?maxUses=1")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has no uses remaining", string(body))
+	})
+
+	t.Run("it should reject a malformed max uses value", func(t *testing.T) {
+		app := newApp(newTestStorage())
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?maxUses=none")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "maxUses value must be a positive integer", string(body))
+	})
+}
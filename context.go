@@ -0,0 +1,80 @@
+package signed
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UserContextLocal is the local apps may store a context.Context in, eg. from
+// tracing middleware, for the middleware to make external calls for the
+// request with. The context of the request is used otherwise.
+const UserContextLocal = "fiber-signed:user-context"
+
+// requestContext returns the context to make external calls for c with
+func requestContext(c *fiber.Ctx) context.Context {
+	if ctx, ok := c.Locals(UserContextLocal).(context.Context); ok && ctx != nil {
+		return ctx
+	}
+
+	return c.Context()
+}
+
+// ContextStorage is implemented by Storage accepting a context, so operations
+// made for a request respect its cancellation and deadline, and carry its
+// tracing context. Operations made outside of requests, eg. flushing
+// analytics, use context.Background().
+type ContextStorage interface {
+	GetWithContext(ctx context.Context, key string) ([]byte, error)
+	SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error
+	DeleteWithContext(ctx context.Context, key string) error
+}
+
+// bindStorage returns storage with its operations bound to ctx, if it is a
+// ContextStorage
+func bindStorage(storage fiber.Storage, ctx context.Context) fiber.Storage {
+	cs, ok := storage.(ContextStorage)
+	if !ok {
+		return storage
+	}
+
+	s := &boundStorage{Storage: storage, storage: cs, ctx: ctx}
+	if runner, ok := storage.(ScriptRunner); ok {
+		return &boundScriptStorage{boundStorage: s, runner: runner}
+	}
+
+	return s
+}
+
+// boundStorage wraps a ContextStorage, passing ctx to its operations
+type boundStorage struct {
+	fiber.Storage
+	storage ContextStorage
+	ctx     context.Context
+}
+
+func (s *boundStorage) Get(key string) ([]byte, error) {
+	return s.storage.GetWithContext(s.ctx, key)
+}
+
+func (s *boundStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.storage.SetWithContext(s.ctx, key, val, exp)
+}
+
+func (s *boundStorage) Delete(key string) error {
+	return s.storage.DeleteWithContext(s.ctx, key)
+}
+
+// boundScriptStorage wraps a ContextStorage implementing ScriptRunner
+type boundScriptStorage struct {
+	*boundStorage
+	runner ScriptRunner
+}
+
+func (s *boundScriptStorage) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.runner.Eval(script, keys, args...)
+}
@@ -0,0 +1,118 @@
+package signed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+type traceKey struct{}
+
+// contextTestStorage is a ContextStorage recording the traces it is called with
+type contextTestStorage struct {
+	*testStorage
+	mu     sync.Mutex
+	traces []interface{}
+}
+
+func (s *contextTestStorage) record(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traces = append(s.traces, ctx.Value(traceKey{}))
+}
+
+func (s *contextTestStorage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	s.record(ctx)
+	return s.Get(key)
+}
+
+func (s *contextTestStorage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	s.record(ctx)
+	return s.Set(key, val, exp)
+}
+
+func (s *contextTestStorage) DeleteWithContext(ctx context.Context, key string) error {
+	s.record(ctx)
+	return s.Delete(key)
+}
+
+func TestContext(t *testing.T) {
+	// Initalize config
+	var keyTraces []interface{}
+	storage := &contextTestStorage{testStorage: newTestStorage()}
+	app := fiber.New()
+
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals(UserContextLocal, context.WithValue(context.Background(), traceKey{}, c.Get("X-Trace")))
+		return c.Next()
+	})
+
+	app.Use(New(Config{
+		GetPrivateKeyContextFunc: func(ctx context.Context) (string, error) {
+			keyTraces = append(keyTraces, ctx.Value(traceKey{}))
+			if ctx.Value(traceKey{}) == "cancelled" {
+				return "", context.Canceled
+			}
+			return "secret", nil
+		},
+		Storage:     storage,
+		Revocations: true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil).WithContext(context.WithValue(context.Background(), traceKey{}, "signing")))
+
+	t.Run("it should look up keys with the context of the signed request", func(t *testing.T) {
+		utils.AssertEqual(t, []interface{}{"signing"}, keyTraces)
+	})
+
+	t.Run("it should pass the user context to key lookups and storage", func(t *testing.T) {
+		keyTraces, storage.traces = nil, nil
+		var eventTrace interface{}
+		unsubscribe := Subscribe(func(e Event) {
+			eventTrace = e.Context.Value(traceKey{})
+		})
+		defer unsubscribe()
+
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Header.Set("X-Trace", "abc")
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "abc", keyTraces[0])
+		utils.AssertEqual(t, "abc", storage.traces[0])
+		utils.AssertEqual(t, "abc", eventTrace)
+	})
+
+	t.Run("it should pass the user context to every storage operation", func(t *testing.T) {
+		nonceURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?nonce=traced", nil))
+		storage.traces = nil
+
+		req := newTestRequest(http.MethodGet, nonceURL)
+		req.Header.Set("X-Trace", "nonce")
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, true, len(storage.traces) > 1)
+		for _, trace := range storage.traces {
+			utils.AssertEqual(t, "nonce", trace)
+		}
+	})
+
+	t.Run("it should fail closed when the key lookup fails", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Header.Set("X-Trace", "cancelled")
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+	})
+}
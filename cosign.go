@@ -0,0 +1,87 @@
+package signed
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getPrivateKeyByID looks up the private key for keyID from the config,
+// mixing in the pepper if configured
+func (cfg *instance) getPrivateKeyByID(keyID string) (string, error) {
+	var privateKey string
+	if cfg.GetPrivateKeyByIDFunc != nil {
+		privateKey = cfg.GetPrivateKeyByIDFunc(keyID)
+	}
+
+	if privateKey == "" {
+		return "", fmt.Errorf("unknown key id %q", keyID)
+	}
+
+	return cfg.pepperKey(privateKey)
+}
+
+// validateCoSignatures counts the distinct keys with a valid co-signature and
+// confirms the count meets RequiredSignatures
+func (cfg *instance) validateCoSignatures(c *fiber.Ctx, method, baseURL, originalURL string, body []byte) error {
+
+	valid := make(map[string]bool)
+	for _, value := range c.Context().QueryArgs().PeekMulti(cfg.SignatureQueryKey) {
+		// Key IDs may contain ":" but hex signatures never do
+		split := strings.LastIndex(string(value), ":")
+		if split < 0 {
+			continue
+		}
+		keyID, signature := string(value[:split]), string(value[split+1:])
+
+		if valid[keyID] {
+			continue
+		}
+
+		privateKey, err := cfg.getPrivateKeyByID(keyID)
+		if err != nil {
+			continue
+		}
+
+		for _, base := range cfg.getAliasBaseURLs(baseURL) {
+			hashedSignature, _ := cfg.getSignatureWithKey(privateKey, "", method, base, originalURL, body)
+			if hashedSignature == signature {
+				valid[keyID] = true
+				break
+			}
+		}
+	}
+
+	if len(valid) < cfg.RequiredSignatures {
+		return fmt.Errorf("%d of %d required signatures are valid", len(valid), cfg.RequiredSignatures)
+	}
+
+	setLabels(c, string(cfg.Algorithm), func() string {
+		keyIDs := make([]string, 0, len(valid))
+		for keyID := range valid {
+			keyIDs = append(keyIDs, keyID)
+		}
+		sort.Strings(keyIDs)
+		return strings.Join(keyIDs, ",")
+	})
+
+	return nil
+}
+
+// GetCoSignedURLFromHTTPRequest takes an instance of *http.Request and a key
+// ID and returns full URL with an additional signature calculated with that
+// key. Existing co-signatures are preserved, so the URL can be passed between
+// key holders until enough signatures are collected.
+func GetCoSignedURLFromHTTPRequest(r *http.Request, keyID string) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	privateKey, err := cfg.getPrivateKeyByID(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.signHTTPRequestAs(r, privateKey, "", keyID)
+}
@@ -0,0 +1,123 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestCoSignatures(t *testing.T) {
+	// Initalize config
+	keys := map[string]string{"alice": "secret-a", "bob": "secret-b", "carol": "secret-c"}
+
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyByIDFunc: func(keyID string) string { return keys[keyID] },
+		RequiredSignatures:    2,
+	}))
+
+	app.Get("/admin/purge", func(c *fiber.Ctx) error {
+		return c.SendString("Purged!")
+	})
+
+	coSign := func(target, keyID string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, err := GetCoSignedURLFromHTTPRequest(req, keyID)
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should succeed when enough distinct keys have signed", func(t *testing.T) {
+		signedURL := coSign(coSign("http://example.com/admin/purge?all=true", "alice"), "carol")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Purged!", string(body))
+	})
+
+	t.Run("it should not succeed with too few signatures", func(t *testing.T) {
+		signedURL := coSign("http://example.com/admin/purge?all=true", "alice")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "1 of 2 required signatures are valid", string(body))
+	})
+
+	t.Run("it should not count the same key twice", func(t *testing.T) {
+		signedURL := coSign(coSign("http://example.com/admin/purge?all=true", "alice"), "alice")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "1 of 2 required signatures are valid", string(body))
+	})
+
+	t.Run("it should not count signatures over a modified URL", func(t *testing.T) {
+		signedURL := coSign(coSign("http://example.com/admin/purge?all=false", "alice"), "bob")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&all=true"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "0 of 2 required signatures are valid", string(body))
+	})
+
+	t.Run("it should not co-sign with an unknown key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/admin/purge", nil)
+		_, err := GetCoSignedURLFromHTTPRequest(req, "mallory")
+		utils.AssertEqual(t, `unknown key id "mallory"`, err.Error())
+	})
+
+	t.Run("it should apply the signing pipeline once, for the first signature", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyByIDFunc: func(keyID string) string { return keys[keyID] },
+			RequiredSignatures:    2,
+			TTLPolicies:           map[string]time.Duration{"purge": time.Hour},
+			StampIssued:           true,
+		})
+
+		var signed []Event
+		unsubscribe := Subscribe(func(e Event) {
+			if e.Type == EventSigned {
+				signed = append(signed, e)
+			}
+		})
+
+		first := coSign("http://example.com/admin/purge?purpose=purge", "alice")
+		u, _ := url.Parse(first)
+		utils.AssertEqual(t, true, u.Query().Get("expires") != "")
+		utils.AssertEqual(t, true, u.Query().Get("issued") != "")
+
+		signedURL := coSign(first, "bob")
+		unsubscribe()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		utils.AssertEqual(t, 2, len(signed))
+		utils.AssertEqual(t, "bob", signed[1].KeyID)
+		utils.AssertEqual(t, true, signed[1].Context != nil)
+	})
+
+	t.Run("it should not co-sign reserved params or urls already signed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/admin/purge?caveat=path:/", nil)
+		_, err := GetCoSignedURLFromHTTPRequest(req, "alice")
+		utils.AssertEqual(t, "caveat is a reserved query parameter when generating signed routes", err.Error())
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/admin/purge?signature=abc", nil)
+		_, err = GetCoSignedURLFromHTTPRequest(req, "alice")
+		utils.AssertEqual(t, "cannot co-sign a URL which is already signed", err.Error())
+	})
+}
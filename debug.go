@@ -0,0 +1,50 @@
+package signed
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// DebugCanonicalHeader carries the canonical string computed for rejected
+	// requests when Debug is enabled, with the private key redacted
+	DebugCanonicalHeader = "X-Fiber-Signed-Canonical"
+
+	// DebugCanonicalHashHeader carries the hash of the redacted canonical
+	// string, for quickly comparing against the client's own
+	DebugCanonicalHashHeader = "X-Fiber-Signed-Canonical-Hash"
+
+	// debugRedactedKey replaces the private key in debug canonical strings
+	debugRedactedKey = "REDACTED"
+)
+
+// setDebugHeaders adds the redacted canonical string and its hash for the
+// request to the response, so client integrators can diff it against the
+// string they signed
+func (cfg *instance) setDebugHeaders(c *fiber.Ctx) {
+
+	// Token mode signs claims rather than the canonical string
+	if c.Query(cfg.TokenQueryKey) != "" {
+		return
+	}
+
+	binding, err := cfg.getBinding(c)
+	if err != nil {
+		return
+	}
+
+	body, err := cfg.signedBody(c.Method(), ctxHeader(c), c.Body(), true)
+	if err != nil {
+		return
+	}
+
+	canonical, err := cfg.getSigningString(debugRedactedKey, binding, c.Method(), c.BaseURL(), c.OriginalURL(), body)
+	if err != nil {
+		return
+	}
+
+	// Quote so encoding differences (and control characters) are visible
+	c.Set(DebugCanonicalHeader, strconv.QuoteToASCII(canonical))
+	c.Set(DebugCanonicalHashHeader, cfg.getHash(canonical))
+}
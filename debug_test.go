@@ -0,0 +1,55 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	newApp := func(debug bool) *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Debug:             debug,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	t.Run("it should expose the redacted canonical string on rejection", func(t *testing.T) {
+		app := newApp(true)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "/?b=2&a=1&signature=bad"))
+
+		canonical := "GET&http://example.com/?a=1&b=2&privateKey=REDACTED"
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, strconv.QuoteToASCII(canonical), resp.Header.Get(DebugCanonicalHeader))
+		utils.AssertEqual(t, current().getHash(canonical), resp.Header.Get(DebugCanonicalHashHeader))
+	})
+
+	t.Run("it should not expose the canonical string on success", func(t *testing.T) {
+		app := newApp(true)
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(DebugCanonicalHeader))
+	})
+
+	t.Run("it should not expose the canonical string unless enabled", func(t *testing.T) {
+		app := newApp(false)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "/?a=1&signature=bad"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(DebugCanonicalHeader))
+	})
+}
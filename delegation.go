@@ -0,0 +1,113 @@
+package signed
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Delegation defines the constraints of a grant allowing a sub-key to sign
+// URLs on behalf of its parent key
+type Delegation struct {
+	// ID identifies the grant, eg. the name of the edge service holding it
+	ID string `json:"id,omitempty"`
+
+	// Expires is the UNIX timestamp after which URLs signed under the grant
+	// are no longer valid
+	Expires int64 `json:"expires,omitempty"`
+
+	// Resource is a request path pattern URLs signed under the grant must
+	// match, where "*" matches any sequence of characters
+	Resource string `json:"resource,omitempty"`
+}
+
+// NewDelegation encodes a grant and derives the sub-key for it from
+// parentKey. Pass the private key to delegate from the root, or a sub-key to
+// extend an existing chain. The grant is public and travels with every URL;
+// the sub-key must be handed to the delegate privately.
+func NewDelegation(parentKey string, d Delegation) (string, string, error) {
+	cfg := current()
+
+	if parentKey == "" {
+		return "", "", errors.New("parent key is required to create a delegation")
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", "", err
+	}
+	grant := base64.RawURLEncoding.EncodeToString(b)
+
+	return grant, cfg.deriveSubKey(parentKey, grant), nil
+}
+
+// deriveSubKey returns the sub-key bound to grant under parentKey
+func (cfg *instance) deriveSubKey(parentKey, grant string) string {
+	mac := hmac.New(cfg.getHashFunc(), []byte(parentKey))
+	mac.Write([]byte(grant))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// deriveDelegatedKey walks the chain of grants from privateKey and returns the
+// final sub-key along with the decoded grants
+func (cfg *instance) deriveDelegatedKey(privateKey string, grants []string) (string, []Delegation, error) {
+	delegations := make([]Delegation, 0, len(grants))
+	for _, grant := range grants {
+		var d Delegation
+		b, err := base64.RawURLEncoding.DecodeString(grant)
+		if err != nil || json.Unmarshal(b, &d) != nil {
+			return "", nil, fmt.Errorf("%s value must be a valid delegation grant", cfg.DelegationQueryKey)
+		}
+		delegations = append(delegations, d)
+		privateKey = cfg.deriveSubKey(privateKey, grant)
+	}
+
+	return privateKey, delegations, nil
+}
+
+// validateDelegation enforces the constraints of a single grant against the
+// inbound request
+func validateDelegation(c *fiber.Ctx, d Delegation) error {
+	if d.Expires != 0 && !timeNow().Before(time.Unix(d.Expires, 0)) {
+		return errors.New("url delegation has expired")
+	}
+
+	if d.Resource != "" && !matchWildcard(d.Resource, c.Path()) {
+		return errors.New("url delegation does not permit this path")
+	}
+
+	return nil
+}
+
+// GetDelegatedSignedURLFromHTTPRequest takes an instance of *http.Request, a
+// sub-key and the chain of grants it was derived through (root first) and
+// returns full URL with the grants and calculated signature. The root private
+// key is not needed.
+func GetDelegatedSignedURLFromHTTPRequest(r *http.Request, subKey string, grants ...string) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if len(grants) < 1 {
+		return "", errors.New("at least one delegation grant is required")
+	}
+
+	// Throw error if delegation query param is already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.DelegationQueryKey); err != nil {
+		return "", err
+	}
+
+	// Append grants in chain order so they are covered by the signature
+	for _, grant := range grants {
+		q.Add(cfg.DelegationQueryKey, grant)
+	}
+	r.URL.RawQuery = q.Encode()
+
+	// Sign with the sub-key in place of the root private key
+	return cfg.signHTTPRequest(r, subKey, "")
+}
@@ -0,0 +1,95 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestDelegation(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/files/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	grant, subKey, err := NewDelegation("secret", Delegation{
+		ID:       "edge-1",
+		Expires:  time.Now().Add(time.Hour).Unix(),
+		Resource: "/files/*",
+	})
+	utils.AssertEqual(t, nil, err)
+
+	t.Run("it should succeed with a URL signed by a delegated sub-key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/files/a.txt", nil)
+		signedURL, err := GetDelegatedSignedURLFromHTTPRequest(req, subKey, grant)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	t.Run("it should succeed with a chain of delegations", func(t *testing.T) {
+		childGrant, childKey, _ := NewDelegation(subKey, Delegation{Resource: "/files/a.txt"})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/files/a.txt", nil)
+		signedURL, _ := GetDelegatedSignedURLFromHTTPRequest(req, childKey, grant, childGrant)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should not succeed with a sub-key from another root", func(t *testing.T) {
+		forgedGrant, forgedKey, _ := NewDelegation("not the secret", Delegation{Resource: "/files/*"})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/files/a.txt", nil)
+		signedURL, _ := GetDelegatedSignedURLFromHTTPRequest(req, forgedKey, forgedGrant)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "invalid signature", string(body))
+	})
+
+	t.Run("it should enforce delegation constraints", func(t *testing.T) {
+		expiredGrant, expiredKey, _ := NewDelegation("secret", Delegation{Expires: time.Now().Add(-time.Hour).Unix()})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/files/a.txt", nil)
+		signedURL, _ := GetDelegatedSignedURLFromHTTPRequest(req, expiredKey, expiredGrant)
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url delegation has expired", string(body))
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/other", nil)
+		signedURL, _ = GetDelegatedSignedURLFromHTTPRequest(req, subKey, grant)
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ = ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url delegation does not permit this path", string(body))
+	})
+
+	t.Run("it should not allow requests to contain protected query param 'delegation'", func(t *testing.T) {
+		expected := "delegation is a reserved query parameter when generating signed routes"
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?delegation=something", nil)
+		_, err := GetSignedURLFromHTTPRequest(req)
+		utils.AssertEqual(t, expected, err.Error())
+	})
+}
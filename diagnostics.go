@@ -0,0 +1,67 @@
+package signed
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DiagnosticsHeader carries metadata about the signature of accepted requests
+// when Diagnostics is enabled, eg. `alg="SHA-1", kid="1a2b3c4d5e6f7a8b",
+// expires-in=3599`
+const DiagnosticsHeader = "X-Fiber-Signed-Diagnostics"
+
+// getEarliestExpiry returns the earliest of the expiries of a request, looking
+// up its query params with query, reporting whether it has one
+func (cfg *instance) getEarliestExpiry(query func(string) string) (time.Time, bool) {
+	params := []ExpiryParam{
+		{Key: cfg.ExpiresQueryKey},
+		{Key: cfg.ExpiresInQueryKey, RelativeTo: cfg.IssuedQueryKey},
+	}
+
+	var earliest time.Time
+	found := false
+	for _, p := range append(params, cfg.ExpiryParams...) {
+		when, ok, err := getExpiry(query, p)
+		if err != nil || !ok {
+			continue
+		}
+		if !found || when.Before(earliest) {
+			earliest, found = when, true
+		}
+	}
+
+	return earliest, found
+}
+
+// setDiagnosticsHeader describes the signature of an accepted request, so
+// client developers and support can see when and why a link will stop working
+func (cfg *instance) setDiagnosticsHeader(c *fiber.Ctx) {
+	var fields []string
+
+	if c.Query(cfg.TokenQueryKey) != "" {
+		fields = append(fields, fmt.Sprintf("alg=%q", cfg.TokenAlgorithm))
+		if cfg.KeyID != "" {
+			fields = append(fields, fmt.Sprintf("kid=%q", cfg.KeyID))
+		}
+	} else {
+		alg, _, _ := cfg.getURLAlgorithm(c)
+		fields = append(fields, fmt.Sprintf("alg=%q", alg))
+		if privateKey, err := cfg.getPrivateKey(requestContext(c)); err == nil {
+			fields = append(fields, fmt.Sprintf("kid=%q", KeyFingerprint(privateKey)))
+		}
+
+		if when, ok := cfg.getEarliestExpiry(ctxQuery(c)); ok {
+			// Round up, so links are never reported as expiring early
+			remaining := (when.Sub(timeNow()) + time.Second - 1) / time.Second
+			fields = append(fields, fmt.Sprintf("expires-in=%d", remaining))
+		}
+		if IsSoftExpired(c) {
+			fields = append(fields, "soft-expired")
+		}
+	}
+
+	c.Set(DiagnosticsHeader, strings.Join(fields, ", "))
+}
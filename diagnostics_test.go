@@ -0,0 +1,65 @@
+package signed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestDiagnosticsHeader(t *testing.T) {
+	newApp := func(diagnostics bool) *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Algorithm:         AlgorithmSHA256,
+			Diagnostics:       diagnostics,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	sign := func(target string) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	// Stop the clock, so expiries aren't reported a second early
+	now := time.Now()
+	restore := InjectFaults(Faults{Now: now})
+	defer restore()
+
+	t.Run("it should describe the signature of accepted requests", func(t *testing.T) {
+		app := newApp(true)
+		signedURL := sign(fmt.Sprintf("http://example.com/?expires=%d&softExpires=%d", now.Unix()+3600, now.Add(-time.Minute).Unix()))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, fmt.Sprintf(`alg="SHA-256", kid="%s", expires-in=3600, soft-expired`, KeyFingerprint("secret")), resp.Header.Get(DiagnosticsHeader))
+	})
+
+	t.Run("it should report the earliest expiry", func(t *testing.T) {
+		app := newApp(true)
+		signedURL, _ := GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/?expires=%d", now.Add(2*time.Hour).Unix()), nil), time.Minute)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fmt.Sprintf(`alg="SHA-256", kid="%s", expires-in=60`, KeyFingerprint("secret")), resp.Header.Get(DiagnosticsHeader))
+	})
+
+	t.Run("it should not describe signatures unless enabled", func(t *testing.T) {
+		app := newApp(false)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/")))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(DiagnosticsHeader))
+	})
+}
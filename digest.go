@@ -0,0 +1,36 @@
+package signed
+
+import (
+	"github.com/bsandusky/fiber-signed/internal/canon"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Digest headers of RFC 9530
+const (
+	HeaderContentDigest = "Content-Digest"
+	HeaderReprDigest    = "Repr-Digest"
+)
+
+// signedBody returns the body hashed into signatures for a request: the
+// digest header (when ContentDigest is set and the request carries one) in
+// place of the body, which is checked against it, or the canonical decoded
+// body. Signers may leave body empty to sign the digest alone; verifiers
+// always check it.
+func (cfg *instance) signedBody(method string, header func(key string) string, body []byte, verify bool) ([]byte, error) {
+	if !cfg.ContentDigest || !canon.HasDigest(header) {
+		decoded, err := cfg.decodeBody(header(fiber.HeaderContentEncoding), body)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.canonicalBody(method, header(fiber.HeaderContentType), decoded)
+	}
+
+	return canon.DigestBody(header, body, verify || len(body) > 0)
+}
+
+// ctxHeader returns a lookup of the request headers of c for signedBody
+func ctxHeader(c *fiber.Ctx) func(string) string {
+	return func(key string) string {
+		return c.Get(key)
+	}
+}
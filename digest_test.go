@@ -0,0 +1,81 @@
+package signed
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestContentDigest(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ContentDigest:     true,
+	}))
+
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	digestOf := func(body string) string {
+		sum := sha256.Sum256([]byte(body))
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}
+
+	newRequest := func(target, body, header, digest string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.RequestURI = ""
+		if digest != "" {
+			req.Header.Set(header, digest)
+		}
+		return req
+	}
+
+	// Sign the digest alone, without the body
+	signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/upload", "", HeaderContentDigest, digestOf("large upload")))
+
+	t.Run("it should accept bodies matching the signed digest", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, "large upload", HeaderContentDigest, digestOf("large upload")))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should accept Repr-Digest headers", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/upload", "", HeaderReprDigest, digestOf("large upload")))
+		resp, _ := app.Test(newRequest(signedURL, "large upload", HeaderReprDigest, digestOf("large upload")))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject bodies not matching the digest", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, "tampered upload", HeaderContentDigest, digestOf("large upload")))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject digests other than the signed one", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, "tampered upload", HeaderContentDigest, digestOf("tampered upload")))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject digests in unsupported algorithms only", func(t *testing.T) {
+		resp, _ := app.Test(newRequest(signedURL, "large upload", HeaderContentDigest, "md5=:AAAA:"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should refuse to sign bodies not matching the digest", func(t *testing.T) {
+		_, err := GetSignedURLFromHTTPRequest(newRequest("http://example.com/upload", "tampered upload", HeaderContentDigest, digestOf("large upload")))
+
+		utils.AssertEqual(t, "body does not match digest header", err.Error())
+	})
+}
@@ -0,0 +1,58 @@
+package signed
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrDuplicateRegistration is passed to DuplicateRegistration when a request
+// passes through handlers returned by separate calls to New. The package
+// keeps one config, so only the config of the last call is in effect.
+var ErrDuplicateRegistration = errors.New("middleware registered more than once on the route with separate configs, only the last config is in effect")
+
+// verifiedLocal holds the generation of the handler which verified the request
+const verifiedLocal = "fiber-signed:verified"
+
+// generations counts the calls to New, telling their handlers apart
+var generations uint64
+
+// duplicateWarning logs ErrDuplicateRegistration once by default
+var duplicateWarning sync.Once
+
+// nextGeneration returns the generation of the handler of a new call to New
+func nextGeneration() uint64 {
+	return atomic.AddUint64(&generations, 1)
+}
+
+// checkDuplicate reports whether the request was already verified by the
+// handler of generation, and calls DuplicateRegistration if it was verified
+// by the handler of another call to New, returning its error
+func (cfg *instance) checkDuplicate(c *fiber.Ctx, generation uint64) (bool, error) {
+	verified, ok := c.Locals(verifiedLocal).(uint64)
+	if !ok {
+		return false, nil
+	}
+	if verified == generation {
+		return true, nil
+	}
+
+	if err := cfg.DuplicateRegistration(ErrDuplicateRegistration); err != nil {
+		return false, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return false, nil
+}
+
+// warnDuplicateRegistration is the default DuplicateRegistration, logging the
+// first duplicate
+func warnDuplicateRegistration(err error) error {
+	duplicateWarning.Do(func() {
+		log.Printf("fiber-signed: %v", err)
+	})
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package signed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestDuplicateRegistration(t *testing.T) {
+	t.Run("it should report handlers of separate calls to New", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		var reported []error
+		config := Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			DuplicateRegistration: func(err error) error {
+				reported = append(reported, err)
+				return nil
+			},
+		}
+
+		app.Use(New(config))
+		app.Get("/", New(config), func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/"))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, []error{ErrDuplicateRegistration}, reported)
+	})
+
+	t.Run("it should reject requests if DuplicateRegistration returns an error", func(t *testing.T) {
+		app := fiber.New()
+
+		config := Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			DuplicateRegistration: func(err error) error {
+				return errors.New("conflicting fiber-signed configs")
+			},
+		}
+
+		app.Use(New(config))
+		app.Get("/", New(config), func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/"))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+		utils.AssertEqual(t, "conflicting fiber-signed configs", string(body))
+	})
+
+	t.Run("it should verify requests passing through one handler twice once", func(t *testing.T) {
+		app := fiber.New()
+
+		storage := newTestStorage()
+		handler := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			DuplicateRegistration: func(err error) error {
+				return err
+			},
+		})
+
+		app.Use(handler)
+		app.Get("/", handler, func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?maxUses=1"))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
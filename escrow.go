@@ -0,0 +1,60 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+	"github.com/gofiber/fiber/v2"
+)
+
+// getEscrowKey combines privateKey with a requester's key fragment into the
+// key escrowed URLs are signed with
+func getEscrowKey(privateKey, fragment string) string {
+	return canon.EscrowKey(privateKey, fragment)
+}
+
+// getEscrowFragment returns the key fragment of the request, from
+// EscrowHeader, or "" if EscrowHeader is not set
+func (cfg *instance) getEscrowFragment(c *fiber.Ctx) (string, error) {
+	if cfg.EscrowHeader == "" {
+		return "", nil
+	}
+
+	fragment := c.Get(cfg.EscrowHeader)
+	if fragment == "" {
+		return "", fmt.Errorf("%s header is required for a signed URL route", cfg.EscrowHeader)
+	}
+
+	return fragment, nil
+}
+
+// GetEscrowedSignedURLFromHTTPRequest takes an instance of *http.Request and
+// a requester's key fragment and returns full URL with signature calculated
+// with a key combining the private key and fragment. Requests must carry
+// fragment in EscrowHeader, so URLs leaked from storage are useless without
+// it.
+func GetEscrowedSignedURLFromHTTPRequest(r *http.Request, fragment string) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if cfg.EscrowHeader == "" {
+		return "", errors.New("EscrowHeader must be configured to escrow signed URLs")
+	}
+	if fragment == "" {
+		return "", errors.New("cannot escrow signed URL with an empty key fragment")
+	}
+
+	// Delegation grants would change the key used to validate the signature
+	if err := cfg.checkSigningParams(urlQuery(r.URL), cfg.DelegationQueryKey); err != nil {
+		return "", err
+	}
+
+	privateKey, err := cfg.getPrivateKey(r.Context())
+	if err != nil {
+		return "", err
+	}
+	events.observeKey(privateKey)
+
+	return cfg.signHTTPRequest(r, getEscrowKey(privateKey, fragment), "")
+}
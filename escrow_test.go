@@ -0,0 +1,74 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestEscrow(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		EscrowHeader:       "X-Key-Fragment",
+		ValidationCacheTTL: time.Minute,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, err := GetEscrowedSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?user=42"), "fragment-of-42")
+	utils.AssertEqual(t, nil, err)
+
+	request := func(fragment string) *http.Response {
+		req := newTestRequest(http.MethodGet, signedURL)
+		if fragment != "" {
+			req.Header.Set("X-Key-Fragment", fragment)
+		}
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	t.Run("it should accept requests carrying the key fragment", func(t *testing.T) {
+		resp := request("fragment-of-42")
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject requests without the key fragment", func(t *testing.T) {
+		resp := request("")
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "X-Key-Fragment header is required for a signed URL route", string(body))
+	})
+
+	t.Run("it should reject requests with another key fragment", func(t *testing.T) {
+		// Even right after a cached validation with the right one
+		resp := request("fragment-of-43")
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject URLs signed with the private key alone", func(t *testing.T) {
+		plainURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?user=42"))
+		req := newTestRequest(http.MethodGet, plainURL)
+		req.Header.Set("X-Key-Fragment", "fragment-of-42")
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should refuse to escrow with an empty fragment", func(t *testing.T) {
+		_, err := GetEscrowedSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/"), "")
+
+		utils.AssertEqual(t, "cannot escrow signed URL with an empty key fragment", err.Error())
+	})
+}
@@ -0,0 +1,63 @@
+package signed
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// normalizeETag returns etag without quotes or a weak validator prefix, so
+// "W/\"abc\"", "\"abc\"" and abc compare equal
+func normalizeETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// checkETag rejects requests whose URL was issued for another version of the
+// resource than ETagFunc returns
+func (cfg *instance) checkETag(c *fiber.Ctx) error {
+	etag := c.Query(cfg.ETagQueryKey)
+	if etag == "" {
+		return nil
+	}
+
+	if cfg.ETagFunc == nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "ETagFunc must be configured to validate ETag bound URLs")
+	}
+
+	current, err := cfg.ETagFunc(c)
+	if err != nil {
+		return err
+	}
+	if normalizeETag(current) != etag {
+		return fiber.NewError(fiber.StatusPreconditionFailed, "resource has changed since the url was signed")
+	}
+
+	return nil
+}
+
+// GetSignedURLForETagFromHTTPRequest takes an instance of *http.Request and
+// the ETag of the resource it requests and returns full URL with calculated
+// signature, which is only valid while ETagFunc returns the same ETag
+func GetSignedURLForETagFromHTTPRequest(r *http.Request, etag string) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	etag = normalizeETag(etag)
+	if etag == "" {
+		return "", errors.New("cannot bind signed URL to an empty ETag")
+	}
+
+	// Throw error if etag query param is already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.ETagQueryKey); err != nil {
+		return "", err
+	}
+
+	q.Set(cfg.ETagQueryKey, etag)
+	r.URL.RawQuery = q.Encode()
+
+	return GetSignedURLFromHTTPRequest(r)
+}
@@ -0,0 +1,53 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestETag(t *testing.T) {
+	// Initalize config
+	version := `"v1"`
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ETagFunc: func(c *fiber.Ctx) (string, error) {
+			return version, nil
+		},
+	}))
+
+	app.Get("/artifact.tar.gz", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLForETagFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/artifact.tar.gz", nil), `W/"v1"`)
+
+	t.Run("it should serve the version the url was issued for", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject urls issued for another version", func(t *testing.T) {
+		version = `"v2"`
+		defer func() { version = `"v1"` }()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+		utils.AssertEqual(t, "resource has changed since the url was signed", string(body))
+	})
+
+	t.Run("it should not sign urls already carrying an etag", func(t *testing.T) {
+		_, err := GetSignedURLForETagFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?etag=v0", nil), "v1")
+
+		utils.AssertEqual(t, "etag is a reserved query parameter when generating signed routes", err.Error())
+	})
+}
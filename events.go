@@ -0,0 +1,203 @@
+package signed
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EventType defines the kinds of events emitted during the signing lifecycle
+type EventType string
+
+// Event type values
+const (
+	// EventSigned is emitted when a signed URL is generated
+	EventSigned EventType = "signed"
+
+	// EventVerified is emitted when a request passes validation
+	EventVerified EventType = "verified"
+
+	// EventRejected is emitted when a request fails validation
+	EventRejected EventType = "rejected"
+
+	// EventRevoked is emitted when a signed URL is revoked
+	EventRevoked EventType = "revoked"
+
+	// EventKeyRotated is emitted the first time a private key with a new
+	// fingerprint is used
+	EventKeyRotated EventType = "keyRotated"
+)
+
+// Event describes something that happened in the signing lifecycle
+type Event struct {
+	// Type is the kind of event
+	Type EventType
+
+	// Time is when the event happened
+	Time time.Time
+
+	// URL is the signed URL for EventSigned and EventRevoked, or the request
+	// URL for EventVerified and EventRejected
+	URL string
+
+	// KeyFingerprint identifies the private key for EventKeyRotated
+	KeyFingerprint string
+
+	// Err is the reason a request was rejected for EventRejected
+	Err error
+
+	// Revocation selects the URLs revoked for EventRevoked
+	Revocation Revocation
+
+	// Algorithm is the algorithm the URL was signed (or verified) with for
+	// EventSigned, EventVerified and EventRejected, eg. "SHA-256", or the
+	// token algorithm in token mode, so dashboards can track migrations. It
+	// is empty for requests rejected before their signature was verified.
+	Algorithm string
+
+	// KeyID identifies the key the URL was signed (or verified) with, like
+	// Algorithm: the key ID of co-signed URLs and tokens, or the fingerprint
+	// of the private key otherwise, so dashboards can track rotations
+	KeyID string
+
+	// Purpose is the purpose signed into the URL for EventSigned and
+	// EventVerified, if any. It is left empty for EventRejected, as the URL
+	// can't be trusted.
+	Purpose string
+
+	// Warnings are the non-fatal problems found with the URL for EventSigned
+	// (see SignedURL.Warnings), so they can be logged wherever URLs are
+	// signed
+	Warnings []Warning
+
+	// RequestID is the ID of the request or trace the URL was issued in (see
+	// ClaimRequestID) for EventSigned and EventVerified, if any
+	RequestID string
+
+	// Expires is when the URL expires for EventVerified, or zero if it
+	// doesn't, so subscribers can record how long URLs have left when they
+	// are used (see LifetimeHistogram)
+	Expires time.Time
+
+	// Issued is when the URL was issued for EventVerified, or zero if it
+	// doesn't carry its issued time, so subscribers can record how old URLs
+	// are when they are used
+	Issued time.Time
+
+	// ClockSkew is how long ago the URL expired for EventRejected, if it
+	// was rejected only for having expired within ClockSkewThreshold, so
+	// dashboards can tell clock skew from genuinely expired URLs
+	ClockSkew time.Duration
+
+	// Context is the context of the request for EventVerified and
+	// EventRejected (see UserContextLocal), or of the signed request for
+	// EventSigned, so subscribers can continue its trace. It may be nil.
+	Context context.Context
+}
+
+// eventBus holds subscribers to events
+type eventBus struct {
+	mu             sync.RWMutex
+	nextID         int
+	subscribers    map[int]func(Event)
+	keyFingerprint string
+}
+
+var events = &eventBus{subscribers: make(map[int]func(Event))}
+
+// Subscribe registers fn to be called synchronously with every event, and
+// returns a function which removes the subscription. Subscribers should hand
+// off slow work (eg. network calls) to their own goroutines.
+func Subscribe(fn func(Event)) func() {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	id := events.nextID
+	events.nextID++
+	events.subscribers[id] = fn
+
+	return func() {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		delete(events.subscribers, id)
+	}
+}
+
+// hasSubscribers reports whether any subscriber is registered, so callers can
+// skip preparing events nobody will receive
+func (b *eventBus) hasSubscribers() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers) > 0
+}
+
+// emit delivers e to every subscriber
+func (b *eventBus) emit(e Event) {
+	b.mu.RLock()
+	subscribers := make([]func(Event), 0, len(b.subscribers))
+	for _, fn := range b.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	b.mu.RUnlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
+
+// observeKey emits EventKeyRotated when privateKey differs from the last key
+// observed
+func (b *eventBus) observeKey(privateKey string) {
+	if !b.hasSubscribers() {
+		return
+	}
+
+	fingerprint := KeyFingerprint(privateKey)
+
+	b.mu.Lock()
+	previous := b.keyFingerprint
+	b.keyFingerprint = fingerprint
+	b.mu.Unlock()
+
+	if previous != "" && previous != fingerprint {
+		b.emit(Event{Type: EventKeyRotated, KeyFingerprint: fingerprint})
+	}
+}
+
+// labelsLocal holds the algorithm and key a request was verified with
+const labelsLocal = "fiber-signed:labels"
+
+// eventLabels are the algorithm and key a request was verified with
+type eventLabels struct {
+	algorithm, keyID string
+}
+
+// setLabels records the algorithm and key c was verified with for events, if
+// anyone subscribes to them. keyID is only called then.
+func setLabels(c *fiber.Ctx, algorithm string, keyID func() string) {
+	if events.hasSubscribers() {
+		c.Locals(labelsLocal, eventLabels{algorithm: algorithm, keyID: keyID()})
+	}
+}
+
+// labelRequestEvent sets the labels recorded for c on e
+func (cfg *instance) labelRequestEvent(c *fiber.Ctx, e *Event) {
+	if labels, ok := c.Locals(labelsLocal).(eventLabels); ok {
+		e.Algorithm, e.KeyID = labels.algorithm, labels.keyID
+	}
+	if skew, ok := c.Locals(clockSkewLocal).(time.Duration); ok {
+		e.ClockSkew = skew
+	}
+	if e.Type == EventVerified {
+		e.Purpose = cfg.getClaim(c, ClaimPurpose)
+		e.RequestID = cfg.getClaim(c, ClaimRequestID)
+		e.Time = timeNow()
+		e.Expires, e.Issued = cfg.getURLLifetime(c)
+	}
+}
@@ -0,0 +1,134 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestEvents(t *testing.T) {
+	// Initalize config
+	privateKey := "secret"
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return privateKey },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	var received []Event
+	unsubscribe := Subscribe(func(e Event) {
+		received = append(received, e)
+	})
+
+	t.Run("it should emit signed and verified events", func(t *testing.T) {
+		received = nil
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, 2, len(received))
+		utils.AssertEqual(t, EventSigned, received[0].Type)
+		utils.AssertEqual(t, signedURL, received[0].URL)
+		utils.AssertEqual(t, EventVerified, received[1].Type)
+		utils.AssertEqual(t, signedURL, received[1].URL)
+	})
+
+	t.Run("it should emit rejected events with the reason", func(t *testing.T) {
+		received = nil
+
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		utils.AssertEqual(t, 1, len(received))
+		utils.AssertEqual(t, EventRejected, received[0].Type)
+		utils.AssertEqual(t, "invalid signature", received[0].Err.Error())
+	})
+
+	t.Run("it should emit key rotated events when the key changes", func(t *testing.T) {
+		received = nil
+
+		privateKey = "rotated"
+		defer func() { privateKey = "secret" }()
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		utils.AssertEqual(t, 2, len(received))
+		utils.AssertEqual(t, EventKeyRotated, received[0].Type)
+		utils.AssertEqual(t, KeyFingerprint("rotated"), received[0].KeyFingerprint)
+	})
+
+	t.Run("it should stop delivering events after unsubscribing", func(t *testing.T) {
+		received = nil
+
+		unsubscribe()
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		utils.AssertEqual(t, 0, len(received))
+	})
+}
+
+func TestEventLabels(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmSHA256,
+		PreviousAlgorithm: AlgorithmSHA1,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	var received []Event
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Type != EventKeyRotated {
+			received = append(received, e)
+		}
+	})
+	defer unsubscribe()
+
+	t.Run("it should label events with the algorithm, key and purpose", func(t *testing.T) {
+		received = nil
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=unsubscribe", nil))
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, 2, len(received))
+		for _, e := range received {
+			utils.AssertEqual(t, string(AlgorithmSHA256), e.Algorithm)
+			utils.AssertEqual(t, KeyFingerprint("secret"), e.KeyID)
+			utils.AssertEqual(t, "unsubscribe", e.Purpose)
+		}
+	})
+
+	t.Run("it should label URLs verified with the previous algorithm", func(t *testing.T) {
+		current().Algorithm = AlgorithmSHA1
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		current().Algorithm = AlgorithmSHA256
+
+		received = nil
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, 1, len(received))
+		utils.AssertEqual(t, EventVerified, received[0].Type)
+		utils.AssertEqual(t, string(AlgorithmSHA1), received[0].Algorithm)
+	})
+
+	t.Run("it should not label rejected URLs with untrusted params", func(t *testing.T) {
+		received = nil
+
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?purpose=unsubscribe&signature=wrong"))
+
+		utils.AssertEqual(t, 1, len(received))
+		utils.AssertEqual(t, "", received[0].Algorithm)
+		utils.AssertEqual(t, "", received[0].Purpose)
+	})
+}
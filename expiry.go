@@ -0,0 +1,185 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExpiryParam describes an additional query param to read an expiry from,
+// eg. when migrating URLs from other signing schemes
+type ExpiryParam struct {
+	// Key is the name of the query param, eg. "X-Amz-Expires"
+	Key string
+
+	// Layout parses absolute expiries with time.Parse. Empty means unix
+	// seconds.
+	Layout string
+
+	// RelativeTo is the query param holding the time the expiry is a number
+	// of seconds after, eg. "X-Amz-Date". Empty means the expiry is absolute.
+	RelativeTo string
+
+	// RelativeToLayout parses the RelativeTo param with time.Parse, eg.
+	// "20060102T150405Z". Empty means unix seconds.
+	RelativeToLayout string
+}
+
+// parseTime parses value with layout, or as unix seconds if layout is empty
+func parseTime(value, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, value)
+	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(i, 0), nil
+}
+
+// getExpiry returns the expiry of the request according to p, reporting
+// whether the request has one. query looks up the query params of the request.
+func getExpiry(query func(string) string, p ExpiryParam) (time.Time, bool, error) {
+	value := query(p.Key)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+
+	if p.RelativeTo == "" {
+		when, err := parseTime(value, p.Layout)
+		if err != nil {
+			return time.Time{}, true, fmt.Errorf("%s value must be a valid time", p.Key)
+		}
+		return when, true, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("%s value must be valid integer", p.Key)
+	}
+	from, err := parseTime(query(p.RelativeTo), p.RelativeToLayout)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("%s value must be a valid time", p.RelativeTo)
+	}
+
+	return from.Add(time.Duration(seconds) * time.Second), true, nil
+}
+
+// ctxQuery returns a lookup of the query params of c for getExpiry
+func ctxQuery(c *fiber.Ctx) func(string) string {
+	return func(key string) string {
+		return c.Query(key)
+	}
+}
+
+// validateExpiryParams rejects requests which have expired according to their
+// relative expiry or any of the configured ExpiryParams
+func (cfg *instance) validateExpiryParams(c *fiber.Ctx) error {
+	relative := ExpiryParam{Key: cfg.ExpiresInQueryKey, RelativeTo: cfg.IssuedQueryKey}
+
+	for _, p := range append([]ExpiryParam{relative}, cfg.ExpiryParams...) {
+		when, ok, err := getExpiry(ctxQuery(c), p)
+		if err != nil {
+			return err
+		}
+		if ok && when.Before(timeNow()) {
+			return errExpired
+		}
+	}
+
+	return nil
+}
+
+// checkQueryExpiry checks the 'expires' query param of the request and the
+// expiries in the formats of other signing schemes, returning errExpired if
+// any has passed
+func (cfg *instance) checkQueryExpiry(c *fiber.Ctx) error {
+	if expires := c.Query(cfg.ExpiresQueryKey); expires != "" {
+		i, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s value must be valid integer", cfg.ExpiresQueryKey)
+		}
+		if time.Unix(i, 0).Before(timeNow()) {
+			return errExpired
+		}
+	}
+
+	return cfg.validateExpiryParams(c)
+}
+
+// GetSignedURLWithTTLFromHTTPRequest takes an instance of *http.Request and
+// returns full URL with calculated signature, expiring ttl after it is issued.
+// The URL carries its issued time and ttl rather than an absolute expiry.
+func GetSignedURLWithTTLFromHTTPRequest(r *http.Request, ttl time.Duration) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if err := cfg.setTTLParams(r, ttl); err != nil {
+		return "", err
+	}
+
+	return GetSignedURLFromHTTPRequest(r)
+}
+
+// setTTLParams adds the relative expiry params for ttl to the query of r
+func (cfg *instance) setTTLParams(r *http.Request, ttl time.Duration) error {
+	if ttl < time.Second {
+		return errors.New("ttl must be at least one second")
+	}
+
+	// Throw error if relative expiry query params are already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.ExpiresInQueryKey, cfg.IssuedQueryKey); err != nil {
+		return err
+	}
+
+	q.Set(cfg.ExpiresInQueryKey, strconv.FormatInt(int64(ttl/time.Second), 10))
+	// StampIssued adds the issued time while signing
+	if !cfg.StampIssued {
+		q.Set(cfg.IssuedQueryKey, strconv.FormatInt(timeNow().Unix(), 10))
+	}
+	r.URL.RawQuery = q.Encode()
+
+	return nil
+}
+
+// SoftExpiredLocal is the local set to true for requests after the soft
+// expiry of their URL
+const SoftExpiredLocal = "fiber-signed:soft-expired"
+
+// checkSoftExpiry flags requests after the soft expiry signed into their URL,
+// so the app can prompt renewal before the URL expires
+func (cfg *instance) checkSoftExpiry(c *fiber.Ctx) error {
+	softExpires := c.Query(cfg.SoftExpiresQueryKey)
+	if softExpires == "" {
+		return nil
+	}
+
+	i, err := strconv.ParseInt(softExpires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s value must be valid integer", cfg.SoftExpiresQueryKey)
+	}
+
+	if timeNow().Before(time.Unix(i, 0)) {
+		return nil
+	}
+
+	c.Locals(SoftExpiredLocal, true)
+	if cfg.SoftExpired != nil {
+		cfg.SoftExpired(c)
+	}
+
+	return nil
+}
+
+// IsSoftExpired reports whether the request is after the soft expiry of its
+// URL, and should be renewed
+func IsSoftExpired(c *fiber.Ctx) bool {
+	expired, _ := c.Locals(SoftExpiredLocal).(bool)
+	return expired
+}
@@ -0,0 +1,166 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSoftExpiry(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	hooked := 0
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		SoftExpired: func(c *fiber.Ctx) {
+			hooked++
+			c.Set("X-Renew", "true")
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(fmt.Sprint(IsSoftExpired(c)))
+	})
+
+	sign := func(soft, hard time.Duration) string {
+		target := fmt.Sprintf("http://example.com/?softExpires=%d&expires=%d", time.Now().Add(soft).Unix(), time.Now().Add(hard).Unix())
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	t.Run("it should not flag requests before the soft expiry", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign(time.Hour, 2*time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "false", string(body))
+		utils.AssertEqual(t, 0, hooked)
+	})
+
+	t.Run("it should flag but accept requests after the soft expiry", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign(-time.Minute, time.Hour)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "true", string(body))
+		utils.AssertEqual(t, "true", resp.Header.Get("X-Renew"))
+		utils.AssertEqual(t, 1, hooked)
+	})
+
+	t.Run("it should reject requests after the hard expiry", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign(-2*time.Minute, -time.Minute)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+		utils.AssertEqual(t, 1, hooked)
+	})
+}
+
+func TestExpiryParams(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ExpiryParams: []ExpiryParam{
+			{Key: "Expires"},
+			{Key: "expiresAt", Layout: time.RFC3339},
+			{Key: "X-Amz-Expires", RelativeTo: "X-Amz-Date", RelativeToLayout: "20060102T150405Z"},
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	amzDate := func(d time.Duration) string {
+		return time.Now().Add(d).UTC().Format("20060102T150405Z")
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		expected string
+	}{
+		{"it should accept an unexpired unix expiry", fmt.Sprintf("Expires=%d", time.Now().Add(time.Hour).Unix()), fiber.StatusOK, "Hello, world!"},
+		{"it should reject an expired unix expiry", fmt.Sprintf("Expires=%d", time.Now().Add(-time.Hour).Unix()), fiber.StatusForbidden, "url signature has expired"},
+		{"it should accept an unexpired layout expiry", "expiresAt=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), fiber.StatusOK, "Hello, world!"},
+		{"it should reject an expired layout expiry", "expiresAt=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), fiber.StatusForbidden, "url signature has expired"},
+		{"it should accept an unexpired relative expiry", "X-Amz-Expires=3600&X-Amz-Date=" + amzDate(-time.Minute), fiber.StatusOK, "Hello, world!"},
+		{"it should reject an expired relative expiry", "X-Amz-Expires=60&X-Amz-Date=" + amzDate(-time.Hour), fiber.StatusForbidden, "url signature has expired"},
+		{"it should reject a relative expiry without a valid base time", "X-Amz-Expires=60", fiber.StatusForbidden, "X-Amz-Date value must be a valid time"},
+		{"it should reject when any expiry has passed", fmt.Sprintf("expires=%d&Expires=%d", time.Now().Add(time.Hour).Unix(), time.Now().Add(-time.Hour).Unix()), fiber.StatusForbidden, "url signature has expired"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?"+tt.query, nil))
+
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, tt.status, resp.StatusCode)
+			utils.AssertEqual(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestGetSignedURLWithTTLFromHTTPRequest(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should accept urls within their ttl", func(t *testing.T) {
+		signedURL, err := GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), time.Hour)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject urls after their ttl", func(t *testing.T) {
+		restore := InjectFaults(Faults{ClockSkew: -2 * time.Hour})
+		signedURL, _ := GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), time.Hour)
+		restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should reject relative expiries without an issued time", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?expiresIn=60", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "issued value must be a valid time", string(body))
+	})
+
+	t.Run("it should not sign urls with an existing issued time", func(t *testing.T) {
+		_, err := GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?issued=1", nil), time.Hour)
+
+		utils.AssertEqual(t, "issued is a reserved query parameter when generating signed routes", err.Error())
+	})
+}
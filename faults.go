@@ -0,0 +1,185 @@
+package signed
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Faults simulates failures inside the middleware, so applications can test
+// their error handling and alerting around URL signing. Only use them in
+// tests.
+type Faults struct {
+	// KeyProviderErr, if set, is returned in place of the private key when
+	// signing, and rejects requests with 500 - Internal Server Error
+	KeyProviderErr error
+
+	// StorageErr, if set, is returned by every Storage operation
+	StorageErr error
+
+	// StorageDelay is added to every Storage operation, eg. to simulate
+	// timeouts
+	StorageDelay time.Duration
+
+	// ClockSkew is added to the middleware's clock, eg. to simulate expiring
+	// URLs or a validator running ahead of signers
+	ClockSkew time.Duration
+
+	// Now, if set, stops the middleware's clock at Now, plus any ClockSkew,
+	// eg. to assert the times signed into URLs
+	Now time.Time
+}
+
+// faults holds the *Faults currently injected, or a nil *Faults. It is read
+// on every request, so it is an atomic.Value rather than behind a lock.
+var faults atomic.Value
+
+func init() {
+	faults.Store((*Faults)(nil))
+}
+
+// InjectFaults simulates f inside the middleware until the returned restore
+// func is called
+func InjectFaults(f Faults) (restore func()) {
+	previous := getFaults()
+	faults.Store(&f)
+
+	return func() {
+		faults.Store(previous)
+	}
+}
+
+// getFaults returns the faults currently injected, or nil if none are
+func getFaults() *Faults {
+	return faults.Load().(*Faults)
+}
+
+// timeNow returns the middleware's clock, including any injected skew
+func timeNow() time.Time {
+	f := getFaults()
+	if f == nil {
+		return time.Now()
+	}
+	if !f.Now.IsZero() {
+		return f.Now.Add(f.ClockSkew)
+	}
+
+	return time.Now().Add(f.ClockSkew)
+}
+
+// getPrivateKey returns the configured private key, looked up with ctx if
+// GetPrivateKeyContextFunc is set, or the injected key provider error. The
+// lookup is guarded by KeyTimeout and the circuit breaker, and skipped while
+// KeyCacheTTL keeps the key warm. The pepper is mixed in if configured.
+// Keys a BatchSigner looked up, already peppered, are returned as they are.
+func (cfg *instance) getPrivateKey(ctx context.Context) (string, error) {
+	if ctx != nil {
+		if privateKey, ok := ctx.Value(batchKeyContextKey{}).(string); ok {
+			return privateKey, nil
+		}
+	}
+	if f := getFaults(); f != nil && f.KeyProviderErr != nil {
+		return "", f.KeyProviderErr
+	}
+	if cfg.KeyCacheTTL > 0 {
+		if privateKey, ok := cfg.keys.get(); ok {
+			return cfg.pepperKey(privateKey)
+		}
+	}
+
+	getPrivateKeyContext, getPrivateKey := cfg.GetPrivateKeyContextFunc, cfg.GetPrivateKeyFunc
+
+	var privateKey string
+	err := cfg.keyBreaker.call(cfg, cfg.KeyTimeout, func() error {
+		if getPrivateKeyContext == nil {
+			privateKey = getPrivateKey()
+			return nil
+		}
+
+		var err error
+		privateKey, err = getPrivateKeyContext(ctx)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.pepperKey(privateKey)
+}
+
+// getStorage returns the configured Storage with its operations bound to ctx,
+// wrapped to inject storage faults when any are set and guarded by
+// StorageTimeout and the circuit breaker
+func (cfg *instance) getStorage(ctx context.Context) fiber.Storage {
+	return cfg.guardStorage(injectStorageFaults(bindStorage(cfg.Storage, ctx)))
+}
+
+// injectStorageFaults wraps storage to inject storage faults when any are set
+func injectStorageFaults(storage fiber.Storage) fiber.Storage {
+	f := getFaults()
+	if storage == nil || f == nil || (f.StorageErr == nil && f.StorageDelay == 0) {
+		return storage
+	}
+
+	s := &faultyStorage{Storage: storage, err: f.StorageErr, delay: f.StorageDelay}
+	if runner, ok := storage.(ScriptRunner); ok {
+		return &faultyScriptStorage{faultyStorage: s, runner: runner}
+	}
+
+	return s
+}
+
+// faultyStorage wraps a Storage, delaying and failing its operations
+type faultyStorage struct {
+	fiber.Storage
+	err   error
+	delay time.Duration
+}
+
+func (s *faultyStorage) fault() error {
+	time.Sleep(s.delay)
+	return s.err
+}
+
+func (s *faultyStorage) Get(key string) ([]byte, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+	return s.Storage.Get(key)
+}
+
+func (s *faultyStorage) Set(key string, val []byte, exp time.Duration) error {
+	if err := s.fault(); err != nil {
+		return err
+	}
+	return s.Storage.Set(key, val, exp)
+}
+
+func (s *faultyStorage) Delete(key string) error {
+	if err := s.fault(); err != nil {
+		return err
+	}
+	return s.Storage.Delete(key)
+}
+
+func (s *faultyStorage) Reset() error {
+	if err := s.fault(); err != nil {
+		return err
+	}
+	return s.Storage.Reset()
+}
+
+// faultyScriptStorage wraps a Storage implementing ScriptRunner
+type faultyScriptStorage struct {
+	*faultyStorage
+	runner ScriptRunner
+}
+
+func (s *faultyScriptStorage) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+	return s.runner.Eval(script, keys, args...)
+}
@@ -0,0 +1,94 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestInjectFaults(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) string {
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should simulate key provider failures", func(t *testing.T) {
+		signedURL := sign("http://example.com/")
+
+		restore := InjectFaults(Faults{KeyProviderErr: errors.New("kms unavailable")})
+
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		utils.AssertEqual(t, "kms unavailable", err.Error())
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+		utils.AssertEqual(t, "kms unavailable", string(body))
+
+		restore()
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should simulate storage failures", func(t *testing.T) {
+		signedURL := sign("http://example.com/?nonce=storage-failure")
+
+		restore := InjectFaults(Faults{StorageErr: errors.New("storage timeout"), StorageDelay: 10 * time.Millisecond})
+		defer restore()
+
+		start := time.Now()
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "storage timeout", string(body))
+		utils.AssertEqual(t, true, time.Since(start) >= 10*time.Millisecond)
+	})
+
+	t.Run("it should simulate clock skew", func(t *testing.T) {
+		signedURL := sign(fmt.Sprintf("http://example.com/?expires=%d", time.Now().Add(time.Minute).Unix()))
+
+		restore := InjectFaults(Faults{ClockSkew: 2 * time.Minute})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should restore the faults injected before", func(t *testing.T) {
+		restoreSkew := InjectFaults(Faults{ClockSkew: time.Hour})
+		restoreNow := InjectFaults(Faults{Now: time.Unix(1000, 0)})
+
+		utils.AssertEqual(t, time.Unix(1000, 0), timeNow())
+
+		restoreNow()
+		utils.AssertEqual(t, time.Hour, getFaults().ClockSkew)
+
+		restoreSkew()
+		utils.AssertEqual(t, (*Faults)(nil), getFaults())
+	})
+}
@@ -0,0 +1,110 @@
+package signed
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+// BatchSigner signs many URLs with a single private key lookup, eg. when
+// generating sitemaps or RSS/Atom feeds. The key is looked up once when it is
+// created, so create one per batch.
+type BatchSigner struct {
+	cfg        *instance
+	privateKey string
+	err        error
+}
+
+// NewBatchSigner creates a BatchSigner with the current private key
+func NewBatchSigner() *BatchSigner {
+	cfg := current()
+
+	privateKey, err := cfg.getPrivateKey(context.Background())
+	if err == nil {
+		events.observeKey(privateKey)
+	}
+
+	return &BatchSigner{cfg: cfg, privateKey: privateKey, err: err}
+}
+
+// Sign returns rawURL signed for GET requests, expiring ttl after it is
+// issued if ttl is not zero
+func (b *BatchSigner) Sign(rawURL string, ttl time.Duration) (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	cfg := b.cfg
+
+	// Sign with the instance and key of the batch, including the fallbacks
+	// for URLs longer than MaxURLLength
+	ctx := context.WithValue(context.Background(), instanceContextKey{}, cfg)
+	ctx = context.WithValue(ctx, batchKeyContextKey{}, b.privateKey)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if ttl != 0 {
+		if err := cfg.setTTLParams(r, ttl); err != nil {
+			return "", err
+		}
+	}
+
+	return cfg.getSignedURL(r)
+}
+
+// batchKeyContextKey is the context key of the private key a BatchSigner
+// looked up, used by getPrivateKey in place of looking it up again
+type batchKeyContextKey struct{}
+
+// Link returns a SignedLink for rawURL, signed by b when marshalled
+func (b *BatchSigner) Link(rawURL string, ttl time.Duration) SignedLink {
+	return SignedLink{URL: rawURL, TTL: ttl, signer: b}
+}
+
+// SignedLink is a URL which is signed when marshalled with encoding/xml,
+// either as an element (eg. a sitemap <loc>) or an attribute (eg. an Atom
+// <link href>). Create them with BatchSigner.Link.
+type SignedLink struct {
+	// URL is the unsigned URL
+	URL string
+
+	// TTL is how long after marshalling the signed URL expires. Zero signs
+	// it without an expiry.
+	TTL time.Duration
+
+	signer *BatchSigner
+}
+
+// sign returns the signed URL of l
+func (l SignedLink) sign() (string, error) {
+	signer := l.signer
+	if signer == nil {
+		signer = NewBatchSigner()
+	}
+
+	return signer.Sign(l.URL, l.TTL)
+}
+
+// MarshalXML implements xml.Marshaler, writing the signed URL as character
+// data
+func (l SignedLink) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	signedURL, err := l.sign()
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeElement(signedURL, start)
+}
+
+// MarshalXMLAttr implements xml.MarshalerAttr, writing the signed URL as the
+// attribute value
+func (l SignedLink) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	signedURL, err := l.sign()
+	if err != nil {
+		return xml.Attr{}, err
+	}
+
+	return xml.Attr{Name: name, Value: signedURL}, nil
+}
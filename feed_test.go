@@ -0,0 +1,105 @@
+package signed
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestBatchSigner(t *testing.T) {
+	// Initalize config
+	lookups := 0
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string {
+			lookups++
+			return "secret"
+		},
+	}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	type entry struct {
+		Link SignedLink `xml:"link"`
+	}
+	type feed struct {
+		XMLName xml.Name   `xml:"feed"`
+		Self    SignedLink `xml:"href,attr"`
+		Entries []entry    `xml:"entry"`
+	}
+
+	t.Run("it should sign links when marshalling with one key lookup", func(t *testing.T) {
+		lookups = 0
+		signer := NewBatchSigner()
+		b, err := xml.Marshal(feed{
+			Self: signer.Link("http://example.com/feed.xml", 0),
+			Entries: []entry{
+				{Link: signer.Link("http://example.com/posts/1", time.Hour)},
+				{Link: signer.Link("http://example.com/posts/2", 24*time.Hour)},
+			},
+		})
+
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 1, lookups)
+
+		links := regexp.MustCompile(`(?:href="|<link>)([^"<]*)`).FindAllStringSubmatch(string(b), -1)
+		utils.AssertEqual(t, 3, len(links))
+		utils.AssertEqual(t, true, regexp.MustCompile(`expiresIn=86400`).MatchString(links[2][1]))
+
+		for _, link := range links {
+			var u string
+			utils.AssertEqual(t, nil, xml.Unmarshal([]byte("<u>"+link[1]+"</u>"), &u))
+
+			resp, _ := app.Test(newTestRequest(http.MethodGet, u))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode, u)
+		}
+	})
+	t.Run("it should fall back for links longer than MaxURLLength", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string {
+				lookups++
+				return "secret"
+			},
+			MaxURLLength: 600,
+		})
+		defer New(Config{
+			GetPrivateKeyFunc: func() string {
+				lookups++
+				return "secret"
+			},
+		})
+
+		lookups = 0
+		signer := NewBatchSigner()
+		// Escaped in query params, purposes like this are far longer than in
+		// tokens
+		rawURL := "http://example.com/posts/1?purpose=" + url.QueryEscape(strings.Repeat("é", 100))
+		signedURL, err := signer.Sign(rawURL, 0)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 1, lookups)
+		utils.AssertEqual(t, true, len(signedURL) <= 600)
+
+		u, _ := url.Parse(signedURL)
+		utils.AssertEqual(t, true, u.Query().Get("token") != "")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxURLLength:      100,
+		})
+		_, err = NewBatchSigner().Sign(rawURL, 0)
+		utils.AssertEqual(t, ErrURLTooLong, err)
+	})
+}
@@ -0,0 +1,38 @@
+package signed
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// notifyFirstUse calls FirstUsed if the request is the first successful use
+// of its signed URL. Storage failures are logged rather than failing the
+// request, as the URL has already been verified.
+func (cfg *instance) notifyFirstUse(c *fiber.Ctx) {
+	if cfg.FirstUsed == nil || cfg.Storage == nil {
+		return
+	}
+
+	signature := cfg.getSignatureID(c)
+	if signature == "" {
+		return
+	}
+
+	// Remember the use for as long as the URL is valid
+	var ttl time.Duration
+	if i, err := strconv.ParseInt(c.Query(cfg.ExpiresQueryKey), 10, 64); err == nil {
+		ttl = time.Until(time.Unix(i, 0))
+	}
+
+	first, err := cfg.storageReplays.add(requestContext(c), StorageKindFirstUse, signature, ttl)
+	if err != nil {
+		log.Printf("fiber-signed: cannot record first use: %v", err)
+		return
+	}
+	if first {
+		cfg.FirstUsed(c, signature)
+	}
+}
@@ -0,0 +1,47 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestFirstUsed(t *testing.T) {
+	// Initalize config
+	var used []string
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		FirstUsed: func(c *fiber.Ctx, signature string) {
+			used = append(used, signature)
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	signature := httptest.NewRequest(http.MethodGet, signedURL, nil).URL.Query().Get("signature")
+
+	t.Run("it should not fire for invalid urls", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&a=1"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, 0, len(used))
+	})
+
+	t.Run("it should fire once on the first use of a url", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+
+		utils.AssertEqual(t, []string{signature}, used)
+	})
+}
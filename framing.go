@@ -0,0 +1,69 @@
+package signed
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// checkFraming rejects requests whose header block exceeds MaxHeaderBytes and,
+// with StrictFraming set, requests framed ambiguously, as the signature only
+// covers the body if every server on the way agrees where it ends. fasthttp
+// resolves conflicting framing headers silently, so the raw headers are
+// checked.
+func (cfg *instance) checkFraming(c *fiber.Ctx) error {
+	raw := c.Request().Header.RawHeaders()
+	if cfg.MaxHeaderBytes > 0 && len(raw) > cfg.MaxHeaderBytes {
+		return fiber.NewError(fiber.StatusRequestHeaderFieldsTooLarge, "request header fields too large")
+	}
+	if !cfg.StrictFraming {
+		return nil
+	}
+
+	contentLengths, transferEncodings := 0, 0
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return newFramingError("folded header lines are not allowed")
+		}
+
+		colon := bytes.IndexByte(line, ':')
+		if colon < 0 {
+			return newFramingError("malformed header line")
+		}
+		name := string(line[:colon])
+		if strings.TrimRight(name, " \t") != name {
+			return newFramingError("whitespace before header colon is not allowed")
+		}
+		value := strings.TrimSpace(string(line[colon+1:]))
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			contentLengths++
+		case "transfer-encoding":
+			transferEncodings++
+			if !strings.EqualFold(value, "chunked") {
+				return newFramingError("transfer encodings other than chunked are not allowed")
+			}
+		}
+	}
+
+	if contentLengths > 1 || transferEncodings > 1 {
+		return newFramingError("repeated framing headers are not allowed")
+	}
+	if contentLengths > 0 && transferEncodings > 0 {
+		return newFramingError("content-length and transfer-encoding must not both be set")
+	}
+
+	return nil
+}
+
+// newFramingError returns the error requests framed ambiguously are rejected
+// with
+func newFramingError(message string) error {
+	return fiber.NewError(fiber.StatusBadRequest, message)
+}
@@ -0,0 +1,75 @@
+package signed
+
+import (
+	"bufio"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+func TestStrictFraming(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		StrictFraming:     true,
+		MaxHeaderBytes:    1024,
+	}))
+
+	app.Post("/submit", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodPost, "http://example.com/submit"))
+	signed, _ := url.Parse(signedURL)
+
+	// serve sends the raw header lines, which net/http would normalize
+	serve := func(headers ...string) *fasthttp.RequestCtx {
+		raw := "POST " + signed.RequestURI() + " HTTP/1.1\r\nHost: example.com\r\n" + strings.Join(headers, "\r\n") + "\r\n\r\n"
+
+		ctx := &fasthttp.RequestCtx{}
+		utils.AssertEqual(t, nil, ctx.Request.Read(bufio.NewReader(strings.NewReader(raw))))
+
+		app.Handler()(ctx)
+		return ctx
+	}
+
+	t.Run("it should accept unambiguously framed requests", func(t *testing.T) {
+		ctx := serve("Content-Length: 0")
+
+		utils.AssertEqual(t, fiber.StatusOK, ctx.Response.StatusCode())
+	})
+
+	t.Run("it should reject conflicting framing headers", func(t *testing.T) {
+		ctx := serve("Content-Length: 0", "Transfer-Encoding: chunked\r\n\r\n0")
+
+		utils.AssertEqual(t, fiber.StatusBadRequest, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "content-length and transfer-encoding must not both be set", string(ctx.Response.Body()))
+	})
+
+	t.Run("it should reject repeated content lengths", func(t *testing.T) {
+		ctx := serve("Content-Length: 0", "Content-Length: 0")
+
+		utils.AssertEqual(t, fiber.StatusBadRequest, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "repeated framing headers are not allowed", string(ctx.Response.Body()))
+	})
+
+	t.Run("it should reject obfuscated transfer encodings", func(t *testing.T) {
+		ctx := serve("Transfer-Encoding: xchunked\r\n\r\n0")
+
+		utils.AssertEqual(t, fiber.StatusBadRequest, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "transfer encodings other than chunked are not allowed", string(ctx.Response.Body()))
+	})
+
+	t.Run("it should reject oversized header blocks", func(t *testing.T) {
+		ctx := serve("Content-Length: 0", "X-Padding: "+strings.Repeat("a", 1024))
+
+		utils.AssertEqual(t, fiber.StatusRequestHeaderFieldsTooLarge, ctx.Response.StatusCode())
+	})
+}
@@ -0,0 +1,74 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ipInRanges reports whether ip is within any of the comma separated CIDR
+// ranges
+func ipInRanges(ip string, ranges string) (bool, error) {
+	parsed := net.ParseIP(ip)
+	for _, cidr := range strings.Split(ranges, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return false, err
+		}
+		if parsed != nil && ipNet.Contains(parsed) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// countryAllowed resolves the country of ip and reports whether it is one of
+// countries
+func (cfg *instance) countryAllowed(ip string, countries []string) (bool, error) {
+	if cfg.CountryResolver == nil {
+		return false, errors.New("url country restriction cannot be enforced without CountryResolver")
+	}
+
+	country, err := cfg.CountryResolver(ip)
+	if err != nil {
+		return false, errors.New("cannot resolve country of ip address")
+	}
+
+	for _, allowed := range countries {
+		if strings.EqualFold(strings.TrimSpace(allowed), country) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkSource enforces the source IP ranges and countries signed into the
+// request URL
+func (cfg *instance) checkSource(c *fiber.Ctx) error {
+	if ranges := c.Query(cfg.SourceIPRangeQueryKey); ranges != "" {
+		allowed, err := ipInRanges(c.IP(), ranges)
+		if err != nil {
+			return fmt.Errorf("%s value must be a list of valid CIDR ranges", cfg.SourceIPRangeQueryKey)
+		}
+		if !allowed {
+			return errors.New("url signature does not permit this ip address")
+		}
+	}
+
+	if countries := c.Query(cfg.CountriesQueryKey); countries != "" {
+		allowed, err := cfg.countryAllowed(c.IP(), strings.Split(countries, ","))
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.New("url signature does not permit this country")
+		}
+	}
+
+	return nil
+}
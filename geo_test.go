@@ -0,0 +1,81 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSourceRestrictions(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		CountryResolver: func(ip string) (string, error) {
+			return "US", nil
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		return signedURL
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		status   int
+		expected string
+	}{
+		{"it should succeed from within a signed ip range", "http://example.com/?sourceIpRange=10.0.0.0/8,0.0.0.0/32", fiber.StatusOK, "Hello, world!"},
+		{"it should reject requests outside signed ip ranges", "http://example.com/?sourceIpRange=10.0.0.0/8", fiber.StatusForbidden, "url signature does not permit this ip address"},
+		{"it should reject malformed ip ranges", "http://example.com/?sourceIpRange=nope", fiber.StatusForbidden, "sourceIpRange value must be a list of valid CIDR ranges"},
+		{"it should succeed from a signed country", "http://example.com/?countries=ca,us", fiber.StatusOK, "Hello, world!"},
+		{"it should reject requests from other countries", "http://example.com/?countries=DE", fiber.StatusForbidden, "url signature does not permit this country"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, sign(tt.target)))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, tt.status, resp.StatusCode)
+			utils.AssertEqual(t, tt.expected, string(body))
+		})
+	}
+
+	t.Run("it should enforce countries in policy documents", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, _ := GetSignedURLWithPolicyFromHTTPRequest(req, Policy{Countries: []string{"FR"}})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url policy does not permit this country", string(body))
+	})
+
+	t.Run("it should not enforce countries without CountryResolver", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		}))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?countries=US")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url country restriction cannot be enforced without CountryResolver", string(body))
+	})
+}
@@ -2,4 +2,7 @@ module github.com/bsandusky/fiber-signed
 
 go 1.15
 
-require github.com/gofiber/fiber/v2 v2.2.1
+require (
+	github.com/gofiber/fiber/v2 v2.2.1
+	github.com/valyala/fasthttp v1.17.0
+)
@@ -0,0 +1,258 @@
+package signed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errInvalidGraphQL is returned for bodies which can't be canonicalized as
+// GraphQL requests
+var errInvalidGraphQL = errors.New("body is not a valid graphql request")
+
+// graphQLRequest is a GraphQL-over-POST request body
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+// canonicalGraphQLRequest returns a JSON GraphQL request (or batch of
+// requests) with the query document normalized and variables sorted, so
+// requests differing only in whitespace, comments or key order sign alike.
+// Bodies with duplicate keys or members other than query, operationName and
+// variables are rejected, as they would reach handlers unsigned.
+func canonicalGraphQLRequest(body []byte) ([]byte, error) {
+	if err := checkDuplicateKeys(body); err != nil {
+		return nil, err
+	}
+
+	var requests []graphQLRequest
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	if batch {
+		var members []json.RawMessage
+		if err := json.Unmarshal(body, &members); err != nil {
+			return nil, errInvalidGraphQL
+		}
+		for _, member := range members {
+			request, err := decodeGraphQLRequest(member)
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, request)
+		}
+	} else {
+		request, err := decodeGraphQLRequest(body)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	var canonical []interface{}
+	for _, request := range requests {
+		query, err := normalizeGraphQLDocument(request.Query)
+		if err != nil {
+			return nil, err
+		}
+
+		// Decoding into interface{} sorts object keys when marshalled
+		var variables interface{}
+		if len(request.Variables) > 0 {
+			d := json.NewDecoder(bytes.NewReader(request.Variables))
+			d.UseNumber()
+			if err := d.Decode(&variables); err != nil {
+				return nil, errInvalidGraphQL
+			}
+		}
+
+		canonical = append(canonical, struct {
+			Query         string      `json:"query"`
+			OperationName string      `json:"operationName"`
+			Variables     interface{} `json:"variables"`
+		}{query, request.OperationName, variables})
+	}
+
+	if batch {
+		return json.Marshal(canonical)
+	}
+	return json.Marshal(canonical[0])
+}
+
+// decodeGraphQLRequest decodes a GraphQL request object, rejecting members
+// the canonical form would drop. Keys must match exactly, as json.Unmarshal
+// would otherwise match them case-insensitively.
+func decodeGraphQLRequest(data []byte) (graphQLRequest, error) {
+	var request graphQLRequest
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return request, errInvalidGraphQL
+	}
+	for name := range members {
+		switch name {
+		case "query", "operationName", "variables":
+		default:
+			return request, errInvalidGraphQL
+		}
+	}
+
+	if err := json.Unmarshal(data, &request); err != nil {
+		return request, errInvalidGraphQL
+	}
+
+	return request, nil
+}
+
+// checkDuplicateKeys returns errInvalidGraphQL if any object in data has a
+// key more than once, as JSON parsers disagree on which of them counts
+func checkDuplicateKeys(data []byte) error {
+	// Arrays are nil entries of the stack
+	type object struct {
+		seen      map[string]bool
+		expectKey bool
+	}
+	var stack []*object
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errInvalidGraphQL
+		}
+
+		if key, ok := t.(string); ok && len(stack) > 0 && stack[len(stack)-1] != nil && stack[len(stack)-1].expectKey {
+			top := stack[len(stack)-1]
+			if top.seen[key] {
+				return errInvalidGraphQL
+			}
+			top.seen[key] = true
+			top.expectKey = false
+			continue
+		}
+
+		switch t {
+		case json.Delim('{'):
+			stack = append(stack, &object{seen: make(map[string]bool), expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, nil)
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// A value is complete, so the object it is in expects a key next
+		if len(stack) > 0 && stack[len(stack)-1] != nil {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+}
+
+// canonicalGraphQLDocument returns an application/graphql body with its query
+// document normalized
+func canonicalGraphQLDocument(body []byte) ([]byte, error) {
+	query, err := normalizeGraphQLDocument(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(query), nil
+}
+
+// normalizeGraphQLDocument returns the tokens of a GraphQL document separated
+// by single spaces, dropping comments, commas and other ignored characters.
+// Strings are kept as written.
+func normalizeGraphQLDocument(doc string) (string, error) {
+	doc = strings.TrimPrefix(doc, "\ufeff")
+
+	var tokens []string
+	for i := 0; i < len(doc); {
+		ch := doc[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+
+		case ch == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := -1
+			for j := i + 3; j <= len(doc); {
+				k := strings.Index(doc[j:], `"""`)
+				if k < 0 {
+					break
+				}
+				if k > 0 && doc[j+k-1] == '\\' {
+					j += k + 3
+					continue
+				}
+				end = j + k + 3
+				break
+			}
+			if end < 0 {
+				return "", errInvalidGraphQL
+			}
+			tokens = append(tokens, doc[i:end])
+			i = end
+
+		case ch == '"':
+			j := i + 1
+			for j < len(doc) && doc[j] != '"' {
+				if doc[j] == '\n' || doc[j] == '\r' {
+					return "", errInvalidGraphQL
+				}
+				if doc[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(doc) {
+				return "", errInvalidGraphQL
+			}
+			tokens = append(tokens, doc[i:j+1])
+			i = j + 1
+
+		case strings.HasPrefix(doc[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+
+		case strings.IndexByte("!$&():=@[]{}|", ch) >= 0:
+			tokens = append(tokens, string(ch))
+			i++
+
+		case ch == '-' || ch >= '0' && ch <= '9':
+			j := i + 1
+			for j < len(doc) && (isGraphQLNameChar(doc[j]) || strings.IndexByte(".+-", doc[j]) >= 0) {
+				j++
+			}
+			tokens = append(tokens, doc[i:j])
+			i = j
+
+		case isGraphQLNameChar(ch):
+			j := i
+			for j < len(doc) && isGraphQLNameChar(doc[j]) {
+				j++
+			}
+			tokens = append(tokens, doc[i:j])
+			i = j
+
+		default:
+			return "", errInvalidGraphQL
+		}
+	}
+
+	return strings.Join(tokens, " "), nil
+}
+
+// isGraphQLNameChar reports whether ch may appear in a GraphQL name
+func isGraphQLNameChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
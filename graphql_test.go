@@ -0,0 +1,95 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestNormalizeGraphQLDocument(t *testing.T) {
+	t.Run("it should drop ignored tokens and keep strings as written", func(t *testing.T) {
+		got, err := normalizeGraphQLDocument(`
+			# Fetch a user
+			query User($id: ID!, $n: Int = -1.5e3) {
+				user(id: $id, note: "a,  b # c") { ...Fields }
+				doc(text: """x "\""  y""")
+			}`)
+
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, `query User ( $ id : ID ! $ n : Int = -1.5e3 ) { user ( id : $ id note : "a,  b # c" ) { ... Fields } doc ( text : """x "\""  y""" ) }`, got)
+	})
+
+	t.Run("it should reject unterminated strings", func(t *testing.T) {
+		_, err := normalizeGraphQLDocument(`{ user(id: "1) }`)
+
+		utils.AssertEqual(t, errInvalidGraphQL, err)
+	})
+}
+
+func TestCanonicalizeGraphQL(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:   func() string { return "secret" },
+		CanonicalizeGraphQL: true,
+	}))
+
+	app.Post("/graphql", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/graphql", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		signedURL, err := GetSignedURLFromHTTPRequest(req)
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	send := func(signedURL, body string) int {
+		req := httptest.NewRequest(http.MethodPost, signedURL, strings.NewReader(body))
+		req.RequestURI = ""
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+
+	t.Run("it should accept equivalent graphql requests", func(t *testing.T) {
+		signedURL := sign(`{"query":"{ user(id: 1) { name } }","variables":{"b":1,"a":2}}`)
+
+		utils.AssertEqual(t, fiber.StatusOK, send(signedURL, `{"variables":{"a":2,"b":1},"query":"{\n  user(id: 1) {\n    name # display\n  }\n}"}`))
+	})
+
+	t.Run("it should reject different graphql requests", func(t *testing.T) {
+		signedURL := sign(`{"query":"{ user(id: 1) { name } }"}`)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, send(signedURL, `{"query":"{ user(id: 2) { name } }"}`))
+	})
+
+	t.Run("it should reject case-variant and duplicate keys", func(t *testing.T) {
+		signedURL := sign(`{"query":"{ me { id } }"}`)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, send(signedURL, `{"query":"{ deleteAll }","Query":"{ me { id } }"}`))
+		utils.AssertEqual(t, fiber.StatusForbidden, send(signedURL, `{"query":"{ deleteAll }","query":"{ me { id } }"}`))
+		utils.AssertEqual(t, fiber.StatusForbidden, send(signedURL, `[{"query":"{ me { id } }","variables":{"id":1,"id":2}}]`))
+	})
+
+	t.Run("it should reject unsigned members", func(t *testing.T) {
+		signedURL := sign(`{"query":"{ me { id } }"}`)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, send(signedURL, `{"query":"{ me { id } }","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`))
+	})
+
+	t.Run("it should not sign bodies which are not graphql requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/graphql", strings.NewReader("not json"))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		_, err := GetSignedURLFromHTTPRequest(req)
+
+		utils.AssertEqual(t, errInvalidGraphQL, err)
+	})
+}
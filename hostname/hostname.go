@@ -0,0 +1,209 @@
+// Package hostname normalizes the host of URLs the way signed URLs are
+// canonicalized, so clients in other languages or processes can match it. It
+// only uses the standard library.
+package hostname
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrInvalidHost is returned for hosts which can't be normalized
+var ErrInvalidHost = errors.New("invalid host")
+
+// Normalize returns host, with any port, in the form used when signing and
+// verifying URLs:
+//
+//   - userinfo (user:password@) is stripped
+//   - IPv6 literals are bracketed and written in their shortest form, eg.
+//     [0:0::1]:3000 becomes [::1]:3000
+//   - names are lowercased, and labels with non-ASCII characters are converted
+//     to punycode, eg. Bücher.example becomes xn--bcher-kva.example
+//
+// Unicode names are lowercased but not otherwise mapped as IDNA would, so
+// clients should sign the name exactly as users type it or its ASCII form.
+func Normalize(host string) (string, error) {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+
+	// IPv6 literals, with an optional zone and port
+	if strings.HasPrefix(host, "[") {
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return "", ErrInvalidHost
+		}
+		port := host[end+1:]
+		if port != "" && !validPort(port) {
+			return "", ErrInvalidHost
+		}
+
+		literal, zone := host[1:end], ""
+		if i := strings.Index(literal, "%"); i >= 0 {
+			literal, zone = literal[:i], literal[i:]
+		}
+		ip := net.ParseIP(literal)
+		if ip == nil || !strings.Contains(literal, ":") {
+			return "", ErrInvalidHost
+		}
+
+		// Keep IPv4-mapped addresses recognisable as IPv6
+		formatted := ip.String()
+		if ip4 := ip.To4(); ip4 != nil {
+			formatted = "::ffff:" + ip4.String()
+		}
+
+		return "[" + formatted + zone + "]" + port, nil
+	}
+
+	name, port := host, ""
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		name, port = host[:i], host[i:]
+		if !validPort(port) {
+			return "", ErrInvalidHost
+		}
+	}
+
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + encoded
+	}
+
+	return strings.Join(labels, ".") + port, nil
+}
+
+// validPort reports whether port is a colon followed by digits
+func validPort(port string) bool {
+	if len(port) < 2 || port[0] != ':' {
+		return false
+	}
+	for _, c := range port[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isASCII reports whether s only contains ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters, from RFC 3492
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes s as described in RFC 3492, without the xn-- prefix
+func punycode(s string) (string, error) {
+	runes := []rune(s)
+
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h < len(runes) {
+		// Find the smallest code point not handled yet
+		m := rune(0x7FFFFFFF)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		if int(m-n) > (1<<31-1-delta)/(h+1) {
+			return "", ErrInvalidHost
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(out), nil
+}
+
+// punyDigit returns the basic code point for digit d
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyAdapt returns the new bias after encoding a code point
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,40 @@
+package hostname
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("it should normalize hosts", func(t *testing.T) {
+		for host, expected := range map[string]string{
+			"example.com":              "example.com",
+			"Example.COM:3000":         "example.com:3000",
+			"user:pass@example.com":    "example.com",
+			"[::1]:3000":               "[::1]:3000",
+			"[0:0:0:0:0:0:0:1]":        "[::1]",
+			"[FE80::1%en0]:80":         "[fe80::1%en0]:80",
+			"[::ffff:192.0.2.1]":       "[::ffff:192.0.2.1]",
+			"192.0.2.1:8080":           "192.0.2.1:8080",
+			"bücher.example":           "xn--bcher-kva.example",
+			"MÜNCHEN.de":               "xn--mnchen-3ya.de",
+			"xn--bcher-kva.example":    "xn--bcher-kva.example",
+			"例え.テスト":                   "xn--r8jz45g.xn--zckzah",
+			"user@bücher.example:8443": "xn--bcher-kva.example:8443",
+		} {
+			got, err := Normalize(host)
+
+			utils.AssertEqual(t, nil, err, host)
+			utils.AssertEqual(t, expected, got, host)
+		}
+	})
+
+	t.Run("it should reject invalid hosts", func(t *testing.T) {
+		for _, host := range []string{"[::1", "[example.com]", "[::1]x", "example.com:http"} {
+			_, err := Normalize(host)
+
+			utils.AssertEqual(t, ErrInvalidHost, err, host)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package signed
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// instance is a config of the middleware, as set by New or UpdateConfig or
+// for a tenant of MultiTenant, with the state kept for it. Everything reading
+// the config is a method of it, so requests of separate tenants are verified
+// side by side without swapping package state.
+type instance struct {
+	Config
+
+	keys           *keyCache
+	jwks           *jwksCache
+	keyAges        *keyAgeTracker
+	abuse          *abuseMonitor
+	migrations     *migrationCounter
+	analytics      *analyticsAggregator
+	revokedBefore  *issuedCutoff
+	keyBreaker     *circuitBreaker
+	storageBreaker *circuitBreaker
+	validations    *validationCache
+	mints          *mintCounters
+	storageReplays *storageReplayCache
+}
+
+// newInstance returns an instance of config, which must be defaulted, with
+// no state kept yet. It starts keeping the key warm if configured.
+func newInstance(config Config) *instance {
+	cfg := &instance{
+		Config:         config,
+		keys:           &keyCache{},
+		jwks:           &jwksCache{},
+		keyAges:        &keyAgeTracker{},
+		abuse:          &abuseMonitor{},
+		migrations:     &migrationCounter{},
+		analytics:      &analyticsAggregator{},
+		revokedBefore:  &issuedCutoff{},
+		keyBreaker:     &circuitBreaker{},
+		storageBreaker: &circuitBreaker{},
+		validations:    &validationCache{},
+		mints:          &mintCounters{},
+		storageReplays: &storageReplayCache{},
+	}
+	cfg.storageReplays.cfg = cfg
+	cfg.keyAges.reset()
+	cfg.analytics.reset()
+
+	// Keep the key warm in the background if configured
+	if cfg.KeyCacheTTL > 0 {
+		cfg.keys.start(config)
+	}
+
+	return cfg
+}
+
+// std holds the instance of New, as swapped by UpdateConfig. It is loaded
+// without locking, so signing functions and requests being verified use
+// whichever instance is current when they start, without racing swaps.
+var std atomic.Value
+
+// stdMu serializes swapping std
+var stdMu sync.Mutex
+
+func init() {
+	std.Store(newInstance(ConfigDefault))
+}
+
+// current returns the instance of New
+func current() *instance {
+	return std.Load().(*instance)
+}
+
+// swap makes cfg the instance of New, passing the previous one to carry
+// over state from, and returns the previous one
+func swap(cfg *instance, carry func(prev *instance)) *instance {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+
+	prev := current()
+	if carry != nil {
+		carry(prev)
+	}
+	std.Store(cfg)
+
+	return prev
+}
+
+// instanceContextKey is the context key of the instance of a tenant
+type instanceContextKey struct{}
+
+// instanceLocal is the fiber.Ctx local holding the instance a request is
+// verified with
+const instanceLocal = "fiber-signed:instance"
+
+// instanceFor returns the instance of the tenant ctx carries, or the instance
+// of New
+func instanceFor(ctx context.Context) *instance {
+	if ctx != nil {
+		if cfg, ok := ctx.Value(instanceContextKey{}).(*instance); ok {
+			return cfg
+		}
+	}
+
+	return current()
+}
+
+// instanceOf returns the instance the request is verified with, or the
+// instance of New if it hasn't been verified
+func instanceOf(c *fiber.Ctx) *instance {
+	if cfg, ok := c.Locals(instanceLocal).(*instance); ok {
+		return cfg
+	}
+
+	return current()
+}
@@ -0,0 +1,399 @@
+// Package canon builds the canonical strings fiber-signed signatures are
+// computed over. The middleware and the verify and sign packages all build
+// them here, so they can't drift apart, and the latter two check and produce
+// signatures without depending on fiber or on each other.
+package canon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bsandusky/fiber-signed/hostname"
+)
+
+// Hash function algorithmic option values, matching signed.Algorithm
+const (
+	AlgorithmSHA1   = "SHA-1"
+	AlgorithmSHA256 = "SHA-256"
+	AlgorithmMD5    = "MD-5"
+
+	AlgorithmHMACSHA256 = "HMAC-SHA-256"
+)
+
+// AlgorithmIDs are the identifiers of algorithms embedded in URLs, like the
+// middleware's EmbedAlgorithm
+var AlgorithmIDs = map[string]string{
+	AlgorithmSHA1:       "sha1",
+	AlgorithmSHA256:     "sha256",
+	AlgorithmMD5:        "md5",
+	AlgorithmHMACSHA256: "hs256",
+}
+
+// Contexts separating the keys derived from the private key
+const (
+	pepperKeyContext = "fiber-signed-pepper:"
+	escrowKeyContext = "fiber-signed-escrow:"
+)
+
+// PepperKey mixes pepper into privateKey, like the middleware's GetPepperFunc
+func PepperKey(privateKey, pepper string) string {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(pepperKeyContext + privateKey))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// EscrowKey combines privateKey with a requester's key fragment into the key
+// escrowed URLs are signed with, like the middleware's EscrowHeader
+func EscrowKey(privateKey, fragment string) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write([]byte(escrowKeyContext + fragment))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// Params are the options signatures depend on
+type Params struct {
+	// Algorithm is the hash function of signatures, AlgorithmSHA1 if empty
+	Algorithm string
+
+	// PrivateKey is the private key signatures are computed with
+	PrivateKey string
+
+	// SignatureBits truncates signatures like the middleware's SignatureBits
+	SignatureBits int
+
+	// PreserveValueOrder signs repeated query params in the order they
+	// appear, matching the middleware's MultiValuePreserve
+	PreserveValueOrder bool
+
+	// ForceScheme replaces the scheme of URLs if not empty
+	ForceScheme string
+
+	// QueryEncoder builds the query string hashed into signatures like the
+	// middleware's QueryEncoderFunc, if not nil
+	QueryEncoder func(q url.Values) string
+
+	// Extra params are merged into the query before it is hashed, eg. the
+	// middleware's BindLocal binding
+	Extra url.Values
+
+	// SignatureQueryKey, CaveatQueryKey and TokenQueryKey name params which
+	// are never hashed, if not empty
+	SignatureQueryKey string
+	CaveatQueryKey    string
+	TokenQueryKey     string
+
+	// PrivateKeyQueryKey names the param PrivateKey is hashed as, unless it
+	// is empty or the algorithm keys an HMAC with it instead
+	PrivateKeyQueryKey string
+	BodyHashQueryKey   string
+}
+
+// excluded reports whether the query param key is left out of the hashed
+// query
+func (p Params) excluded(key string) bool {
+	for _, k := range []string{p.SignatureQueryKey, p.CaveatQueryKey, p.TokenQueryKey} {
+		if k != "" && key == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashFunc returns the hash constructor for the algorithm
+func (p Params) hashFunc() func() hash.Hash {
+	switch p.Algorithm {
+	case AlgorithmSHA256, AlgorithmHMACSHA256:
+		return sha256.New
+	case AlgorithmMD5:
+		return md5.New
+	default:
+		return sha1.New
+	}
+}
+
+// hash returns the hex encoded hash of b
+func (p Params) hash(b []byte) string {
+	h := p.hashFunc()()
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Sign returns the signature of canonical, keying an HMAC with the private
+// key for AlgorithmHMACSHA256 and truncating it to SignatureBits
+func (p Params) Sign(canonical string) string {
+	var h hash.Hash
+	if p.Algorithm == AlgorithmHMACSHA256 {
+		h = hmac.New(sha256.New, []byte(p.PrivateKey))
+	} else {
+		h = p.hashFunc()()
+	}
+	h.Write([]byte(canonical))
+	sum := h.Sum(nil)
+
+	if p.SignatureBits <= 0 {
+		return fmt.Sprintf("%x", sum)
+	}
+	if n := (p.SignatureBits + 7) / 8; n < len(sum) {
+		sum = sum[:n]
+	}
+	return base64.RawURLEncoding.EncodeToString(sum)
+}
+
+// String returns the string hashed to produce signatures of a request to
+// originalURL (path and query) on host
+func (p Params) String(method, scheme, host, originalURL string, body []byte) (string, error) {
+	if p.ForceScheme != "" {
+		scheme = strings.ToLower(p.ForceScheme)
+	}
+
+	parsed, err := url.ParseRequestURI(fmt.Sprintf("%s://%s%s", scheme, host, originalURL))
+	if err != nil {
+		return "", errors.New("cannot parse provided URL")
+	}
+	if parsed.Host, err = hostname.Normalize(parsed.Host); err != nil {
+		return "", err
+	}
+	if len(parsed.Path) < 1 {
+		parsed.Path = "/"
+	}
+
+	q := url.Values{}
+	if split := strings.IndexByte(originalURL, '?'); split >= 0 {
+		if q, err = ParseQuery(originalURL[split+1:]); err != nil {
+			return "", err
+		}
+	}
+
+	for k, v := range p.Extra {
+		q[k] = v
+	}
+	if p.Algorithm != AlgorithmHMACSHA256 && p.PrivateKeyQueryKey != "" {
+		q.Set(p.PrivateKeyQueryKey, p.PrivateKey)
+	}
+	if len(body) > 0 {
+		q.Set(p.BodyHashQueryKey, p.hash(body))
+	}
+
+	return fmt.Sprintf("%s&%s://%s%s?%s", method, parsed.Scheme, parsed.Host, parsed.Path, p.Query(q)), nil
+}
+
+// Query returns the query string of q hashed into signatures: its params,
+// less those never hashed, ordered by key, or encoded with QueryEncoder if
+// set. Values of repeated keys are sorted in place unless PreserveValueOrder
+// is set.
+func (p Params) Query(q url.Values) string {
+	var keys []string
+	for k := range q {
+		if p.excluded(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	if p.QueryEncoder != nil {
+		covered := make(url.Values, len(keys))
+		for _, key := range keys {
+			covered[key] = q[key]
+		}
+		return p.QueryEncoder(covered)
+	}
+
+	sort.Strings(keys)
+
+	var ordered []string
+	for _, key := range keys {
+		if !p.PreserveValueOrder {
+			sort.Strings(q[key])
+		}
+		for _, val := range q[key] {
+			ordered = append(ordered, fmt.Sprintf("%s=%s", key, val))
+		}
+	}
+
+	return strings.Join(ordered, "&")
+}
+
+// digestAlgorithms are the digest algorithms bodies are checked with, like
+// the middleware
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// ErrDecodedBodyTooLarge rejects bodies decoding larger than the limit of
+// DecodeBody
+var ErrDecodedBodyTooLarge = errors.New("decoded body is too large")
+
+// ErrQueryEscape rejects queries with invalid percent-encoding
+var ErrQueryEscape = errors.New("query params must be validly percent-encoded")
+
+// ParseQuery parses rawQuery the way Fiber does, as the middleware does:
+// params are separated by "&" only, so semicolons are part of keys and values,
+// and keys without "=" have an empty value. Pairs with invalid escapes are
+// left out of q, and ErrQueryEscape returned.
+func ParseQuery(rawQuery string) (url.Values, error) {
+	q := url.Values{}
+
+	var err error
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+
+		key, keyErr := url.QueryUnescape(key)
+		value, valueErr := url.QueryUnescape(value)
+		if keyErr != nil || valueErr != nil {
+			err = ErrQueryEscape
+			continue
+		}
+
+		q[key] = append(q[key], value)
+	}
+
+	return q, err
+}
+
+// digestHeader returns the name and value of the digest header of a request,
+// preferring Content-Digest, like the middleware
+func digestHeader(header func(key string) string) (string, string) {
+	if value := header("Content-Digest"); value != "" {
+		return "Content-Digest", value
+	}
+	if encoding := header("Content-Encoding"); encoding == "" || strings.EqualFold(encoding, "identity") {
+		return "Repr-Digest", header("Repr-Digest")
+	}
+
+	return "", ""
+}
+
+// HasDigest reports whether a request has a digest header DigestBody signs
+// in place of its body
+func HasDigest(header func(key string) string) bool {
+	_, value := digestHeader(header)
+	return value != ""
+}
+
+// DecodeBody returns body with the content codings of encoding, a
+// Content-Encoding header value, undone in reverse order, as the middleware
+// hashes bodies without a digest header as decoded content. Bodies decoding
+// larger than limit, if positive, are rejected.
+func DecodeBody(encoding string, body []byte, limit int) ([]byte, error) {
+	if encoding == "" || len(body) == 0 {
+		return body, nil
+	}
+
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var r io.ReadCloser
+		var err error
+		switch coding {
+		case "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			r, err = zlib.NewReader(bytes.NewReader(body))
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("body is not valid %s content", coding)
+		}
+
+		var decoded io.Reader = r
+		if limit > 0 {
+			decoded = io.LimitReader(r, int64(limit)+1)
+		}
+		body, err = ioutil.ReadAll(decoded)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("body is not valid %s content", coding)
+		}
+		if limit > 0 && len(body) > limit {
+			return nil, ErrDecodedBodyTooLarge
+		}
+	}
+
+	return body, nil
+}
+
+// DigestBody returns the Content-Digest (or Repr-Digest) header signed in
+// place of body, once body is checked against every digest in it, or body if
+// there is none. Signers may skip the check to sign a digest alone.
+func DigestBody(header func(key string) string, body []byte, check bool) ([]byte, error) {
+	name, value := digestHeader(header)
+	if value == "" {
+		return body, nil
+	}
+	if !check {
+		return []byte(strings.ToLower(name) + ": " + value), nil
+	}
+
+	checked := false
+	for _, member := range strings.Split(value, ",") {
+		split := strings.IndexByte(member, '=')
+		if split < 0 {
+			return nil, errors.New("malformed digest header")
+		}
+
+		newHash, ok := digestAlgorithms[strings.ToLower(strings.TrimSpace(member[:split]))]
+		if !ok {
+			continue
+		}
+
+		encoded := strings.TrimSpace(member[split+1:])
+		if len(encoded) < 2 || encoded[0] != ':' || encoded[len(encoded)-1] != ':' {
+			return nil, errors.New("malformed digest header")
+		}
+		digest, err := base64.StdEncoding.DecodeString(encoded[1 : len(encoded)-1])
+		if err != nil {
+			return nil, errors.New("malformed digest header")
+		}
+
+		h := newHash()
+		h.Write(body)
+		if subtle.ConstantTimeCompare(h.Sum(nil), digest) != 1 {
+			return nil, errors.New("body does not match digest header")
+		}
+		checked = true
+	}
+	if !checked {
+		return nil, errors.New("digest header has no supported algorithm")
+	}
+
+	return []byte(strings.ToLower(name) + ": " + value), nil
+}
+
+// PrefixQueryKey returns the default query key name with prefix prepended,
+// capitalizing name when there is a prefix
+func PrefixQueryKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,225 @@
+package signed
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jwksMinRefetchInterval limits how often an unknown "kid" can force keys to
+// be fetched again
+var jwksMinRefetchInterval = 30 * time.Second
+
+// jwksRetryBackoff is how long fetching keys is held off after a failed
+// fetch, doubled for each further failure up to jwksMaxRetryBackoff, so an
+// unavailable JWKS URL isn't fetched again for every request
+var (
+	jwksRetryBackoff    = time.Second
+	jwksMaxRetryBackoff = time.Minute
+)
+
+// jwksHTTPClient is used to fetch keys from JWKSURL
+var jwksHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// jwk is a single JSON Web Key. Only Ed25519 keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Kid string `json:"kid,omitempty"`
+	X   string `json:"x"`
+}
+
+// jwkSet is the document served at a JWKS URL
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// errUnknownKeyID is returned for tokens whose "kid" is not among the keys
+// served at JWKSURL. It doesn't name the kid, which is client supplied.
+var errUnknownKeyID = errors.New("unknown key id")
+
+// errJWKSUnavailable is returned while no keys could be fetched from JWKSURL.
+// The cause is logged rather than returned, as it names the JWKS URL.
+var errJWKSUnavailable = fiber.NewError(fiber.StatusServiceUnavailable, "signing keys are unavailable")
+
+// jwksCache holds keys fetched from JWKSURL
+type jwksCache struct {
+	mu       sync.Mutex
+	keys     map[string]ed25519.PublicKey
+	fetched  time.Time
+	fetching *jwksFetch
+	failures int
+	retryAt  time.Time
+}
+
+// jwksFetch is a fetch of the keys in flight, shared by every request
+// waiting for it
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// reset drops all cached keys
+func (j *jwksCache) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.keys = nil
+	j.fetched = time.Time{}
+	j.failures = 0
+	j.retryAt = time.Time{}
+}
+
+// get returns the cached key for kid, fetching keys with ctx when the cache
+// is stale or kid is unknown. Keys are fetched outside the lock, once for all
+// the requests needing them, while requests for known keys are served from
+// the cache meanwhile. Failed fetches are backed off.
+func (j *jwksCache) get(ctx context.Context, cfg *instance, kid string) (ed25519.PublicKey, error) {
+	j.mu.Lock()
+
+	since := time.Since(j.fetched)
+	_, known := j.keys[kid]
+	stale := j.keys == nil || since > cfg.JWKSRefreshInterval || (!known && since > jwksMinRefetchInterval)
+	if !stale || (known && j.fetching != nil) || (j.fetching == nil && time.Now().Before(j.retryAt)) {
+		defer j.mu.Unlock()
+		return j.lookup(kid)
+	}
+
+	fetch := j.fetching
+	if fetch == nil {
+		fetch = &jwksFetch{done: make(chan struct{})}
+		j.fetching = fetch
+		j.mu.Unlock()
+
+		keys, err := fetchJWKS(ctx, cfg.JWKSURL)
+
+		j.mu.Lock()
+		// Keep serving stale keys if the JWKS URL is temporarily unavailable
+		switch {
+		case err == nil:
+			j.keys = keys
+			j.failures, j.retryAt = 0, time.Time{}
+		case ctx.Err() == nil:
+			log.Printf("fiber-signed: %v", err)
+			backoff := jwksRetryBackoff << uint(j.failures)
+			if backoff <= 0 || backoff > jwksMaxRetryBackoff {
+				backoff = jwksMaxRetryBackoff
+			}
+			j.failures++
+			j.retryAt = time.Now().Add(backoff)
+		}
+		if j.keys != nil {
+			j.fetched = time.Now()
+		}
+		fetch.err = err
+		j.fetching = nil
+		close(fetch.done)
+	} else {
+		j.mu.Unlock()
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, errJWKSUnavailable
+		}
+		j.mu.Lock()
+	}
+	defer j.mu.Unlock()
+
+	return j.lookup(kid)
+}
+
+// lookup returns the cached key for kid. The lock must be held.
+func (j *jwksCache) lookup(kid string) (ed25519.PublicKey, error) {
+	if j.keys == nil {
+		return nil, errJWKSUnavailable
+	}
+
+	publicKey, ok := j.keys[kid]
+	if !ok {
+		return nil, errUnknownKeyID
+	}
+
+	return publicKey, nil
+}
+
+// fetchJWKS downloads and decodes the Ed25519 keys served at url with ctx
+func fetchJWKS(ctx context.Context, url string) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch JWKS: %v", err)
+	}
+	resp, err := jwksHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.New("cannot fetch JWKS: invalid key set")
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[key.Kid] = ed25519.PublicKey(x)
+	}
+
+	return keys, nil
+}
+
+// getPublicKey returns the key used to verify EdDSA tokens with the given
+// kid, fetching keys from JWKSURL with ctx
+func (cfg *instance) getPublicKey(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	if cfg.JWKSURL != "" {
+		return cfg.jwks.get(ctx, cfg, kid)
+	}
+
+	if cfg.GetPublicKeyFunc == nil {
+		return nil, errors.New("GetPublicKeyFunc is required to verify EdDSA tokens")
+	}
+
+	return cfg.GetPublicKeyFunc(), nil
+}
+
+// MarshalJWKS encodes Ed25519 public keys by key ID as a JWKS document, for
+// serving at the JWKSURL of validators
+func MarshalJWKS(keys map[string]ed25519.PublicKey) ([]byte, error) {
+	set := jwkSet{Keys: make([]jwk, 0, len(keys))}
+	for kid, publicKey := range keys {
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q is not a valid Ed25519 public key", kid)
+		}
+		set.Keys = append(set.Keys, jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Kid: kid,
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		})
+	}
+
+	// Sort keys for stable output
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return json.Marshal(set)
+}
@@ -0,0 +1,188 @@
+package signed
+
+import (
+	"context"
+	"crypto/ed25519"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestJWKS(t *testing.T) {
+	// Serve a key set which can be rotated during the test
+	var mu sync.Mutex
+	publicKeys := make(map[string]ed25519.PublicKey)
+	privateKeys := make(map[string]ed25519.PrivateKey)
+	addKey := func(kid string) {
+		mu.Lock()
+		defer mu.Unlock()
+		publicKeys[kid], privateKeys[kid], _ = ed25519.GenerateKey(nil)
+	}
+	addKey("k1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := MarshalJWKS(publicKeys)
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		TokenAlgorithm:    TokenAlgorithmEdDSA,
+		KeyID:             "k1",
+		GetSigningKeyFunc: func() ed25519.PrivateKey { return privateKeys[current().KeyID] },
+		JWKSURL:           server.URL,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func() string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, err := GetSignedTokenURLFromHTTPRequest(req, nil)
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should verify tokens with keys fetched from the JWKS URL", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign()))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should refresh keys when a token has an unknown kid", func(t *testing.T) {
+		defer func(d time.Duration) { jwksMinRefetchInterval = d }(jwksMinRefetchInterval)
+		jwksMinRefetchInterval = 0
+
+		addKey("k2")
+		current().KeyID = "k2"
+		defer func() { current().KeyID = "k1" }()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign()))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should not verify tokens with a kid missing from the JWKS", func(t *testing.T) {
+		addKey("k3")
+		current().KeyID = "k3"
+		defer func() { current().KeyID = "k1" }()
+		signedURL := sign()
+
+		mu.Lock()
+		delete(publicKeys, "k3")
+		mu.Unlock()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "unknown key id", string(body))
+	})
+
+	t.Run("it should not marshal an invalid public key", func(t *testing.T) {
+		_, err := MarshalJWKS(map[string]ed25519.PublicKey{"bad": []byte("short")})
+		utils.AssertEqual(t, `key "bad" is not a valid Ed25519 public key`, err.Error())
+	})
+}
+
+func TestJWKSFetch(t *testing.T) {
+	// Serve a key set whose fetches can be held up
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		b, _ := MarshalJWKS(map[string]ed25519.PublicKey{"k1": publicKey})
+		w.Write(b)
+	}))
+	defer server.Close()
+	defer func(d time.Duration) { jwksMinRefetchInterval = d }(jwksMinRefetchInterval)
+	jwksMinRefetchInterval = 0
+
+	// Initalize config
+	cfg := newInstance(configDefault(Config{JWKSURL: server.URL}))
+	_, err := cfg.jwks.get(context.Background(), cfg, "k1")
+	utils.AssertEqual(t, nil, err)
+
+	t.Run("it should serve known keys while keys are fetched", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cfg.jwks.get(context.Background(), cfg, "k2")
+				utils.AssertEqual(t, errUnknownKeyID, err)
+			}()
+		}
+
+		// Wait for the fetch to be held up by the server
+		for atomic.LoadInt32(&fetches) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		key, err := cfg.jwks.get(context.Background(), cfg, "k1")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, publicKey, key)
+
+		close(release)
+		wg.Wait()
+	})
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	// Serve errors, counting the fetches
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	// Initalize config
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	app := fiber.New()
+
+	app.Use(New(Config{
+		TokenAlgorithm:    TokenAlgorithmEdDSA,
+		KeyID:             "k1",
+		GetSigningKeyFunc: func() ed25519.PrivateKey { return privateKey },
+		JWKSURL:           server.URL,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, err := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), nil)
+	utils.AssertEqual(t, nil, err)
+
+	t.Run("it should reject requests with a generic server error", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+		utils.AssertEqual(t, "signing keys are unavailable", string(body))
+	})
+
+	t.Run("it should back off fetching keys after a failure", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+		utils.AssertEqual(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+}
@@ -0,0 +1,82 @@
+package signed
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// keyAgeWarnInterval limits how often KeyAgeExceeded is called per key
+var keyAgeWarnInterval = 1 * time.Hour
+
+// KeyFingerprint returns a short, non-reversible identifier for a private key
+// which is safe to log
+func KeyFingerprint(privateKey string) string {
+	sum := sha256.Sum256([]byte(privateKey))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// keyAgeTracker records when each key was first seen and when its age was
+// last reported
+type keyAgeTracker struct {
+	mu        sync.Mutex
+	firstSeen map[string]time.Time
+	warned    map[string]time.Time
+}
+
+// reset drops all tracked keys
+func (k *keyAgeTracker) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.firstSeen = make(map[string]time.Time)
+	k.warned = make(map[string]time.Time)
+}
+
+// check reports the key through KeyAgeExceeded when it is older than
+// MaxKeyAge
+func (k *keyAgeTracker) check(cfg *instance, ctx context.Context, privateKey string) {
+	fingerprint := KeyFingerprint(privateKey)
+	now := timeNow()
+
+	k.mu.Lock()
+	firstSeen, ok := k.firstSeen[fingerprint]
+	if !ok {
+		firstSeen = cfg.loadKeyFirstSeen(ctx, fingerprint, now)
+		k.firstSeen[fingerprint] = firstSeen
+	}
+
+	age := now.Sub(firstSeen)
+	report := age > cfg.MaxKeyAge && now.Sub(k.warned[fingerprint]) > keyAgeWarnInterval
+	if report {
+		k.warned[fingerprint] = now
+	}
+	k.mu.Unlock()
+
+	if report {
+		cfg.KeyAgeExceeded(fingerprint, age)
+	}
+}
+
+// loadKeyFirstSeen returns when the key was first seen according to Storage,
+// read with ctx, recording now if it has not been seen before. Without
+// Storage keys are tracked from when this process first used them.
+func (cfg *instance) loadKeyFirstSeen(ctx context.Context, fingerprint string, now time.Time) time.Time {
+	if cfg.Storage == nil {
+		return now
+	}
+
+	storage, key := cfg.getStorage(ctx), cfg.storageKey(StorageKindKeys, fingerprint)
+	if b, err := storage.Get(key); err == nil && len(b) > 0 {
+		if i, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return time.Unix(i, 0)
+		}
+	}
+
+	_ = storage.Set(key, []byte(strconv.FormatInt(now.Unix(), 10)), cfg.storageTTL(StorageKindKeys, 0))
+
+	return now
+}
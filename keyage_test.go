@@ -0,0 +1,60 @@
+package signed
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestKeyAge(t *testing.T) {
+
+	t.Run("it should report a key older than MaxKeyAge once", func(t *testing.T) {
+		storage := newTestStorage()
+		firstSeen := time.Now().Add(-100 * 24 * time.Hour)
+		storage.Set("fiber-signed:keys:"+KeyFingerprint("secret"), []byte(strconv.FormatInt(firstSeen.Unix(), 10)), 0)
+
+		var reported []string
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			MaxKeyAge:         90 * 24 * time.Hour,
+			KeyAgeExceeded: func(fingerprint string, age time.Duration) {
+				reported = append(reported, fingerprint)
+			},
+		}))
+
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/"))
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/"))
+
+		utils.AssertEqual(t, []string{KeyFingerprint("secret")}, reported)
+	})
+
+	t.Run("it should record first use of a new key in Storage", func(t *testing.T) {
+		storage := newTestStorage()
+		now := time.Now()
+		restore := InjectFaults(Faults{Now: now})
+		defer restore()
+
+		var reported []string
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "new secret" },
+			Storage:           storage,
+			MaxKeyAge:         90 * 24 * time.Hour,
+			KeyAgeExceeded: func(fingerprint string, age time.Duration) {
+				reported = append(reported, fingerprint)
+			},
+		}))
+
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/"))
+
+		b, _ := storage.Get("fiber-signed:keys:" + KeyFingerprint("new secret"))
+		utils.AssertEqual(t, strconv.FormatInt(now.Unix(), 10), string(b))
+		utils.AssertEqual(t, 0, len(reported))
+	})
+}
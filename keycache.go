@@ -0,0 +1,112 @@
+package signed
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keyCache keeps the private key warm in memory, refreshing it in the
+// background every KeyCacheTTL so requests never wait on the key provider.
+// The key replaced by the last rotation is kept for KeyRotationGrace.
+type keyCache struct {
+	mu      sync.RWMutex
+	current string
+	prior   string
+	rotated time.Time
+	fetched bool
+	grace   time.Duration
+	stop    chan struct{}
+}
+
+// reset stops refreshing and drops the cached keys
+func (k *keyCache) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.stop != nil {
+		close(k.stop)
+	}
+	k.current, k.prior, k.rotated, k.fetched, k.grace, k.stop = "", "", time.Time{}, false, 0, nil
+}
+
+// start fetches the key of config and keeps refreshing it every KeyCacheTTL
+// until reset. The config is read up front, so it is unaffected by tenant
+// swaps.
+func (k *keyCache) start(config Config) {
+	getPrivateKeyContext, getPrivateKey, timeout := config.GetPrivateKeyContextFunc, config.GetPrivateKeyFunc, config.KeyTimeout
+	fetch := func() (string, error) {
+		var privateKey string
+		err := callWithTimeout(timeout, func() error {
+			if getPrivateKeyContext == nil {
+				privateKey = getPrivateKey()
+				return nil
+			}
+
+			var err error
+			privateKey, err = getPrivateKeyContext(context.Background())
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return privateKey, nil
+	}
+
+	stop := make(chan struct{})
+	k.mu.Lock()
+	k.grace, k.stop = config.KeyRotationGrace, stop
+	k.mu.Unlock()
+
+	k.refresh(fetch)
+	go func() {
+		ticker := time.NewTicker(config.KeyCacheTTL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				k.refresh(fetch)
+			}
+		}
+	}()
+}
+
+// refresh fetches the key, keeping the last one fetched if it fails
+func (k *keyCache) refresh(fetch func() (string, error)) {
+	privateKey, err := fetch()
+	if err != nil || privateKey == "" {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.fetched && privateKey != k.current {
+		k.prior, k.rotated = k.current, timeNow()
+	}
+	k.current, k.fetched = privateKey, true
+}
+
+// get returns the cached key, reporting whether there is one
+func (k *keyCache) get() (string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current, k.fetched
+}
+
+// previous returns the key replaced by the last rotation while it is within
+// KeyRotationGrace, if any
+func (k *keyCache) previous() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.prior == "" || timeNow().Sub(k.rotated) >= k.grace {
+		return nil
+	}
+
+	return []string{k.prior}
+}
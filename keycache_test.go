@@ -0,0 +1,100 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestKeyCache(t *testing.T) {
+	// Initalize config
+	var calls int64
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string {
+			atomic.AddInt64(&calls, 1)
+			return "secret"
+		},
+		KeyCacheTTL: time.Hour,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should only fetch the key in the background", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		for i := 0; i < 3; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+		utils.AssertEqual(t, int64(1), atomic.LoadInt64(&calls))
+	})
+}
+
+func TestKeyRotationGrace(t *testing.T) {
+	var key atomic.Value
+	newApp := func(grace time.Duration) *fiber.App {
+		key.Store("old")
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return key.Load().(string) },
+			KeyCacheTTL:       10 * time.Millisecond,
+			KeyRotationGrace:  grace,
+		}))
+
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		return app
+	}
+
+	// rotate signs a URL with the old key, then waits for the new one to be
+	// picked up
+	rotate := func() string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		key.Store("new")
+		time.Sleep(50 * time.Millisecond)
+		return signedURL
+	}
+
+	t.Run("it should accept the previous key during the grace period", func(t *testing.T) {
+		app := newApp(time.Minute)
+		signedURL := rotate()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		newURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		resp, _ = app.Test(newTestRequest(http.MethodGet, newURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject the previous key after the grace period", func(t *testing.T) {
+		app := newApp(time.Minute)
+		signedURL := rotate()
+
+		restore := InjectFaults(Faults{ClockSkew: 2 * time.Minute})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject the previous key without a grace period", func(t *testing.T) {
+		app := newApp(0)
+		signedURL := rotate()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,53 @@
+package signed
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestGenerateSecret(t *testing.T) {
+
+	t.Run("it should return a random secret of the requested size", func(t *testing.T) {
+		a, err := GenerateSecret(32)
+		utils.AssertEqual(t, nil, err)
+		b, _ := GenerateSecret(32)
+
+		decoded, _ := base64.RawURLEncoding.DecodeString(a)
+		utils.AssertEqual(t, 32, len(decoded))
+		utils.AssertEqual(t, false, a == b)
+	})
+
+	t.Run("it should not return a weak secret", func(t *testing.T) {
+		_, err := GenerateSecret(8)
+		utils.AssertEqual(t, "secret must be at least 16 bytes", err.Error())
+	})
+}
+
+func TestKeyPEM(t *testing.T) {
+
+	t.Run("it should round trip a key pair through PEM", func(t *testing.T) {
+		publicKey, privateKey, err := GenerateEd25519KeyPair()
+		utils.AssertEqual(t, nil, err)
+
+		privatePEM, _ := EncodePrivateKeyPEM(privateKey)
+		publicPEM, _ := EncodePublicKeyPEM(publicKey)
+
+		decodedPrivate, err := DecodePrivateKeyPEM(privatePEM)
+		utils.AssertEqual(t, nil, err)
+		decodedPublic, err := DecodePublicKeyPEM(publicPEM)
+		utils.AssertEqual(t, nil, err)
+
+		utils.AssertEqual(t, privateKey, decodedPrivate)
+		utils.AssertEqual(t, publicKey, decodedPublic)
+	})
+
+	t.Run("it should not decode mismatched PEM blocks", func(t *testing.T) {
+		publicKey, _, _ := GenerateEd25519KeyPair()
+		publicPEM, _ := EncodePublicKeyPEM(publicKey)
+
+		_, err := DecodePrivateKeyPEM(publicPEM)
+		utils.AssertEqual(t, "no PRIVATE KEY PEM block found", err.Error())
+	})
+}
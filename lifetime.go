@@ -0,0 +1,133 @@
+package signed
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultLifetimeBounds are the bucket bounds of NewLifetimeHistogram when
+// none are given
+var DefaultLifetimeBounds = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// getURLLifetime returns when the URL of a verified request expires and when
+// it was issued, each zero if the URL doesn't carry it
+func (cfg *instance) getURLLifetime(c *fiber.Ctx) (expires, issued time.Time) {
+	if c.Query(cfg.TokenQueryKey) != "" {
+		return cfg.getTokenExpiry(getTokenClaims(c), ctxQuery(c)), issued
+	}
+
+	return cfg.getURLLifetimeFrom(requestContext(c), ctxQuery(c))
+}
+
+// getURLLifetimeFrom returns when a URL expires and when it was issued, each
+// zero if it doesn't carry it, looking up its query params with query
+func (cfg *instance) getURLLifetimeFrom(ctx context.Context, query func(string) string) (expires, issued time.Time) {
+	if token := query(cfg.TokenQueryKey); token != "" {
+		claims, _ := cfg.parseToken(ctx, token)
+		return cfg.getTokenExpiry(claims, query), issued
+	}
+
+	expires, _ = cfg.getEarliestExpiry(query)
+	if i, err := strconv.ParseInt(query(cfg.IssuedQueryKey), 10, 64); err == nil {
+		issued = time.Unix(i, 0)
+	}
+
+	return expires, issued
+}
+
+// LifetimeBuckets counts uses of signed URLs by how long they had left until
+// expiry and by how long ago they were issued. Remaining[i] and Age[i] count
+// uses up to Bounds[i] (and over the bound before), and their last element
+// uses over every bound.
+type LifetimeBuckets struct {
+	Bounds    []time.Duration
+	Remaining []int
+	Age       []int
+}
+
+// observe counts d in counts
+func (b LifetimeBuckets) observe(counts []int, d time.Duration) {
+	counts[sort.Search(len(b.Bounds), func(i int) bool { return d <= b.Bounds[i] })]++
+}
+
+// LifetimeHistogram records the distribution of how long signed URLs have
+// left until expiry, and how old they are, when they are used, per purpose,
+// so TTLs can be tuned to real usage. Subscribe its Observe method to events.
+type LifetimeHistogram struct {
+	mu        sync.Mutex
+	bounds    []time.Duration
+	byPurpose map[string]LifetimeBuckets
+}
+
+// NewLifetimeHistogram creates a LifetimeHistogram with buckets up to bounds,
+// in ascending order, or DefaultLifetimeBounds if none are given
+func NewLifetimeHistogram(bounds ...time.Duration) *LifetimeHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLifetimeBounds
+	}
+
+	return &LifetimeHistogram{
+		bounds:    append([]time.Duration(nil), bounds...),
+		byPurpose: make(map[string]LifetimeBuckets),
+	}
+}
+
+// Observe records the lifetime of the URL of an EventVerified event,
+// ignoring other events and URLs without an expiry or issued time for the
+// respective distribution
+func (h *LifetimeHistogram) Observe(e Event) {
+	if e.Type != EventVerified || (e.Expires.IsZero() && e.Issued.IsZero()) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, ok := h.byPurpose[e.Purpose]
+	if !ok {
+		b = LifetimeBuckets{
+			Bounds:    h.bounds,
+			Remaining: make([]int, len(h.bounds)+1),
+			Age:       make([]int, len(h.bounds)+1),
+		}
+		h.byPurpose[e.Purpose] = b
+	}
+
+	if !e.Expires.IsZero() {
+		b.observe(b.Remaining, e.Expires.Sub(e.Time))
+	}
+	if !e.Issued.IsZero() {
+		b.observe(b.Age, e.Time.Sub(e.Issued))
+	}
+}
+
+// Snapshot returns the counts recorded so far by purpose, with "" for URLs
+// without one
+func (h *LifetimeHistogram) Snapshot() map[string]LifetimeBuckets {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := make(map[string]LifetimeBuckets, len(h.byPurpose))
+	for purpose, b := range h.byPurpose {
+		snapshot[purpose] = LifetimeBuckets{
+			Bounds:    b.Bounds,
+			Remaining: append([]int(nil), b.Remaining...),
+			Age:       append([]int(nil), b.Age...),
+		}
+	}
+
+	return snapshot
+}
@@ -0,0 +1,96 @@
+package signed
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestLifetimeHistogram(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	h := NewLifetimeHistogram(time.Minute, time.Hour)
+	unsubscribe := Subscribe(h.Observe)
+	defer unsubscribe()
+
+	use := func(query string) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?"+query))
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+	}
+	at := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+	}
+
+	use("expires=" + at(30*time.Second) + "&issued=" + at(-2*time.Hour))
+	use("expires=" + at(30*time.Minute))
+	use("expiresIn=7200&issued=" + at(-10*time.Second) + "&purpose=invite")
+	use("purpose=download")
+
+	// Rejected requests are not recorded
+	app.Test(newTestRequest(http.MethodGet, "http://example.com/?expires="+at(time.Minute)+"&signature=wrong"))
+
+	snapshot := h.Snapshot()
+
+	t.Run("it should record the time left until expiry at use", func(t *testing.T) {
+		utils.AssertEqual(t, []int{1, 1, 0}, snapshot[""].Remaining)
+		utils.AssertEqual(t, []int{0, 0, 1}, snapshot["invite"].Remaining)
+	})
+
+	t.Run("it should record the age at use", func(t *testing.T) {
+		utils.AssertEqual(t, []int{0, 0, 1}, snapshot[""].Age)
+		utils.AssertEqual(t, []int{1, 0, 0}, snapshot["invite"].Age)
+	})
+
+	t.Run("it should skip urls without an expiry or issued time", func(t *testing.T) {
+		_, ok := snapshot["download"]
+
+		utils.AssertEqual(t, false, ok)
+	})
+
+	t.Run("it should report the bounds of the buckets", func(t *testing.T) {
+		utils.AssertEqual(t, []time.Duration{time.Minute, time.Hour}, snapshot[""].Bounds)
+	})
+}
+
+func TestEventLifetime(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	var verified Event
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Type == EventVerified {
+			verified = e
+		}
+	})
+	defer unsubscribe()
+
+	t.Run("it should report the expiry and issued time of verified urls", func(t *testing.T) {
+		issued := time.Now().Add(-time.Minute).Unix()
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?expiresIn=3600&issued="+strconv.FormatInt(issued, 10)))
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, time.Unix(issued+3600, 0), verified.Expires)
+		utils.AssertEqual(t, time.Unix(issued, 0), verified.Issued)
+	})
+}
@@ -0,0 +1,103 @@
+package signed
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// failureReasonLocal holds the failure reason of the request, once checked
+// for FailureKey
+const failureReasonLocal = "fiber-signed:failure-reason"
+
+// Failure reasons of FailureKey
+const (
+	// FailureMissing is the reason of requests without a signature or token
+	FailureMissing = "missing"
+	// FailureExpired is the reason of requests for expired URLs
+	FailureExpired = "expired"
+	// FailureInvalid is the reason of requests whose signature doesn't match
+	FailureInvalid = "invalid"
+	// FailureRejected is the reason of requests rejected for anything else
+	FailureRejected = "rejected"
+)
+
+// getFailureReason returns why the signature of the request would be
+// rejected, or "" if it is valid. Only the signature and its expiry are
+// checked, not the stateful checks, which the middleware runs when the
+// request reaches it. The request is left as it was.
+func (cfg *instance) getFailureReason(c *fiber.Ctx) string {
+	if reason, ok := c.Locals(failureReasonLocal).(string); ok {
+		return reason
+	}
+
+	// Transports may rewrite the request while it is checked
+	uri, path := string(c.Request().RequestURI()), utils.CopyString(c.Path())
+	defer func() {
+		restoreSignedPath(c)
+		if string(c.Request().RequestURI()) != uri {
+			c.Request().SetRequestURI(uri)
+			c.Request().URI() // Parse it back into the buffer of the named params
+		}
+		if c.Path() != path {
+			c.Path(path)
+		}
+	}()
+
+	err := cfg.checkFraming(c)
+	if err == nil {
+		err = cfg.extractTransportParams(c)
+	}
+	if err == nil {
+		_, err = cfg.validateRequest(c)
+	}
+
+	var reason string
+	switch {
+	case err == nil:
+	case err == errExpired:
+		reason = FailureExpired
+	case err == errInvalidSignature:
+		reason = FailureInvalid
+	case c.Query(cfg.SignatureQueryKey) == "" && c.Query(cfg.TokenQueryKey) == "":
+		reason = FailureMissing
+	default:
+		reason = FailureRejected
+	}
+	c.Locals(failureReasonLocal, reason)
+
+	return reason
+}
+
+// FailureKey returns a key for Fiber's limiter middleware (its KeyGenerator)
+// bucketing requests by IP, route and the reason their signature would be
+// rejected, eg. "203.0.113.7|/files/*|expired", or "" if it is valid. Reasons
+// are one of FailureMissing, FailureExpired, FailureInvalid and
+// FailureRejected. The limiter must run before the middleware, which never
+// passes rejected requests on.
+func FailureKey(c *fiber.Ctx) string {
+	cfg := instanceOf(c)
+
+	reason := cfg.getFailureReason(c)
+	if reason == "" {
+		return ""
+	}
+
+	return c.IP() + "|" + c.Route().Path + "|" + reason
+}
+
+// FailureLimiter returns Fiber's limiter middleware configured with config,
+// counting only requests whose signature would be rejected, under FailureKey,
+// so clients repeatedly presenting bad URLs are throttled without limiting
+// valid ones. Register it before the middleware, on the routes (or groups)
+// to bucket apart, as the route of a key is the one the limiter is
+// registered on.
+func FailureLimiter(config limiter.Config) fiber.Handler {
+	next := config.Next
+	config.Next = func(c *fiber.Ctx) bool {
+		return (next != nil && next(c)) || instanceOf(c).getFailureReason(c) == ""
+	}
+	config.KeyGenerator = FailureKey
+
+	return limiter.New(config)
+}
@@ -0,0 +1,101 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestFailureKey(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	var keys []string
+	app.Get("/files/*", func(c *fiber.Ctx) error {
+		keys = append(keys, FailureKey(c))
+		return c.Next()
+	}, New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}), func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	t.Run("it should bucket failures by ip, route and reason", func(t *testing.T) {
+		keys = nil
+		expired := sign(fmt.Sprintf("http://example.com/files/a?expires=%d", time.Now().Add(-time.Hour).Unix()))
+		invalid := strings.Replace(sign("http://example.com/files/a"), "signature=", "signature=x", 1)
+
+		for _, target := range []string{"http://example.com/files/a", expired, invalid, "http://example.com/files/a?signature=x&expires=soon"} {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, target))
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		}
+
+		utils.AssertEqual(t, []string{
+			"0.0.0.0|/files/*|missing",
+			"0.0.0.0|/files/*|expired",
+			"0.0.0.0|/files/*|invalid",
+			"0.0.0.0|/files/*|rejected",
+		}, keys)
+	})
+
+	t.Run("it should return no key for valid signatures", func(t *testing.T) {
+		keys = nil
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/files/a")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, []string{""}, keys)
+	})
+}
+
+func TestFailureLimiter(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(FailureLimiter(limiter.Config{Max: 2, Expiration: time.Minute}))
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Transport:         PathTransport{},
+	}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString(c.Path())
+	})
+
+	// Signatures in the path are checked with the request rewritten
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/a?expires=%d", time.Now().Add(time.Hour).Unix()), nil)
+	utils.AssertEqual(t, nil, SignRequest(r))
+	signedURL := r.URL.String()
+	invalid := strings.Replace(signedURL, "/a", "/b", 1)
+
+	t.Run("it should throttle repeated failures", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, invalid))
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, invalid))
+		utils.AssertEqual(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("it should not count or throttle valid requests", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+			utils.AssertEqual(t, "/a", string(body))
+		}
+	})
+}
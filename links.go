@@ -0,0 +1,128 @@
+package signed
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LinkSignerConfig defines the config for NewLinkSigner
+type LinkSignerConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// Patterns are the paths of links to sign, where "*" matches any sequence
+	// of characters, eg. "/files/*".
+	//
+	// Required.
+	Patterns []string
+
+	// TTL signs links to expire TTL after the page is rendered, as with
+	// GetSignedURLWithTTLFromHTTPRequest. Zero signs links without an expiry.
+	//
+	// Optional. Default: 0
+	TTL time.Duration
+}
+
+// linkAttr matches href and src attributes with quoted values
+var linkAttr = regexp.MustCompile(`(?i)(\s(?:href|src)\s*=\s*)("[^"]*"|'[^']*')`)
+
+// NewLinkSigner creates a middleware handler which rewrites the href and src
+// attributes of HTML responses matching Patterns into signed URLs, so
+// server-rendered apps don't have to thread the signer through every
+// template. Only root-relative links and absolute links to the host of the
+// request are signed, keeping their form. Streamed and already encoded (eg.
+// compressed) responses are left untouched, so register it after compression
+// middleware.
+func NewLinkSigner(config LinkSignerConfig) fiber.Handler {
+	return registerLinkSigner(func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.IsBodyStream() || len(resp.Header.Peek(fiber.HeaderContentEncoding)) > 0 {
+			return nil
+		}
+		if !strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMETextHTML) {
+			return nil
+		}
+
+		cfg, baseURL := instanceOf(c), c.BaseURL()
+		body := linkAttr.ReplaceAllFunc(resp.Body(), func(attr []byte) []byte {
+			m := linkAttr.FindSubmatch(attr)
+			quote := m[2][0]
+			link := html.UnescapeString(string(m[2][1 : len(m[2])-1]))
+
+			signedLink, ok := cfg.signLink(config, baseURL, link)
+			if !ok {
+				return attr
+			}
+
+			return []byte(string(m[1]) + string(quote) + html.EscapeString(signedLink) + string(quote))
+		})
+		resp.SetBodyRaw(body)
+
+		return nil
+	})
+}
+
+// signLink returns link signed if it matches the patterns of config and
+// points at baseURL, in the same root-relative or absolute form
+func (cfg *instance) signLink(config LinkSignerConfig, baseURL, link string) (string, bool) {
+	relative := strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//")
+	if !relative && !strings.HasPrefix(link, baseURL+"/") {
+		return "", false
+	}
+
+	target := link
+	if relative {
+		target = baseURL + link
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+
+	matched := false
+	for _, pattern := range config.Patterns {
+		if matchWildcard(pattern, u.Path) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return "", false
+	}
+
+	r, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return "", false
+	}
+
+	var signedURL string
+	if config.TTL > 0 {
+		signedURL, err = GetSignedURLWithTTLFromHTTPRequest(r, config.TTL)
+	} else {
+		signedURL, err = GetSignedURLFromHTTPRequest(r)
+	}
+	if err != nil {
+		return "", false
+	}
+
+	if relative {
+		return strings.TrimPrefix(signedURL, baseURL), true
+	}
+	return signedURL, true
+}
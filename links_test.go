@@ -0,0 +1,46 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestLinkSigner(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	verify := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	})
+
+	app.Get("/page", NewLinkSigner(LinkSignerConfig{Patterns: []string{"/files/*"}}), func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.SendString(`<a href="/files/a.pdf?v=1&amp;x=2">a</a> <img src='http://example.com/files/b.png'> <a href="/about">about</a> <a href="https://other.com/files/c.pdf">c</a>`)
+	})
+
+	app.Get("/files/*", verify, func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/page"))
+	b, _ := ioutil.ReadAll(resp.Body)
+	body := string(b)
+
+	t.Run("it should sign matching links on the same host", func(t *testing.T) {
+		links := regexp.MustCompile(`(?:href|src)=["']([^"']*)`).FindAllStringSubmatch(body, -1)
+
+		utils.AssertEqual(t, 4, len(links))
+		utils.AssertEqual(t, true, regexp.MustCompile(`^/files/a\.pdf\?signature=\w+&amp;v=1&amp;x=2$`).MatchString(links[0][1]))
+		utils.AssertEqual(t, true, regexp.MustCompile(`^http://example\.com/files/b\.png\?signature=\w+$`).MatchString(links[1][1]))
+		utils.AssertEqual(t, "/about", links[2][1])
+		utils.AssertEqual(t, "https://other.com/files/c.pdf", links[3][1])
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, links[1][1]))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
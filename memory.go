@@ -0,0 +1,239 @@
+package signed
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	// memoryShards is the number of independently locked shards of a
+	// MemoryStorage
+	memoryShards = 32
+	// memoryWheelSlots is the number of slots of the expiry wheel of a
+	// MemoryStorage
+	memoryWheelSlots = 64
+)
+
+// memoryTick is the resolution expired entries of a MemoryStorage are
+// dropped at. Gets never return expired entries regardless.
+var memoryTick = time.Second
+
+// MemoryStorage is an in-memory fiber.Storage for nonces, uses, leases and
+// the rest of the middleware state, so single-node deployments get replay
+// protection without external infrastructure. Keys are spread over sharded
+// maps, expired entries are dropped by a timing wheel, and the least recently
+// used entries are evicted beyond the maximum number of entries.
+//
+// Evicting nonce or uses entries allows their URLs to be used again, so
+// maxEntries should comfortably exceed the number of live single-use URLs.
+type MemoryStorage struct {
+	shards [memoryShards]*memoryShard
+	done   chan struct{}
+	once   sync.Once
+}
+
+// memoryShard is one shard of a MemoryStorage
+type memoryShard struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	wheel      [memoryWheelSlots]map[string]struct{}
+	lastTick   int64
+}
+
+// memoryEntry is a value stored in a memoryShard
+type memoryEntry struct {
+	key     string
+	val     []byte
+	expires int64
+	slot    int
+}
+
+// NewMemoryStorage creates a MemoryStorage holding up to maxEntries entries.
+// Zero means no limit. Close stops its expiry goroutine.
+func NewMemoryStorage(maxEntries int) *MemoryStorage {
+	s := &MemoryStorage{done: make(chan struct{})}
+
+	// Round up so the limit is never below maxEntries
+	perShard := 0
+	if maxEntries > 0 {
+		perShard = (maxEntries + memoryShards - 1) / memoryShards
+	}
+
+	now := time.Now().UnixNano() / int64(memoryTick)
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{maxEntries: perShard, lastTick: now}
+		s.shards[i].reset()
+	}
+
+	go s.expire()
+
+	return s
+}
+
+// shard returns the shard of key
+func (s *MemoryStorage) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%memoryShards]
+}
+
+// expire drops expired entries every tick until the storage is closed
+func (s *MemoryStorage) expire() {
+	ticker := time.NewTicker(memoryTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case t := <-ticker.C:
+			for _, shard := range s.shards {
+				shard.advance(t.UnixNano())
+			}
+		}
+	}
+}
+
+// Get implements fiber.Storage
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	return s.shard(key).get(key, time.Now().UnixNano()), nil
+}
+
+// Set implements fiber.Storage
+func (s *MemoryStorage) Set(key string, val []byte, exp time.Duration) error {
+	var expires int64
+	if exp > 0 {
+		expires = time.Now().Add(exp).UnixNano()
+	}
+
+	s.shard(key).set(key, append([]byte(nil), val...), expires)
+	return nil
+}
+
+// Delete implements fiber.Storage
+func (s *MemoryStorage) Delete(key string) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if e, ok := shard.entries[key]; ok {
+		shard.remove(e)
+	}
+	return nil
+}
+
+// Reset implements fiber.Storage
+func (s *MemoryStorage) Reset() error {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		shard.reset()
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+// Close implements fiber.Storage, stopping the expiry goroutine
+func (s *MemoryStorage) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+// Len returns the number of entries held, including expired entries not
+// dropped yet
+func (s *MemoryStorage) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// reset drops every entry. The caller must hold mu.
+func (m *memoryShard) reset() {
+	m.entries = make(map[string]*list.Element)
+	m.lru = list.New()
+	for i := range m.wheel {
+		m.wheel[i] = make(map[string]struct{})
+	}
+}
+
+// get returns the unexpired value of key, marking it as recently used
+func (m *memoryShard) get(key string, now int64) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := e.Value.(*memoryEntry)
+	if entry.expires != 0 && entry.expires <= now {
+		m.remove(e)
+		return nil
+	}
+	m.lru.MoveToFront(e)
+
+	return entry.val
+}
+
+// set stores val for key until expires, evicting the least recently used
+// entry if the shard is full
+func (m *memoryShard) set(key string, val []byte, expires int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		m.remove(e)
+	}
+
+	if m.maxEntries > 0 && len(m.entries) >= m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+
+	entry := &memoryEntry{key: key, val: val, expires: expires, slot: -1}
+	if expires != 0 {
+		entry.slot = int(expires/int64(memoryTick)) % memoryWheelSlots
+		m.wheel[entry.slot][key] = struct{}{}
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+}
+
+// remove drops an entry. The caller must hold mu.
+func (m *memoryShard) remove(e *list.Element) {
+	entry := m.lru.Remove(e).(*memoryEntry)
+	delete(m.entries, entry.key)
+	if entry.slot >= 0 {
+		delete(m.wheel[entry.slot], entry.key)
+	}
+}
+
+// advance drops the expired entries of every slot passed since the last
+// tick. Entries expiring more than a revolution of the wheel later stay in
+// their slot until a later revolution.
+func (m *memoryShard) advance(now int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tick := now / int64(memoryTick)
+	from := m.lastTick
+	if tick-from >= memoryWheelSlots {
+		from = tick - memoryWheelSlots + 1
+	}
+
+	for t := from; t <= tick; t++ {
+		for key := range m.wheel[t%memoryWheelSlots] {
+			e := m.entries[key]
+			if e.Value.(*memoryEntry).expires <= now {
+				m.remove(e)
+			}
+		}
+	}
+	m.lastTick = tick
+}
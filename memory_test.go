@@ -0,0 +1,99 @@
+package signed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	t.Run("it should get, set and delete entries", func(t *testing.T) {
+		s := NewMemoryStorage(0)
+		defer s.Close()
+
+		_ = s.Set("a", []byte("1"), 0)
+		b, _ := s.Get("a")
+		utils.AssertEqual(t, "1", string(b))
+
+		_ = s.Delete("a")
+		b, _ = s.Get("a")
+		utils.AssertEqual(t, 0, len(b))
+	})
+
+	t.Run("it should not return expired entries", func(t *testing.T) {
+		s := NewMemoryStorage(0)
+		defer s.Close()
+
+		_ = s.Set("a", []byte("1"), time.Nanosecond)
+		time.Sleep(time.Millisecond)
+
+		b, _ := s.Get("a")
+		utils.AssertEqual(t, 0, len(b))
+	})
+
+	t.Run("it should drop expired entries as the wheel turns", func(t *testing.T) {
+		s := NewMemoryStorage(0)
+		defer s.Close()
+
+		for i := 0; i < 100; i++ {
+			_ = s.Set(fmt.Sprintf("short-%d", i), []byte("1"), time.Second)
+			_ = s.Set(fmt.Sprintf("long-%d", i), []byte("1"), time.Hour)
+		}
+		utils.AssertEqual(t, 200, s.Len())
+
+		now := time.Now().Add(2 * time.Second).UnixNano()
+		for _, shard := range s.shards {
+			shard.advance(now)
+		}
+		utils.AssertEqual(t, 100, s.Len())
+	})
+
+	t.Run("it should evict the least recently used entries beyond the maximum", func(t *testing.T) {
+		s := NewMemoryStorage(memoryShards)
+		defer s.Close()
+
+		// Find three keys in the same shard, which holds one entry
+		var keys []string
+		for i := 0; len(keys) < 3; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if s.shard(key) == s.shard("key-0") {
+				keys = append(keys, key)
+			}
+		}
+
+		_ = s.Set(keys[0], []byte("1"), 0)
+		_ = s.Set(keys[1], []byte("1"), 0)
+
+		b, _ := s.Get(keys[0])
+		utils.AssertEqual(t, 0, len(b))
+		b, _ = s.Get(keys[1])
+		utils.AssertEqual(t, "1", string(b))
+	})
+
+	t.Run("it should protect single-use urls without external storage", func(t *testing.T) {
+		s := NewMemoryStorage(10000)
+		defer s.Close()
+
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           s,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL, _ := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,120 @@
+package signed
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// acceptRange is a range of an Accept or Accept-Language header, eg.
+// "text/html" or "de-ch", with its quality
+type acceptRange struct {
+	value   string
+	quality float64
+}
+
+// parseAccept returns the ranges of an Accept or Accept-Language header,
+// lowercased and most preferred first, leaving out those with zero quality
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, acceptRange{value: value, quality: quality})
+	}
+
+	// Ranges of equal quality keep the order of the header
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	return ranges
+}
+
+// matchLanguage returns the language of Messages best matching tag: the
+// language itself, the language with subtags of tag dropped from the end, eg.
+// "de" for "de-CH", or the first language (in order) tag is a prefix of, eg.
+// "de-AT" for "de". "*" matches no language, leaving messages unlocalized.
+func (cfg *instance) matchLanguage(tag string) (string, bool) {
+	languages := make([]string, 0, len(cfg.Messages))
+	for language := range cfg.Messages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	for prefix := tag; prefix != ""; {
+		for _, language := range languages {
+			if strings.EqualFold(language, prefix) {
+				return language, true
+			}
+		}
+
+		split := strings.LastIndexByte(prefix, '-')
+		if split < 0 {
+			break
+		}
+		prefix = prefix[:split]
+	}
+
+	for _, language := range languages {
+		if strings.HasPrefix(strings.ToLower(language), tag+"-") {
+			return language, true
+		}
+	}
+
+	return "", false
+}
+
+// localizeRejection returns message, a rejection message, as translated in
+// Messages for the language best matching the Accept-Language header of the
+// request, or message itself if there is no translation
+func (cfg *instance) localizeRejection(c *fiber.Ctx, message string) string {
+	if len(cfg.Messages) == 0 {
+		return message
+	}
+
+	// Responses differ by language, so caches must tell them apart
+	c.Vary(fiber.HeaderAcceptLanguage)
+
+	for _, r := range parseAccept(c.Get(fiber.HeaderAcceptLanguage)) {
+		language, ok := cfg.matchLanguage(r.value)
+		if !ok {
+			continue
+		}
+
+		messages := cfg.Messages[language]
+		localized, ok := messages[message]
+		if !ok {
+			localized, ok = messages[""]
+		}
+		if !ok {
+			return message
+		}
+
+		c.Set(fiber.HeaderContentLanguage, language)
+		return localized
+	}
+
+	return message
+}
@@ -0,0 +1,84 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMessages(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Messages: map[string]map[string]string{
+			"de": {
+				"url signature has expired": "Dieser Link ist abgelaufen.",
+			},
+			"fr-CA": {
+				"": "Ce lien n'est plus valide.",
+			},
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	expiredURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?expires=1"))
+	reject := func(target, acceptLanguage string) (*http.Response, string) {
+		req := newTestRequest(http.MethodGet, target)
+		if acceptLanguage != "" {
+			req.Header.Set(fiber.HeaderAcceptLanguage, acceptLanguage)
+		}
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("it should translate messages to the best matching language", func(t *testing.T) {
+		resp, body := reject(expiredURL, "en;q=0.5, de-CH, fr;q=0.8")
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "Dieser Link ist abgelaufen.", body)
+		utils.AssertEqual(t, "de", resp.Header.Get(fiber.HeaderContentLanguage))
+		utils.AssertEqual(t, fiber.HeaderAcceptLanguage, resp.Header.Get(fiber.HeaderVary))
+	})
+
+	t.Run("it should match languages with more subtags than requested", func(t *testing.T) {
+		resp, body := reject("http://example.com/", "fr")
+
+		utils.AssertEqual(t, "Ce lien n'est plus valide.", body)
+		utils.AssertEqual(t, "fr-CA", resp.Header.Get(fiber.HeaderContentLanguage))
+	})
+
+	t.Run("it should send messages without a translation as is", func(t *testing.T) {
+		resp, body := reject("http://example.com/", "de")
+
+		utils.AssertEqual(t, "signature is a required query param for a signed URL route", body)
+		utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderContentLanguage))
+	})
+
+	t.Run("it should skip languages with zero quality", func(t *testing.T) {
+		_, body := reject(expiredURL, "de;q=0, es")
+
+		utils.AssertEqual(t, "url signature has expired", body)
+	})
+}
+
+func TestParseAccept(t *testing.T) {
+	t.Run("it should order ranges by quality", func(t *testing.T) {
+		ranges := parseAccept("en;q=0.5, DE-ch, fr;q=0.8, *;q=0.1, it;q=0")
+
+		utils.AssertEqual(t, []acceptRange{
+			{value: "de-ch", quality: 1},
+			{value: "fr", quality: 0.8},
+			{value: "en", quality: 0.5},
+			{value: "*", quality: 0.1},
+		}, ranges)
+	})
+}
@@ -0,0 +1,66 @@
+package signed
+
+import (
+	"sync/atomic"
+)
+
+// MigrationStats counts signatures verified with each algorithm, to track how
+// many requests still use PreviousAlgorithm while migrating
+type MigrationStats struct {
+	// Current is the number of signatures verified with Algorithm
+	Current uint64
+
+	// Previous is the number of signatures verified with PreviousAlgorithm
+	Previous uint64
+}
+
+// migrationCounter counts signatures verified with each algorithm
+type migrationCounter struct {
+	current  uint64
+	previous uint64
+}
+
+// reset zeroes the counts
+func (m *migrationCounter) reset() {
+	atomic.StoreUint64(&m.current, 0)
+	atomic.StoreUint64(&m.previous, 0)
+}
+
+// record counts a verified signature
+func (m *migrationCounter) record(previous bool) {
+	if previous {
+		atomic.AddUint64(&m.previous, 1)
+	} else {
+		atomic.AddUint64(&m.current, 1)
+	}
+}
+
+// GetMigrationStats returns the number of signatures verified with Algorithm
+// and PreviousAlgorithm since New was called
+func GetMigrationStats() MigrationStats {
+	cfg := current()
+
+	return MigrationStats{
+		Current:  atomic.LoadUint64(&cfg.migrations.current),
+		Previous: atomic.LoadUint64(&cfg.migrations.previous),
+	}
+}
+
+// matchesPreviousAlgorithm reports whether signature was calculated with
+// PreviousAlgorithm, while it is still accepted
+func (cfg *instance) matchesPreviousAlgorithm(signature string, caveats []string, privateKey, binding, method, baseURL, originalURL string, body []byte) bool {
+	if cfg.PreviousAlgorithm == "" || cfg.PreviousAlgorithm == cfg.Algorithm {
+		return false
+	}
+	if !cfg.PreviousAlgorithmUntil.IsZero() && !timeNow().Before(cfg.PreviousAlgorithmUntil) {
+		return false
+	}
+
+	hashedSignature, err := cfg.getSignatureFor(cfg.PreviousAlgorithm, privateKey, binding, method, baseURL, originalURL, body)
+	if err != nil || cfg.chainCaveatsFor(cfg.PreviousAlgorithm, hashedSignature, caveats) != signature {
+		return false
+	}
+
+	cfg.migrations.record(true)
+	return true
+}
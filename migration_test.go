@@ -0,0 +1,63 @@
+package signed
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestAlgorithmMigration(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:      func() string { return "secret" },
+		Algorithm:              AlgorithmHMACSHA256,
+		PreviousAlgorithm:      AlgorithmSHA1,
+		PreviousAlgorithmUntil: time.Now().Add(24 * time.Hour),
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	// Signed with SHA-1 concatenation before the migration
+	legacyURL := "/?signature=d07242c7ef0dfb2e22c5339faa8317fe1f3f670e"
+
+	t.Run("it should sign urls with the new algorithm", func(t *testing.T) {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte("GET&http://example.com/?"))
+		expected := fmt.Sprintf("http://example.com/?signature=%x", mac.Sum(nil))
+
+		got, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, expected, got)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, got))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should accept urls signed with the previous algorithm and count them", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, legacyURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, MigrationStats{Current: 1, Previous: 1}, GetMigrationStats())
+	})
+
+	t.Run("it should reject the previous algorithm once the window has passed", func(t *testing.T) {
+		restore := InjectFaults(Faults{ClockSkew: 48 * time.Hour})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, legacyURL))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
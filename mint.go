@@ -0,0 +1,85 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrMintLimitExceeded is returned by signing functions once MintLimit URLs
+// have been signed in the current MintWindow for the key of the request
+var ErrMintLimitExceeded = errors.New("signed URL minting limit exceeded")
+
+// mintMemoryEntries is the number of counters kept in memory when Storage is
+// not set
+const mintMemoryEntries = 10000
+
+// mintCounters keeps minting counters in memory when Storage is not set
+type mintCounters struct {
+	mu      sync.Mutex
+	storage *MemoryStorage
+}
+
+// get returns the storage of the counters, created on first use
+func (m *mintCounters) get() fiber.Storage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.storage == nil {
+		m.storage = NewMemoryStorage(mintMemoryEntries)
+	}
+
+	return m.storage
+}
+
+// reset drops the counters kept in memory
+func (m *mintCounters) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.storage != nil {
+		m.storage.Reset()
+	}
+}
+
+// checkMintLimit counts a URL signed for r against MintLimit, returning
+// ErrMintLimitExceeded once the limit of its key is reached. URLs signed while
+// signURLWithinBudget tries fallbacks are counted once one fits instead.
+func (cfg *instance) checkMintLimit(r *http.Request) error {
+	if cfg.MintLimit <= 0 {
+		return nil
+	}
+
+	var key string
+	if cfg.MintKeyFunc != nil {
+		key = cfg.MintKeyFunc(r)
+	}
+
+	if d := getDeferredSigning(r.Context()); d != nil {
+		d.mintKey = &key
+		return nil
+	}
+
+	return cfg.countMint(r.Context(), key)
+}
+
+// countMint counts a URL signed with ctx against the MintLimit of key
+func (cfg *instance) countMint(ctx context.Context, key string) error {
+	storage := cfg.mints.get()
+	if cfg.Storage != nil {
+		storage = cfg.getStorage(ctx)
+	}
+
+	allowed, err := cfg.countInWindow(storage, StorageKindMint, cfg.storageKey(StorageKindMint, cfg.getHash(key)), cfg.MintLimit, cfg.MintWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrMintLimitExceeded
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMintLimit(t *testing.T) {
+	sign := func(target string) error {
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return err
+	}
+
+	t.Run("it should refuse to sign beyond the limit of a key", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MintLimit:         2,
+			MintKeyFunc: func(r *http.Request) string {
+				return r.URL.Query().Get("tenant")
+			},
+		})
+
+		utils.AssertEqual(t, nil, sign("http://example.com/?tenant=a"))
+		utils.AssertEqual(t, nil, sign("http://example.com/?tenant=a"))
+		utils.AssertEqual(t, ErrMintLimitExceeded, sign("http://example.com/?tenant=a"))
+		utils.AssertEqual(t, nil, sign("http://example.com/?tenant=b"))
+
+		_, err := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?tenant=a", nil), Claims{})
+		utils.AssertEqual(t, ErrMintLimitExceeded, err)
+	})
+
+	t.Run("it should allow signing again in the next window", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+			MintLimit:         1,
+		})
+
+		utils.AssertEqual(t, nil, sign("http://example.com/"))
+		utils.AssertEqual(t, ErrMintLimitExceeded, sign("http://example.com/"))
+
+		restore := InjectFaults(Faults{ClockSkew: time.Minute})
+		defer restore()
+
+		utils.AssertEqual(t, nil, sign("http://example.com/"))
+	})
+
+	t.Run("it should not limit signing by default", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		})
+
+		for i := 0; i < 10; i++ {
+			utils.AssertEqual(t, nil, sign("http://example.com/"))
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package signed
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MultiTenantConfig defines the config for MultiTenant
+type MultiTenantConfig struct {
+	// Resolver returns the tenant ID of the request
+	//
+	// Optional. Default: the hostname of the request
+	Resolver func(c *fiber.Ctx) (string, error)
+
+	// Tenants maps tenant IDs to their config. Each config is defaulted like
+	// the config of New, except StoragePrefix defaults to
+	// "fiber-signed:<tenant ID>:" so tenants can share a store without seeing
+	// each other's nonces, uses or limits.
+	//
+	// Required.
+	Tenants map[string]Config
+}
+
+// MultiTenant selects an entire Config per request, so SaaS platforms can
+// isolate signing per customer within one Fiber app.
+//
+// Each tenant keeps its own config and state, so requests of separate tenants
+// are verified side by side. Handlers behind Handler calling package functions
+// with the fiber.Ctx of the request use the config of its tenant. Functions
+// taking an *http.Request use it when the request carries the context passed
+// by Do or DoFor, eg. r.WithContext(ctx). Other package functions, eg.
+// RevokeURL, use the config of New.
+type MultiTenant struct {
+	resolver func(c *fiber.Ctx) (string, error)
+	tenants  map[string]*instance
+}
+
+// NewMultiTenant creates a MultiTenant for config
+func NewMultiTenant(config MultiTenantConfig) *MultiTenant {
+	m := &MultiTenant{
+		resolver: config.Resolver,
+		tenants:  make(map[string]*instance, len(config.Tenants)),
+	}
+	if m.resolver == nil {
+		m.resolver = func(c *fiber.Ctx) (string, error) {
+			return c.Hostname(), nil
+		}
+	}
+
+	for id, tc := range config.Tenants {
+		if tc.StoragePrefix == "" {
+			tc.StoragePrefix = ConfigDefault.StoragePrefix + id + ":"
+		}
+		m.tenants[id] = newInstance(configDefault(tc))
+	}
+
+	return m
+}
+
+// resolve returns the instance of the tenant of the request
+func (m *MultiTenant) resolve(c *fiber.Ctx) (*instance, error) {
+	id, err := m.resolver(c)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := m.tenants[id]
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusForbidden, "unknown tenant")
+	}
+
+	return t, nil
+}
+
+// Handler returns the middleware handler, verifying each request with the
+// config of its tenant
+func (m *MultiTenant) Handler() fiber.Handler {
+	return register(func(c *fiber.Ctx) error {
+		cfg, err := m.resolve(c)
+		if err != nil {
+			return err
+		}
+
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		return cfg.serve(c, 0)
+	})
+}
+
+// Do runs fn with a context carrying the config of tenant id
+func (m *MultiTenant) Do(id string, fn func(ctx context.Context) error) error {
+	t, ok := m.tenants[id]
+	if !ok {
+		return errors.New("unknown tenant")
+	}
+
+	return fn(context.WithValue(context.Background(), instanceContextKey{}, t))
+}
+
+// DoFor runs fn with a context carrying the config of the tenant of the
+// request
+func (m *MultiTenant) DoFor(c *fiber.Ctx, fn func(ctx context.Context) error) error {
+	t, err := m.resolve(c)
+	if err != nil {
+		return err
+	}
+
+	return fn(context.WithValue(requestContext(c), instanceContextKey{}, t))
+}
@@ -0,0 +1,113 @@
+package signed
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMultiTenant(t *testing.T) {
+	// Initalize config
+	storage := newTestStorage()
+	tenants := NewMultiTenant(MultiTenantConfig{
+		Tenants: map[string]Config{
+			"a.example.com": {
+				GetPrivateKeyFunc: func() string { return "secret-a" },
+				Storage:           storage,
+			},
+			"b.example.com": {
+				GetPrivateKeyFunc: func() string { return "secret-b" },
+				SignatureQueryKey: "sig",
+				Storage:           storage,
+			},
+		},
+	})
+
+	app := fiber.New()
+
+	app.Use(tenants.Handler())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(tenant, target string) string {
+		var signedURL string
+		err := tenants.Do(tenant, func(ctx context.Context) (err error) {
+			signedURL, err = GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx))
+			return err
+		})
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should verify requests with the config of their tenant", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("a.example.com", "http://a.example.com/")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		signedURL := sign("b.example.com", "http://b.example.com/")
+		utils.AssertEqual(t, true, httptest.NewRequest(http.MethodGet, signedURL, nil).URL.Query().Get("sig") != "")
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should verify requests of separate tenants side by side", func(t *testing.T) {
+		var wg sync.WaitGroup
+		codes := make(chan int, 20)
+		for i := 0; i < 10; i++ {
+			for _, tenant := range []string{"a.example.com", "b.example.com"} {
+				wg.Add(1)
+				go func(signedURL string) {
+					defer wg.Done()
+					resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+					codes <- resp.StatusCode
+				}(sign(tenant, "http://"+tenant+"/"))
+			}
+		}
+		wg.Wait()
+		close(codes)
+
+		for code := range codes {
+			utils.AssertEqual(t, fiber.StatusOK, code)
+		}
+	})
+
+	t.Run("it should not accept urls signed for another tenant", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, sign("a.example.com", "http://a.example.com/"))
+		req.Host = "b.example.com"
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject requests for unknown tenants", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://c.example.com/?signature=anything"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "unknown tenant", string(body))
+
+		utils.AssertEqual(t, "unknown tenant", tenants.Do("c.example.com", func(ctx context.Context) error { return nil }).Error())
+	})
+
+	t.Run("it should namespace storage by tenant", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("a.example.com", "http://a.example.com/?nonce=abc")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, sign("b.example.com", "http://b.example.com/?nonce=abc")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, sign("a.example.com", "http://a.example.com/?nonce=abc")))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+
+		b, _ := storage.Get("fiber-signed:a.example.com:nonces:abc")
+		utils.AssertEqual(t, "1", string(b))
+	})
+}
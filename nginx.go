@@ -0,0 +1,106 @@
+package signed
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getNginxSecureLinkHash returns the nginx secure_link_md5 hash of the
+// NginxSecureLinkMD5 expression for the given variables: the base64url
+// encoded MD5 of the expression, without padding
+func (cfg *instance) getNginxSecureLinkHash(privateKey, expires, uri, remoteAddr string) string {
+	expression := strings.NewReplacer(
+		"$secure_link_expires", expires,
+		"$uri", uri,
+		"$remote_addr", remoteAddr,
+		"$secret", privateKey,
+	).Replace(cfg.NginxSecureLinkMD5)
+
+	sum := md5.Sum([]byte(expression))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validateNginxSecureLink validates requests signed in the nginx
+// secure_link_md5 format, which carry hash instead of a signature
+func (cfg *instance) validateNginxSecureLink(c *fiber.Ctx, hash string) (bool, error) {
+	expires := c.Query(cfg.ExpiresQueryKey)
+	if expires != "" {
+		i, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s value must be valid integer", cfg.ExpiresQueryKey)
+		}
+		if time.Unix(i, 0).Before(timeNow()) {
+			return false, errors.New("url signature has expired")
+		}
+	}
+
+	// nginx accepts hashes with or without padding
+	hash = strings.TrimRight(hash, "=")
+	privateKey, err := cfg.getPrivateKey(requestContext(c))
+	if err != nil {
+		return false, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	expected := cfg.getNginxSecureLinkHash(privateKey, expires, c.Path(), c.IP())
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) != 1 {
+		return false, errInvalidSignature
+	}
+	setLabels(c, string(AlgorithmMD5), func() string { return KeyFingerprint(privateKey) })
+
+	return true, nil
+}
+
+// GetNginxSecureLinkFromHTTPRequest takes an instance of *http.Request and
+// returns full URL signed in the nginx secure_link_md5 format, expiring at
+// expires if not zero, so links can be shared with nginx-based
+// infrastructure during migrations. $remote_addr is the host of r.RemoteAddr.
+func GetNginxSecureLinkFromHTTPRequest(r *http.Request, expires time.Time) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if cfg.NginxSecureLinkMD5 == "" {
+		return "", errors.New("NginxSecureLinkMD5 must be set to sign nginx secure links")
+	}
+
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.NginxMD5QueryKey, cfg.ExpiresQueryKey); err != nil {
+		return "", err
+	}
+
+	privateKey, err := cfg.getPrivateKey(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	var expiresValue string
+	if !expires.IsZero() {
+		expiresValue = strconv.FormatInt(expires.Unix(), 10)
+		q.Set(cfg.ExpiresQueryKey, expiresValue)
+	}
+
+	// Count the URL against the minting limit of its key
+	if err := cfg.checkMintLimit(r); err != nil {
+		return "", err
+	}
+
+	remoteAddr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+
+	q.Set(cfg.NginxMD5QueryKey, cfg.getNginxSecureLinkHash(privateKey, expiresValue, r.URL.Path, remoteAddr))
+	r.URL.RawQuery = q.Encode()
+
+	signedURL := r.URL.String()
+	events.emit(Event{Type: EventSigned, URL: signedURL, Algorithm: string(AlgorithmMD5), KeyID: KeyFingerprint(privateKey), Purpose: q.Get(cfg.PurposeQueryKey), RequestID: q.Get(cfg.RequestIDQueryKey), Warnings: cfg.getWarnings(r.URL)})
+
+	return signedURL, nil
+}
@@ -0,0 +1,69 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestNginxSecureLink(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		NginxSecureLinkMD5: "$secure_link_expires$uri$remote_addr $secret",
+	}))
+
+	app.Get("/s/link", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should match the hashes nginx generates", func(t *testing.T) {
+		// From the nginx documentation of secure_link_md5
+		utils.AssertEqual(t, "_e4Nc3iduzkWRm01TBBNYw", current().getNginxSecureLinkHash("secret", "2147483647", "/s/link", "127.0.0.1"))
+	})
+
+	t.Run("it should accept links generated by nginx", func(t *testing.T) {
+		hash := current().getNginxSecureLinkHash("secret", "2147483647", "/s/link", "0.0.0.0")
+		resp, _ := app.Test(newTestRequest(http.MethodGet, fmt.Sprintf("http://example.com/s/link?md5=%s&expires=2147483647", hash)))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should accept links it generates", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/s/link", nil)
+		r.RemoteAddr = "0.0.0.0:1234"
+		signedURL, err := GetNginxSecureLinkFromHTTPRequest(r, time.Now().Add(time.Hour))
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject tampered links", func(t *testing.T) {
+		hash := current().getNginxSecureLinkHash("secret", "2147483647", "/s/link", "0.0.0.0")
+		resp, _ := app.Test(newTestRequest(http.MethodGet, fmt.Sprintf("http://example.com/s/link?md5=%s&expires=2147483646", hash)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "invalid signature", string(body))
+	})
+
+	t.Run("it should reject expired links", func(t *testing.T) {
+		expires := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+		hash := current().getNginxSecureLinkHash("secret", expires, "/s/link", "0.0.0.0")
+		resp, _ := app.Test(newTestRequest(http.MethodGet, fmt.Sprintf("http://example.com/s/link?md5=%s&expires=%s", hash, expires)))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+}
@@ -0,0 +1,49 @@
+package signed
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getRequestOrigin returns the Origin header of a request, or the origin of
+// its Referer header when Origin is absent
+func getRequestOrigin(c *fiber.Ctx) string {
+	if origin := c.Get(fiber.HeaderOrigin); origin != "" && origin != "null" {
+		return origin
+	}
+
+	referer, err := url.Parse(c.Get(fiber.HeaderReferer))
+	if err != nil || referer.Scheme == "" || referer.Host == "" {
+		return ""
+	}
+
+	return referer.Scheme + "://" + referer.Host
+}
+
+// checkOrigin enforces the comma separated origin patterns signed into the
+// request URL, where "*" matches any sequence of characters
+func (cfg *instance) checkOrigin(c *fiber.Ctx) error {
+	patterns := c.Query(cfg.OriginQueryKey)
+	if patterns == "" {
+		return nil
+	}
+
+	origin := getRequestOrigin(c)
+	if origin == "" {
+		if cfg.AllowMissingOrigin {
+			return nil
+		}
+		return errors.New("url signature requires an origin or referer")
+	}
+
+	for _, pattern := range strings.Split(patterns, ",") {
+		if matchWildcard(strings.TrimSpace(pattern), origin) {
+			return nil
+		}
+	}
+
+	return errors.New("url signature does not permit this origin")
+}
@@ -0,0 +1,69 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestOriginBinding(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/media.mp4", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/media.mp4?origin=https://example.com,https://*.example.com", nil)
+	signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		status   int
+		expected string
+	}{
+		{"it should succeed with a matching origin", fiber.HeaderOrigin, "https://example.com", fiber.StatusOK, "Hello, world!"},
+		{"it should succeed with a referer matching an origin pattern", fiber.HeaderReferer, "https://www.example.com/videos/1", fiber.StatusOK, "Hello, world!"},
+		{"it should reject a hotlinking origin", fiber.HeaderOrigin, "https://evil.test", fiber.StatusForbidden, "url signature does not permit this origin"},
+		{"it should reject a hotlinking referer", fiber.HeaderReferer, "https://evil.test/?https://example.com", fiber.StatusForbidden, "url signature does not permit this origin"},
+		{"it should reject a request without origin or referer", "", "", fiber.StatusForbidden, "url signature requires an origin or referer"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestRequest(http.MethodGet, signedURL)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			resp, _ := app.Test(req)
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, tt.status, resp.StatusCode)
+			utils.AssertEqual(t, tt.expected, string(body))
+		})
+	}
+
+	t.Run("it should accept a request without origin or referer when allowed", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc:  func() string { return "secret" },
+			AllowMissingOrigin: true,
+		}))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
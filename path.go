@@ -0,0 +1,111 @@
+package signed
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// signedPathLocal holds the request URI of requests to Route while they are
+// verified
+const signedPathLocal = "fiber-signed:signed-path"
+
+// PathTransport carries the expires and signature params as path segments,
+// eg. /signed/<expires>/<signature>/real/path (nginx secure_link style), for
+// CDNs and caches which normalize or strip query strings. The signature
+// covers the real path, which requests are rewritten to before the rest of
+// the stack handles them, unless the middleware is mounted on Route. Any
+// other signature params stay in the query.
+type PathTransport struct {
+	// Prefix is the path the expiry and signature segments follow. Empty
+	// means "/signed".
+	Prefix string
+}
+
+// prefix returns the path the expiry and signature segments follow
+func (t PathTransport) prefix() string {
+	if t.Prefix == "" {
+		return "/signed"
+	}
+
+	return strings.TrimSuffix(t.Prefix, "/")
+}
+
+// Route returns the route of signed paths, with the expires and signature
+// segments as named params and the real path as a wildcard, eg.
+// /signed/:expires/:signature/*, so the middleware can be mounted on it.
+// Requests are not rewritten to their real path when it is, so handlers can
+// read the named params.
+func (t PathTransport) Route() string {
+	return t.prefix() + "/:expires/:signature/*"
+}
+
+// Extract implements Transport, rewriting the request to its real path
+func (t PathTransport) Extract(c *fiber.Ctx, keys []string) (url.Values, error) {
+	cfg := instanceOf(c)
+
+	// Mounted on Route, the named params share the buffer of the path, so it
+	// is only rewritten while the request is verified
+	if expires, signature := utils.CopyString(c.Params("expires")), utils.CopyString(c.Params("signature")); expires != "" && signature != "" {
+		c.Locals(signedPathLocal, utils.CopyString(c.OriginalURL()))
+		c.Request().URI().SetPath("/" + c.Params("*"))
+
+		return url.Values{
+			cfg.ExpiresQueryKey:   {expires},
+			cfg.SignatureQueryKey: {signature},
+		}, nil
+	}
+
+	// Copy the path, as rewriting it reuses its buffer
+	path := utils.CopyString(c.Path())
+	if !strings.HasPrefix(path, t.prefix()+"/") {
+		return nil, nil
+	}
+
+	segments := strings.SplitN(strings.TrimPrefix(path, t.prefix()+"/"), "/", 3)
+	if len(segments) < 3 || segments[0] == "" || segments[1] == "" {
+		return nil, errors.New("signed path must have expires and signature segments")
+	}
+	c.Path("/" + segments[2])
+
+	return url.Values{
+		cfg.ExpiresQueryKey:   {segments[0]},
+		cfg.SignatureQueryKey: {segments[1]},
+	}, nil
+}
+
+// Inject implements Transport
+func (t PathTransport) Inject(r *http.Request, params url.Values) error {
+	cfg := instanceFor(r.Context())
+
+	expires, signature := params.Get(cfg.ExpiresQueryKey), params.Get(cfg.SignatureQueryKey)
+	if expires == "" {
+		return errors.New("path transport requires an expiry")
+	}
+
+	segments := t.prefix() + "/" + url.PathEscape(expires) + "/" + url.PathEscape(signature)
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = segments + r.URL.RawPath
+	}
+	r.URL.Path = segments + r.URL.Path
+
+	// Everything else stays in the query
+	params.Del(cfg.ExpiresQueryKey)
+	params.Del(cfg.SignatureQueryKey)
+
+	return QueryTransport{}.Inject(r, params)
+}
+
+// restoreSignedPath restores the request URI of requests to Route once they
+// are verified, so the named params are intact for the rest of the stack
+func restoreSignedPath(c *fiber.Ctx) {
+	if uri, ok := c.Locals(signedPathLocal).(string); ok {
+		c.Request().SetRequestURI(uri)
+		c.Request().URI() // Parse it back into the buffer of the named params
+		c.Locals(signedPathLocal, nil)
+	}
+}
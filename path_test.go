@@ -0,0 +1,102 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestPathTransport(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Transport:         PathTransport{},
+	}))
+
+	app.Get("/files/report.pdf", func(c *fiber.Ctx) error {
+		return c.SendString(c.Path())
+	})
+
+	sign := func(target string) string {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		utils.AssertEqual(t, nil, SignRequest(r))
+		return r.URL.String()
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	t.Run("it should embed the expiry and signature in the path", func(t *testing.T) {
+		signedURL := sign(fmt.Sprintf("http://example.com/files/report.pdf?expires=%d", expires))
+
+		utils.AssertEqual(t, true, strings.HasPrefix(signedURL, fmt.Sprintf("http://example.com/signed/%d/", expires)))
+		utils.AssertEqual(t, true, strings.HasSuffix(signedURL, "/files/report.pdf"))
+	})
+
+	t.Run("it should serve the real path", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign(fmt.Sprintf("http://example.com/files/report.pdf?expires=%d", expires))))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "/files/report.pdf", string(body))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject tampered paths", func(t *testing.T) {
+		signedURL := sign(fmt.Sprintf("http://example.com/files/report.pdf?expires=%d", expires))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, strings.Replace(signedURL, "report", "secret", 1)))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject expired paths", func(t *testing.T) {
+		signedURL := sign(fmt.Sprintf("http://example.com/files/report.pdf?expires=%d", time.Now().Add(-time.Hour).Unix()))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should reject paths missing a segment", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/signed/123"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should require an expiry to sign", func(t *testing.T) {
+		err := SignRequest(httptest.NewRequest(http.MethodGet, "http://example.com/files/report.pdf", nil))
+
+		utils.AssertEqual(t, "path transport requires an expiry", err.Error())
+	})
+}
+
+func TestPathTransportRoute(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+	transport := PathTransport{Prefix: "/dl"}
+
+	app.Get(transport.Route(), New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Transport:         transport,
+	}), func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("*") + " " + c.Params("expires"))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/files/report.pdf?expires=%d", time.Now().Add(time.Hour).Unix()), nil)
+	utils.AssertEqual(t, nil, SignRequest(r))
+
+	t.Run("it should validate with named params", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, r.URL.String()))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "files/report.pdf "+strings.Split(r.URL.Path, "/")[2], string(body))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
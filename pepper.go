@@ -0,0 +1,34 @@
+package signed
+
+import (
+	"errors"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+)
+
+// pepperKey mixes the pepper from GetPepperFunc, if set, into privateKey
+func (cfg *instance) pepperKey(privateKey string) (string, error) {
+	if cfg.GetPepperFunc == nil {
+		return privateKey, nil
+	}
+
+	pepper := cfg.GetPepperFunc()
+	if pepper == "" {
+		return "", errors.New("pepper must not be empty")
+	}
+
+	return canon.PepperKey(privateKey, pepper), nil
+}
+
+// getPreviousKeys returns the keys replaced by the last rotation while they
+// are within KeyRotationGrace, peppered like getPrivateKey
+func (cfg *instance) getPreviousKeys() []string {
+	var previous []string
+	for _, key := range cfg.keys.previous() {
+		if peppered, err := cfg.pepperKey(key); err == nil {
+			previous = append(previous, peppered)
+		}
+	}
+
+	return previous
+}
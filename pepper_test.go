@@ -0,0 +1,79 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestPepper(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	pepper := "pepper"
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		GetPepperFunc:     func() string { return pepper },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, err := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?a=1"))
+	utils.AssertEqual(t, nil, err)
+
+	t.Run("it should accept URLs signed with the pepper", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject URLs forged with the private key alone", func(t *testing.T) {
+		current().GetPepperFunc = nil
+		forgedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?a=1"))
+		current().GetPepperFunc = func() string { return pepper }
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, forgedURL))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject token URLs forged with the private key alone", func(t *testing.T) {
+		tokenURL, err := GetSignedTokenURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?a=1"), Claims{})
+		utils.AssertEqual(t, nil, err)
+
+		current().GetPepperFunc = nil
+		forgedURL, _ := GetSignedTokenURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?a=1"), Claims{})
+		current().GetPepperFunc = func() string { return pepper }
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, tokenURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, forgedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject URLs signed with another pepper", func(t *testing.T) {
+		pepper = "rotated"
+		defer func() { pepper = "pepper" }()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should fail closed without a pepper", func(t *testing.T) {
+		pepper = ""
+		defer func() { pepper = "pepper" }()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+		utils.AssertEqual(t, "pepper must not be empty", string(body))
+	})
+}
@@ -0,0 +1,135 @@
+package signed
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Policy defines a set of conditions which are encoded into a signed URL and
+// enforced when the request is validated
+type Policy struct {
+	// Resource is the URL (without query params) the policy applies to. The
+	// wildcard "*" matches any sequence of characters, eg.
+	// "https://example.com/files/*"
+	Resource string `json:"resource,omitempty"`
+
+	// DateLessThan is the UNIX timestamp at which the URL stops being valid
+	DateLessThan int64 `json:"dateLessThan,omitempty"`
+
+	// DateGreaterThan is the UNIX timestamp at which the URL starts being valid
+	DateGreaterThan int64 `json:"dateGreaterThan,omitempty"`
+
+	// IPAddress is a CIDR range from which requests must originate, eg.
+	// "192.0.2.0/24"
+	IPAddress string `json:"ipAddress,omitempty"`
+
+	// Countries lists the ISO 3166 country codes from which requests may
+	// originate, as resolved by CountryResolver
+	Countries []string `json:"countries,omitempty"`
+}
+
+// encodePolicy marshals policy to JSON and encodes it for use in query params
+func encodePolicy(policy Policy) (string, error) {
+
+	// Fail early on an IP range that could never be matched
+	if policy.IPAddress != "" {
+		if _, _, err := net.ParseCIDR(policy.IPAddress); err != nil {
+			return "", errors.New("policy ipAddress must be a valid CIDR range")
+		}
+	}
+
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePolicy reverses encodePolicy
+func (cfg *instance) decodePolicy(encoded string) (Policy, error) {
+	var policy Policy
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return policy, fmt.Errorf("%s value must be a valid policy document", cfg.PolicyQueryKey)
+	}
+
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return policy, fmt.Errorf("%s value must be a valid policy document", cfg.PolicyQueryKey)
+	}
+
+	return policy, nil
+}
+
+// validatePolicy checks every condition of the policy document against the
+// inbound request
+func (cfg *instance) validatePolicy(c *fiber.Ctx, policy Policy) error {
+
+	now := timeNow()
+	if policy.DateLessThan != 0 && !now.Before(time.Unix(policy.DateLessThan, 0)) {
+		return errors.New("url policy has expired")
+	}
+
+	if policy.DateGreaterThan != 0 && now.Before(time.Unix(policy.DateGreaterThan, 0)) {
+		return errors.New("url policy is not yet valid")
+	}
+
+	if policy.Resource != "" && !matchWildcard(policy.Resource, c.BaseURL()+c.Path()) {
+		return errors.New("url policy does not permit this resource")
+	}
+
+	if policy.IPAddress != "" {
+		_, ipNet, err := net.ParseCIDR(policy.IPAddress)
+		if err != nil {
+			return errors.New("url policy does not permit this ip address")
+		}
+		ip := net.ParseIP(c.IP())
+		if ip == nil || !ipNet.Contains(ip) {
+			return errors.New("url policy does not permit this ip address")
+		}
+	}
+
+	if len(policy.Countries) > 0 {
+		allowed, err := cfg.countryAllowed(c.IP(), policy.Countries)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.New("url policy does not permit this country")
+		}
+	}
+
+	return nil
+}
+
+// GetSignedURLWithPolicyFromHTTPRequest takes an instance of *http.Request
+// and a Policy and returns full URL with the encoded policy and calculated
+// signature
+func GetSignedURLWithPolicyFromHTTPRequest(r *http.Request, policy Policy) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	// Throw error if policy query param is already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.PolicyQueryKey); err != nil {
+		return "", err
+	}
+
+	encoded, err := encodePolicy(policy)
+	if err != nil {
+		return "", err
+	}
+
+	// Append policy to query params so it is covered by the signature
+	q.Set(cfg.PolicyQueryKey, encoded)
+	r.URL.RawQuery = q.Encode()
+
+	return GetSignedURLFromHTTPRequest(r)
+}
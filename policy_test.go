@@ -0,0 +1,86 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestPolicy(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/files/:name", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string, policy Policy) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, err := GetSignedURLWithPolicyFromHTTPRequest(req, policy)
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should succeed when all policy conditions are met", func(t *testing.T) {
+		signedURL := sign("http://example.com/files/a.txt", Policy{
+			Resource:        "http://example.com/files/*",
+			DateLessThan:    time.Now().Add(time.Hour).Unix(),
+			DateGreaterThan: time.Now().Add(-time.Hour).Unix(),
+			IPAddress:       "0.0.0.0/32",
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		expected string
+	}{
+		{"expired", Policy{DateLessThan: time.Now().Add(-time.Hour).Unix()}, "url policy has expired"},
+		{"not yet valid", Policy{DateGreaterThan: time.Now().Add(time.Hour).Unix()}, "url policy is not yet valid"},
+		{"non-matching resource", Policy{Resource: "http://example.com/other/*"}, "url policy does not permit this resource"},
+		{"non-matching ip address", Policy{IPAddress: "10.0.0.0/8"}, "url policy does not permit this ip address"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprintf("it should reject a request with %s policy", tt.name), func(t *testing.T) {
+			signedURL := sign("http://example.com/files/a.txt", tt.policy)
+
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+			utils.AssertEqual(t, tt.expected, string(body))
+		})
+	}
+
+	t.Run("it should not allow requests to contain protected query param 'policy'", func(t *testing.T) {
+		expected := "policy is a reserved query parameter when generating signed routes"
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?policy=something", nil)
+		_, err := GetSignedURLWithPolicyFromHTTPRequest(req, Policy{})
+		utils.AssertEqual(t, expected, err.Error())
+	})
+
+	t.Run("it should not sign a policy with an invalid ip address range", func(t *testing.T) {
+		expected := "policy ipAddress must be a valid CIDR range"
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		_, err := GetSignedURLWithPolicyFromHTTPRequest(req, Policy{IPAddress: "nope"})
+		utils.AssertEqual(t, expected, err.Error())
+	})
+}
@@ -0,0 +1,133 @@
+package signed
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProbeHeader is the header infrastructure probes may send their probe token
+// in, as an alternative to ProbeQueryKey
+const ProbeHeader = "X-Fiber-Signed-Probe"
+
+// ProbeLocal is the local set to true for requests let through on a probe
+// token, so handlers can answer probes cheaply
+const ProbeLocal = "fiber-signed:probe"
+
+// probeTokenContext separates probe token MACs from URL signatures
+const probeTokenContext = "fiber-signed-probe:"
+
+// MintProbeToken returns a token valid for ttl which lets infrastructure
+// probes (eg. uptime checkers which can't sign requests) make GET and HEAD
+// requests to path through signed routes while AllowProbes is set. "*" in
+// path matches any sequence of characters, but keep path, and ttl, as narrow
+// as the monitoring setup allows.
+func MintProbeToken(path string, ttl time.Duration) (string, error) {
+	cfg := current()
+
+	if ttl < time.Second {
+		return "", errors.New("ttl must be at least one second")
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", errors.New("probe token path must start with /")
+	}
+
+	privateKey, err := cfg.getPrivateKey(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(timeNow().Add(ttl).Unix(), 10)
+	encodedPath := base64.RawURLEncoding.EncodeToString([]byte(path))
+	return expires + "." + encodedPath + "." + getProbeMAC(privateKey, expires, path), nil
+}
+
+// VerifyProbeToken returns nil if token was minted with the current private
+// key for a GET or HEAD request to path with method, and has not expired
+func VerifyProbeToken(token, method, path string) error {
+	return VerifyProbeTokenWithContext(context.Background(), token, method, path)
+}
+
+// VerifyProbeTokenWithContext is VerifyProbeToken, looking up the private key
+// with ctx, eg. the context of the request presenting the token
+func VerifyProbeTokenWithContext(ctx context.Context, token, method, path string) error {
+	return instanceFor(ctx).verifyProbeToken(ctx, token, method, path)
+}
+
+// verifyProbeToken is VerifyProbeToken with ctx passed on to key lookups
+func (cfg *instance) verifyProbeToken(ctx context.Context, token, method, path string) error {
+	invalid := errors.New("invalid probe token")
+
+	// Probes only ever need to read
+	if method != http.MethodGet && method != http.MethodHead {
+		return errors.New("probe tokens only permit GET and HEAD requests")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return invalid
+	}
+	expires, mac := parts[0], parts[2]
+	decodedPath, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return invalid
+	}
+
+	privateKey, err := cfg.getPrivateKey(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if subtle.ConstantTimeCompare([]byte(getProbeMAC(privateKey, expires, string(decodedPath))), []byte(mac)) != 1 {
+		return invalid
+	}
+
+	if !matchWildcard(string(decodedPath), path) {
+		return errors.New("probe token does not permit this path")
+	}
+
+	i, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return invalid
+	}
+	if time.Unix(i, 0).Before(timeNow()) {
+		return errors.New("probe token has expired")
+	}
+
+	return nil
+}
+
+// getProbeMAC returns the MAC of a probe token for path expiring at expires
+func getProbeMAC(privateKey, expires, path string) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write([]byte(probeTokenContext + expires + "." + path))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// getProbeToken returns the probe token of the request, if any
+func (cfg *instance) getProbeToken(c *fiber.Ctx) string {
+	if token := c.Get(ProbeHeader); token != "" {
+		return token
+	}
+
+	return c.Query(cfg.ProbeQueryKey)
+}
+
+// validateProbe handles requests carrying a probe token, flagging them with
+// ProbeLocal if it is valid
+func (cfg *instance) validateProbe(c *fiber.Ctx, token string) (bool, error) {
+	if err := cfg.verifyProbeToken(requestContext(c), token, c.Method(), c.Path()); err != nil {
+		return false, err
+	}
+
+	c.Locals(ProbeLocal, true)
+	return true, nil
+}
@@ -0,0 +1,81 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestProbeToken(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		AllowProbes:       true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		if c.Locals(ProbeLocal) == true {
+			return c.SendString("ok")
+		}
+		return c.SendString("Hello, world!")
+	})
+
+	token, err := MintProbeToken("/", time.Hour)
+	utils.AssertEqual(t, nil, err)
+
+	t.Run("it should let probes through with a valid token", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, "http://example.com/")
+		req.Header.Set(ProbeHeader, token)
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "ok", string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, "http://example.com/?probe="+token))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should only let probes read the path of their token", func(t *testing.T) {
+		req := newTestRequest(http.MethodPost, "http://example.com/")
+		req.Header.Set(ProbeHeader, token)
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "probe tokens only permit GET and HEAD requests", string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, "http://example.com/admin?probe="+token))
+		body, _ = ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "probe token does not permit this path", string(body))
+	})
+
+	t.Run("it should match paths against patterns", func(t *testing.T) {
+		pattern, _ := MintProbeToken("/status/*", time.Hour)
+
+		utils.AssertEqual(t, nil, VerifyProbeToken(pattern, http.MethodHead, "/status/db"))
+		utils.AssertEqual(t, "probe token does not permit this path", VerifyProbeToken(pattern, http.MethodGet, "/").Error())
+	})
+
+	t.Run("it should reject tampered and expired tokens", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/?probe=9999999999"+token[10:]))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+
+		restore := InjectFaults(Faults{ClockSkew: 2 * time.Hour})
+		defer restore()
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, "http://example.com/?probe="+token))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "probe token has expired", string(body))
+	})
+}
@@ -0,0 +1,123 @@
+package signed
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// handlersMu guards handlers
+var handlersMu sync.Mutex
+
+// handlers holds the code pointers of the handlers this middleware returns,
+// which are the same for every handler a constructor returns
+var handlers = map[uintptr]bool{}
+
+// register records h as a handler of this middleware for Protected
+func register(h fiber.Handler) fiber.Handler {
+	handlersMu.Lock()
+	handlers[reflect.ValueOf(h).Pointer()] = true
+	handlersMu.Unlock()
+
+	return h
+}
+
+// isMiddleware reports whether h is a handler of this middleware
+func isMiddleware(h fiber.Handler) bool {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	return handlers[reflect.ValueOf(h).Pointer()]
+}
+
+// Protected returns the routes of app which requests reach only through this
+// middleware, as "METHOD /path", eg. to log at startup or report from an ops
+// endpoint that every sensitive route is covered. A route is protected if the
+// middleware is one of its own handlers or is mounted with app.Use on a
+// prefix of its path before it. Routes skipped by Next or by handlers which
+// respond before the middleware runs are still reported as protected.
+func Protected(app *fiber.App) []string {
+	caseSensitive := app.Config().CaseSensitive
+
+	var protected []string
+	for _, routes := range app.Stack() {
+		// Paths mounted with app.Use which run the middleware so far
+		var mounts []string
+
+		for _, route := range routes {
+			covered := false
+			for _, mount := range mounts {
+				if mountMatches(mount, route.Path, caseSensitive) {
+					covered = true
+					break
+				}
+			}
+
+			signs := false
+			for _, h := range route.Handlers {
+				if isMiddleware(h) {
+					signs = true
+					break
+				}
+			}
+
+			if isUse(route) {
+				if signs {
+					mounts = append(mounts, route.Path)
+				}
+				continue
+			}
+
+			if covered || signs {
+				protected = append(protected, route.Method+" "+route.Path)
+			}
+		}
+	}
+
+	return protected
+}
+
+// isUse reports whether route was mounted with app.Use. Fiber only records
+// this in an unexported field, which is read but never written.
+func isUse(route *fiber.Route) bool {
+	field := reflect.ValueOf(route).Elem().FieldByName("use")
+	return field.IsValid() && field.Kind() == reflect.Bool && field.Bool()
+}
+
+// mountMatches reports whether requests to path run handlers mounted with
+// app.Use on mount, matching params segment by segment like the router
+func mountMatches(mount, path string, caseSensitive bool) bool {
+	if !caseSensitive {
+		mount, path = strings.ToLower(mount), strings.ToLower(path)
+	}
+	if mount == "" || mount == "/" || mount == "/*" {
+		return true
+	}
+	if !strings.ContainsAny(mount, ":*") {
+		return strings.HasPrefix(path, mount)
+	}
+
+	mountSegments := strings.Split(strings.Trim(mount, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range mountSegments {
+		if segment == "*" {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return strings.HasSuffix(segment, "?")
+		}
+		if strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if i == len(mountSegments)-1 {
+			return strings.HasPrefix(pathSegments[i], segment)
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package signed
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestProtected(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	handler := func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	}
+
+	app.Get("/public", handler)
+	app.Get("/inline", New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}), handler)
+	app.Use("/files", New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+	app.Get("/files/:name", handler)
+	app.Post("/upload", handler)
+
+	protected := Protected(app)
+
+	contains := func(route string) bool {
+		for _, r := range protected {
+			if r == route {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("it should report routes with the middleware as a handler", func(t *testing.T) {
+		utils.AssertEqual(t, true, contains("GET /inline"))
+		utils.AssertEqual(t, true, contains("HEAD /inline"))
+	})
+
+	t.Run("it should report routes under a mounted prefix", func(t *testing.T) {
+		utils.AssertEqual(t, true, contains("GET /files/:name"))
+	})
+
+	t.Run("it should not report unprotected routes", func(t *testing.T) {
+		utils.AssertEqual(t, false, contains("GET /public"))
+		utils.AssertEqual(t, false, contains("POST /upload"))
+	})
+
+	t.Run("it should not report the mounts themselves", func(t *testing.T) {
+		utils.AssertEqual(t, false, contains("GET /files"))
+	})
+
+	t.Run("it should report routes behind multi-tenant handlers", func(t *testing.T) {
+		app := fiber.New()
+
+		app.Use(NewMultiTenant(MultiTenantConfig{
+			Tenants: map[string]Config{
+				"a": {GetPrivateKeyFunc: func() string { return "secret" }},
+			},
+			Resolver: func(c *fiber.Ctx) (string, error) { return "a", nil },
+		}).Handler())
+		app.Get("/tenant", handler)
+
+		utils.AssertEqual(t, []string{"GET /tenant", "HEAD /tenant"}, filterMethods(Protected(app), "GET", "HEAD"))
+	})
+}
+
+// filterMethods returns the routes of methods
+func filterMethods(routes []string, methods ...string) []string {
+	var filtered []string
+	for _, route := range routes {
+		for _, method := range methods {
+			if len(route) > len(method) && route[:len(method)+1] == method+" " {
+				filtered = append(filtered, route)
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,81 @@
+package qr
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Format type defines options for the image format of QR codes
+type Format string
+
+// Image format option values
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+// Config defines the config for Handler
+type Config struct {
+	// Sign returns the signed URL to issue as a QR code for the request, eg.
+	// by calling signed.GetSignedURLFromHTTPRequest
+	//
+	// Required.
+	Sign func(c *fiber.Ctx) (string, error)
+
+	// Format defines the image format of QR codes. Options are FormatPNG,
+	// FormatSVG.
+	//
+	// Optional. Default: FormatPNG
+	Format Format
+
+	// Level defines the error correction level of QR codes
+	//
+	// Optional. Default: LevelM
+	Level Level
+
+	// Scale defines the number of pixels per module in PNG QR codes
+	//
+	// Optional. Default: 8
+	Scale int
+}
+
+// Handler creates a handler which issues signed URLs as QR codes
+func Handler(config Config) fiber.Handler {
+	if config.Sign == nil {
+		panic(errors.New("qr: Sign is required"))
+	}
+	if config.Format == "" {
+		config.Format = FormatPNG
+	}
+	if config.Scale <= 0 {
+		config.Scale = 8
+	}
+
+	return func(c *fiber.Ctx) error {
+		signedURL, err := config.Sign(c)
+		if err != nil {
+			return err
+		}
+
+		code, err := Encode(signedURL, config.Level)
+		if err != nil {
+			return err
+		}
+
+		// Signed URLs are usually single-recipient, so must not be cached
+		c.Set(fiber.HeaderCacheControl, "no-store")
+
+		if config.Format == FormatSVG {
+			c.Type("svg")
+			return c.SendString(code.SVG())
+		}
+
+		b, err := code.PNG(config.Scale)
+		if err != nil {
+			return err
+		}
+		c.Type("png")
+		return c.Send(b)
+	}
+}
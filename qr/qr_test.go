@@ -0,0 +1,307 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// decode reads the content back out of q, checking its format information
+// and error correction codewords along the way
+func decode(t *testing.T, q *Code) (string, Level) {
+	version := (q.Size - 17) / 4
+
+	// Format information, from the copy around the top left finder pattern
+	format := 0
+	positions := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, p := range positions {
+		if q.Black(p[0], p[1]) {
+			format |= 1 << uint(i)
+		}
+	}
+	format ^= 0x5412
+
+	// The 15 bit format value must be divisible by the BCH generator
+	rem := format
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= 0x537 << uint(i-10)
+		}
+	}
+	utils.AssertEqual(t, 0, rem, "format BCH remainder")
+
+	// Version information must match the size and its BCH code
+	if version >= 7 {
+		info := 0
+		for i := 0; i < 18; i++ {
+			if q.Black(q.Size-11+i%3, i/3) {
+				info |= 1 << uint(i)
+			}
+		}
+		utils.AssertEqual(t, version, info>>12, "version")
+		for i := 17; i >= 12; i-- {
+			if info&(1<<uint(i)) != 0 {
+				info ^= 0x1F25 << uint(i-12)
+			}
+		}
+		utils.AssertEqual(t, 0, info, "version BCH remainder")
+	}
+
+	mask := (format >> 10) & 7
+	level := map[int]Level{1: LevelL, 0: LevelM, 3: LevelQ, 2: LevelH}[format>>13]
+
+	// Read codewords in zigzag order, unmasking data modules
+	reference := newCode(version)
+	var bits bitBuffer
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !reference.isFunction[y][x] {
+					bits = append(bits, q.Black(x, y) != masked(mask, x, y))
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()[:numRawDataModules(version)/8]
+
+	// De-interleave blocks, checking every syndrome is zero
+	tb := level.table()
+	numBlocks := numErrorCorrectionBlocks[tb][version]
+	eccLen := eccCodewordsPerBlock[tb][version]
+	numShortBlocks := numBlocks - len(codewords)%numBlocks
+	shortDataLen := len(codewords)/numBlocks - eccLen
+
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for j := range blocks {
+			if i < shortDataLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		alpha := byte(1)
+		for i := 0; i < eccLen; i++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMultiply(syndrome, alpha) ^ c
+			}
+			utils.AssertEqual(t, byte(0), syndrome, "syndrome")
+			alpha = gfMultiply(alpha, 0x02)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	// Byte mode segment
+	read := func(offset, length int) int {
+		v := 0
+		for i := 0; i < length; i++ {
+			v = v<<1 | int(data[(offset+i)/8]>>(7-uint((offset+i)%8))&1)
+		}
+		return v
+	}
+	utils.AssertEqual(t, 0x4, read(0, 4), "mode")
+	count := read(4, charCountBits(version))
+	content := make([]byte, count)
+	for i := range content {
+		content[i] = byte(read(4+charCountBits(version)+i*8, 8))
+	}
+
+	return string(content), level
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("it should multiply in GF(2^8)", func(t *testing.T) {
+		utils.AssertEqual(t, byte(0x1D), gfMultiply(0x80, 0x02))
+
+		// The generator has order 255
+		alpha := byte(1)
+		for i := 0; i < 255; i++ {
+			alpha = gfMultiply(alpha, 0x02)
+		}
+		utils.AssertEqual(t, byte(1), alpha)
+	})
+
+	t.Run("it should draw known format and version information", func(t *testing.T) {
+		// Known values from the specification
+		q := newCode(7)
+		q.drawFormatBits(LevelL, 0)
+
+		format := 0
+		for i := 0; i < 8; i++ {
+			if q.Black(q.Size-1-i, 8) {
+				format |= 1 << uint(i)
+			}
+		}
+		for i := 8; i < 15; i++ {
+			if q.Black(8, q.Size-15+i) {
+				format |= 1 << uint(i)
+			}
+		}
+		utils.AssertEqual(t, 0x77C4, format)
+
+		version := 0
+		for i := 0; i < 18; i++ {
+			if q.Black(i/3, q.Size-11+i%3) {
+				version |= 1 << uint(i)
+			}
+		}
+		utils.AssertEqual(t, 0x07C94, version)
+		utils.AssertEqual(t, []int{6, 22, 38}, alignmentPositions(7))
+		utils.AssertEqual(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPositions(40))
+		utils.AssertEqual(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+	})
+
+	t.Run("it should choose the smallest version", func(t *testing.T) {
+		tests := []struct {
+			length  int
+			level   Level
+			version int
+		}{
+			{17, LevelL, 1},
+			{18, LevelL, 2},
+			{14, LevelM, 1},
+			{15, LevelM, 2},
+			{7, LevelH, 1},
+			{2953, LevelL, 40},
+		}
+
+		for _, tt := range tests {
+			q, err := Encode(strings.Repeat("a", tt.length), tt.level)
+			utils.AssertEqual(t, nil, err)
+			utils.AssertEqual(t, tt.version, q.version)
+			utils.AssertEqual(t, tt.version*4+17, q.Size)
+		}
+
+		_, err := Encode(strings.Repeat("a", 2954), LevelL)
+		utils.AssertEqual(t, ErrTooLong, err)
+	})
+
+	t.Run("it should round trip content at every level", func(t *testing.T) {
+		signedURL := "https://example.com/tickets/42?expires=1700000000&nonce=8f2c&signature=" + strings.Repeat("0123456789abcdef", 4)
+
+		for _, content := range []string{"a", "https://example.com/", signedURL, strings.Repeat(signedURL, 4), "Zoë 東京"} {
+			for _, level := range []Level{LevelL, LevelM, LevelQ, LevelH} {
+				q, err := Encode(content, level)
+				utils.AssertEqual(t, nil, err)
+
+				got, gotLevel := decode(t, q)
+				utils.AssertEqual(t, content, got)
+				utils.AssertEqual(t, level, gotLevel)
+			}
+		}
+	})
+
+	t.Run("it should draw finder patterns and timing patterns", func(t *testing.T) {
+		q, _ := Encode("https://example.com/", LevelM)
+
+		for _, corner := range [][2]int{{0, 0}, {q.Size - 7, 0}, {0, q.Size - 7}} {
+			utils.AssertEqual(t, true, q.Black(corner[0], corner[1]))
+			utils.AssertEqual(t, false, q.Black(corner[0]+1, corner[1]+1))
+			utils.AssertEqual(t, true, q.Black(corner[0]+3, corner[1]+3))
+		}
+		for i := 8; i < q.Size-8; i++ {
+			utils.AssertEqual(t, i%2 == 0, q.Black(i, 6))
+			utils.AssertEqual(t, i%2 == 0, q.Black(6, i))
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	q, _ := Encode("https://example.com/", LevelM)
+
+	t.Run("it should render a png with a quiet zone", func(t *testing.T) {
+		b, err := q.PNG(2)
+		utils.AssertEqual(t, nil, err)
+
+		img, err := png.Decode(bytes.NewReader(b))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, (q.Size+8)*2, img.Bounds().Dx())
+
+		r, _, _, _ := img.At(0, 0).RGBA()
+		utils.AssertEqual(t, uint32(0xffff), r)
+		r, _, _, _ = img.At(8, 8).RGBA()
+		utils.AssertEqual(t, uint32(0), r)
+	})
+
+	t.Run("it should render an svg", func(t *testing.T) {
+		svg := q.SVG()
+
+		utils.AssertEqual(t, true, strings.HasPrefix(svg, fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d"`, q.Size+8, q.Size+8)))
+		utils.AssertEqual(t, true, strings.Contains(svg, "M4,4h1v1h-1z"))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/tickets/:id/qr.png", Handler(Config{
+		Sign: func(c *fiber.Ctx) (string, error) {
+			return "https://example.com/tickets/" + c.Params("id") + "?signature=abc", nil
+		},
+	}))
+
+	app.Get("/tickets/:id/qr.svg", Handler(Config{
+		Sign: func(c *fiber.Ctx) (string, error) {
+			return "https://example.com/tickets/" + c.Params("id") + "?signature=abc", nil
+		},
+		Format: FormatSVG,
+	}))
+
+	app.Get("/broken/qr.png", Handler(Config{
+		Sign: func(c *fiber.Ctx) (string, error) {
+			return "", fiber.NewError(fiber.StatusForbidden, "not allowed")
+		},
+	}))
+
+	t.Run("it should issue png qr codes", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/tickets/42/qr.png", nil))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "image/png", resp.Header.Get(fiber.HeaderContentType))
+		utils.AssertEqual(t, "no-store", resp.Header.Get(fiber.HeaderCacheControl))
+		_, err := png.Decode(bytes.NewReader(body))
+		utils.AssertEqual(t, nil, err)
+	})
+
+	t.Run("it should issue svg qr codes", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/tickets/42/qr.svg", nil))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "image/svg+xml", resp.Header.Get(fiber.HeaderContentType))
+		utils.AssertEqual(t, true, strings.HasPrefix(string(body), "<svg"))
+	})
+
+	t.Run("it should return signing errors", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/broken/qr.png", nil))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
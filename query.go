@@ -0,0 +1,29 @@
+package signed
+
+import (
+	"net/url"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+)
+
+// errQueryEscape rejects queries with invalid percent-encoding
+var errQueryEscape = canon.ErrQueryEscape
+
+// parseQuery parses rawQuery the way Fiber does, on the signing and verifying
+// sides alike: params are separated by "&" only, so semicolons are part of
+// keys and values, and keys without "=" have an empty value, eg. "a;b" and
+// "a;b=" are both the key "a;b" with an empty value. url.ParseQuery drops
+// pairs holding semicolons, which signatures would then not cover though
+// handlers see them, so it must not be used for signed queries. Pairs with
+// invalid escapes are left out of q, and errQueryEscape returned.
+func parseQuery(rawQuery string) (url.Values, error) {
+	return canon.ParseQuery(rawQuery)
+}
+
+// urlQuery returns the query params of u parsed like parseQuery, for signing
+// functions which add params before signing, where invalid escapes are
+// reported
+func urlQuery(u *url.URL) url.Values {
+	q, _ := parseQuery(u.RawQuery)
+	return q
+}
@@ -0,0 +1,81 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Run("it should split params on & only", func(t *testing.T) {
+		q, err := parseQuery("a=1;b=2&c;d=3")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, url.Values{"a": []string{"1;b=2"}, "c;d": []string{"3"}}, q)
+	})
+
+	t.Run("it should give bare keys an empty value", func(t *testing.T) {
+		q, err := parseQuery("a&b=&&c=1")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, url.Values{"a": []string{""}, "b": []string{""}, "c": []string{"1"}}, q)
+	})
+
+	t.Run("it should report invalid escapes", func(t *testing.T) {
+		q, err := parseQuery("a=%zz&b=1")
+		utils.AssertEqual(t, errQueryEscape, err)
+		utils.AssertEqual(t, url.Values{"b": []string{"1"}}, q)
+	})
+}
+
+func TestSemicolonQuery(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Query("a"))
+	})
+
+	t.Run("it should sign semicolons as Fiber parses them", func(t *testing.T) {
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1;b=2", nil))
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "1;b=2", string(body))
+	})
+
+	t.Run("it should reject appended params holding semicolons", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&a;x=1"))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should sign bare keys like keys with an empty value", func(t *testing.T) {
+		bare, _ := current().getCanonicalString(http.MethodGet, "http://example.com", "/?flag", nil, nil)
+		empty, _ := current().getCanonicalString(http.MethodGet, "http://example.com", "/?flag=", nil, nil)
+		utils.AssertEqual(t, empty, bare)
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?flag", nil))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject invalid escapes", func(t *testing.T) {
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=%zz", nil))
+		utils.AssertEqual(t, errQueryEscape, err)
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&b=%zz"))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
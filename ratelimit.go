@@ -0,0 +1,108 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit formats a rateLimit query param value allowing max requests per
+// window for a signed URL
+func RateLimit(max int, window time.Duration) string {
+	return fmt.Sprintf("%d/%d", max, int64(window/time.Second))
+}
+
+// parseRateLimit splits a rateLimit value into max requests and window
+func (cfg *instance) parseRateLimit(value string) (int, time.Duration, error) {
+	split := strings.SplitN(value, "/", 2)
+	if len(split) == 2 {
+		max, errMax := strconv.Atoi(split[0])
+		seconds, errWindow := strconv.ParseInt(split[1], 10, 64)
+		if errMax == nil && errWindow == nil && max > 0 && seconds > 0 {
+			return max, time.Duration(seconds) * time.Second, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("%s value must be in the form <requests>/<seconds>", cfg.RateLimitQueryKey)
+}
+
+// getSignatureID returns the value identifying the signed URL of a request.
+// URLs with caveats are identified by their root signature, as any holder can
+// add caveats to get a new signature for the same URL.
+func (cfg *instance) getSignatureID(c *fiber.Ctx) string {
+	if token := c.Query(cfg.TokenQueryKey); token != "" {
+		return token
+	}
+
+	return cfg.getVerifiedSignatures(c)[0]
+}
+
+// enforceRateLimit counts the request against the rateLimit signed into its
+// URL, using a fixed window tracked in Storage
+func (cfg *instance) enforceRateLimit(c *fiber.Ctx) error {
+	value := c.Query(cfg.RateLimitQueryKey)
+	if value == "" {
+		return nil
+	}
+
+	max, window, err := cfg.parseRateLimit(value)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage == nil {
+		return errors.New("url signature rate limit cannot be enforced without Storage")
+	}
+
+	key := cfg.storageKey(StorageKindRateLimit, cfg.getHash(cfg.getSignatureID(c)))
+	allowed, err := cfg.countInWindow(cfg.getStorage(requestContext(c)), StorageKindRateLimit, key, max, window)
+	if err != nil {
+		return &storeError{err}
+	}
+	if !allowed {
+		return fiber.NewError(fiber.StatusTooManyRequests, "url signature rate limit exceeded")
+	}
+
+	return nil
+}
+
+// countInWindow counts a hit against the fixed window of max hits per window
+// tracked at key in storage, an entry of kind, reporting whether the hit is
+// allowed
+func (cfg *instance) countInWindow(storage fiber.Storage, kind, key string, max int, window time.Duration) (bool, error) {
+	now := timeNow().Unix()
+
+	defer storageLocks.lock(key)()
+
+	// Entries are stored as "<window start> <count>"
+	start, count := now, 0
+	b, err := storage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if len(b) > 0 {
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 {
+			s, _ := strconv.ParseInt(fields[0], 10, 64)
+			n, _ := strconv.Atoi(fields[1])
+			if now-s < int64(window/time.Second) {
+				start, count = s, n
+			}
+		}
+	}
+
+	if count >= max {
+		return false, nil
+	}
+
+	ttl := cfg.storageTTL(kind, time.Duration(start+int64(window/time.Second)-now)*time.Second)
+	if err := storage.Set(key, []byte(fmt.Sprintf("%d %d", start, count+1)), ttl); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
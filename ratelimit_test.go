@@ -0,0 +1,88 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestRateLimit(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		return signedURL
+	}
+
+	t.Run("it should throttle a signed URL once its rate limit is reached", func(t *testing.T) {
+		signedURL := sign("http://example.com/?rateLimit=" + RateLimit(2, time.Minute))
+
+		for i := 0; i < 2; i++ {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		utils.AssertEqual(t, "url signature rate limit exceeded", string(body))
+	})
+
+	t.Run("it should track each signed URL independently", func(t *testing.T) {
+		signedURL := sign("http://example.com/?other=1&rateLimit=" + RateLimit(1, time.Minute))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should count caveated copies against the rate limit of the url", func(t *testing.T) {
+		signedURL := sign("http://example.com/?copy=1&rateLimit=" + RateLimit(1, time.Minute))
+		caveated, _ := AddCaveat(signedURL, PathCaveat("/*"))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, caveated))
+		utils.AssertEqual(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("it should reject a malformed rate limit", func(t *testing.T) {
+		signedURL := sign("http://example.com/?rateLimit=lots")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "rateLimit value must be in the form <requests>/<seconds>", string(body))
+	})
+
+	t.Run("it should not enforce a rate limit without Storage", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		}))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?rateLimit=1/60")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature rate limit cannot be enforced without Storage", string(body))
+	})
+}
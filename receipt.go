@@ -0,0 +1,121 @@
+package signed
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReceiptHeader is the response header receipt tokens are returned in when
+// Receipts is set
+const ReceiptHeader = "X-Fiber-Signed-Receipt"
+
+// receiptTokenContext separates receipt MACs from URL signatures
+const receiptTokenContext = "fiber-signed-receipt:"
+
+// Receipt is the proof a signed URL was served, as carried by receipt tokens
+type Receipt struct {
+	// SignatureID is the signature (or token) of the URL served
+	SignatureID string `json:"sig"`
+
+	// Bytes is the number of response body bytes served
+	Bytes int `json:"bytes"`
+
+	// Served is when the response was served, in unix seconds
+	Served int64 `json:"served"`
+}
+
+// getReceiptIssuer returns a func issuing receipt tokens for the validated
+// request, capturing the key while the config is current, or nil if
+// Receipts is not set
+func (cfg *instance) getReceiptIssuer(c *fiber.Ctx) func(bytes int) string {
+	if !cfg.Receipts {
+		return nil
+	}
+
+	// Probes are let through without a signed URL to receipt
+	signatureID := cfg.getSignatureID(c)
+	if signatureID == "" {
+		return nil
+	}
+
+	privateKey, err := cfg.getPrivateKey(requestContext(c))
+	if err != nil {
+		return nil
+	}
+
+	return func(bytes int) string {
+		return getReceiptToken(privateKey, Receipt{
+			SignatureID: signatureID,
+			Bytes:       bytes,
+			Served:      timeNow().Unix(),
+		})
+	}
+}
+
+// getReceiptToken returns receipt encoded and MACed with privateKey
+func getReceiptToken(privateKey string, receipt Receipt) string {
+	b, _ := json.Marshal(receipt)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	return payload + "." + getReceiptMAC(privateKey, payload)
+}
+
+// getReceiptMAC returns the MAC of a receipt token with payload
+func getReceiptMAC(privateKey, payload string) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write([]byte(receiptTokenContext + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyReceipt returns the receipt carried by token if it was issued with
+// the current private key, so the issuing side can check proofs of download
+// clients present
+func VerifyReceipt(token string) (Receipt, error) {
+	return VerifyReceiptWithContext(context.Background(), token)
+}
+
+// VerifyReceiptWithContext is VerifyReceipt, looking up the private key with
+// ctx, eg. the context of the request presenting the receipt
+func VerifyReceiptWithContext(ctx context.Context, token string) (Receipt, error) {
+	cfg := instanceFor(ctx)
+
+	var receipt Receipt
+
+	split := strings.LastIndex(token, ".")
+	if split < 0 {
+		return receipt, errors.New("invalid receipt token")
+	}
+	payload, mac := token[:split], token[split+1:]
+
+	privateKey, err := cfg.getPrivateKey(ctx)
+	if err != nil {
+		return receipt, err
+	}
+	if subtle.ConstantTimeCompare([]byte(getReceiptMAC(privateKey, payload)), []byte(mac)) != 1 {
+		return receipt, errors.New("invalid receipt token")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return receipt, errors.New("invalid receipt token")
+	}
+	if err := json.Unmarshal(b, &receipt); err != nil {
+		return receipt, errors.New("invalid receipt token")
+	}
+
+	return receipt, nil
+}
+
+// ServedAt returns when the response was served
+func (r Receipt) ServedAt() time.Time {
+	return time.Unix(r.Served, 0)
+}
@@ -0,0 +1,70 @@
+package signed
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestReceipts(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Receipts:          true,
+	}))
+
+	app.Get("/files/a.txt", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	app.Get("/missing", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNotFound)
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/files/a.txt"))
+	signature := signedURL[strings.Index(signedURL, "signature=")+len("signature="):]
+
+	t.Run("it should return receipts verifiable by the issuing side", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		receipt, err := VerifyReceipt(resp.Header.Get(ReceiptHeader))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, signature, receipt.SignatureID)
+		utils.AssertEqual(t, len("Hello, world!"), receipt.Bytes)
+		utils.AssertEqual(t, true, time.Since(receipt.ServedAt()) < time.Minute)
+	})
+
+	t.Run("it should reject tampered receipts", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		token := resp.Header.Get(ReceiptHeader)
+
+		forged := getReceiptToken("other", Receipt{SignatureID: signature, Bytes: 1 << 30})
+		_, err := VerifyReceipt(token[:strings.Index(token, ".")] + forged[strings.Index(forged, "."):])
+		utils.AssertEqual(t, "invalid receipt token", err.Error())
+
+		_, err = VerifyReceipt(forged[:strings.Index(forged, ".")] + token[strings.Index(token, "."):])
+		utils.AssertEqual(t, "invalid receipt token", err.Error())
+	})
+
+	t.Run("it should not receipt failed responses", func(t *testing.T) {
+		missingURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/missing"))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, missingURL))
+
+		utils.AssertEqual(t, fiber.StatusNotFound, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(ReceiptHeader))
+	})
+
+	t.Run("it should not receipt rejected requests", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/files/a.txt"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "", resp.Header.Get(ReceiptHeader))
+	})
+}
@@ -0,0 +1,74 @@
+package signed
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errRejectionRendered is returned by verifyRequest once it has rendered
+// RejectionView in place of the error
+var errRejectionRendered = errors.New("rejection page rendered")
+
+// RejectionPage is the data RejectionView is rendered with
+type RejectionPage struct {
+	// Status is the status code of the response, eg. 403
+	Status int
+
+	// Message is the rejection message, translated by Messages if possible
+	Message string
+
+	// RenewalURL is where a fresh link can be requested, from RenewalURLFunc,
+	// or "" if there is none
+	RenewalURL string
+}
+
+// acceptsHTML reports whether the request prefers pages, ie. lists HTML in
+// its Accept header, as browsers do and API clients sending */* don't
+func acceptsHTML(c *fiber.Ctx) bool {
+	for _, r := range parseAccept(c.Get(fiber.HeaderAccept)) {
+		if r.value == fiber.MIMETextHTML || r.value == "application/xhtml+xml" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderRejection renders RejectionView for requests from browsers, reporting
+// whether it did. Other requests, and those the view fails to render for, are
+// left to be rejected with the error.
+func (cfg *instance) renderRejection(c *fiber.Ctx, status int, message string) bool {
+	if cfg.RejectionView == "" {
+		return false
+	}
+
+	// Responses differ by client, so caches must tell them apart
+	c.Vary(fiber.HeaderAccept)
+
+	if !acceptsHTML(c) {
+		return false
+	}
+
+	page := RejectionPage{Status: status, Message: message}
+	if cfg.RenewalURLFunc != nil {
+		page.RenewalURL = cfg.RenewalURLFunc(c)
+	}
+	if err := c.Render(cfg.RejectionView, page); err != nil {
+		c.Response().ResetBody()
+		return false
+	}
+	c.Status(status)
+
+	return true
+}
+
+// rejection returns the error a handler of the middleware returns for a
+// request verifyRequest rejected with err
+func rejection(err error) error {
+	if err == errRejectionRendered {
+		return nil
+	}
+
+	return err
+}
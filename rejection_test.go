@@ -0,0 +1,102 @@
+package signed
+
+import (
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// testViews renders views from templates in memory
+type testViews struct {
+	templates *template.Template
+}
+
+func (v testViews) Load() error {
+	return nil
+}
+
+func (v testViews) Render(w io.Writer, name string, bind interface{}, layouts ...string) error {
+	return v.templates.ExecuteTemplate(w, name, bind)
+}
+
+func TestRejectionView(t *testing.T) {
+	// Initalize config
+	app := fiber.New(fiber.Config{
+		Views: testViews{templates: template.Must(template.New("expired").Parse(
+			`<p>{{.Message}} ({{.Status}})</p><a href="{{.RenewalURL}}">Get a new link</a>`,
+		))},
+	})
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		RejectionView:     "expired",
+		RenewalURLFunc: func(c *fiber.Ctx) string {
+			return "https://example.com/renew?path=" + c.Path()
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	expiredURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?expires=1"))
+	reject := func(accept string) (*http.Response, string) {
+		req := newTestRequest(http.MethodGet, expiredURL)
+		req.Header.Set(fiber.HeaderAccept, accept)
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("it should render the view for browsers", func(t *testing.T) {
+		resp, body := reject("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, fiber.MIMETextHTMLCharsetUTF8, resp.Header.Get(fiber.HeaderContentType))
+		utils.AssertEqual(t, `<p>url signature has expired (403)</p><a href="https://example.com/renew?path=/">Get a new link</a>`, body)
+		utils.AssertEqual(t, fiber.HeaderAccept, resp.Header.Get(fiber.HeaderVary))
+	})
+
+	t.Run("it should reject API requests with the message", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/json", "text/html;q=0"} {
+			resp, body := reject(accept)
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode, accept)
+			utils.AssertEqual(t, "url signature has expired", body, accept)
+		}
+	})
+
+	t.Run("it should not render the view for accepted requests", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/"))
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Header.Set(fiber.HeaderAccept, fiber.MIMETextHTML)
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	t.Run("it should fall back to the message if the view fails", func(t *testing.T) {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			RejectionView:     "./missing.html",
+		}))
+
+		req := newTestRequest(http.MethodGet, expiredURL)
+		req.Header.Set(fiber.HeaderAccept, fiber.MIMETextHTML)
+		resp, _ := app.Test(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+}
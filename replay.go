@@ -0,0 +1,178 @@
+package signed
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReplayCache records nonces which have been used so signed URLs carrying a
+// nonce can only be used once
+type ReplayCache interface {
+	// CheckAndAdd records nonce for at least ttl and reports whether it had
+	// already been recorded
+	CheckAndAdd(nonce string, ttl time.Duration) (bool, error)
+}
+
+// ContextReplayCache is implemented by ReplayCaches accepting a context, so
+// nonces checked for a request respect its cancellation and deadline, and
+// carry its tracing context
+type ContextReplayCache interface {
+	CheckAndAddWithContext(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// storageReplayCache is a ReplayCache backed by the configured Storage
+type storageReplayCache struct {
+	mu  sync.Mutex
+	cfg *instance
+}
+
+// CheckAndAdd implements ReplayCache. The check and add are atomic across
+// validators when Storage implements ScriptRunner, and only within this
+// process otherwise.
+func (s *storageReplayCache) CheckAndAdd(nonce string, ttl time.Duration) (bool, error) {
+	return s.CheckAndAddWithContext(context.Background(), nonce, ttl)
+}
+
+// CheckAndAddWithContext implements ContextReplayCache
+func (s *storageReplayCache) CheckAndAddWithContext(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	added, err := s.add(ctx, StorageKindNonces, nonce, ttl)
+	return !added, err
+}
+
+// add records the entry of kind identified by id for ttl unless it is
+// already recorded, reporting whether it was added
+func (s *storageReplayCache) add(ctx context.Context, kind, id string, ttl time.Duration) (bool, error) {
+	cfg := s.cfg
+	key := cfg.storageKey(kind, id)
+	ttl = cfg.storageTTL(kind, ttl)
+
+	storage := cfg.getStorage(ctx)
+	if runner, ok := storage.(ScriptRunner); ok {
+		return evalScript(runner, luaSetNonce, []string{key}, ttl.Milliseconds())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := storage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if len(b) > 0 {
+		return false, nil
+	}
+
+	return true, storage.Set(key, []byte("1"), ttl)
+}
+
+// getReplayCache returns the configured ReplayCache, falling back to Storage
+func (cfg *instance) getReplayCache() ReplayCache {
+	if cfg.ReplayCache != nil {
+		return cfg.ReplayCache
+	}
+
+	if cfg.Storage != nil {
+		return cfg.storageReplays
+	}
+
+	return nil
+}
+
+// checkNonce rejects requests whose nonce has been used before, and with
+// RequireNonce set, requests without a nonce or an expiry (other than probes). Nonces are
+// remembered until the URL expires, or for NonceTTL without an expiry.
+func (cfg *instance) checkNonce(c *fiber.Ctx) error {
+	nonce := c.Query(cfg.NonceQueryKey)
+	if probe, _ := c.Locals(ProbeLocal).(bool); cfg.RequireNonce && !probe {
+		if expires, _ := cfg.getURLLifetime(c); nonce == "" || expires.IsZero() {
+			return errRequireNonce
+		}
+	}
+	if nonce == "" {
+		return nil
+	}
+
+	replays := cfg.getReplayCache()
+	if replays == nil {
+		return errors.New("url nonce cannot be checked without Storage or ReplayCache")
+	}
+
+	var seen bool
+	var err error
+	ttl := cfg.getUseTTL(c)
+	if contextReplays, ok := replays.(ContextReplayCache); ok {
+		seen, err = contextReplays.CheckAndAddWithContext(requestContext(c), nonce, ttl)
+	} else {
+		seen, err = replays.CheckAndAdd(nonce, ttl)
+	}
+	if err != nil {
+		return &storeError{err}
+	}
+	if seen {
+		return errors.New("url signature has already been used")
+	}
+
+	return nil
+}
+
+// errRequireNonce rejects URLs lacking a nonce or an expiry when
+// RequireNonce is set
+var errRequireNonce = errors.New("url must carry a nonce and an expiry when RequireNonce is set")
+
+// checkRequireNonce returns an error if the signing params q lack a nonce or
+// an expiry when RequireNonce is set, as requests for the URL would be
+// rejected
+func (cfg *instance) checkRequireNonce(q url.Values) error {
+	if !cfg.RequireNonce {
+		return nil
+	}
+	if _, ok := cfg.getEarliestExpiry(q.Get); q.Get(cfg.NonceQueryKey) == "" || !ok {
+		return errRequireNonce
+	}
+
+	return nil
+}
+
+// newNonce returns 16 random bytes, hex encoded
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate nonce: %v", err)
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
+
+// GetSingleUseSignedURLFromHTTPRequest takes an instance of *http.Request and
+// returns full URL with calculated signature, carrying a nonce from NonceFunc
+// so it can only be used once
+func GetSingleUseSignedURLFromHTTPRequest(r *http.Request) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	// Throw error if nonce query param is already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.NonceQueryKey); err != nil {
+		return "", err
+	}
+
+	nonce, err := cfg.NonceFunc()
+	if err != nil {
+		return "", err
+	}
+	if nonce == "" {
+		return "", errors.New("NonceFunc returned an empty nonce")
+	}
+
+	q.Set(cfg.NonceQueryKey, nonce)
+	r.URL.RawQuery = q.Encode()
+
+	return GetSignedURLFromHTTPRequest(r)
+}
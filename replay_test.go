@@ -0,0 +1,318 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestNonceReplay(t *testing.T) {
+
+	newApp := func(config Config) *fiber.App {
+		app := fiber.New()
+		config.GetPrivateKeyFunc = func() string { return "secret" }
+		app.Use(New(config))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	sign := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		return signedURL
+	}
+
+	bloom, _ := NewBloomReplayCache(1000, 0.001, time.Minute)
+	caches := map[string]Config{
+		"Storage":          {Storage: newTestStorage()},
+		"BloomReplayCache": {ReplayCache: bloom},
+	}
+
+	for name, config := range caches {
+		app := newApp(config)
+
+		t.Run(fmt.Sprintf("it should reject a replayed nonce with %s", name), func(t *testing.T) {
+			signedURL := sign("http://example.com/?nonce=abc123")
+
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+			resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+			utils.AssertEqual(t, "url signature has already been used", string(body))
+		})
+
+		t.Run(fmt.Sprintf("it should accept distinct nonces with %s", name), func(t *testing.T) {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?nonce=one")))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+			resp, _ = app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?nonce=two")))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		})
+	}
+
+	t.Run("it should not check nonces without Storage or ReplayCache", func(t *testing.T) {
+		app := newApp(Config{})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?nonce=abc123")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url nonce cannot be checked without Storage or ReplayCache", string(body))
+	})
+
+	t.Run("it should remember nonces of urls without an expiry for NonceTTL", func(t *testing.T) {
+		storage := &ttlStorage{testStorage: newTestStorage(), ttls: make(map[string]time.Duration)}
+		app := newApp(Config{Storage: storage})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, sign("http://example.com/?nonce=forever")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, 24*time.Hour, storage.ttls["fiber-signed:nonces:forever"])
+	})
+
+	t.Run("it should remember nonces until relative expiries beyond NonceTTL", func(t *testing.T) {
+		now := time.Unix(time.Now().Unix(), 0)
+		restore := InjectFaults(Faults{Now: now})
+		defer func() { restore() }()
+
+		storage := &ttlStorage{testStorage: newTestStorage(), ttls: make(map[string]time.Duration)}
+		app := newApp(Config{Storage: storage})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?nonce=later&maxUses=1", nil)
+		signedURL, err := GetSignedURLWithTTLFromHTTPRequest(req, 48*time.Hour)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, 48*time.Hour, storage.ttls["fiber-signed:nonces:later"])
+
+		// Still valid once NonceTTL has passed, so it must still be remembered
+		restore()
+		restore = InjectFaults(Faults{Now: now.Add(25 * time.Hour)})
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has already been used", string(body))
+	})
+}
+
+func TestBloomReplayCache(t *testing.T) {
+
+	t.Run("it should forget nonces after two intervals", func(t *testing.T) {
+		cache, err := NewBloomReplayCache(100, 0.01, time.Minute)
+		utils.AssertEqual(t, nil, err)
+
+		seen, _ := cache.CheckAndAdd("abc", time.Minute)
+		utils.AssertEqual(t, false, seen)
+
+		cache.rotated = cache.rotated.Add(-time.Minute)
+		seen, _ = cache.CheckAndAdd("abc", time.Minute)
+		utils.AssertEqual(t, true, seen)
+
+		cache.rotated = cache.rotated.Add(-2 * time.Minute)
+		seen, _ = cache.CheckAndAdd("abc", time.Minute)
+		utils.AssertEqual(t, false, seen)
+	})
+
+	t.Run("it should reject invalid sizes and rates", func(t *testing.T) {
+		_, err := NewBloomReplayCache(0, 0.01, time.Minute)
+		utils.AssertEqual(t, "bloom replay cache capacity must be positive", err.Error())
+
+		for _, rate := range []float64{0, 1, -0.5, math.NaN()} {
+			_, err = NewBloomReplayCache(100, rate, time.Minute)
+			utils.AssertEqual(t, "bloom replay cache false positive rate must be between 0 and 1", err.Error())
+		}
+
+		_, err = NewBloomReplayCache(100, 0.01, 0)
+		utils.AssertEqual(t, "bloom replay cache interval must be positive", err.Error())
+	})
+
+	t.Run("it should stay near the configured false positive rate", func(t *testing.T) {
+		filter := newBloomFilter(10000, 0.01)
+		for i := 0; i < 10000; i++ {
+			filter.add(fmt.Sprintf("added-%d", i))
+		}
+
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if filter.test(fmt.Sprintf("fresh-%d", i)) {
+				falsePositives++
+			}
+		}
+
+		utils.AssertEqual(t, true, falsePositives < 300)
+	})
+}
+
+func TestGetSingleUseSignedURLFromHTTPRequest(t *testing.T) {
+	t.Run("it should add a nonce from NonceFunc", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			NonceFunc:         func() (string, error) { return "fixed", nil },
+		})
+
+		first, err := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1", nil))
+		utils.AssertEqual(t, nil, err)
+		second, _ := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1", nil))
+
+		utils.AssertEqual(t, first, second)
+		utils.AssertEqual(t, true, strings.Contains(first, "nonce=fixed"))
+	})
+
+	t.Run("it should default to random nonces", func(t *testing.T) {
+		New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+
+		first, _ := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		second, _ := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		nonce, err := newNonce()
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, false, first == second)
+		utils.AssertEqual(t, 32, len(nonce))
+	})
+
+	t.Run("it should return the error of NonceFunc", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			NonceFunc:         func() (string, error) { return "", errors.New("entropy source unavailable") },
+		})
+
+		_, err := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		utils.AssertEqual(t, "entropy source unavailable", err.Error())
+	})
+
+	t.Run("it should not sign a url with an existing nonce", func(t *testing.T) {
+		_, err := GetSingleUseSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?nonce=mine", nil))
+
+		utils.AssertEqual(t, "nonce is a reserved query parameter when generating signed routes", err.Error())
+	})
+}
+
+func TestReplayWindow(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		StampIssued:       true,
+		ReplayWindow:      30 * time.Second,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	t.Run("it should accept requests signed within the window", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject requests signed outside the window", func(t *testing.T) {
+		for _, skew := range []time.Duration{time.Minute, -time.Minute} {
+			restore := InjectFaults(Faults{ClockSkew: skew})
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+			restore()
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+			utils.AssertEqual(t, "url signature is outside the replay window", string(body))
+		}
+	})
+
+	t.Run("it should reject urls without an issued time", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=abc"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "issued is a required query param when ReplayWindow is set", string(body))
+	})
+}
+
+func TestRequireNonce(t *testing.T) {
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	sign := func(target string) (string, error) {
+		return GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, target))
+	}
+
+	// Sign URLs lacking freshness params before they are required
+	New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	})
+	withoutNonce, _ := sign("http://example.com/?expires=" + expires)
+	withoutExpiry, _ := sign("http://example.com/?nonce=abc")
+
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		RequireNonce:      true,
+		Storage:           newTestStorage(),
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should accept urls with a nonce and an expiry", func(t *testing.T) {
+		signedURL, err := sign("http://example.com/?nonce=def&expires=" + expires)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject urls without a nonce or an expiry", func(t *testing.T) {
+		for _, signedURL := range []string{withoutNonce, withoutExpiry} {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+			utils.AssertEqual(t, "url must carry a nonce and an expiry when RequireNonce is set", string(body))
+		}
+	})
+
+	t.Run("it should refuse to sign urls without a nonce or an expiry", func(t *testing.T) {
+		_, err := sign("http://example.com/?expires=" + expires)
+		utils.AssertEqual(t, errRequireNonce, err)
+
+		_, err = sign("http://example.com/?nonce=ghi")
+		utils.AssertEqual(t, errRequireNonce, err)
+	})
+
+	t.Run("it should accept relative expiries", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, "http://example.com/?nonce=jkl")
+		signedURL, err := GetSignedURLWithTTLFromHTTPRequest(req, time.Hour)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should require somewhere to remember nonces", func(t *testing.T) {
+		err := UpdateConfig(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			RequireNonce:      true,
+		})
+
+		utils.AssertEqual(t, "RequireNonce needs ReplayCache or Storage to remember nonces", err.Error())
+	})
+}
@@ -0,0 +1,59 @@
+package signed
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClaimRequestID is the claim carrying the ID of the request or trace a URL was
+// issued in, so its use can be traced back to its issuance. It is signed into
+// URLs as the RequestIDQueryKey param.
+const ClaimRequestID = "rid"
+
+// RequestIDLocal is the local the request ID signed into a validated URL is
+// stored in, eg. for the logger middleware's ${locals:fiber-signed:request-id}
+// tag
+const RequestIDLocal = "fiber-signed:request-id"
+
+// getIssuingRequestID returns the ID RequestIDFunc returns for ctx, or "" if
+// RequestIDFunc is not set
+func (cfg *instance) getIssuingRequestID(ctx context.Context) string {
+	if cfg.RequestIDFunc == nil {
+		return ""
+	}
+
+	return cfg.RequestIDFunc(ctx)
+}
+
+// stampRequestID adds the ID of the issuing request to the signing params q,
+// unless they carry one already, returning whether q was changed
+func (cfg *instance) stampRequestID(ctx context.Context, q url.Values) bool {
+	if _, ok := q[cfg.RequestIDQueryKey]; ok {
+		return false
+	}
+
+	id := cfg.getIssuingRequestID(ctx)
+	if id == "" {
+		return false
+	}
+	q.Set(cfg.RequestIDQueryKey, id)
+
+	return true
+}
+
+// setRequestID stores the request ID signed into the URL of a validated
+// request in RequestIDLocal
+func (cfg *instance) setRequestID(c *fiber.Ctx) {
+	if id := cfg.getClaim(c, ClaimRequestID); id != "" {
+		c.Locals(RequestIDLocal, id)
+	}
+}
+
+// GetRequestID returns the ID of the request a validated URL was issued in,
+// or "" if it carries none
+func GetRequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(RequestIDLocal).(string)
+	return id
+}
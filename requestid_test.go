@@ -0,0 +1,93 @@
+package signed
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestRequestID(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		RequestIDFunc: func(ctx context.Context) string {
+			id, _ := ctx.Value(traceKey{}).(string)
+			return id
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(GetRequestID(c))
+	})
+
+	sign := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), traceKey{}, "trace-1"))
+	}
+
+	var signed, verified Event
+	unsubscribe := Subscribe(func(e Event) {
+		switch e.Type {
+		case EventSigned:
+			signed = e
+		case EventVerified:
+			verified = e
+		}
+	})
+	defer unsubscribe()
+
+	t.Run("it should sign the id of the issuing request in", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(sign("http://example.com/"))
+		utils.AssertEqual(t, "trace-1", signed.RequestID)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "trace-1", string(body))
+		utils.AssertEqual(t, "trace-1", verified.RequestID)
+	})
+
+	t.Run("it should keep a request id supplied by the caller", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(sign("http://example.com/?rid=job-7"))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "job-7", string(body))
+	})
+
+	t.Run("it should leave app params named rid alone under a prefix", func(t *testing.T) {
+		current().RequestIDQueryKey = "X-Sig-Rid"
+		defer func() { current().RequestIDQueryKey = "rid" }()
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(sign("http://example.com/?rid=job-7"))
+		utils.AssertEqual(t, "trace-1", signed.RequestID)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "trace-1", string(body))
+	})
+
+	t.Run("it should sign the id into tokens", func(t *testing.T) {
+		signedURL, _ := GetSignedTokenURLFromHTTPRequest(sign("http://example.com/"), Claims{})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, "trace-1", string(body))
+	})
+
+	t.Run("it should reject a tampered request id", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(sign("http://example.com/"))
+		tampered := strings.Replace(signedURL, "rid=trace-1", "rid=trace-2", 1)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, tampered))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,65 @@
+package signed
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReservedParamsError is returned when a URL to sign already contains query
+// params reserved by the middleware, listing all of them at once
+type ReservedParamsError struct {
+	// Params are the reserved query params found in the URL
+	Params []string
+}
+
+// Error implements error
+func (e *ReservedParamsError) Error() string {
+	if len(e.Params) == 1 {
+		return fmt.Sprintf("%s is a reserved query parameter when generating signed routes", e.Params[0])
+	}
+	return fmt.Sprintf("%s are reserved query parameters when generating signed routes", strings.Join(e.Params, ", "))
+}
+
+// checkReservedParams returns a *ReservedParamsError listing every key
+// present in q
+func checkReservedParams(q url.Values, keys ...string) error {
+	var found []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := q[key]; ok {
+			found = append(found, key)
+		}
+	}
+
+	if len(found) > 0 {
+		return &ReservedParamsError{Params: found}
+	}
+	return nil
+}
+
+// checkSigningParams returns a *ReservedParamsError listing every param of q
+// which signing would add or which would change how the URL is verified,
+// along with any of extra
+func (cfg *instance) checkSigningParams(q url.Values, extra ...string) error {
+	keys := append(append([]string(nil), extra...),
+		cfg.SignatureQueryKey,
+		cfg.PrivateKeyQueryKey,
+		cfg.BodyHashQueryKey,
+		cfg.CaveatQueryKey,
+		cfg.TokenQueryKey,
+		cfg.BindLocal,
+	)
+	if cfg.StampIssued {
+		keys = append(keys, cfg.IssuedQueryKey)
+	}
+	if cfg.EmbedAlgorithm {
+		keys = append(keys, cfg.AlgorithmQueryKey)
+	}
+
+	return checkReservedParams(q, keys...)
+}
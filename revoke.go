@@ -0,0 +1,288 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClaimUser is the claim naming the user a URL was signed for, which URLs can
+// be revoked by. It is signed into URLs as the UserQueryKey param.
+const ClaimUser = "user"
+
+// Revocation selects signed URLs to revoke. URLs matching any field set are
+// rejected.
+type Revocation struct {
+	// Signature revokes the URL with this signature, or token in token mode
+	Signature string
+
+	// User revokes URLs signed with this ClaimUser
+	User string
+
+	// Purpose revokes URLs signed with this ClaimPurpose
+	Purpose string
+
+	// Path revokes URLs for this path, or every path under it if it ends
+	// with "/*", eg. "/exports/2023/*"
+	Path string
+
+	// IssuedBefore is the cutoff set with RevokeAllBefore. It is ignored by
+	// Revoke.
+	IssuedBefore time.Time
+}
+
+// revocationIDs returns the Storage IDs of the index entries of r
+func (r Revocation) revocationIDs() ([]string, error) {
+	var ids []string
+	if r.Signature != "" {
+		ids = append(ids, "signature:"+r.Signature)
+	}
+	if r.User != "" {
+		ids = append(ids, "user:"+r.User)
+	}
+	if r.Purpose != "" {
+		ids = append(ids, "purpose:"+r.Purpose)
+	}
+	if r.Path != "" {
+		if i := strings.Index(r.Path, "*"); i >= 0 && (i != len(r.Path)-1 || !strings.HasSuffix(r.Path, "/*")) {
+			return nil, fmt.Errorf("cannot revoke path %q, wildcards are only supported as a trailing /*", r.Path)
+		}
+		ids = append(ids, "path:"+r.Path)
+	}
+
+	if len(ids) == 0 {
+		return nil, errors.New("revocation must select signed URLs")
+	}
+	return ids, nil
+}
+
+// Revoke rejects the signed URLs selected by r for ttl, or indefinitely if
+// ttl is zero. Revocations are kept in Storage, so every validator sharing
+// it rejects the URLs.
+func Revoke(r Revocation, ttl time.Duration) error {
+	cfg := current()
+
+	if err := cfg.revoke(r, ttl); err != nil {
+		return err
+	}
+
+	events.emit(Event{Type: EventRevoked, Revocation: r})
+	return nil
+}
+
+// revoke adds the index entries of r to Storage
+func (cfg *instance) revoke(r Revocation, ttl time.Duration) error {
+	if err := cfg.checkRevocations(); err != nil {
+		return err
+	}
+
+	storage := cfg.getStorage(context.Background())
+	id, err := cfg.storeRevocation(storage, r, ttl)
+	if err != nil {
+		return err
+	}
+
+	// Remember the revocation for ExportRevocations
+	return cfg.appendRevocationLog(storage, []string{id})
+}
+
+// checkRevocations returns an error if signed URLs can't be revoked
+func (cfg *instance) checkRevocations() error {
+	if !cfg.Revocations {
+		return errors.New("Revocations must be enabled to revoke signed URLs")
+	}
+	if cfg.Storage == nil {
+		return errors.New("signed URLs cannot be revoked without Storage")
+	}
+
+	return nil
+}
+
+// storeRevocation adds the index entries and the log record of r to storage,
+// returning the ID of the record to add to the revocation log
+func (cfg *instance) storeRevocation(storage fiber.Storage, r Revocation, ttl time.Duration) (string, error) {
+	ids, err := r.revocationIDs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		if err := storage.Set(cfg.storageKey(StorageKindRevoked, id), []byte("1"), cfg.storageTTL(StorageKindRevoked, ttl)); err != nil {
+			return "", err
+		}
+	}
+
+	return cfg.logRevocation(storage, r, ttl)
+}
+
+// RevokeURL rejects signedURL for ttl, or indefinitely if ttl is zero
+func RevokeURL(signedURL string, ttl time.Duration) error {
+	cfg := current()
+
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return errors.New("cannot parse provided URL")
+	}
+
+	q := urlQuery(u)
+	signature := q.Get(cfg.TokenQueryKey)
+	if signature == "" {
+		signature = q.Get(cfg.SignatureQueryKey)
+	}
+	if signature == "" {
+		return fmt.Errorf("%s is a required query param for a signed URL route", cfg.SignatureQueryKey)
+	}
+
+	r := Revocation{Signature: signature}
+	if err := cfg.revoke(r, ttl); err != nil {
+		return err
+	}
+
+	events.emit(Event{Type: EventRevoked, URL: signedURL, Revocation: r})
+	return nil
+}
+
+// checkRevoked rejects requests for signed URLs matching a revocation
+func (cfg *instance) checkRevoked(c *fiber.Ctx) error {
+	if !cfg.Revocations || cfg.Storage == nil {
+		return nil
+	}
+
+	// Caveats are folded into the signature one at a time, so URLs are
+	// revoked along with every URL derived from them by adding caveats
+	ids := []string{"path:" + c.Path()}
+	if token := c.Query(cfg.TokenQueryKey); token != "" {
+		ids = append(ids, "signature:"+token)
+	} else {
+		for _, signature := range cfg.getVerifiedSignatures(c) {
+			ids = append(ids, "signature:"+signature)
+		}
+	}
+	if user := cfg.getClaim(c, ClaimUser); user != "" {
+		ids = append(ids, "user:"+user)
+	}
+	if purpose := cfg.getClaim(c, ClaimPurpose); purpose != "" {
+		ids = append(ids, "purpose:"+purpose)
+	}
+	for i, ch := range c.Path() {
+		if ch == '/' {
+			ids = append(ids, "path:"+c.Path()[:i+1]+"*")
+		}
+	}
+
+	storage := cfg.getStorage(requestContext(c))
+	for _, id := range ids {
+		b, err := storage.Get(cfg.storageKey(StorageKindRevoked, id))
+		if err != nil {
+			return &storeError{err}
+		}
+		if len(b) > 0 {
+			return errors.New("url signature has been revoked")
+		}
+	}
+
+	return nil
+}
+
+// revokedBeforeRefresh is how often the cutoff of RevokeAllBefore is re-read
+// from Storage
+var revokedBeforeRefresh = 5 * time.Second
+
+// issuedCutoff holds the cutoff set with RevokeAllBefore
+type issuedCutoff struct {
+	mu      sync.Mutex
+	cutoff  time.Time
+	fetched time.Time
+}
+
+// reset clears the cutoff
+func (i *issuedCutoff) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.cutoff, i.fetched = time.Time{}, time.Time{}
+}
+
+// get returns the cutoff, re-reading it from Storage with ctx when shared
+// there
+func (i *issuedCutoff) get(cfg *instance, ctx context.Context) (time.Time, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !cfg.Revocations || cfg.Storage == nil || timeNow().Sub(i.fetched) < revokedBeforeRefresh {
+		return i.cutoff, nil
+	}
+
+	b, err := cfg.getStorage(ctx).Get(cfg.storageKey(StorageKindRevoked, "before"))
+	if err != nil {
+		return i.cutoff, &storeError{err}
+	}
+	if n, err := strconv.ParseInt(string(b), 10, 64); err == nil && time.Unix(n, 0).After(i.cutoff) {
+		i.cutoff = time.Unix(n, 0)
+	}
+	i.fetched = timeNow()
+
+	return i.cutoff, nil
+}
+
+// RevokeAllBefore rejects every URL issued before t, and URLs without an
+// issued time (see StampIssued), eg. to invalidate everything signed before
+// a suspected key leak without rotating the key immediately. Probe tokens
+// are rejected too. With Revocations set the cutoff is shared through
+// Storage, and otherwise only applies to this process.
+func RevokeAllBefore(t time.Time) error {
+	cfg := current()
+
+	if err := cfg.revokeAllBefore(t); err != nil {
+		return err
+	}
+
+	events.emit(Event{Type: EventRevoked, Revocation: Revocation{IssuedBefore: t}})
+	return nil
+}
+
+// revokeAllBefore moves the cutoff of RevokeAllBefore to t, unless it is
+// later already
+func (cfg *instance) revokeAllBefore(t time.Time) error {
+	cfg.revokedBefore.mu.Lock()
+	if t.After(cfg.revokedBefore.cutoff) {
+		cfg.revokedBefore.cutoff = t
+	}
+	cutoff := cfg.revokedBefore.cutoff
+	cfg.revokedBefore.mu.Unlock()
+
+	if cfg.Revocations && cfg.Storage != nil {
+		err := cfg.getStorage(context.Background()).Set(cfg.storageKey(StorageKindRevoked, "before"), []byte(strconv.FormatInt(cutoff.Unix(), 10)), cfg.storageTTL(StorageKindRevoked, 0))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkIssuedCutoff rejects requests for URLs issued before the cutoff set
+// with RevokeAllBefore
+func (cfg *instance) checkIssuedCutoff(c *fiber.Ctx) error {
+	cutoff, err := cfg.revokedBefore.get(cfg, requestContext(c))
+	if err != nil || cutoff.IsZero() {
+		return err
+	}
+
+	issued, err := cfg.getIssued(c, "RevokeAllBefore")
+	if err != nil {
+		return err
+	}
+	if issued.Before(cutoff) {
+		return errors.New("url signature has been revoked")
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestRevoke(t *testing.T) {
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+			Revocations:       true,
+		}))
+		app.Get("/*", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	sign := func(target string) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	status := func(app *fiber.App, signedURL string) int {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		return resp.StatusCode
+	}
+
+	t.Run("it should reject revoked urls", func(t *testing.T) {
+		app := newApp()
+		signedURL := sign("http://example.com/a")
+		utils.AssertEqual(t, fiber.StatusOK, status(app, signedURL))
+
+		utils.AssertEqual(t, nil, RevokeURL(signedURL, 0))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has been revoked", string(body))
+		utils.AssertEqual(t, fiber.StatusOK, status(app, sign("http://example.com/b")))
+	})
+
+	t.Run("it should reject caveated copies of revoked urls", func(t *testing.T) {
+		app := newApp()
+		signedURL := sign("http://example.com/a")
+		utils.AssertEqual(t, nil, RevokeURL(signedURL, 0))
+
+		caveated, err := AddCaveat(signedURL, PathCaveat("/*"))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, caveated))
+	})
+
+	t.Run("it should revoke caveated urls without their parent", func(t *testing.T) {
+		app := newApp()
+		signedURL := sign("http://example.com/a")
+		caveated, _ := AddCaveat(signedURL, PathCaveat("/*"))
+		utils.AssertEqual(t, nil, RevokeURL(caveated, 0))
+
+		narrowed, _ := AddCaveat(caveated, PathCaveat("/a"))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, caveated))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, narrowed))
+		utils.AssertEqual(t, fiber.StatusOK, status(app, signedURL))
+	})
+
+	t.Run("it should reject urls by user, purpose and path", func(t *testing.T) {
+		app := newApp()
+		byUser := sign("http://example.com/a?user=42")
+		byPurpose := sign("http://example.com/a?purpose=password-reset")
+		byPath := sign("http://example.com/exports/2023/report.csv")
+		other := sign("http://example.com/exports/2024/report.csv?user=7&purpose=share")
+
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42", Purpose: "password-reset", Path: "/exports/2023/*"}, 0))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byUser))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byPurpose))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byPath))
+		utils.AssertEqual(t, fiber.StatusOK, status(app, other))
+	})
+
+	t.Run("it should leave app params named user alone under a prefix", func(t *testing.T) {
+		app := newApp()
+		current().UserQueryKey = "X-Sig-User"
+		defer func() { current().UserQueryKey = "user" }()
+
+		appUser := sign("http://example.com/a?user=42")
+		byUser := sign("http://example.com/a?X-Sig-User=42")
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, 0))
+
+		utils.AssertEqual(t, fiber.StatusOK, status(app, appUser))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byUser))
+	})
+
+	t.Run("it should only support trailing path wildcards", func(t *testing.T) {
+		newApp()
+
+		utils.AssertEqual(t, `cannot revoke path "/exports/*/a", wildcards are only supported as a trailing /*`, Revoke(Revocation{Path: "/exports/*/a"}, 0).Error())
+	})
+}
+
+func TestRevokeAllBefore(t *testing.T) {
+	// Initalize config
+	storage := newTestStorage()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		StampIssued:       true,
+		Storage:           storage,
+		Revocations:       true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	before, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	restore := InjectFaults(Faults{ClockSkew: time.Hour})
+	defer restore()
+
+	utils.AssertEqual(t, nil, RevokeAllBefore(timeNow().Add(-time.Minute)))
+	after, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	t.Run("it should reject urls issued before the cutoff", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, before))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has been revoked", string(body))
+	})
+
+	t.Run("it should accept urls issued after the cutoff", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, after))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should share the cutoff through storage", func(t *testing.T) {
+		current().revokedBefore.reset()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, before))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
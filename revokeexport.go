@@ -0,0 +1,355 @@
+package signed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// revocationLogID is the Storage ID of the index of revocations exported by
+// ExportRevocations, as Storage can't list the revocations themselves. It
+// holds the IDs of their records, one per line.
+const revocationLogID = "log"
+
+// revocationRecordPrefix prefixes the Storage IDs of revocation records
+const revocationRecordPrefix = "record:"
+
+// Lua scripts maintaining the revocation log with ScriptRunner
+const (
+	// luaAppendLog appends the lines ARGV which KEYS[1] doesn't hold yet
+	luaAppendLog = `local log = redis.call('GET', KEYS[1]) or ''
+for i = 1, #ARGV do
+  if not string.find('\n' .. log, '\n' .. ARGV[i] .. '\n', 1, true) then
+    log = log .. ARGV[i] .. '\n'
+  end
+end
+redis.call('SET', KEYS[1], log)
+return 1`
+
+	// luaPruneLog removes the lines ARGV from KEYS[1] whose records, KEYS[2]
+	// onwards, are gone
+	luaPruneLog = `local log = redis.call('GET', KEYS[1]) or ''
+for i = 1, #ARGV do
+  local at = string.find('\n' .. log, '\n' .. ARGV[i] .. '\n', 1, true)
+  if at and redis.call('EXISTS', KEYS[i + 1]) == 0 then
+    log = string.sub(log, 1, at - 1) .. string.sub(log, at + #ARGV[i] + 1)
+  end
+end
+redis.call('SET', KEYS[1], log)
+return 1`
+)
+
+// revocationRecord is a revocation as logged and exported
+type revocationRecord struct {
+	Signature string `json:"signature,omitempty"`
+	User      string `json:"user,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	Path      string `json:"path,omitempty"`
+
+	// Expires is when the revocation lapses, in unix seconds, or 0 if never
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// revocationSet is the document ExportRevocations produces
+type revocationSet struct {
+	// IssuedBefore is the cutoff set with RevokeAllBefore, in unix seconds,
+	// or 0 if unset
+	IssuedBefore int64 `json:"issuedBefore,omitempty"`
+
+	Revocations []revocationRecord `json:"revocations"`
+}
+
+// revocationLogMu serializes updates to the revocation log within a process
+// when Storage doesn't implement ScriptRunner
+var revocationLogMu sync.Mutex
+
+// revocation returns the revocation r records
+func (r revocationRecord) revocation() Revocation {
+	return Revocation{Signature: r.Signature, User: r.User, Purpose: r.Purpose, Path: r.Path}
+}
+
+// lapsed reports whether the revocation no longer applies at now
+func (r revocationRecord) lapsed(now time.Time) bool {
+	return r.Expires != 0 && r.Expires <= now.Unix()
+}
+
+// id returns the ID of the record in the revocation log, the same for every
+// record of the revocation
+func (r revocationRecord) id() string {
+	b, _ := json.Marshal(r.revocation())
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// readRevocationLog returns the IDs of the records in the revocation log
+func (cfg *instance) readRevocationLog(storage fiber.Storage) ([]string, error) {
+	b, err := storage.Get(cfg.storageKey(StorageKindRevoked, revocationLogID))
+	if err != nil || len(b) == 0 {
+		return nil, err
+	}
+
+	return strings.Fields(string(b)), nil
+}
+
+// appendRevocationLog adds the record IDs ids to the revocation log,
+// atomically when Storage implements ScriptRunner
+func (cfg *instance) appendRevocationLog(storage fiber.Storage, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	key := cfg.storageKey(StorageKindRevoked, revocationLogID)
+	if runner, ok := storage.(ScriptRunner); ok {
+		args := make([]interface{}, len(ids))
+		for i, id := range ids {
+			args[i] = id
+		}
+		_, err := evalScript(runner, luaAppendLog, []string{key}, args...)
+		return err
+	}
+
+	revocationLogMu.Lock()
+	defer revocationLogMu.Unlock()
+
+	logged, err := cfg.readRevocationLog(storage)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(logged))
+	for _, id := range logged {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			logged = append(logged, id)
+		}
+	}
+
+	return cfg.writeRevocationLog(storage, logged)
+}
+
+// pruneRevocationLog removes the record IDs ids from the revocation log if
+// their records are gone, atomically when Storage implements ScriptRunner
+func (cfg *instance) pruneRevocationLog(storage fiber.Storage, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	key := cfg.storageKey(StorageKindRevoked, revocationLogID)
+	if runner, ok := storage.(ScriptRunner); ok {
+		keys := []string{key}
+		args := make([]interface{}, len(ids))
+		for i, id := range ids {
+			keys = append(keys, cfg.storageKey(StorageKindRevoked, revocationRecordPrefix+id))
+			args[i] = id
+		}
+		_, err := evalScript(runner, luaPruneLog, keys, args...)
+		return err
+	}
+
+	revocationLogMu.Lock()
+	defer revocationLogMu.Unlock()
+
+	logged, err := cfg.readRevocationLog(storage)
+	if err != nil {
+		return err
+	}
+
+	// Records may have been logged again since they were found gone
+	gone := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		record, err := cfg.readRevocationRecord(storage, id)
+		if err != nil {
+			return err
+		}
+		gone[id] = record == nil
+	}
+	kept := logged[:0]
+	for _, id := range logged {
+		if !gone[id] {
+			kept = append(kept, id)
+		}
+	}
+
+	return cfg.writeRevocationLog(storage, kept)
+}
+
+// writeRevocationLog replaces the revocation log with the record IDs ids
+func (cfg *instance) writeRevocationLog(storage fiber.Storage, ids []string) error {
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString(id + "\n")
+	}
+
+	return storage.Set(cfg.storageKey(StorageKindRevoked, revocationLogID), []byte(b.String()), cfg.storageTTL(StorageKindRevoked, 0))
+}
+
+// readRevocationRecord returns the record with id in the revocation log, or
+// nil if it is gone
+func (cfg *instance) readRevocationRecord(storage fiber.Storage, id string) (*revocationRecord, error) {
+	b, err := storage.Get(cfg.storageKey(StorageKindRevoked, revocationRecordPrefix+id))
+	if err != nil || len(b) == 0 {
+		return nil, err
+	}
+
+	var record revocationRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, errors.New("revocation log in Storage is corrupt")
+	}
+
+	return &record, nil
+}
+
+// logRevocation writes the record of r, revoked for ttl (or indefinitely if
+// zero), for the revocation log, returning its ID to add to the log. A
+// revocation logged again is kept until the later of its expiries.
+func (cfg *instance) logRevocation(storage fiber.Storage, r Revocation, ttl time.Duration) (string, error) {
+	record := revocationRecord{Signature: r.Signature, User: r.User, Purpose: r.Purpose, Path: r.Path}
+	if ttl > 0 {
+		record.Expires = timeNow().Add(ttl).Unix()
+	}
+	id := record.id()
+
+	logged, err := cfg.readRevocationRecord(storage, id)
+	if err != nil {
+		return "", err
+	}
+	if logged != nil && (logged.Expires == 0 || (record.Expires != 0 && logged.Expires > record.Expires)) {
+		record.Expires = logged.Expires
+	}
+
+	// Keep the record, and its ID in the log, only while the revocation lasts
+	ttl = 0
+	if record.Expires != 0 {
+		ttl = time.Unix(record.Expires, 0).Sub(timeNow())
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	if err := storage.Set(cfg.storageKey(StorageKindRevoked, revocationRecordPrefix+id), b, cfg.storageTTL(StorageKindRevoked, ttl)); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// ExportRevocations returns the revocations in effect, made with Revoke,
+// RevokeURL and RevokeAllBefore, as JSON for ImportRevocations, so they can
+// be propagated to validators which don't share Storage, eg. in other
+// regions. Unless Storage implements ScriptRunner, revocations made
+// concurrently by other validators sharing Storage may be missing from the
+// export until they are made again.
+func ExportRevocations() ([]byte, error) {
+	cfg := current()
+
+	if !cfg.Revocations {
+		return nil, errors.New("Revocations must be enabled to export revocations")
+	}
+	if cfg.Storage == nil {
+		return nil, errors.New("revocations cannot be exported without Storage")
+	}
+
+	storage := cfg.getStorage(context.Background())
+	ids, err := cfg.readRevocationLog(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the IDs of records which expired from Storage from the log
+	now := timeNow()
+	set := revocationSet{Revocations: []revocationRecord{}}
+	var gone []string
+	for _, id := range ids {
+		record, err := cfg.readRevocationRecord(storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			gone = append(gone, id)
+		}
+		if record == nil || record.lapsed(now) {
+			continue
+		}
+		set.Revocations = append(set.Revocations, *record)
+	}
+	if err := cfg.pruneRevocationLog(storage, gone); err != nil {
+		return nil, err
+	}
+
+	cutoff, err := cfg.revokedBefore.get(cfg, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if !cutoff.IsZero() {
+		set.IssuedBefore = cutoff.Unix()
+	}
+
+	return json.Marshal(set)
+}
+
+// ImportRevocations applies the revocations exported by ExportRevocations,
+// for the rest of their lifetimes. Revocations already in effect are kept
+// until the later of their expiries, so exports can be imported repeatedly or
+// exchanged both ways. No EventRevoked is emitted, so subscribers propagating
+// revocations don't echo them back.
+func ImportRevocations(data []byte) error {
+	cfg := current()
+
+	var set revocationSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return errors.New("cannot parse exported revocations")
+	}
+
+	// Check every revocation before applying any
+	for _, record := range set.Revocations {
+		if _, err := record.revocation().revocationIDs(); err != nil {
+			return err
+		}
+	}
+
+	if len(set.Revocations) > 0 {
+		if err := cfg.checkRevocations(); err != nil {
+			return err
+		}
+	}
+
+	// Add the records to the log at once rather than one by one
+	storage := cfg.getStorage(context.Background())
+	now := timeNow()
+	ids := make([]string, 0, len(set.Revocations))
+	for _, record := range set.Revocations {
+		if record.lapsed(now) {
+			continue
+		}
+
+		var ttl time.Duration
+		if record.Expires != 0 {
+			ttl = time.Unix(record.Expires, 0).Sub(now)
+		}
+		id, err := cfg.storeRevocation(storage, record.revocation(), ttl)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := cfg.appendRevocationLog(storage, ids); err != nil {
+		return err
+	}
+
+	if set.IssuedBefore != 0 {
+		return cfg.revokeAllBefore(time.Unix(set.IssuedBefore, 0))
+	}
+
+	return nil
+}
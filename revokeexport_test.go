@@ -0,0 +1,181 @@
+package signed
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestExportRevocations(t *testing.T) {
+	// Each region has its own Storage
+	newRegion := func() *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			StampIssued:       true,
+			Storage:           newTestStorage(),
+			Revocations:       true,
+		}))
+		app.Get("/*", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	sign := func(target string) string {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		return signedURL
+	}
+
+	status := func(app *fiber.App, signedURL string) int {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		return resp.StatusCode
+	}
+
+	t.Run("it should propagate revocations to another region", func(t *testing.T) {
+		newRegion()
+		byURL := sign("http://example.com/a")
+		byUser := sign("http://example.com/a?user=42")
+		byPath := sign("http://example.com/exports/2023/report.csv")
+		other := sign("http://example.com/b")
+
+		utils.AssertEqual(t, nil, RevokeURL(byURL, time.Hour))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, 0))
+		utils.AssertEqual(t, nil, Revoke(Revocation{Path: "/exports/2023/*"}, 0))
+		exported, err := ExportRevocations()
+		utils.AssertEqual(t, nil, err)
+
+		app := newRegion()
+		utils.AssertEqual(t, fiber.StatusOK, status(app, byURL))
+		utils.AssertEqual(t, nil, ImportRevocations(exported))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byUser))
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, byPath))
+		utils.AssertEqual(t, fiber.StatusOK, status(app, other))
+
+		// Imported revocations are exported again
+		reexported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(reexported, &set)
+		utils.AssertEqual(t, 3, len(set.Revocations))
+	})
+
+	t.Run("it should keep the later expiry of a revocation", func(t *testing.T) {
+		newRegion()
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, 0))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, time.Hour))
+
+		exported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(exported, &set)
+		utils.AssertEqual(t, []revocationRecord{{User: "42"}}, set.Revocations)
+	})
+
+	t.Run("it should leave out lapsed revocations", func(t *testing.T) {
+		newRegion()
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, time.Minute))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "7"}, 0))
+
+		restore := InjectFaults(Faults{ClockSkew: time.Hour})
+		defer restore()
+
+		exported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(exported, &set)
+		utils.AssertEqual(t, []revocationRecord{{User: "7"}}, set.Revocations)
+	})
+
+	t.Run("it should propagate the issued cutoff", func(t *testing.T) {
+		newRegion()
+		before := sign("http://example.com/a")
+
+		restore := InjectFaults(Faults{ClockSkew: time.Hour})
+		defer restore()
+
+		utils.AssertEqual(t, nil, RevokeAllBefore(timeNow().Add(-time.Minute)))
+		exported, _ := ExportRevocations()
+
+		app := newRegion()
+		after := sign("http://example.com/a")
+		utils.AssertEqual(t, nil, ImportRevocations(exported))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, status(app, before))
+		utils.AssertEqual(t, fiber.StatusOK, status(app, after))
+	})
+
+	t.Run("it should log revocations atomically with ScriptRunner", func(t *testing.T) {
+		storage := &scriptStorage{testStorage: newTestStorage()}
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			Revocations:       true,
+		})
+
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, 0))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, 0))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "7"}, 0))
+		utils.AssertEqual(t, 3, storage.evals)
+
+		exported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(exported, &set)
+		utils.AssertEqual(t, []revocationRecord{{User: "42"}, {User: "7"}}, set.Revocations)
+	})
+
+	t.Run("it should import revocations in one log update", func(t *testing.T) {
+		newRegion()
+		for _, user := range []string{"1", "2", "3"} {
+			utils.AssertEqual(t, nil, Revoke(Revocation{User: user}, 0))
+		}
+		exported, _ := ExportRevocations()
+
+		storage := &scriptStorage{testStorage: newTestStorage()}
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			Revocations:       true,
+		})
+		utils.AssertEqual(t, nil, ImportRevocations(exported))
+		utils.AssertEqual(t, 1, storage.evals)
+
+		reexported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(reexported, &set)
+		utils.AssertEqual(t, 3, len(set.Revocations))
+	})
+
+	t.Run("it should drop revocations expired from Storage from the log", func(t *testing.T) {
+		storage := newTestStorage()
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           storage,
+			Revocations:       true,
+		})
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "42"}, time.Minute))
+		utils.AssertEqual(t, nil, Revoke(Revocation{User: "7"}, 0))
+
+		// Storage expires the record with the revocation
+		storage.Delete(current().storageKey(StorageKindRevoked, revocationRecordPrefix+revocationRecord{User: "42"}.id()))
+
+		exported, _ := ExportRevocations()
+		var set revocationSet
+		json.Unmarshal(exported, &set)
+		utils.AssertEqual(t, []revocationRecord{{User: "7"}}, set.Revocations)
+
+		ids, _ := current().readRevocationLog(storage)
+		utils.AssertEqual(t, []string{revocationRecord{User: "7"}.id()}, ids)
+	})
+
+	t.Run("it should reject invalid exports", func(t *testing.T) {
+		newRegion()
+
+		utils.AssertEqual(t, "cannot parse exported revocations", ImportRevocations([]byte("nope")).Error())
+		utils.AssertEqual(t, "revocation must select signed URLs", ImportRevocations([]byte(`{"revocations":[{}]}`)).Error())
+	})
+}
@@ -0,0 +1,181 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// weekdays maps day names in schedules to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// scheduleWindow is a window of time recurring on some days of the week, in
+// minutes after midnight. Windows ending at or before their start run past
+// midnight into the next day.
+type scheduleWindow struct {
+	days     [7]bool
+	from, to int
+}
+
+// schedule is a set of recurring windows in a location
+type schedule struct {
+	location *time.Location
+	windows  []scheduleWindow
+}
+
+// Schedule formats a schedule query param value for URLs valid only within
+// windows recurring every week in loc, eg. Schedule(berlin, "Mon-Fri
+// 09:00-17:00", "Sat 10:00-14:00"). Windows are days (a day name, a range of
+// them, a comma separated list, or "*" for every day) followed by a time
+// range. Ranges ending at or before their start run past midnight. loc must be
+// a named location, as it is loaded again when URLs are verified.
+func Schedule(loc *time.Location, windows ...string) (string, error) {
+	value := strings.Join(append([]string{loc.String()}, windows...), ";")
+	if _, err := parseSchedule(value); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// parseSchedule parses a value formatted by Schedule
+func parseSchedule(value string) (schedule, error) {
+	parts := strings.Split(value, ";")
+	if len(parts) < 2 {
+		return schedule{}, errors.New("schedule must have at least one window")
+	}
+
+	location, err := time.LoadLocation(parts[0])
+	if err != nil || parts[0] == "Local" {
+		return schedule{}, fmt.Errorf("unknown schedule location %q", parts[0])
+	}
+
+	s := schedule{location: location}
+	for _, part := range parts[1:] {
+		window, err := parseScheduleWindow(part)
+		if err != nil {
+			return schedule{}, err
+		}
+		s.windows = append(s.windows, window)
+	}
+
+	return s, nil
+}
+
+// parseScheduleWindow parses a window of a schedule, eg. "Mon-Fri 09:00-17:00"
+func parseScheduleWindow(value string) (scheduleWindow, error) {
+	var w scheduleWindow
+	invalid := fmt.Errorf("invalid schedule window %q", value)
+
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return w, invalid
+	}
+
+	if fields[0] == "*" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else {
+		for _, days := range strings.Split(fields[0], ",") {
+			bounds := strings.SplitN(days, "-", 2)
+			first, ok := weekdays[strings.ToLower(bounds[0])]
+			if !ok {
+				return w, invalid
+			}
+			last := first
+			if len(bounds) == 2 {
+				if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+					return w, invalid
+				}
+			}
+			// Ranges may wrap around the week, eg. Fri-Mon
+			for day := first; ; day = (day + 1) % 7 {
+				w.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+	}
+
+	times := strings.SplitN(fields[1], "-", 2)
+	if len(times) != 2 {
+		return w, invalid
+	}
+	var err error
+	if w.from, err = parseClock(times[0]); err != nil {
+		return w, invalid
+	}
+	if w.to, err = parseClock(times[1]); err != nil {
+		return w, invalid
+	}
+
+	return w, nil
+}
+
+// parseClock parses a time of day formatted HH:MM into minutes after
+// midnight, allowing 24:00 for the end of a day
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		if value == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls within any window of the schedule
+func (s schedule) contains(t time.Time) bool {
+	t = t.In(s.location)
+	day, minute := t.Weekday(), t.Hour()*60+t.Minute()
+	yesterday := (day + 6) % 7
+
+	for _, w := range s.windows {
+		if w.from < w.to {
+			if w.days[day] && minute >= w.from && minute < w.to {
+				return true
+			}
+			continue
+		}
+
+		// Windows past midnight start on their days and end the day after
+		if (w.days[day] && minute >= w.from) || (w.days[yesterday] && minute < w.to) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkSchedule rejects requests outside the schedule signed into their URL
+func (cfg *instance) checkSchedule(c *fiber.Ctx) error {
+	value := c.Query(cfg.ScheduleQueryKey)
+	if value == "" {
+		return nil
+	}
+
+	s, err := parseSchedule(value)
+	if err != nil {
+		return fmt.Errorf("%s value must be a valid schedule", cfg.ScheduleQueryKey)
+	}
+	if !s.contains(timeNow()) {
+		return errors.New("url signature is not valid at this time")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSchedule(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(windows ...string) string {
+		schedule, err := Schedule(time.UTC, windows...)
+		utils.AssertEqual(t, nil, err)
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?schedule="+url.QueryEscape(schedule)))
+		return signedURL
+	}
+
+	// at requests signedURL at t
+	at := func(signedURL string, t time.Time) *http.Response {
+		restore := InjectFaults(Faults{ClockSkew: time.Until(t)})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		return resp
+	}
+
+	// Monday 2024-01-01
+	monday := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("it should accept requests within the schedule", func(t *testing.T) {
+		signedURL := sign("Mon-Fri 09:00-17:00")
+
+		resp := at(signedURL, monday.Add(10*time.Hour))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = at(signedURL, monday.Add(4*24*time.Hour+16*time.Hour+59*time.Minute))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject requests outside the schedule", func(t *testing.T) {
+		signedURL := sign("Mon-Fri 09:00-17:00")
+
+		resp := at(signedURL, monday.Add(17*time.Hour))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature is not valid at this time", string(body))
+
+		resp = at(signedURL, monday.Add(5*24*time.Hour+10*time.Hour))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should run windows past midnight into the next day", func(t *testing.T) {
+		signedURL := sign("Fri 22:00-02:00")
+
+		resp := at(signedURL, monday.Add(4*24*time.Hour+23*time.Hour))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = at(signedURL, monday.Add(5*24*time.Hour+time.Hour))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = at(signedURL, monday.Add(time.Hour))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should accept any of several windows", func(t *testing.T) {
+		signedURL := sign("Sat,Sun 10:00-14:00", "* 20:00-21:00")
+
+		resp := at(signedURL, monday.Add(-time.Hour*12))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = at(signedURL, monday.Add(20*time.Hour+30*time.Minute))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should enforce windows in the schedule location", func(t *testing.T) {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		utils.AssertEqual(t, nil, err)
+		schedule, _ := Schedule(tokyo, "Mon 09:00-10:00")
+		signedURL, _ := GetSignedURLFromHTTPRequest(newTestRequest(http.MethodGet, "http://example.com/?schedule="+url.QueryEscape(schedule)))
+
+		// 09:30 in Tokyo is 00:30 UTC
+		resp := at(signedURL, monday.Add(30*time.Minute))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = at(signedURL, monday.Add(9*time.Hour+30*time.Minute))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should refuse invalid schedules", func(t *testing.T) {
+		_, err := Schedule(time.UTC, "Mon-Fri 9-17")
+		utils.AssertEqual(t, `invalid schedule window "Mon-Fri 9-17"`, err.Error())
+
+		_, err = Schedule(time.UTC)
+		utils.AssertEqual(t, "schedule must have at least one window", err.Error())
+
+		_, err = Schedule(time.Local, "* 09:00-17:00")
+		utils.AssertEqual(t, `unknown schedule location "Local"`, err.Error())
+	})
+}
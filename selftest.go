@@ -0,0 +1,121 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// selfTestURL is the synthetic URL SelfTest signs and verifies
+const selfTestURL = "https://self-test.fiber-signed.invalid/self-test"
+
+// minClockTime is the earliest time the clock may read, as clocks of
+// machines which haven't synced yet often start at the epoch
+var minClockTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Checks run by SelfTest, as reported in SelfTestError
+const (
+	SelfTestClock   = "clock"
+	SelfTestKey     = "key"
+	SelfTestStorage = "storage"
+	SelfTestSign    = "sign"
+	SelfTestVerify  = "verify"
+	SelfTestExpiry  = "expiry"
+)
+
+// SelfTestError is the failure of a SelfTest check
+type SelfTestError struct {
+	// Check is the check which failed, eg. SelfTestKey
+	Check string
+	// Err is what went wrong
+	Err error
+}
+
+func (e *SelfTestError) Error() string {
+	return fmt.Sprintf("self-test %s check failed: %v", e.Check, e.Err)
+}
+
+func (e *SelfTestError) Unwrap() error {
+	return e.Err
+}
+
+// SelfTest signs and verifies a synthetic request against the current config,
+// checking the clock is sane, the private key can be fetched, Storage and the
+// ReplayCache round-trip (see Healthy), and that URLs are accepted until they
+// expire and rejected after. It returns a *SelfTestError naming the first
+// check which failed, so readiness probes catch misconfigurations at deploy
+// time rather than on the first request. Only the signature and its expiry
+// are verified, not the stateful checks, which would consume the synthetic URL.
+func SelfTest() error {
+	cfg := current()
+
+	if now := timeNow(); now.Before(minClockTime) {
+		return &SelfTestError{Check: SelfTestClock, Err: fmt.Errorf("clock reads %s", now.UTC().Format(time.RFC3339))}
+	}
+
+	if _, err := cfg.getPrivateKey(context.Background()); err != nil {
+		return &SelfTestError{Check: SelfTestKey, Err: err}
+	}
+
+	if err := Healthy(); err != nil {
+		return &SelfTestError{Check: SelfTestStorage, Err: err}
+	}
+
+	app := fiber.New()
+	app.Get("/*", func(c *fiber.Ctx) error {
+		if _, err := cfg.validateRequest(c); err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	// verify signs the synthetic URL expiring at expires and verifies it,
+	// returning why it was rejected, or "" if it was accepted
+	verify := func(expires time.Time) (string, error) {
+		r, _ := http.NewRequest(http.MethodGet, selfTestURL+"?"+cfg.ExpiresQueryKey+"="+strconv.FormatInt(expires.Unix(), 10), nil)
+		signedURL, err := cfg.getSignedURL(r)
+		if err != nil {
+			return "", &SelfTestError{Check: SelfTestSign, Err: err}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, signedURL, nil)
+		req.RequestURI = ""
+		// app.Test serves plain HTTP, so forward the scheme signed for
+		req.Header.Set(fiber.HeaderXForwardedProto, "https")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			return "", &SelfTestError{Check: SelfTestVerify, Err: err}
+		}
+		if resp.StatusCode == fiber.StatusOK {
+			return "", nil
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body), nil
+	}
+
+	rejected, err := verify(timeNow().Add(time.Minute))
+	if err != nil {
+		return err
+	}
+	if rejected != "" {
+		return &SelfTestError{Check: SelfTestVerify, Err: errors.New(rejected)}
+	}
+
+	rejected, err = verify(timeNow().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if rejected == "" {
+		return &SelfTestError{Check: SelfTestExpiry, Err: errors.New("expired URL was accepted")}
+	}
+
+	return nil
+}
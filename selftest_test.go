@@ -0,0 +1,51 @@
+package signed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSelfTest(t *testing.T) {
+	// Initalize config
+	New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		RequireHTTPS:      true,
+	})
+
+	t.Run("it should pass with a working config", func(t *testing.T) {
+		utils.AssertEqual(t, nil, SelfTest())
+	})
+
+	t.Run("it should name the check which failed", func(t *testing.T) {
+		for check, faults := range map[string]Faults{
+			SelfTestKey:     {KeyProviderErr: errors.New("vault unavailable")},
+			SelfTestStorage: {StorageErr: errors.New("connection refused")},
+			SelfTestClock:   {ClockSkew: -time.Since(time.Unix(0, 0))},
+		} {
+			restore := InjectFaults(faults)
+			err := SelfTest()
+			restore()
+
+			var selfTestErr *SelfTestError
+			utils.AssertEqual(t, true, errors.As(err, &selfTestErr), check)
+			utils.AssertEqual(t, check, selfTestErr.Check)
+		}
+	})
+
+	t.Run("it should report why the synthetic request was rejected", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxAge:            time.Hour,
+		})
+
+		err := SelfTest()
+
+		var selfTestErr *SelfTestError
+		utils.AssertEqual(t, true, errors.As(err, &selfTestErr))
+		utils.AssertEqual(t, SelfTestVerify, selfTestErr.Check)
+	})
+}
@@ -0,0 +1,107 @@
+package signed
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shortTokenBytes is the number of random bytes in short URL tokens. Tokens
+// are bearer credentials for the signed URLs they stand for, so they must not
+// be guessable.
+const shortTokenBytes = 16
+
+// errShortURLUnavailable is returned when Storage fails resolving a short URL
+var errShortURLUnavailable = fiber.NewError(fiber.StatusServiceUnavailable, "short url cannot be resolved")
+
+// GetShortSignedURLFromHTTPRequest takes an instance of *http.Request, signs
+// it and stores the signed URL in Storage under a short random token,
+// returning a short URL (eg. https://example.com/r/q3Xz9aBcT0kLm2PwRv7Yhg)
+// which the middleware resolves and validates server-side
+func GetShortSignedURLFromHTTPRequest(r *http.Request) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	if !cfg.ShortURLs {
+		return "", errors.New("short urls are not enabled")
+	}
+	if cfg.Storage == nil {
+		return "", errors.New("short urls cannot be generated without Storage")
+	}
+
+	// Short URLs resolving to short URLs would never reach a handler
+	if strings.HasPrefix(r.URL.Path, cfg.ShortURLPrefix) {
+		return "", fmt.Errorf("cannot shorten urls under %s", cfg.ShortURLPrefix)
+	}
+
+	// Only the short URL is handed out, so MaxURLLength doesn't apply
+	if _, err := cfg.signURL(r); err != nil {
+		return "", err
+	}
+
+	// Keep the short URL until the signed URL expires, or for ShortURLTTL
+	ttl := cfg.ShortURLTTL
+	if i, err := strconv.ParseInt(urlQuery(r.URL).Get(cfg.ExpiresQueryKey), 10, 64); err == nil {
+		if ttl = time.Until(time.Unix(i, 0)); ttl <= 0 {
+			return "", errors.New("url signature has expired")
+		}
+	}
+
+	storage, ttl := cfg.getStorage(r.Context()), cfg.storageTTL(StorageKindShort, ttl)
+	b := make([]byte, shortTokenBytes)
+	for attempt := 0; attempt < 3; attempt++ {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		token := base64.RawURLEncoding.EncodeToString(b)
+
+		// Never overwrite another short URL
+		if existing, err := storage.Get(cfg.storageKey(StorageKindShort, token)); err != nil {
+			return "", err
+		} else if len(existing) > 0 {
+			continue
+		}
+
+		if err := storage.Set(cfg.storageKey(StorageKindShort, token), []byte(r.URL.RequestURI()), ttl); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%s://%s%s%s", r.URL.Scheme, r.Host, cfg.ShortURLPrefix, token), nil
+	}
+
+	return "", errors.New("cannot generate a unique short url token")
+}
+
+// resolveShortURL returns the request URI stored for the short URL of the
+// request
+func (cfg *instance) resolveShortURL(c *fiber.Ctx) ([]byte, error) {
+	token := strings.TrimPrefix(c.Path(), cfg.ShortURLPrefix)
+
+	target, err := cfg.getStorage(requestContext(c)).Get(cfg.storageKey(StorageKindShort, token))
+	if err != nil {
+		log.Printf("fiber-signed: cannot resolve short url: %v", err)
+		return nil, errShortURLUnavailable
+	}
+	if len(target) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, "short url not found")
+	}
+
+	return target, nil
+}
+
+// redispatch serves target through the whole stack in place of the request.
+// Fiber can't restart routing of a request, so middleware registered before
+// this one runs again, seeing target, after having seen the short URL.
+func redispatch(c *fiber.Ctx, target []byte) error {
+	c.Request().SetRequestURIBytes(target)
+	c.App().Handler()(c.Context())
+
+	return nil
+}
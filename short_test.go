@@ -0,0 +1,134 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestShortURLs(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	var ttls []time.Duration
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           newTestStorage(),
+		ShortURLs:         true,
+		StorageTTL: func(kind string, ttl time.Duration) time.Duration {
+			if kind == StorageKindShort {
+				ttls = append(ttls, ttl)
+			}
+			return ttl
+		},
+	}))
+
+	app.Get("/downloads/:file", func(c *fiber.Ctx) error {
+		return c.SendString("Downloading " + c.Params("file") + " " + c.Query("a"))
+	})
+
+	app.Get("/r/*", func(c *fiber.Ctx) error {
+		return c.SendString("App route")
+	})
+
+	shorten := func(target string) string {
+		shortURL, err := GetShortSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		return shortURL
+	}
+
+	t.Run("it should serve the signed url behind a short url", func(t *testing.T) {
+		shortURL := shorten(fmt.Sprintf("http://example.com/downloads/report.pdf?a=1&expires=%d", time.Now().Add(time.Hour).Unix()))
+		parsed, _ := url.Parse(shortURL)
+
+		utils.AssertEqual(t, true, strings.HasPrefix(shortURL, "http://example.com/r/"))
+		utils.AssertEqual(t, 25, len(parsed.Path))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, shortURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Downloading report.pdf 1", string(body))
+	})
+
+	t.Run("it should validate the signed url behind a short url", func(t *testing.T) {
+		shortURL := shorten("http://example.com/downloads/report.pdf?nonce=short")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, shortURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, shortURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has already been used", string(body))
+	})
+
+	t.Run("it should respond 404 for unknown short urls", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "/r/unknown"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusNotFound, resp.StatusCode)
+		utils.AssertEqual(t, "short url not found", string(body))
+	})
+
+	t.Run("it should not expose Storage failures", func(t *testing.T) {
+		shortURL := shorten("http://example.com/downloads/report.pdf?nonce=outage")
+
+		restore := InjectFaults(Faults{StorageErr: errors.New("dial tcp 10.0.0.7:6379: connection refused")})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, shortURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+		utils.AssertEqual(t, "short url cannot be resolved", string(body))
+	})
+
+	t.Run("it should keep short urls without an expiry for ShortURLTTL", func(t *testing.T) {
+		ttls = nil
+		shorten("http://example.com/downloads/report.pdf?nonce=forever")
+
+		utils.AssertEqual(t, []time.Duration{30 * 24 * time.Hour}, ttls)
+	})
+
+	t.Run("it should not shorten short urls", func(t *testing.T) {
+		_, err := GetShortSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/r/abc", nil))
+
+		utils.AssertEqual(t, "cannot shorten urls under /r/", err.Error())
+	})
+
+	t.Run("it should leave the prefix to app routes unless enabled", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+		})
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/r/page", nil))
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "App route", string(body))
+
+		_, err := GetShortSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		utils.AssertEqual(t, "short urls are not enabled", err.Error())
+	})
+
+	t.Run("it should require Storage", func(t *testing.T) {
+		New(Config{GetPrivateKeyFunc: func() string { return "secret" }, ShortURLs: true})
+
+		_, err := GetShortSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		utils.AssertEqual(t, "short urls cannot be generated without Storage", err.Error())
+	})
+}
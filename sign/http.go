@@ -0,0 +1,27 @@
+//go:build !tinygo
+// +build !tinygo
+
+package sign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// SignRequest returns the full URL of r with calculated signature, expiring
+// at expires if not zero, like the middleware's GetSignedURLFromHTTPRequest.
+// The body of r is read and replaced so the request can still be sent.
+func (s *Signer) SignRequest(r *http.Request, expires time.Time) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return "", err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	return s.sign(r.Method, r.URL, r.Host, r.Header.Get, body, len(body) > 0, expires)
+}
@@ -0,0 +1,277 @@
+// Package sign generates fiber-signed URL signatures without depending on
+// fiber or on the verifying code, for client tools and services which hand
+// out signed URLs but never serve them.
+//
+// It only generates plain signatures with an optional expiry. URLs using
+// features which need the middleware's state (nonces, policies, tokens, ...)
+// must be signed with the middleware package.
+//
+// The signing core only depends on net/url and the crypto packages, so it
+// compiles with TinyGo and for WebAssembly, eg. for browser or edge worker
+// components. SignRequest, which takes a *http.Request, is left out of TinyGo
+// builds (the tinygo build tag).
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bsandusky/fiber-signed/internal/canon"
+)
+
+// Hash function algorithmic option values, matching signed.Algorithm
+const (
+	AlgorithmSHA1   = canon.AlgorithmSHA1
+	AlgorithmSHA256 = canon.AlgorithmSHA256
+	AlgorithmMD5    = canon.AlgorithmMD5
+
+	AlgorithmHMACSHA256 = canon.AlgorithmHMACSHA256
+)
+
+// GET and HEAD body policy values, matching signed.BodyPolicy
+const (
+	BodyHash = iota
+	BodyIgnore
+	BodyReject
+)
+
+// Config defines the config for Signer, matching the middleware's config
+type Config struct {
+	// Algorithm defines the hash function used to create signatures.
+	//
+	// Optional. Default: AlgorithmSHA1
+	Algorithm string
+
+	// GetPrivateKeyFunc defines a function to retrieve the private key.
+	//
+	// Required.
+	GetPrivateKeyFunc func() string
+
+	// GetPepperFunc defines a function to obtain the pepper mixed into the
+	// private key like the middleware's GetPepperFunc.
+	//
+	// Optional. Default: nil
+	GetPepperFunc func() string
+
+	// SignatureBits truncates signatures like the middleware's SignatureBits.
+	//
+	// Optional. Default: 0
+	SignatureBits int
+
+	// EmbedAlgorithm adds the identifier of Algorithm to signed URLs like the
+	// middleware's EmbedAlgorithm.
+	//
+	// Optional. Default: false
+	EmbedAlgorithm bool
+
+	// PreserveValueOrder signs repeated query params in the order they
+	// appear, matching the middleware's MultiValuePreserve.
+	//
+	// Optional. Default: false
+	PreserveValueOrder bool
+
+	// QueryEncoderFunc builds the query string hashed into signatures like
+	// the middleware's QueryEncoderFunc.
+	//
+	// Optional. Default: nil
+	QueryEncoderFunc func(q url.Values) string
+
+	// GetHeadBodyPolicy defines how bodies of GET and HEAD requests are
+	// signed like the middleware's GetHeadBodyPolicy.
+	//
+	// Optional. Default: BodyHash
+	GetHeadBodyPolicy int
+
+	// ContentDigest signs the Content-Digest (or Repr-Digest) header of
+	// requests in place of their body like the middleware's ContentDigest.
+	//
+	// Optional. Default: false
+	ContentDigest bool
+
+	// ForceScheme replaces the scheme of URLs like the middleware's
+	// ForceScheme.
+	//
+	// Optional. Default: ""
+	ForceScheme string
+
+	// QueryKeyPrefix namespaces the default query keys like the
+	// middleware's QueryKeyPrefix.
+	//
+	// Optional. Default: ""
+	QueryKeyPrefix string
+
+	// Optional. Default: "signature"
+	SignatureQueryKey string
+
+	// Optional. Default: "privateKey"
+	PrivateKeyQueryKey string
+
+	// Optional. Default: "expires"
+	ExpiresQueryKey string
+
+	// Optional. Default: "bodyHash"
+	BodyHashQueryKey string
+
+	// Optional. Default: "alg"
+	AlgorithmQueryKey string
+}
+
+// Signer generates signed URLs
+type Signer struct {
+	config Config
+}
+
+// New creates a Signer
+func New(config Config) *Signer {
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmSHA1
+	}
+	if config.SignatureBits > 0 && config.SignatureBits < 64 {
+		config.SignatureBits = 64
+	}
+	if config.SignatureQueryKey == "" {
+		config.SignatureQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "signature")
+	}
+	if config.PrivateKeyQueryKey == "" {
+		config.PrivateKeyQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "privateKey")
+	}
+	if config.ExpiresQueryKey == "" {
+		config.ExpiresQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "expires")
+	}
+	if config.BodyHashQueryKey == "" {
+		config.BodyHashQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "bodyHash")
+	}
+
+	if config.AlgorithmQueryKey == "" {
+		config.AlgorithmQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "alg")
+	}
+
+	return &Signer{config: config}
+}
+
+// SignURL returns rawURL signed for method requests with body, expiring at
+// expires if not zero
+func (s *Signer) SignURL(method, rawURL string, body []byte, expires time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return s.sign(method, u, u.Host, noHeader, body, true, expires)
+}
+
+// SignDigest returns rawURL signed for method requests carrying the
+// Content-Digest header contentDigest, eg. sha-256=:<base64>:, expiring at
+// expires if not zero, so uploads can be signed from their digest alone.
+// ContentDigest must be set.
+func (s *Signer) SignDigest(method, rawURL, contentDigest string, expires time.Time) (string, error) {
+	if !s.config.ContentDigest {
+		return "", errors.New("ContentDigest must be set to sign digests")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	header := func(key string) string {
+		if key == "Content-Digest" {
+			return contentDigest
+		}
+		return ""
+	}
+
+	return s.sign(method, u, u.Host, header, nil, false, expires)
+}
+
+// noHeader is the header lookup of requests without headers
+func noHeader(string) string {
+	return ""
+}
+
+// sign returns u signed for method requests to host with header and body,
+// expiring at expires if not zero. u is updated with the signature params.
+// Bodies are only checked against digest headers if checkDigest is set.
+func (s *Signer) sign(method string, u *url.URL, host string, header func(string) string, body []byte, checkDigest bool, expires time.Time) (string, error) {
+	if !s.config.ContentDigest || !canon.HasDigest(header) {
+		var err error
+		if body, err = canon.DecodeBody(header("Content-Encoding"), body, 0); err != nil {
+			return "", err
+		}
+	}
+	if len(body) > 0 && (method == "GET" || method == "HEAD") {
+		switch s.config.GetHeadBodyPolicy {
+		case BodyIgnore:
+			body = nil
+		case BodyReject:
+			return "", fmt.Errorf("%s requests must not have a body", method)
+		}
+	}
+	if s.config.ContentDigest {
+		var err error
+		if body, err = canon.DigestBody(header, body, checkDigest); err != nil {
+			return "", err
+		}
+	}
+
+	// Throw error if reserved query params are used in signature request
+	q, err := canon.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+	var reserved []string
+	for _, key := range []string{s.config.SignatureQueryKey, s.config.PrivateKeyQueryKey, s.config.BodyHashQueryKey} {
+		if _, ok := q[key]; ok {
+			reserved = append(reserved, key)
+		}
+	}
+	if len(reserved) == 1 {
+		return "", fmt.Errorf("%s is a reserved query parameter when generating signed routes", reserved[0])
+	} else if len(reserved) > 1 {
+		return "", fmt.Errorf("%s are reserved query parameters when generating signed routes", strings.Join(reserved, ", "))
+	}
+
+	if !expires.IsZero() {
+		q.Set(s.config.ExpiresQueryKey, strconv.FormatInt(expires.Unix(), 10))
+		u.RawQuery = q.Encode()
+	}
+	if s.config.EmbedAlgorithm {
+		q.Set(s.config.AlgorithmQueryKey, canon.AlgorithmIDs[s.config.Algorithm])
+		u.RawQuery = q.Encode()
+	}
+
+	privateKey := s.config.GetPrivateKeyFunc()
+	if s.config.GetPepperFunc != nil {
+		pepper := s.config.GetPepperFunc()
+		if pepper == "" {
+			return "", errors.New("pepper must not be empty")
+		}
+		privateKey = canon.PepperKey(privateKey, pepper)
+	}
+
+	params := canon.Params{
+		Algorithm:          s.config.Algorithm,
+		PrivateKey:         privateKey,
+		SignatureBits:      s.config.SignatureBits,
+		PreserveValueOrder: s.config.PreserveValueOrder,
+		QueryEncoder:       s.config.QueryEncoderFunc,
+		ForceScheme:        s.config.ForceScheme,
+		SignatureQueryKey:  s.config.SignatureQueryKey,
+		PrivateKeyQueryKey: s.config.PrivateKeyQueryKey,
+		BodyHashQueryKey:   s.config.BodyHashQueryKey,
+	}
+	canonical, err := params.String(method, u.Scheme, host, fmt.Sprintf("%s?%s", u.Path, u.RawQuery), body)
+	if err != nil {
+		return "", err
+	}
+
+	// Append signature to query params
+	q.Add(s.config.SignatureQueryKey, params.Sign(canonical))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,162 @@
+//go:build !tinygo
+// +build !tinygo
+
+package sign
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	signed "github.com/bsandusky/fiber-signed"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSign(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         signed.AlgorithmSHA256,
+	}))
+
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	s := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmSHA256,
+	})
+
+	newRequest := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RequestURI = ""
+		return req
+	}
+
+	t.Run("it should sign urls the middleware accepts", func(t *testing.T) {
+		for _, target := range []string{
+			"http://example.com",
+			"http://example.com/files/a%20b.txt?tag=b&tag=a&empty=",
+			"http://example.com/?name=Zo%C3%AB&q=a+b",
+		} {
+			signedURL, err := s.SignURL(http.MethodGet, target, nil, time.Now().Add(time.Hour))
+			utils.AssertEqual(t, nil, err, target)
+
+			resp, _ := app.Test(newRequest(http.MethodGet, signedURL, ""))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode, target)
+		}
+	})
+
+	t.Run("it should sign bodies and leave them readable", func(t *testing.T) {
+		r := newRequest(http.MethodPost, "http://example.com/submit", "body")
+		signedURL, err := s.SignRequest(r, time.Time{})
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newRequest(http.MethodPost, signedURL, "body"))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		body := make([]byte, 4)
+		n, _ := r.Body.Read(body)
+		utils.AssertEqual(t, "body", string(body[:n]))
+	})
+
+	t.Run("it should sign expiries the middleware enforces", func(t *testing.T) {
+		signedURL, _ := s.SignURL(http.MethodGet, "http://example.com/", nil, time.Now().Add(-time.Hour))
+
+		resp, _ := app.Test(newRequest(http.MethodGet, signedURL, ""))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should refuse to sign reserved query params", func(t *testing.T) {
+		_, err := s.SignURL(http.MethodGet, "http://example.com/?signature=x&privateKey=y", nil, time.Time{})
+
+		utils.AssertEqual(t, "signature, privateKey are reserved query parameters when generating signed routes", err.Error())
+	})
+}
+
+func TestSignContentDigest(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ContentDigest:     true,
+	}))
+
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sum := sha256.Sum256([]byte("large upload"))
+	digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	t.Run("it should sign digests without the body", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, "http://example.com/upload", nil)
+		r.Header.Set("Content-Digest", digest)
+		signedURL, err := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			ContentDigest:     true,
+		}).SignRequest(r, time.Time{})
+		utils.AssertEqual(t, nil, err)
+
+		req := httptest.NewRequest(http.MethodPost, signedURL, strings.NewReader("large upload"))
+		req.RequestURI = ""
+		req.Header.Set("Content-Digest", digest)
+		resp, _ := app.Test(req)
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should sign digests given directly", func(t *testing.T) {
+		signedURL, err := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			ContentDigest:     true,
+		}).SignDigest(http.MethodPost, "http://example.com/upload", digest, time.Time{})
+		utils.AssertEqual(t, nil, err)
+
+		req := httptest.NewRequest(http.MethodPost, signedURL, strings.NewReader("large upload"))
+		req.RequestURI = ""
+		req.Header.Set("Content-Digest", digest)
+		resp, _ := app.Test(req)
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestSignMiddlewareOptions(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		GetPepperFunc:     func() string { return "pepper" },
+		Algorithm:         signed.AlgorithmSHA256,
+		EmbedAlgorithm:    true,
+		AllowedAlgorithms: []signed.Algorithm{signed.AlgorithmSHA256, signed.AlgorithmHMACSHA256},
+	}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should sign urls with a pepper and an embedded algorithm the middleware accepts", func(t *testing.T) {
+		s := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			GetPepperFunc:     func() string { return "pepper" },
+			Algorithm:         AlgorithmHMACSHA256,
+			EmbedAlgorithm:    true,
+		})
+
+		signedURL, err := s.SignURL(http.MethodGet, "http://example.com/", nil, time.Time{})
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.Contains(signedURL, "alg=hs256"))
+
+		req := httptest.NewRequest(http.MethodGet, signedURL, nil)
+		req.RequestURI = ""
+		resp, _ := app.Test(req)
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,32 @@
+package sign
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// TestTinyGoImports keeps the signing core buildable with TinyGo and for
+// WebAssembly, checking the packages it is made of don't import anything
+// those targets lack, without needing TinyGo installed
+func TestTinyGoImports(t *testing.T) {
+	ctx := build.Default
+	ctx.BuildTags = append(ctx.BuildTags, "tinygo")
+
+	forbidden := []string{"net/http", "os/exec", "github.com/gofiber/", "github.com/valyala/"}
+
+	for _, dir := range []string{".", "../internal/canon", "../hostname"} {
+		t.Run("it should not import net/http, fiber or fasthttp in "+dir, func(t *testing.T) {
+			pkg, err := ctx.ImportDir(dir, 0)
+			utils.AssertEqual(t, nil, err)
+
+			for _, imported := range pkg.Imports {
+				for _, prefix := range forbidden {
+					utils.AssertEqual(t, false, strings.HasPrefix(imported, prefix), imported)
+				}
+			}
+		})
+	}
+}
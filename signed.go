@@ -1,44 +1,324 @@
 package signed
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-var cfg Config
+// checks are run in order on requests which pass validateRequest
+var checks = []func(cfg *instance, c *fiber.Ctx) error{
+	// Reject revoked URLs
+	(*instance).checkRevoked,
+	(*instance).checkIssuedCutoff,
+	// Enforce the lifetime governed by the purpose of the URL
+	(*instance).checkTTLPolicy,
+	// Flag URLs due for renewal
+	(*instance).checkSoftExpiry,
+	// Restrict when and where the URL may be used from
+	(*instance).checkSchedule,
+	(*instance).checkSource,
+	(*instance).checkOrigin,
+	(*instance).checkClientCertificate,
+	// Reject reuse of single-use signed URLs
+	(*instance).checkNonce,
+	// Count the request against the uses signed into the URL
+	(*instance).consumeUse,
+	// Throttle requests per signed URL
+	(*instance).enforceRateLimit,
+	// Serve only the content version the URL was issued for
+	(*instance).checkETag,
+}
 
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
-	// Set default config
-	cfg = configDefault(config...)
+	// Set default config, dropping keys fetched or tracked for any previous
+	// config
+	swap(newInstance(configDefault(config...)), nil).keys.reset()
+
+	// Tell this handler apart from those of other calls
+	generation := nextGeneration()
 
 	// Return new handler
-	return func(c *fiber.Ctx) error {
+	return register(func(c *fiber.Ctx) error {
+		// Verify with one config throughout, as UpdateConfig may swap it
+		cfg := current()
+
 		// Don't execute middleware if Next returns true
 		if cfg.Next != nil && cfg.Next(c) {
 			return c.Next()
 		}
 
-		// validate request before continuing to next handler
-		ok, err := validateRequest(c)
+		// Verify requests passing through this handler again only once, and
+		// report those verified by the handler of another call
+		if verified, err := cfg.checkDuplicate(c, generation); verified || err != nil {
+			if err != nil {
+				return err
+			}
+			return c.Next()
+		}
+
+		return cfg.serve(c, generation)
+	})
+}
+
+// serve verifies the request with cfg and continues the stack, marking it
+// verified by the handler of generation unless it is 0
+func (cfg *instance) serve(c *fiber.Ctx, generation uint64) error {
+	// Package functions called by handlers use the same config
+	c.Locals(instanceLocal, cfg)
+
+	// Serve short URLs as the signed URLs they stand for
+	if cfg.ShortURLs && cfg.Storage != nil && strings.HasPrefix(c.Path(), cfg.ShortURLPrefix) {
+		target, err := cfg.resolveShortURL(c)
+		if err != nil {
+			return err
+		}
+		return redispatch(c, target)
+	}
+
+	l, err := cfg.verifyRequest(c)
+	if err != nil {
+		return rejection(err)
+	}
+	if generation != 0 {
+		c.Locals(verifiedLocal, generation)
+	}
+
+	err = serveVerified(c, cfg.getServing(c, l))
+	cfg.analytics.record(cfg, c)
+
+	return err
+}
+
+// verifyRequest runs every check of the current config against the request,
+// returning the error to respond with if any fails. The returned lease, if
+// any, must be ended once the request is done.
+func (cfg *instance) verifyRequest(c *fiber.Ctx) (*lease, error) {
+	// Refuse requests whose body boundary is ambiguous before trusting it
+	err := cfg.checkFraming(c)
+
+	// Fail closed if the private key cannot be loaded
+	if err == nil {
+		var privateKey string
+		if privateKey, err = cfg.getPrivateKey(requestContext(c)); err != nil {
+			err = fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		} else {
+			// Nudge operators towards rotating long-lived keys
+			if cfg.MaxKeyAge > 0 {
+				cfg.keyAges.check(cfg, requestContext(c), privateKey)
+			}
+			events.observeKey(privateKey)
+		}
+	}
+
+	// Read signature params from wherever Transport carries them
+	if err == nil {
+		defer restoreSignedPath(c)
+		err = cfg.extractTransportParams(c)
+	}
+
+	// validate request before continuing to next handler
+	ok := err == nil
+	if ok {
+		ok, err = cfg.validateRequest(c)
+	}
+
+	// Run conditional and stateful checks once the URL is known to be valid
+	for _, check := range checks {
 		if !ok {
-			return fiber.NewError(fiber.StatusForbidden, err.Error())
+			break
+		}
+		if err = cfg.handleStoreFailure(check(cfg, c)); err != nil {
+			ok = false
 		}
+	}
+
+	// Limit simultaneous use of the signed URL
+	var l *lease
+	if ok {
+		l, err = cfg.acquireLease(c)
+		if err = cfg.handleStoreFailure(err); err != nil {
+			ok = false
+		}
+	}
+
+	// Tell the app about the first use of the URL
+	if ok {
+		cfg.setRequestID(c)
+		cfg.notifyFirstUse(c)
+	}
+
+	if events.hasSubscribers() {
+		e := Event{Type: EventVerified, URL: c.BaseURL() + c.OriginalURL(), Context: requestContext(c)}
+		if !ok {
+			e.Type, e.Err = EventRejected, err
+		}
+		cfg.labelRequestEvent(c, &e)
+		events.emit(e)
+	}
+
+	if !ok {
+		cfg.abuse.record(cfg, err)
+
+		// Help client integrators find canonicalization mismatches
+		if cfg.Debug {
+			cfg.setDebugHeaders(c)
+		}
+
+		// Some checks choose their own status code
+		status, message := fiber.StatusForbidden, err.Error()
+		if e, isFiberError := err.(*fiber.Error); isFiberError {
+			status, message = e.Code, e.Message
+		}
+		message = cfg.localizeRejection(c, message)
+
+		// Show people opening links in a browser a page instead
+		if cfg.renderRejection(c, status, message) {
+			return nil, errRejectionRendered
+		}
+		return nil, fiber.NewError(status, message)
+	}
+
+	// Describe the signature to client developers
+	if cfg.Diagnostics {
+		cfg.setDiagnosticsHeader(c)
+	}
+
+	return l, nil
+}
+
+// serving holds what serveVerified needs from the config of a verified
+// request
+type serving struct {
+	lease        *lease
+	bytesServed  func(c *fiber.Ctx, signature string, bytes int)
+	signatureID  string
+	issueReceipt func(bytes int) string
+	private      bool
+}
+
+// getServing captures what serveVerified needs for the verified request,
+// holding its concurrent use lease l until it ends
+func (cfg *instance) getServing(c *fiber.Ctx, l *lease) serving {
+	s := serving{
+		lease:        l,
+		bytesServed:  cfg.BytesServed,
+		issueReceipt: cfg.getReceiptIssuer(c),
+		private:      cfg.PrivateCacheControl,
+	}
+	if s.bytesServed != nil {
+		s.signatureID = cfg.getSignatureID(c)
+	}
 
-		// Continue stack
+	return s
+}
+
+// serveVerified continues the stack for a verified request
+func serveVerified(c *fiber.Ctx, s serving) error {
+	// Hold the concurrent use lease until the rest of the stack is done
+	if s.lease != nil {
+		defer s.lease.end(c)
+	}
+
+	// Continue stack
+	if s.bytesServed == nil && s.issueReceipt == nil && !s.private {
 		return c.Next()
 	}
+
+	err := c.Next()
+
+	// Keep shared caches from serving the response to other requests
+	if s.private {
+		c.Set(fiber.HeaderCacheControl, getPrivateCacheControl(string(c.Response().Header.Peek(fiber.HeaderCacheControl))))
+	}
+
+	// Report response size for quota and billing, and receipt it, once
+	// handlers are done
+	if s.bytesServed == nil && s.issueReceipt == nil {
+		return err
+	}
+	size := getResponseSize(c)
+	if s.bytesServed != nil {
+		s.bytesServed(c, s.signatureID, size)
+	}
+	// Only successful responses prove a download
+	if s.issueReceipt != nil && err == nil && c.Response().StatusCode() < fiber.StatusBadRequest {
+		c.Set(ReceiptHeader, s.issueReceipt(size))
+	}
+
+	return err
 }
 
 // External Interface to get Signed URLs. Middleware package must be initialized
 // (i.e. signed.New() must be called) before the following can be called
 
 // GetSignedURLFromHTTPRequest takes an instance of *http.Request and returns
-// full URL with calculated signature
+// full URL with calculated signature. URLs longer than MaxURLLength are
+// signed again with URLLengthFallbacks, or refused with ErrURLTooLong.
 func GetSignedURLFromHTTPRequest(r *http.Request) (string, error) {
+	return instanceFor(r.Context()).getSignedURL(r)
+}
+
+// getSignedURL returns full URL for r with calculated signature, within
+// MaxURLLength
+func (cfg *instance) getSignedURL(r *http.Request) (string, error) {
+	if cfg.MaxURLLength > 0 {
+		return cfg.signURLWithinBudget(r)
+	}
+
+	return cfg.signURL(r)
+}
+
+// signURL returns full URL for r with calculated signature, regardless of
+// MaxURLLength
+func (cfg *instance) signURL(r *http.Request) (string, error) {
+
+	// Delegation grants would change the key used to validate the signature
+	if err := cfg.checkSigningParams(urlQuery(r.URL), cfg.DelegationQueryKey); err != nil {
+		return "", err
+	}
+
+	privateKey, err := cfg.getPrivateKey(r.Context())
+	if err != nil {
+		return "", err
+	}
+	events.observeKey(privateKey)
+
+	return cfg.signHTTPRequest(r, privateKey, "")
+}
+
+// GetSignedURLForHostFromHTTPRequest takes an instance of *http.Request and
+// returns full URL on publicHost with signature calculated for the host of r,
+// so apps can sign against an internal service URL but hand out a CDN-fronted
+// one (or vice versa). Requests must reach the middleware with the host
+// signed, or it must be among the HostAliases of publicHost.
+func GetSignedURLForHostFromHTTPRequest(r *http.Request, publicHost string) (string, error) {
+	if publicHost == "" {
+		return "", errors.New("public host must not be empty")
+	}
+
+	// The signature covers r.Host while the URL is built from r.URL
+	r.URL.Host = publicHost
+
+	return GetSignedURLFromHTTPRequest(r)
+}
+
+// signHTTPRequest returns full URL for r with signature calculated using
+// privateKey and bound to binding if not empty
+func (cfg *instance) signHTTPRequest(r *http.Request, privateKey, binding string) (string, error) {
+	return cfg.signHTTPRequestAs(r, privateKey, binding, "")
+}
+
+// signHTTPRequestAs is signHTTPRequest, adding a co-signature of the key with
+// keyID if not empty. Co-signatures r already carries are kept, and the URL
+// they cover is signed as it is, as changing it would invalidate them.
+func (cfg *instance) signHTTPRequestAs(r *http.Request, privateKey, binding, keyID string) (string, error) {
 
 	baseURL := fmt.Sprintf("%s://%s", r.URL.Scheme, r.Host)
 	originalURL := fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
@@ -52,23 +332,105 @@ func GetSignedURLFromHTTPRequest(r *http.Request) (string, error) {
 			return "", err
 		}
 	}
+	if body, err = cfg.signedBody(r.Method, r.Header.Get, body, false); err != nil {
+		return "", err
+	}
 
 	// Throw error if reserved query params are used in signature request
-	q := r.URL.Query()
-	if q.Get(cfg.SignatureQueryKey) != "" {
-		return "", fmt.Errorf("%s is a reserved query parameter when generating signed routes", cfg.SignatureQueryKey)
-	} else if q.Get(cfg.PrivateKeyQueryKey) != "" {
-		return "", fmt.Errorf("%s is a reserved query parameter when generating signed routes", cfg.PrivateKeyQueryKey)
-	} else if q.Get(cfg.BodyHashQueryKey) != "" {
-		return "", fmt.Errorf("%s is a reserved query parameter when generating signed routes", cfg.BodyHashQueryKey)
+	q, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		return "", err
+	}
+	var cosignatures []string
+	if keyID != "" {
+		cosignatures = q[cfg.SignatureQueryKey]
+		q.Del(cfg.SignatureQueryKey)
+		for _, cosignature := range cosignatures {
+			if !strings.Contains(cosignature, ":") {
+				return "", errors.New("cannot co-sign a URL which is already signed")
+			}
+		}
+	}
+	if len(cosignatures) > 0 {
+		// Params added when the first co-signature was are now covered by it
+		err = checkReservedParams(q, cfg.PrivateKeyQueryKey, cfg.BodyHashQueryKey, cfg.CaveatQueryKey, cfg.TokenQueryKey, cfg.BindLocal, cfg.DelegationQueryKey)
+	} else if keyID != "" {
+		err = cfg.checkSigningParams(q, cfg.DelegationQueryKey)
+	} else {
+		err = cfg.checkSigningParams(q)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := cfg.checkRepeatedParams(r.URL.RawQuery); err != nil {
+		return "", err
 	}
 
-	// Get signature
-	signature, _ := getSignature(r.Method, baseURL, originalURL, body)
+	if len(cosignatures) == 0 {
+		// Expire the URL as governed by the TTL policy of its purpose
+		if changed, err := cfg.applyTTLPolicy(q); err != nil {
+			return "", err
+		} else if changed {
+			r.URL.RawQuery = q.Encode()
+			originalURL = fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+
+		// Sign the ID of the issuing request in so uses can be traced to it
+		if cfg.stampRequestID(r.Context(), q) {
+			r.URL.RawQuery = q.Encode()
+			originalURL = fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
+		}
 
-	// Append signature to query params
-	q.Add("signature", signature)
+		// Name the algorithm so verifiers use it rather than guessing
+		if cfg.EmbedAlgorithm {
+			q.Set(cfg.AlgorithmQueryKey, getAlgorithmID(cfg.Algorithm))
+			r.URL.RawQuery = q.Encode()
+			originalURL = fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+
+		// Stamp the time of signing so validators can enforce MaxAge
+		if cfg.StampIssued {
+			q.Set(cfg.IssuedQueryKey, strconv.FormatInt(timeNow().Unix(), 10))
+			r.URL.RawQuery = q.Encode()
+			originalURL = fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+	}
+
+	// Refuse URLs which would be rejected for lacking freshness params
+	if err := cfg.checkRequireNonce(q); err != nil {
+		return "", err
+	}
+
+	// Count the URL against the minting limit of its key
+	if err := cfg.checkMintLimit(r); err != nil {
+		return "", err
+	}
+
+	// Get signature, which ignores any existing co-signatures
+	signature, _ := cfg.getSignatureWithKey(privateKey, binding, r.Method, baseURL, originalURL, body)
+
+	// Append signature to query params, after any existing co-signatures
+	eventKeyID := KeyFingerprint(privateKey)
+	if keyID != "" {
+		signature = fmt.Sprintf("%s:%s", keyID, signature)
+		eventKeyID = keyID
+	}
+	q[cfg.SignatureQueryKey] = append(cosignatures, signature)
 	r.URL.RawQuery = q.Encode()
 
-	return r.URL.String(), nil
+	signedURL := r.URL.String()
+	if events.hasSubscribers() {
+		emitSigned(r.Context(), Event{
+			Type:      EventSigned,
+			URL:       signedURL,
+			Algorithm: string(cfg.Algorithm),
+			KeyID:     eventKeyID,
+			Purpose:   q.Get(cfg.PurposeQueryKey),
+			RequestID: q.Get(cfg.RequestIDQueryKey),
+			Warnings:  cfg.getWarnings(r.URL),
+			Context:   r.Context(),
+		})
+	}
+
+	return signedURL, nil
 }
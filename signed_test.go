@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
@@ -20,6 +22,55 @@ func (errReader) Read(p []byte) (n int, err error) {
 	return 0, errors.New("test error")
 }
 
+// testStorage is a minimal fiber.Storage for tests
+type testStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newTestStorage() *testStorage {
+	return &testStorage{data: make(map[string][]byte)}
+}
+
+func (s *testStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *testStorage) Set(key string, val []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = val
+	return nil
+}
+
+func (s *testStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *testStorage) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = make(map[string][]byte)
+	return nil
+}
+
+func (s *testStorage) Close() error {
+	return nil
+}
+
+// newTestRequest creates a request for app.Test with an origin-form request
+// URI, as a real client would send it
+func newTestRequest(method, target string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.RequestURI = ""
+	return req
+}
+
 func TestValidateRequest(t *testing.T) {
 
 	// Initalize config
@@ -171,4 +222,206 @@ func TestGetSignedURLFromHTTPRequest(t *testing.T) {
 		_, err := GetSignedURLFromHTTPRequest(req)
 		utils.AssertEqual(t, expected, err.Error())
 	})
+
+	t.Run("it should list every reserved query param present", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?bodyHash=a&delegation=b&signature=&caveat=c", nil)
+		_, err := GetSignedURLFromHTTPRequest(req)
+
+		reserved, ok := err.(*ReservedParamsError)
+		utils.AssertEqual(t, true, ok)
+		utils.AssertEqual(t, []string{"delegation", "signature", "bodyHash", "caveat"}, reserved.Params)
+		utils.AssertEqual(t, "delegation, signature, bodyHash, caveat are reserved query parameters when generating signed routes", err.Error())
+	})
+
+	t.Run("it should emit urls on a public host while signing for the request host", func(t *testing.T) {
+		hash := sha1.New()
+		hash.Write([]byte("GET&http://internal.svc:8080/?privateKey=secret"))
+		expected := fmt.Sprintf("%s%x", "http://cdn.example.com/?signature=", hash.Sum(nil))
+
+		req := httptest.NewRequest(http.MethodGet, "http://internal.svc:8080/", nil)
+		got, _ := GetSignedURLForHostFromHTTPRequest(req, "cdn.example.com")
+		utils.AssertEqual(t, expected, got)
+	})
+}
+
+func TestBytesServed(t *testing.T) {
+	// Initalize config
+	served := make(map[string]int)
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		BytesServed: func(c *fiber.Ctx, signature string, bytes int) {
+			served[signature] += bytes
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	app.Get("/stream", func(c *fiber.Ctx) error {
+		return c.SendStream(strings.NewReader("streamed body"), 13)
+	})
+
+	t.Run("it should report bytes served per signature", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		signature := req.URL.Query().Get("signature")
+
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, 26, served[signature])
+	})
+
+	t.Run("it should report bytes served for streamed responses", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/stream", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+		signature := req.URL.Query().Get("signature")
+
+		app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, 13, served[signature])
+	})
+
+	t.Run("it should not report rejected requests", func(t *testing.T) {
+		app.Test(newTestRequest(http.MethodGet, "http://example.com/?signature=wrong"))
+
+		utils.AssertEqual(t, 0, served["wrong"])
+	})
+}
+
+func TestMaxAge(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		StampIssued:       true,
+		MaxAge:            time.Hour,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string) (string, error) {
+		return GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	t.Run("it should stamp and accept recently issued urls", func(t *testing.T) {
+		now := time.Now()
+		restore := InjectFaults(Faults{Now: now})
+		defer restore()
+
+		signedURL, err := sign(fmt.Sprintf("http://example.com/?expires=%d", now.Add(24*time.Hour).Unix()))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.Contains(signedURL, fmt.Sprintf("issued=%d", now.Unix())))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject urls older than the maximum age regardless of expiry", func(t *testing.T) {
+		restore := InjectFaults(Faults{ClockSkew: -2 * time.Hour})
+		signedURL, _ := sign(fmt.Sprintf("http://example.com/?expires=%d", time.Now().Add(24*time.Hour).Unix()))
+		restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature is older than the maximum age", string(body))
+	})
+
+	t.Run("it should reject urls without an issued time", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "/?signature=anything"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "issued is a required query param when MaxAge is set", string(body))
+	})
+
+	t.Run("it should not sign urls with an existing issued time", func(t *testing.T) {
+		_, err := sign("http://example.com/?issued=1")
+
+		utils.AssertEqual(t, "issued is a reserved query parameter when generating signed routes", err.Error())
+	})
+}
+
+func TestSignatureBits(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmHMACSHA256,
+		SignatureBits:     128,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should truncate and base64url encode signatures", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		signedURL, err := GetSignedURLFromHTTPRequest(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 22, len(req.URL.Query().Get("signature")))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should chain caveats onto truncated signatures", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		attenuated, err := AddCaveat(signedURL, PathCaveat("/"))
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, attenuated))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should not truncate signatures below the minimum", func(t *testing.T) {
+		utils.AssertEqual(t, minSignatureBits, configDefault(Config{SignatureBits: 8}).SignatureBits)
+	})
+}
+
+func TestQueryKeyPrefix(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		QueryKeyPrefix:    "X-Sig-",
+		NonceQueryKey:     "once",
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Query("signature") + c.Query("expires"))
+	})
+
+	t.Run("it should namespace the params it owns", func(t *testing.T) {
+		utils.AssertEqual(t, "X-Sig-Signature", current().SignatureQueryKey)
+		utils.AssertEqual(t, "X-Sig-Expires", current().ExpiresQueryKey)
+		utils.AssertEqual(t, "X-Sig-MaxUses", current().MaxUsesQueryKey)
+		utils.AssertEqual(t, "X-Sig-Purpose", current().PurposeQueryKey)
+		utils.AssertEqual(t, "X-Sig-User", current().UserQueryKey)
+		utils.AssertEqual(t, "X-Sig-Rid", current().RequestIDQueryKey)
+		utils.AssertEqual(t, "once", current().NonceQueryKey)
+	})
+
+	t.Run("it should leave app params of the same name alone", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?signature=jane&expires=never", nil)
+		signedURL, err := GetSignedURLFromHTTPRequest(req)
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "janenever", string(body))
+	})
 }
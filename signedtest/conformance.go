@@ -0,0 +1,86 @@
+// Package signedtest provides helpers for testing against the middleware. It
+// is kept separate so the middleware itself doesn't import testing.
+package signedtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	signed "github.com/bsandusky/fiber-signed"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConformancePrivateKey is the private key signers under test must use when
+// run against RunConformance
+const ConformancePrivateKey = "fiber-signed-conformance"
+
+// ConformanceSigner signs r, returning the full signed URL. It has the same
+// shape as signed.GetSignedURLFromHTTPRequest, which is the reference behaviour.
+type ConformanceSigner func(r *http.Request) (string, error)
+
+// conformanceCases are the encoding edge cases exercised by RunConformance
+var conformanceCases = []struct {
+	name   string
+	method string
+	target string
+	body   string
+}{
+	{"root without trailing slash", http.MethodGet, "http://example.com", ""},
+	{"root", http.MethodGet, "http://example.com/", ""},
+	{"unordered params", http.MethodGet, "http://example.com/?b=2&c=3&a=1", ""},
+	{"repeated keys", http.MethodGet, "http://example.com/?tag=b&tag=c&tag=a", ""},
+	{"empty values", http.MethodGet, "http://example.com/?a=&b=1", ""},
+	{"key without value", http.MethodGet, "http://example.com/?flag&b=1", ""},
+	{"unicode params", http.MethodGet, "http://example.com/?name=Zo%C3%AB&city=%E6%9D%B1%E4%BA%AC", ""},
+	{"reserved characters in values", http.MethodGet, "http://example.com/?q=a%26b%3Dc%3F", ""},
+	{"plus and encoded space", http.MethodGet, "http://example.com/?q=a+b&r=c%20d", ""},
+	{"encoded space in path", http.MethodGet, "http://example.com/files/a%20b.txt", ""},
+	{"unicode path", http.MethodGet, "http://example.com/%E2%9C%93/caf%C3%A9", ""},
+	{"trailing slash", http.MethodGet, "http://example.com/dir/?a=1", ""},
+	{"non-default port", http.MethodGet, "http://example.com:8080/?a=1", ""},
+	{"body", http.MethodPost, "http://example.com/submit?a=1", `{"b":2}`},
+	{"unicode body", http.MethodPut, "http://example.com/submit", "Zoë"},
+}
+
+// RunConformance verifies signer produces URLs accepted by this package for a
+// range of encoding edge cases (unicode params, repeated keys, empty values,
+// unusual paths), so alternative client implementations can be checked
+// against the reference behaviour. signer must sign with
+// ConformancePrivateKey and the default config. RunConformance replaces the
+// middleware config, so it must not run in parallel with other tests using it.
+func RunConformance(t *testing.T, signer ConformanceSigner) {
+	app := fiber.New()
+	app.Use(signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return ConformancePrivateKey },
+	}))
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for _, tc := range conformanceCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			signedURL, err := signer(httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+			if err != nil {
+				t.Fatalf("signer returned error: %v", err)
+			}
+
+			req := httptest.NewRequest(tc.method, signedURL, bytes.NewReader([]byte(tc.body)))
+			// Send the origin-form request target rather than the absolute URL
+			req.RequestURI = ""
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				body, _ := ioutil.ReadAll(resp.Body)
+				t.Errorf("signed URL %q rejected with %d: %s", signedURL, resp.StatusCode, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package signedtest
+
+import (
+	"testing"
+
+	signed "github.com/bsandusky/fiber-signed"
+)
+
+func TestRunConformance(t *testing.T) {
+	t.Run("it should pass with the reference signer", func(t *testing.T) {
+		RunConformance(t, signed.GetSignedURLFromHTTPRequest)
+	})
+}
@@ -0,0 +1,122 @@
+package signed
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SignedURL is a signed URL along with its expiry, so APIs can return expiry
+// metadata alongside the URL without clients re-parsing its query params. It
+// marshals to JSON as {"url": "...", "expiresAt": "..."}, leaving out
+// expiresAt for URLs which never expire. Warnings are left out of the JSON.
+type SignedURL struct {
+	url       *url.URL
+	expiresAt time.Time
+	warnings  []Warning
+}
+
+// signedURLJSON is the JSON representation of a SignedURL
+type signedURLJSON struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// SignURL takes an instance of *http.Request and returns its SignedURL, as
+// GetSignedURLFromHTTPRequest
+func SignURL(r *http.Request) (SignedURL, error) {
+	cfg := instanceFor(r.Context())
+
+	signedURL, err := GetSignedURLFromHTTPRequest(r)
+	if err != nil {
+		return SignedURL{}, err
+	}
+
+	return cfg.parseSignedURL(signedURL)
+}
+
+// ParseSignedURL returns the SignedURL for rawURL, eg. as returned by any of
+// the GetSignedURL functions. The expiry is read from the expiry query params
+// of the URL, so it is zero for token URLs.
+func ParseSignedURL(rawURL string) (SignedURL, error) {
+	return current().parseSignedURL(rawURL)
+}
+
+// parseSignedURL returns the SignedURL for rawURL with the expiry params of cfg
+func (cfg *instance) parseSignedURL(rawURL string) (SignedURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return SignedURL{}, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return SignedURL{}, errors.New("signed url must be absolute")
+	}
+
+	expiresAt, _ := cfg.getEarliestExpiry(urlQuery(u).Get)
+
+	return SignedURL{url: u, expiresAt: expiresAt, warnings: cfg.getWarnings(u)}, nil
+}
+
+// String returns the full signed URL
+func (s SignedURL) String() string {
+	if s.url == nil {
+		return ""
+	}
+
+	return s.url.String()
+}
+
+// ExpiresAt returns when the URL expires, or the zero time if it never does
+func (s SignedURL) ExpiresAt() time.Time {
+	return s.expiresAt
+}
+
+// Warnings returns the non-fatal problems found with the URL under the
+// current config, eg. WarningNoExpiry, so applications can log them or
+// surface them to whoever signs URLs rather than find out when requests for
+// it are rejected
+func (s SignedURL) Warnings() []Warning {
+	return s.warnings
+}
+
+// QueryValues returns a copy of the query params of the URL, including the
+// signature
+func (s SignedURL) QueryValues() url.Values {
+	if s.url == nil {
+		return url.Values{}
+	}
+
+	return urlQuery(s.url)
+}
+
+// MarshalJSON implements json.Marshaler
+func (s SignedURL) MarshalJSON() ([]byte, error) {
+	v := signedURLJSON{URL: s.String()}
+	if !s.expiresAt.IsZero() {
+		expiresAt := s.expiresAt.UTC()
+		v.ExpiresAt = &expiresAt
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *SignedURL) UnmarshalJSON(data []byte) error {
+	var v signedURLJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := ParseSignedURL(v.URL)
+	if err != nil {
+		return err
+	}
+	if v.ExpiresAt != nil {
+		parsed.expiresAt = *v.ExpiresAt
+	}
+
+	*s = parsed
+	return nil
+}
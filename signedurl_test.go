@@ -0,0 +1,81 @@
+package signed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestSignedURL(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	expires := time.Now().Add(time.Hour).Unix()
+
+	t.Run("it should expose the url and its expiry", func(t *testing.T) {
+		signedURL, err := SignURL(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/?expires=%d", expires), nil))
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL.String()))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		utils.AssertEqual(t, expires, signedURL.ExpiresAt().Unix())
+		utils.AssertEqual(t, fmt.Sprint(expires), signedURL.QueryValues().Get("expires"))
+		utils.AssertEqual(t, true, signedURL.QueryValues().Get("signature") != "")
+	})
+
+	t.Run("it should read relative expiries", func(t *testing.T) {
+		raw, _ := GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), time.Minute)
+		signedURL, err := ParseSignedURL(raw)
+		utils.AssertEqual(t, nil, err)
+
+		utils.AssertEqual(t, true, signedURL.ExpiresAt().After(time.Now()))
+		utils.AssertEqual(t, true, signedURL.ExpiresAt().Before(time.Now().Add(time.Minute+time.Second)))
+	})
+
+	t.Run("it should marshal to and from json", func(t *testing.T) {
+		signedURL, _ := SignURL(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/?expires=%d", expires), nil))
+
+		b, err := json.Marshal(signedURL)
+		utils.AssertEqual(t, nil, err)
+		var fields map[string]string
+		utils.AssertEqual(t, nil, json.Unmarshal(b, &fields))
+		utils.AssertEqual(t, signedURL.String(), fields["url"])
+		utils.AssertEqual(t, time.Unix(expires, 0).UTC().Format(time.RFC3339), fields["expiresAt"])
+
+		var decoded SignedURL
+		utils.AssertEqual(t, nil, json.Unmarshal(b, &decoded))
+		utils.AssertEqual(t, signedURL.String(), decoded.String())
+		utils.AssertEqual(t, true, signedURL.ExpiresAt().Equal(decoded.ExpiresAt()))
+	})
+
+	t.Run("it should leave out the expiry of urls which never expire", func(t *testing.T) {
+		signedURL, _ := SignURL(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		b, _ := json.Marshal(signedURL)
+		var fields map[string]string
+		_ = json.Unmarshal(b, &fields)
+		utils.AssertEqual(t, map[string]string{"url": signedURL.String()}, fields)
+		utils.AssertEqual(t, true, signedURL.ExpiresAt().IsZero())
+	})
+
+	t.Run("it should reject relative urls", func(t *testing.T) {
+		_, err := ParseSignedURL("/?signature=abc")
+
+		utils.AssertEqual(t, "signed url must be absolute", err.Error())
+	})
+}
@@ -0,0 +1,173 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Kinds of entries written to Storage, as passed to StorageTTL
+const (
+	// StorageKindNonces are the nonces of used single-use URLs
+	StorageKindNonces = "nonces"
+	// StorageKindUses are the remaining uses of max-uses URLs
+	StorageKindUses = "uses"
+	// StorageKindLeases are the concurrent use leases of URLs
+	StorageKindLeases = "leases"
+	// StorageKindRateLimit are the rate limit windows of URLs
+	StorageKindRateLimit = "ratelimit"
+	// StorageKindKeys are the times private keys were first seen
+	StorageKindKeys = "keys"
+	// StorageKindShort are the signed URLs of short URLs
+	StorageKindShort = "short"
+	// StorageKindHealth are the entries written by Healthy
+	StorageKindHealth = "health"
+	// StorageKindAnalytics are the validation counts of signed URLs
+	StorageKindAnalytics = "analytics"
+	// StorageKindFirstUse are the signatures of URLs which have been used
+	StorageKindFirstUse = "firstuse"
+	// StorageKindRevoked are the revocation index entries
+	StorageKindRevoked = "revoked"
+	// StorageKindMint are the minting counters of MintLimit
+	StorageKindMint = "mint"
+)
+
+// storageKey returns the Storage key of the entry of kind identified by id
+func (cfg *instance) storageKey(kind, id string) string {
+	return cfg.StoragePrefix + kind + ":" + id
+}
+
+// storageTTL returns the TTL of an entry of kind which would be stored for
+// ttl, applying StorageTTL
+func (cfg *instance) storageTTL(kind string, ttl time.Duration) time.Duration {
+	if cfg.StorageTTL == nil {
+		return ttl
+	}
+
+	return cfg.StorageTTL(kind, ttl)
+}
+
+// StoreFailurePolicy defines how requests are handled when Storage or the
+// ReplayCache fail
+type StoreFailurePolicy int
+
+// Store failure policy values
+const (
+	// StoreFailClosed rejects the request
+	StoreFailClosed StoreFailurePolicy = iota
+	// StoreFailOpen skips the check which failed
+	StoreFailOpen
+	// StoreFailOpenWithAlert skips the check which failed and calls
+	// StoreFailed
+	StoreFailOpenWithAlert
+)
+
+// storageLocks serializes read-modify-write of Storage entries within this
+// process. Entries are locked by key, so updates of different entries don't
+// wait on each other's Storage round-trips.
+var storageLocks keyLocks
+
+// keyLocks is a set of mutexes by key, kept only while held or waited on
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the mutex of a key and the number of its holders and waiters
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key, returning the function unlocking it
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// storeError is the failure of Storage or the ReplayCache during a check
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string {
+	return e.err.Error()
+}
+
+// handleStoreFailure applies StoreFailurePolicy to the error of a check,
+// returning nil if the check should be skipped
+func (cfg *instance) handleStoreFailure(err error) error {
+	if _, ok := err.(*storeError); !ok || cfg.StoreFailurePolicy == StoreFailClosed {
+		return err
+	}
+
+	if cfg.StoreFailurePolicy == StoreFailOpenWithAlert {
+		cfg.StoreFailed(err)
+	}
+
+	return nil
+}
+
+// Healthy reports whether Storage and the ReplayCache, if configured, are
+// working, for readiness probes. Storage is checked by writing, reading back
+// and deleting an entry, and the ReplayCache by recording a random nonce.
+func Healthy() error {
+	cfg := current()
+
+	if cfg.Storage != nil {
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		storage := cfg.getStorage(context.Background())
+		key := cfg.storageKey(StorageKindHealth, nonce)
+		if err := storage.Set(key, []byte("1"), cfg.storageTTL(StorageKindHealth, time.Minute)); err != nil {
+			return err
+		}
+		b, err := storage.Get(key)
+		if err != nil {
+			return err
+		}
+		if string(b) != "1" {
+			return errors.New("storage did not return the entry written")
+		}
+		if err := storage.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if cfg.ReplayCache != nil {
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		if _, err := cfg.ReplayCache.CheckAndAdd(nonce, time.Second); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package signed
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ttlStorage records the TTL entries are set with
+type ttlStorage struct {
+	*testStorage
+	ttls map[string]time.Duration
+}
+
+func (s *ttlStorage) Set(key string, val []byte, ttl time.Duration) error {
+	s.ttls[key] = ttl
+	return s.testStorage.Set(key, val, ttl)
+}
+
+func TestStoragePrefix(t *testing.T) {
+	// Initalize config
+	storage := &ttlStorage{testStorage: newTestStorage(), ttls: make(map[string]time.Duration)}
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Storage:           storage,
+		StoragePrefix:     "app:signed:",
+		MaxKeyAge:         365 * 24 * time.Hour,
+		StorageTTL: func(kind string, ttl time.Duration) time.Duration {
+			if kind == StorageKindKeys {
+				return 90 * 24 * time.Hour
+			}
+			return ttl
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should prefix and apply the ttl policy to entries it writes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?nonce=abc", nil)
+		signedURL, _ := GetSignedURLFromHTTPRequest(req)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		b, _ := storage.Get("app:signed:nonces:abc")
+		utils.AssertEqual(t, "1", string(b))
+		utils.AssertEqual(t, 24*time.Hour, storage.ttls["app:signed:nonces:abc"])
+
+		fingerprint := KeyFingerprint("secret")
+		utils.AssertEqual(t, 90*24*time.Hour, storage.ttls["app:signed:keys:"+fingerprint])
+
+		for key := range storage.data {
+			utils.AssertEqual(t, "app:signed:", key[:len("app:signed:")])
+		}
+	})
+}
+
+func TestStoreFailurePolicy(t *testing.T) {
+	// Initalize config
+	var alerts []error
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		Storage:            newTestStorage(),
+		StoreFailurePolicy: StoreFailOpenWithAlert,
+		StoreFailed: func(err error) {
+			alerts = append(alerts, err)
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?nonce=abc&maxUses=1", nil))
+
+	t.Run("it should report healthy storage", func(t *testing.T) {
+		utils.AssertEqual(t, nil, Healthy())
+	})
+
+	t.Run("it should skip failed checks and alert", func(t *testing.T) {
+		restore := InjectFaults(Faults{StorageErr: errors.New("storage timeout")})
+		defer restore()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, 2, len(alerts))
+		utils.AssertEqual(t, "storage timeout", alerts[0].Error())
+		utils.AssertEqual(t, "storage timeout", Healthy().Error())
+	})
+
+	t.Run("it should not skip checks which fail without a storage error", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestStorageLocks(t *testing.T) {
+	t.Run("it should not hold up updates of other entries", func(t *testing.T) {
+		var locks keyLocks
+
+		unlock := locks.lock("a")
+
+		done := make(chan struct{})
+		go func() {
+			locks.lock("b")()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("locking b waited on a")
+		}
+
+		unlock()
+	})
+
+	t.Run("it should serialize updates of the same entry", func(t *testing.T) {
+		var locks keyLocks
+
+		unlock := locks.lock("a")
+
+		done := make(chan struct{})
+		go func() {
+			locks.lock("a")()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("a was locked twice")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		unlock()
+		<-done
+		utils.AssertEqual(t, 0, len(locks.locks))
+	})
+}
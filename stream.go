@@ -0,0 +1,94 @@
+package signed
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StreamUntilExpired sets fn as the body stream writer of the response, like
+// fasthttp's SetBodyStreamWriter, for Server-Sent Events and long-poll routes.
+// The expiry of the signed URL is re-checked every RevalidateInterval while
+// streaming, closing expired once it has passed so fn can end the stream.
+// Streams fn hasn't ended one RevalidateInterval later are terminated by
+// closing the connection.
+// The concurrent use lease of the request is held until fn returns.
+// It must be called from the handler, before the request is released.
+func StreamUntilExpired(c *fiber.Ctx, fn func(w *bufio.Writer, expired <-chan struct{})) {
+	cfg := instanceOf(c)
+
+	// Capture everything needed from the request before it is released
+	deadline, ok := cfg.getEarliestExpiry(ctxQuery(c))
+	interval := cfg.RevalidateInterval
+	conn := c.Context().Conn()
+	l := holdLease(c)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if l != nil {
+			defer l.release()
+		}
+
+		expired := make(chan struct{})
+		if !ok {
+			fn(w, expired)
+			return
+		}
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for timeNow().Before(deadline) {
+				select {
+				case <-ticker.C:
+				case <-stop:
+					return
+				}
+			}
+			close(expired)
+
+			// Cut the stream off if fn doesn't end it in time
+			select {
+			case <-ticker.C:
+				_ = conn.Close()
+			case <-stop:
+			}
+		}()
+
+		fn(w, expired)
+	})
+}
+
+// SendStream sets stream as the body of the response, like c.SendStream,
+// holding the concurrent use lease of the request until it has been sent.
+// Leases of streams set otherwise are held for LeaseTTL after the handler
+// returns.
+func SendStream(c *fiber.Ctx, stream io.Reader, size ...int) error {
+	if l := holdLease(c); l != nil {
+		stream = &leasedStream{Reader: stream, lease: l}
+	}
+
+	return c.SendStream(stream, size...)
+}
+
+// leasedStream releases the lease of the request it is the body of once it
+// is closed, after it has been sent
+type leasedStream struct {
+	io.Reader
+	lease *lease
+}
+
+// Close releases the lease and closes the underlying stream if it can be
+func (s *leasedStream) Close() error {
+	s.lease.release()
+	if closer, ok := s.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package signed
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestStreamUntilExpired(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		RevalidateInterval: 5 * time.Millisecond,
+	}))
+
+	app.Get("/events", func(c *fiber.Ctx) error {
+		StreamUntilExpired(c, func(w *bufio.Writer, expired <-chan struct{}) {
+			fmt.Fprint(w, "data: hello\n\n")
+			w.Flush()
+
+			// Move the clock past the expiry of the URL mid-stream
+			restore := InjectFaults(Faults{ClockSkew: 2 * time.Hour})
+			defer restore()
+
+			select {
+			case <-expired:
+				fmt.Fprint(w, "event: expired\n\n")
+			case <-time.After(500 * time.Millisecond):
+				fmt.Fprint(w, "event: timeout\n\n")
+			}
+		})
+		return nil
+	})
+
+	app.Get("/unbounded", func(c *fiber.Ctx) error {
+		StreamUntilExpired(c, func(w *bufio.Writer, expired <-chan struct{}) {
+			select {
+			case <-expired:
+				fmt.Fprint(w, "expired")
+			case <-time.After(20 * time.Millisecond):
+				fmt.Fprint(w, "still valid")
+			}
+		})
+		return nil
+	})
+
+	t.Run("it should signal when the url expires mid-stream", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/events?expires=%d", time.Now().Add(time.Hour).Unix()), nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "data: hello\n\nevent: expired\n\n", string(body))
+	})
+
+	t.Run("it should not signal urls without an expiry", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/unbounded", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, "still valid", string(body))
+	})
+
+	t.Run("it should terminate streams which ignore the expiry", func(t *testing.T) {
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Use(New(Config{
+			GetPrivateKeyFunc:  func() string { return "secret" },
+			RevalidateInterval: 5 * time.Millisecond,
+		}))
+		app.Get("/stubborn", func(c *fiber.Ctx) error {
+			StreamUntilExpired(c, func(w *bufio.Writer, expired <-chan struct{}) {
+				restore := InjectFaults(Faults{ClockSkew: 2 * time.Hour})
+				defer restore()
+
+				for i := 0; i < 200; i++ {
+					fmt.Fprint(w, "data: tick\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				fmt.Fprint(w, "data: done\n\n")
+			})
+			return nil
+		})
+
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		go func() { _ = app.Listener(ln) }()
+		defer func() { _ = app.Shutdown() }()
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/stubborn?expires=%d", ln.Addr(), time.Now().Add(time.Hour).Unix()), nil))
+
+		start := time.Now()
+		resp, err := http.Get(signedURL)
+		utils.AssertEqual(t, nil, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		utils.AssertEqual(t, true, time.Since(start) < time.Second)
+		utils.AssertEqual(t, false, strings.Contains(string(body), "done"))
+	})
+}
@@ -0,0 +1,69 @@
+package signed
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FakeSignature is the signature added by FakeSigner when none is set
+const FakeSignature = "fake-signature"
+
+// NewStub creates a middleware handler for application tests which accepts
+// every request if valid is true, and otherwise rejects every request the way
+// New does for an invalid signature. It does not touch the package config.
+func NewStub(valid bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !valid {
+			return fiber.NewError(fiber.StatusForbidden, "invalid signature")
+		}
+		return c.Next()
+	}
+}
+
+// FakeSigner stands in for GetSignedURLFromHTTPRequest in application tests,
+// adding a fixed signature to URLs without a private key. Pair it with
+// NewStub, as real middleware will reject its URLs.
+type FakeSigner struct {
+	// Signature is added to signed URLs. Default: FakeSignature
+	Signature string
+
+	// Err, if set, is returned instead of a signed URL
+	Err error
+
+	mu     sync.Mutex
+	signed []string
+}
+
+// GetSignedURLFromHTTPRequest returns the URL of r with the fake signature
+// added, or Err if set
+func (f *FakeSigner) GetSignedURLFromHTTPRequest(r *http.Request) (string, error) {
+	if f.Err != nil {
+		return "", f.Err
+	}
+
+	signature := f.Signature
+	if signature == "" {
+		signature = FakeSignature
+	}
+
+	q := urlQuery(r.URL)
+	q.Set(ConfigDefault.SignatureQueryKey, signature)
+	r.URL.RawQuery = q.Encode()
+	signedURL := r.URL.String()
+
+	f.mu.Lock()
+	f.signed = append(f.signed, signedURL)
+	f.mu.Unlock()
+
+	return signedURL, nil
+}
+
+// Signed returns the URLs signed so far, in order
+func (f *FakeSigner) Signed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.signed...)
+}
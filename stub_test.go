@@ -0,0 +1,60 @@
+package signed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestNewStub(t *testing.T) {
+	newApp := func(valid bool) *fiber.App {
+		app := fiber.New()
+		app.Use(NewStub(valid))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+		return app
+	}
+
+	t.Run("it should accept unsigned requests when valid", func(t *testing.T) {
+		resp, _ := newApp(true).Test(newTestRequest(http.MethodGet, "/"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	t.Run("it should reject requests when invalid", func(t *testing.T) {
+		resp, _ := newApp(false).Test(newTestRequest(http.MethodGet, "/?signature=anything"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "invalid signature", string(body))
+	})
+}
+
+func TestFakeSigner(t *testing.T) {
+	t.Run("it should add the fake signature and record signed URLs", func(t *testing.T) {
+		f := &FakeSigner{}
+
+		got, err := f.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/path?a=1", nil))
+
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "http://example.com/path?a=1&signature=fake-signature", got)
+		utils.AssertEqual(t, []string{got}, f.Signed())
+	})
+
+	t.Run("it should return the configured error", func(t *testing.T) {
+		f := &FakeSigner{Err: errors.New("boom")}
+
+		_, err := f.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		utils.AssertEqual(t, "boom", err.Error())
+		utils.AssertEqual(t, 0, len(f.Signed()))
+	})
+}
@@ -0,0 +1,175 @@
+package signed
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// placeholderPattern matches the placeholders of path templates, eg. {month}
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// TemplateParam constrains the values clients may substitute for a
+// placeholder of a template URL. Values must match Pattern, if set, and be
+// one of Values, if set.
+type TemplateParam struct {
+	// Pattern is a regular expression the whole value must match, eg.
+	// `2024-(0[1-9]|1[0-2])`
+	Pattern string `json:"pattern,omitempty"`
+
+	// Values lists the allowed values, eg. []string{"pdf", "csv"}
+	Values []string `json:"values,omitempty"`
+}
+
+// urlTemplate is the path template of a template URL and the constraints of
+// its placeholders, as signed into its query params
+type urlTemplate struct {
+	Path   string                   `json:"path"`
+	Params map[string]TemplateParam `json:"params"`
+}
+
+// compile returns a regular expression matching paths the template may be
+// substituted into, with one group per placeholder, and the placeholder
+// names in order. Every placeholder must be constrained.
+func (t urlTemplate) compile() (*regexp.Regexp, []string, error) {
+	var names []string
+	seen := make(map[string]bool)
+
+	pattern := "^"
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(t.Path, -1) {
+		name := t.Path[m[2]:m[3]]
+		if seen[name] {
+			return nil, nil, fmt.Errorf("placeholder %s is repeated in the template", name)
+		}
+		p, ok := t.Params[name]
+		if !ok || (p.Pattern == "" && len(p.Values) == 0) {
+			return nil, nil, fmt.Errorf("placeholder %s has no constraint", name)
+		}
+		if p.Pattern != "" {
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				return nil, nil, fmt.Errorf("placeholder %s has an invalid pattern", name)
+			}
+		}
+		seen[name] = true
+		names = append(names, name)
+
+		// Placeholders stand for exactly one path segment
+		pattern += regexp.QuoteMeta(t.Path[last:m[0]]) + "([^/]+)"
+		last = m[1]
+	}
+	if len(names) == 0 {
+		return nil, nil, errors.New("template has no placeholders")
+	}
+	for name := range t.Params {
+		if !seen[name] {
+			return nil, nil, fmt.Errorf("placeholder %s is not in the template", name)
+		}
+	}
+	pattern += regexp.QuoteMeta(t.Path[last:]) + "$"
+
+	return regexp.MustCompile(pattern), names, nil
+}
+
+// allows reports whether value satisfies the constraint
+func (p TemplateParam) allows(value string) bool {
+	if p.Pattern != "" {
+		if ok, _ := regexp.MatchString("^(?:"+p.Pattern+")$", value); !ok {
+			return false
+		}
+	}
+	if len(p.Values) == 0 {
+		return true
+	}
+	for _, v := range p.Values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetSignedTemplateURLFromHTTPRequest takes an instance of *http.Request whose
+// path has named placeholders, eg. /reports/{month}, and the constraints of
+// each, and returns full URL with calculated signature which clients may
+// substitute any values satisfying the constraints into. Each placeholder
+// stands for one path segment, so one URL replaces many near-identical links.
+func GetSignedTemplateURLFromHTTPRequest(r *http.Request, params map[string]TemplateParam) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	// Throw error if template query param is already in use
+	q := urlQuery(r.URL)
+	if err := cfg.checkSigningParams(q, cfg.TemplateQueryKey); err != nil {
+		return "", err
+	}
+
+	t := urlTemplate{Path: r.URL.Path, Params: params}
+	_, names, err := t.compile()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	// Append template to query params so it is covered by the signature
+	q.Set(cfg.TemplateQueryKey, base64.RawURLEncoding.EncodeToString(b))
+	r.URL.RawQuery = q.Encode()
+
+	signedURL, err := GetSignedURLFromHTTPRequest(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Leave placeholders readable for clients substituting them
+	for _, name := range names {
+		signedURL = strings.Replace(signedURL, "%7B"+name+"%7D", "{"+name+"}", 1)
+	}
+
+	return signedURL, nil
+}
+
+// getTemplateURL returns originalURL with its path replaced by the template
+// signed into it, once the path is checked to be a substitution of the
+// template satisfying its constraints
+func (cfg *instance) getTemplateURL(c *fiber.Ctx, encoded, originalURL string) (string, error) {
+	invalid := fmt.Errorf("%s value must be a valid url template", cfg.TemplateQueryKey)
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", invalid
+	}
+	var t urlTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", invalid
+	}
+	matcher, names, err := t.compile()
+	if err != nil {
+		return "", invalid
+	}
+
+	values := matcher.FindStringSubmatch(string(c.Request().URI().Path()))
+	if values == nil {
+		return "", errors.New("url does not match its template")
+	}
+	for i, name := range names {
+		if !t.Params[name].allows(values[i+1]) {
+			return "", fmt.Errorf("url template does not permit %s %q", name, values[i+1])
+		}
+	}
+
+	if split := strings.IndexByte(originalURL, '?'); split >= 0 {
+		return t.Path + originalURL[split:], nil
+	}
+
+	return t.Path, nil
+}
@@ -0,0 +1,85 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestTemplateURL(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/reports/:month/:format", func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("month") + "." + c.Params("format"))
+	})
+
+	signedURL, err := GetSignedTemplateURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/reports/{month}/{format}?team=ops", nil), map[string]TemplateParam{
+		"month":  {Pattern: `2024-(0[1-9]|1[0-2])`},
+		"format": {Values: []string{"pdf", "csv"}},
+	})
+	utils.AssertEqual(t, nil, err)
+
+	substitute := func(month, format string) string {
+		return strings.NewReplacer("{month}", month, "{format}", format).Replace(signedURL)
+	}
+
+	t.Run("it should leave placeholders readable", func(t *testing.T) {
+		utils.AssertEqual(t, true, strings.HasPrefix(signedURL, "http://example.com/reports/{month}/{format}?"))
+	})
+
+	t.Run("it should accept values satisfying the constraints", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, substitute("2024-03", "pdf")))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "2024-03.pdf", string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, substitute("2024-12", "csv")))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject values violating the constraints", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, substitute("2024-13", "pdf")))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, `url template does not permit month "2024-13"`, string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, substitute("2024-03", "xlsx")))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject paths not matching the template", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, strings.Replace(substitute("2024-03", "pdf"), "/reports/", "/invoices/", 1)))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url does not match its template", string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, substitute("2024-03/extra", "pdf")))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should reject tampered templates", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, strings.Replace(substitute("2024-03", "pdf"), "team=ops", "team=dev", 1)))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("it should refuse templates with unconstrained placeholders", func(t *testing.T) {
+		_, err := GetSignedTemplateURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/reports/{month}", nil), nil)
+		utils.AssertEqual(t, "placeholder month has no constraint", err.Error())
+
+		_, err = GetSignedTemplateURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/reports", nil), map[string]TemplateParam{
+			"month": {Values: []string{"2024-01"}},
+		})
+		utils.AssertEqual(t, "template has no placeholders", err.Error())
+	})
+}
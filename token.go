@@ -0,0 +1,317 @@
+package signed
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// tokenHeader is the JOSE header of tokens issued by the middleware
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// tokenClaimsLocal is the fiber.Ctx local holding the claims of the token of
+// a verified request, so they are verified once per request
+const tokenClaimsLocal = "fiber-signed:token-claims"
+
+// getTokenClaims returns the claims of the token of a verified request, or
+// nil if it carries no verified token
+func getTokenClaims(c *fiber.Ctx) Claims {
+	claims, _ := c.Locals(tokenClaimsLocal).(Claims)
+	return claims
+}
+
+// getTokenExpiry returns when a token URL expires, at the earliest of its
+// ClaimExpires claim and the expiry query params signed alongside it, or zero
+// if it carries none
+func (cfg *instance) getTokenExpiry(claims Claims, query func(string) string) time.Time {
+	var expires time.Time
+	if exp, ok := claims[ClaimExpires].(float64); ok {
+		expires = time.Unix(int64(exp), 0)
+	}
+	if when, ok := cfg.getEarliestExpiry(query); ok && (expires.IsZero() || when.Before(expires)) {
+		expires = when
+	}
+
+	return expires
+}
+
+// getURLHash returns the claim value binding a token to a canonical request
+func getURLHash(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signToken encodes claims into a compact JWT signed with the configured
+// token algorithm
+func (cfg *instance) signToken(ctx context.Context, claims Claims) (string, error) {
+
+	header, err := json.Marshal(tokenHeader{Alg: string(cfg.TokenAlgorithm), Typ: "JWT", Kid: cfg.KeyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := fmt.Sprintf("%s.%s", base64.RawURLEncoding.EncodeToString(header), base64.RawURLEncoding.EncodeToString(payload))
+
+	var signature []byte
+	switch cfg.TokenAlgorithm {
+	case TokenAlgorithmHS256:
+		privateKey, err := cfg.getPrivateKey(ctx)
+		if err != nil {
+			return "", err
+		}
+		mac := hmac.New(sha256.New, []byte(privateKey))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case TokenAlgorithmEdDSA:
+		if cfg.GetSigningKeyFunc == nil {
+			return "", errors.New("GetSigningKeyFunc is required to sign EdDSA tokens")
+		}
+		// ed25519.Sign panics on keys of any other length
+		signingKey := cfg.GetSigningKeyFunc()
+		if len(signingKey) != ed25519.PrivateKeySize {
+			return "", errors.New("GetSigningKeyFunc must return a valid Ed25519 private key")
+		}
+		signature = ed25519.Sign(signingKey, []byte(signingInput))
+	default:
+		return "", fmt.Errorf("unsupported token algorithm %q", cfg.TokenAlgorithm)
+	}
+
+	return fmt.Sprintf("%s.%s", signingInput, base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+// parseToken verifies a compact JWT and returns its claims
+func (cfg *instance) parseToken(ctx context.Context, token string) (Claims, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid token")
+	}
+
+	// Only accept the configured algorithm, never the one the token asks for
+	var header tokenHeader
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(b, &header) != nil || header.Alg != string(cfg.TokenAlgorithm) {
+		return nil, errors.New("invalid token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	signingInput := []byte(fmt.Sprintf("%s.%s", parts[0], parts[1]))
+	switch cfg.TokenAlgorithm {
+	case TokenAlgorithmHS256:
+		privateKey, err := cfg.getPrivateKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mac := hmac.New(sha256.New, []byte(privateKey))
+		mac.Write(signingInput)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("invalid token")
+		}
+	case TokenAlgorithmEdDSA:
+		publicKey, err := cfg.getPublicKey(ctx, header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		// ed25519.Verify panics on keys of any other length
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, errors.New("GetPublicKeyFunc must return a valid Ed25519 public key")
+		}
+		if !ed25519.Verify(publicKey, signingInput, signature) {
+			return nil, errors.New("invalid token")
+		}
+	default:
+		return nil, errors.New("invalid token")
+	}
+
+	var claims Claims
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(b, &claims) != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// getTokenKeyID returns the key ID in the header of a verified token, or the
+// fingerprint of the private key for HS256 tokens without one
+func (cfg *instance) getTokenKeyID(ctx context.Context, token string) string {
+	var header tokenHeader
+	if b, err := base64.RawURLEncoding.DecodeString(strings.SplitN(token, ".", 2)[0]); err == nil {
+		_ = json.Unmarshal(b, &header)
+	}
+	if header.Kid == "" && cfg.TokenAlgorithm == TokenAlgorithmHS256 {
+		if privateKey, err := cfg.getPrivateKey(ctx); err == nil {
+			return KeyFingerprint(privateKey)
+		}
+	}
+
+	return header.Kid
+}
+
+// validateToken handles token mode requests, confirming the token is validly
+// signed, unexpired and bound to the inbound request. The verified claims are
+// kept in tokenClaimsLocal for later checks.
+func (cfg *instance) validateToken(c *fiber.Ctx, token string) (bool, error) {
+
+	claims, err := cfg.parseToken(requestContext(c), token)
+	if err != nil {
+		return false, err
+	}
+
+	// Tokens expire at their ClaimExpires claim, and at the expiry query
+	// params bound to them along with the rest of the URL, as plain signed
+	// URLs do
+	var expired bool
+	if exp, ok := claims[ClaimExpires]; ok {
+		i, ok := exp.(float64)
+		if !ok {
+			return false, fmt.Errorf("%s claim must be valid integer", ClaimExpires)
+		}
+		expired = time.Unix(int64(i), 0).Before(timeNow())
+	}
+	if err := cfg.checkQueryExpiry(c); err == errExpired {
+		expired = true
+	} else if err != nil {
+		return false, err
+	}
+
+	// Tokens expired within ClockSkewThreshold are rejected once they are
+	// known to be bound to the request, to tell clock skew from expiry
+	var skew time.Duration
+	if expired {
+		var ok bool
+		if skew, ok = cfg.getClockSkew(cfg.getTokenExpiry(claims, ctxQuery(c))); !ok {
+			return false, errExpired
+		}
+	}
+
+	body, err := cfg.signedBody(c.Method(), ctxHeader(c), c.Body(), true)
+	if err != nil {
+		return false, err
+	}
+
+	canonical, err := cfg.getCanonicalString(c.Method(), c.BaseURL(), c.OriginalURL(), body, nil)
+	if err != nil {
+		return false, err
+	}
+	if skew > 0 {
+		if claims[ClaimURLHash] == getURLHash(canonical) {
+			cfg.reportClockSkew(c, skew)
+		}
+		return false, errExpired
+	}
+	if claims[ClaimURLHash] != getURLHash(canonical) {
+		return false, errors.New("token does not match request URL")
+	}
+
+	// Run application specific checks on the now trusted claims
+	if err := cfg.validateClaims(c, claims); err != nil {
+		return false, err
+	}
+
+	c.Locals(tokenClaimsLocal, claims)
+	setLabels(c, string(cfg.TokenAlgorithm), func() string { return cfg.getTokenKeyID(requestContext(c), token) })
+
+	return true, nil
+}
+
+// GetSignedTokenURLFromHTTPRequest takes an instance of *http.Request and a
+// set of claims and returns full URL with a JWT bound to the request. Set
+// ClaimExpires and ClaimPurpose in claims as needed; ClaimURLHash is always
+// calculated.
+func GetSignedTokenURLFromHTTPRequest(r *http.Request, claims Claims) (string, error) {
+	cfg := instanceFor(r.Context())
+
+	baseURL := fmt.Sprintf("%s://%s", r.URL.Scheme, r.Host)
+	originalURL := fmt.Sprintf("%s?%s", r.URL.Path, r.URL.RawQuery)
+
+	// Read body if exists
+	var body []byte
+	var err error
+	if r.Body != nil {
+		body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+	}
+	if body, err = cfg.signedBody(r.Method, r.Header.Get, body, false); err != nil {
+		return "", err
+	}
+
+	// Throw error if reserved query params are used in token request
+	q := urlQuery(r.URL)
+	if err := checkReservedParams(q, cfg.TokenQueryKey, cfg.BodyHashQueryKey); err != nil {
+		return "", err
+	}
+
+	canonical, err := cfg.getCanonicalString(r.Method, baseURL, originalURL, body, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Copy claims so the caller's map is left untouched
+	tokenClaims := make(Claims, len(claims)+1)
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+	tokenClaims[ClaimURLHash] = getURLHash(canonical)
+
+	// Sign the ID of the issuing request in so uses can be traced to it
+	if _, ok := tokenClaims[ClaimRequestID]; !ok {
+		if id := cfg.getIssuingRequestID(r.Context()); id != "" {
+			tokenClaims[ClaimRequestID] = id
+		}
+	}
+
+	// Expire the token as governed by the TTL policy of its purpose
+	if err := cfg.applyTokenTTLPolicy(tokenClaims); err != nil {
+		return "", err
+	}
+
+	// Count the URL against the minting limit of its key
+	if err := cfg.checkMintLimit(r); err != nil {
+		return "", err
+	}
+
+	token, err := cfg.signToken(r.Context(), tokenClaims)
+	if err != nil {
+		return "", err
+	}
+
+	// Append token to query params
+	q.Set(cfg.TokenQueryKey, token)
+	r.URL.RawQuery = q.Encode()
+
+	signedURL := r.URL.String()
+	if events.hasSubscribers() {
+		purpose, _ := tokenClaims[ClaimPurpose].(string)
+		requestID, _ := tokenClaims[ClaimRequestID].(string)
+		emitSigned(r.Context(), Event{Type: EventSigned, URL: signedURL, Algorithm: string(cfg.TokenAlgorithm), KeyID: cfg.getTokenKeyID(r.Context(), token), Purpose: purpose, RequestID: requestID, Warnings: cfg.getWarnings(r.URL)})
+	}
+
+	return signedURL, nil
+}
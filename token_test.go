@@ -0,0 +1,175 @@
+package signed
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestTokenMode(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/files/:name", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	sign := func(target string, claims Claims) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		signedURL, err := GetSignedTokenURLFromHTTPRequest(req, claims)
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should succeed with a valid HS256 token", func(t *testing.T) {
+		signedURL := sign("http://example.com/files/a.txt?v=1", Claims{
+			ClaimExpires: time.Now().Add(time.Hour).Unix(),
+			ClaimPurpose: "download",
+			"userID":     "42",
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "Hello, world!", string(body))
+	})
+
+	t.Run("it should not succeed with an expired token", func(t *testing.T) {
+		signedURL := sign("http://example.com/files/a.txt", Claims{
+			ClaimExpires: time.Now().Add(-time.Hour).Unix(),
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should not succeed when the expires param has passed", func(t *testing.T) {
+		signedURL := sign(fmt.Sprintf("http://example.com/files/a.txt?expires=%d", time.Now().Add(-time.Hour).Unix()), Claims{
+			ClaimExpires: time.Now().Add(time.Hour).Unix(),
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url signature has expired", string(body))
+	})
+
+	t.Run("it should not succeed when the URL is modified", func(t *testing.T) {
+		signedURL := sign("http://example.com/files/a.txt?v=1", nil)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&v=2"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "token does not match request URL", string(body))
+	})
+
+	t.Run("it should not succeed with a tampered token", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, "http://example.com/files/a.txt?token=eyJhbGciOiJub25lIn0.e30."))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "invalid token", string(body))
+	})
+
+	t.Run("it should succeed with a valid EdDSA token", func(t *testing.T) {
+		publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+
+		app := fiber.New()
+		app.Use(New(Config{
+			TokenAlgorithm:    TokenAlgorithmEdDSA,
+			GetSigningKeyFunc: func() ed25519.PrivateKey { return privateKey },
+			GetPublicKeyFunc:  func() ed25519.PublicKey { return publicKey },
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		signedURL := sign("http://example.com/", Claims{ClaimPurpose: "download"})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should verify EdDSA tokens once per request", func(t *testing.T) {
+		publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+
+		lookups := 0
+		app := fiber.New()
+		app.Use(New(Config{
+			TokenAlgorithm:    TokenAlgorithmEdDSA,
+			GetSigningKeyFunc: func() ed25519.PrivateKey { return privateKey },
+			GetPublicKeyFunc: func() ed25519.PublicKey {
+				lookups++
+				return publicKey
+			},
+			TTLPolicies: map[string]time.Duration{"download": time.Hour},
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(GetRequestID(c))
+		})
+
+		signedURL := sign("http://example.com/", Claims{
+			ClaimExpires:   time.Now().Add(time.Minute).Unix(),
+			ClaimPurpose:   "download",
+			ClaimRequestID: "req-1",
+		})
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, "req-1", string(body))
+		utils.AssertEqual(t, 1, lookups)
+	})
+
+	t.Run("it should not panic on invalid EdDSA keys", func(t *testing.T) {
+		publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+
+		app := fiber.New()
+		app.Use(New(Config{
+			TokenAlgorithm:    TokenAlgorithmEdDSA,
+			GetSigningKeyFunc: func() ed25519.PrivateKey { return nil },
+			GetPublicKeyFunc:  func() ed25519.PublicKey { return publicKey[:16] },
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, world!")
+		})
+
+		_, err := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), nil)
+		utils.AssertEqual(t, "GetSigningKeyFunc must return a valid Ed25519 private key", err.Error())
+
+		current().GetSigningKeyFunc = func() ed25519.PrivateKey { return privateKey }
+		signedURL := sign("http://example.com/", nil)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "GetPublicKeyFunc must return a valid Ed25519 public key", string(body))
+	})
+
+	t.Run("it should not allow requests to contain protected query param 'token'", func(t *testing.T) {
+		expected := "token is a reserved query parameter when generating signed routes"
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/?token=something", nil)
+		_, err := GetSignedTokenURLFromHTTPRequest(req, nil)
+		utils.AssertEqual(t, expected, err.Error())
+	})
+}
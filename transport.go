@@ -0,0 +1,257 @@
+package signed
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Transport carries the signature params of signed URLs, eg. signature and
+// expires, somewhere other than the query. Requests are verified as if the
+// params were in the query, so the same verification core supports every
+// placement.
+type Transport interface {
+	// Extract returns the params named by keys carried by the request to c,
+	// removing them from anywhere they would otherwise be signed, eg. the
+	// body
+	Extract(c *fiber.Ctx, keys []string) (url.Values, error)
+
+	// Inject places params on r, a request signed without them
+	Inject(r *http.Request, params url.Values) error
+}
+
+// QueryTransport carries signature params in the query
+type QueryTransport struct{}
+
+// Extract implements Transport
+func (QueryTransport) Extract(c *fiber.Ctx, keys []string) (url.Values, error) {
+	return nil, nil
+}
+
+// Inject implements Transport
+func (QueryTransport) Inject(r *http.Request, params url.Values) error {
+	q := urlQuery(r.URL)
+	for k, v := range params {
+		q[k] = v
+	}
+	r.URL.RawQuery = q.Encode()
+
+	return nil
+}
+
+// HeaderTransport carries signature params in request headers, named by
+// Prefix followed by the query key, eg. X-Signed-Signature
+type HeaderTransport struct {
+	// Prefix is prepended to query keys to name headers. Empty means
+	// "X-Signed-".
+	Prefix string
+}
+
+// name returns the header carrying key
+func (t HeaderTransport) name(key string) string {
+	if t.Prefix == "" {
+		return prefixQueryKey("X-Signed-", key)
+	}
+
+	return t.Prefix + key
+}
+
+// Extract implements Transport
+func (t HeaderTransport) Extract(c *fiber.Ctx, keys []string) (url.Values, error) {
+	params := url.Values{}
+	for _, key := range keys {
+		if value := c.Get(t.name(key)); value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	return params, nil
+}
+
+// Inject implements Transport
+func (t HeaderTransport) Inject(r *http.Request, params url.Values) error {
+	for k, v := range params {
+		r.Header.Set(t.name(k), v[0])
+	}
+
+	return nil
+}
+
+// CookieTransport carries signature params in cookies, named by Prefix
+// followed by the query key, eg. signed_signature
+type CookieTransport struct {
+	// Prefix is prepended to query keys to name cookies. Empty means
+	// "signed_".
+	Prefix string
+}
+
+// name returns the cookie carrying key
+func (t CookieTransport) name(key string) string {
+	if t.Prefix == "" {
+		return "signed_" + key
+	}
+
+	return t.Prefix + key
+}
+
+// Extract implements Transport
+func (t CookieTransport) Extract(c *fiber.Ctx, keys []string) (url.Values, error) {
+	params := url.Values{}
+	for _, key := range keys {
+		if value := c.Cookies(t.name(key)); value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	return params, nil
+}
+
+// Inject implements Transport
+func (t CookieTransport) Inject(r *http.Request, params url.Values) error {
+	for k, v := range params {
+		r.AddCookie(&http.Cookie{Name: t.name(k), Value: v[0]})
+	}
+
+	return nil
+}
+
+// FormTransport carries signature params as fields appended to
+// application/x-www-form-urlencoded bodies. The body is signed without them,
+// so they are removed from it before it is verified.
+type FormTransport struct{}
+
+// Extract implements Transport
+func (FormTransport) Extract(c *fiber.Ctx, keys []string) (url.Values, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		return nil, nil
+	}
+
+	params := url.Values{}
+	var rest []string
+	for _, pair := range strings.Split(string(c.Body()), "&") {
+		k, v := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			k, v = pair[:i], pair[i+1:]
+		}
+		key, err := url.QueryUnescape(k)
+		if err == nil && containsString(keys, key) {
+			if value, err := url.QueryUnescape(v); err == nil {
+				params.Set(key, value)
+				continue
+			}
+		}
+		rest = append(rest, pair)
+	}
+	c.Request().SetBodyString(strings.Join(rest, "&"))
+
+	return params, nil
+}
+
+// Inject implements Transport
+func (FormTransport) Inject(r *http.Request, params url.Values) error {
+	if !strings.HasPrefix(r.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		return errors.New("form transport requires a form body")
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return err
+		}
+	}
+	if len(body) > 0 {
+		body = append(body, '&')
+	}
+	body = append(body, params.Encode()...)
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set(fiber.HeaderContentLength, strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transportKeys returns the query keys of the params carried by Transport
+func (cfg *instance) transportKeys() []string {
+	return []string{
+		cfg.SignatureQueryKey,
+		cfg.ExpiresQueryKey,
+		cfg.ExpiresInQueryKey,
+		cfg.IssuedQueryKey,
+		cfg.AlgorithmQueryKey,
+		cfg.NonceQueryKey,
+	}
+}
+
+// extractTransportParams moves the signature params carried by Transport into
+// the query of c, so they are verified as if they had been there all along
+func (cfg *instance) extractTransportParams(c *fiber.Ctx) error {
+	params, err := cfg.Transport.Extract(c, cfg.transportKeys())
+	if err != nil || len(params) == 0 {
+		return err
+	}
+
+	// Transports may have rewritten the path, eg. PathTransport
+	uri := c.Request().URI()
+	q, _ := parseQuery(string(uri.QueryString()))
+	for k, v := range params {
+		q[k] = v
+	}
+	c.Request().SetRequestURI(string(uri.PathOriginal()) + "?" + q.Encode())
+
+	return nil
+}
+
+// SignRequest signs r in place, placing its signature params with Transport,
+// eg. for service-to-service requests sending the signature in a header. The
+// body of r is left intact.
+func SignRequest(r *http.Request) error {
+	cfg := instanceFor(r.Context())
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	// The signature goes out in headers, so MaxURLLength doesn't apply
+	if _, err := cfg.signURL(r); err != nil {
+		return err
+	}
+	if r.Body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	q := urlQuery(r.URL)
+	params := url.Values{}
+	for _, key := range cfg.transportKeys() {
+		if v, ok := q[key]; ok {
+			params[key] = v
+			delete(q, key)
+		}
+	}
+	r.URL.RawQuery = q.Encode()
+
+	return cfg.Transport.Inject(r, params)
+}
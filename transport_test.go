@@ -0,0 +1,104 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// toTestRequest copies a request signed with SignRequest into one for app.Test
+func toTestRequest(r *http.Request) *http.Request {
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+
+	req := httptest.NewRequest(r.Method, r.URL.String(), strings.NewReader(string(body)))
+	req.Header = r.Header
+	req.RequestURI = ""
+
+	return req
+}
+
+func TestTransport(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+
+	for _, transport := range []Transport{HeaderTransport{}, CookieTransport{}, FormTransport{}, QueryTransport{}} {
+		// Initalize config
+		app := fiber.New()
+
+		app.Use(New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Transport:         transport,
+		}))
+
+		app.Post("/submit", func(c *fiber.Ctx) error {
+			return c.SendString(c.FormValue("name"))
+		})
+
+		newRequest := func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://example.com/submit?expires=%d", expires), strings.NewReader("name=gopher"))
+			r.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+			return r
+		}
+
+		t.Run(fmt.Sprintf("it should accept requests signed for %T", transport), func(t *testing.T) {
+			r := newRequest()
+			utils.AssertEqual(t, nil, SignRequest(r))
+
+			resp, _ := app.Test(toTestRequest(r))
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			utils.AssertEqual(t, "gopher", string(body))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		})
+
+		t.Run(fmt.Sprintf("it should reject tampered requests for %T", transport), func(t *testing.T) {
+			r := newRequest()
+			utils.AssertEqual(t, nil, SignRequest(r))
+
+			req := toTestRequest(r)
+			req.Body = ioutil.NopCloser(strings.NewReader(strings.Replace(readAllString(req), "gopher", "hacker", 1)))
+			resp, _ := app.Test(req)
+
+			utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		})
+
+		t.Run(fmt.Sprintf("it should still accept params in the query for %T", transport), func(t *testing.T) {
+			r := newRequest()
+			signedURL, _ := GetSignedURLFromHTTPRequest(newRequest())
+			r.URL, _ = r.URL.Parse(signedURL)
+
+			resp, _ := app.Test(toTestRequest(r))
+
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		})
+	}
+
+	t.Run("it should keep signature params out of the url", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Transport:         HeaderTransport{},
+		})
+
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/?q=search&expires=%d", expires), nil)
+		utils.AssertEqual(t, nil, SignRequest(r))
+
+		utils.AssertEqual(t, "q=search", r.URL.RawQuery)
+		utils.AssertEqual(t, fmt.Sprint(expires), r.Header.Get("X-Signed-Expires"))
+		utils.AssertEqual(t, true, r.Header.Get("X-Signed-Signature") != "")
+	})
+}
+
+// readAllString reads the body of r
+func readAllString(r *http.Request) string {
+	b, _ := ioutil.ReadAll(r.Body)
+	return string(b)
+}
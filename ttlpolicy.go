@@ -0,0 +1,99 @@
+package signed
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getTTLPolicy returns the TTL policy of purpose, if any
+func (cfg *instance) getTTLPolicy(purpose string) (time.Duration, bool) {
+	ttl, ok := cfg.TTLPolicies[purpose]
+	return ttl, ok && ttl > 0
+}
+
+// checkTTL returns an error if expires is later than the TTL policy of
+// purpose allows from now
+func checkTTL(purpose string, ttl time.Duration, expires time.Time) error {
+	if expires.Sub(timeNow()) > ttl {
+		return fmt.Errorf("url expiry exceeds the %s TTL policy of purpose %q", ttl, purpose)
+	}
+
+	return nil
+}
+
+// applyTTLPolicy expires the signing params q as governed by the TTL policy
+// of their purpose, returning whether q was changed
+func (cfg *instance) applyTTLPolicy(q url.Values) (bool, error) {
+	purpose := q.Get(cfg.PurposeQueryKey)
+	ttl, ok := cfg.getTTLPolicy(purpose)
+	if !ok {
+		return false, nil
+	}
+
+	expires := q.Get(cfg.ExpiresQueryKey)
+	if expires == "" {
+		q.Set(cfg.ExpiresQueryKey, strconv.FormatInt(timeNow().Add(ttl).Unix(), 10))
+		return true, nil
+	}
+
+	i, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("%s value must be valid integer", cfg.ExpiresQueryKey)
+	}
+
+	return false, checkTTL(purpose, ttl, time.Unix(i, 0))
+}
+
+// applyTokenTTLPolicy expires token claims as governed by the TTL policy of
+// their purpose
+func (cfg *instance) applyTokenTTLPolicy(claims Claims) error {
+	purpose, _ := claims[ClaimPurpose].(string)
+	ttl, ok := cfg.getTTLPolicy(purpose)
+	if !ok {
+		return nil
+	}
+
+	var expires int64
+	switch exp := claims[ClaimExpires].(type) {
+	case nil:
+		claims[ClaimExpires] = timeNow().Add(ttl).Unix()
+		return nil
+	case int:
+		expires = int64(exp)
+	case int64:
+		expires = exp
+	case float64:
+		expires = int64(exp)
+	default:
+		return fmt.Errorf("%s claim must be valid integer", ClaimExpires)
+	}
+
+	return checkTTL(purpose, ttl, time.Unix(expires, 0))
+}
+
+// checkTTLPolicy rejects requests for URLs expiring later than the TTL policy
+// of their purpose allows, or not expiring at all
+func (cfg *instance) checkTTLPolicy(c *fiber.Ctx) error {
+	purpose := cfg.getClaim(c, ClaimPurpose)
+	ttl, ok := cfg.getTTLPolicy(purpose)
+	if !ok {
+		return nil
+	}
+
+	var expires time.Time
+	if c.Query(cfg.TokenQueryKey) != "" {
+		expires, _ = cfg.getURLLifetime(c)
+	} else if i, err := strconv.ParseInt(c.Query(cfg.ExpiresQueryKey), 10, 64); err == nil {
+		expires = time.Unix(i, 0)
+	}
+	if expires.IsZero() {
+		return errors.New("url must expire under the TTL policy of its purpose")
+	}
+
+	return checkTTL(purpose, ttl, expires)
+}
@@ -0,0 +1,104 @@
+package signed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestTTLPolicies(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		TTLPolicies: map[string]time.Duration{
+			"download": 10 * time.Minute,
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	expiresIn := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+	}
+
+	t.Run("it should expire URLs after the TTL of their purpose by default", func(t *testing.T) {
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=download", nil))
+		utils.AssertEqual(t, nil, err)
+
+		u, _ := url.Parse(signedURL)
+		expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+		utils.AssertEqual(t, true, time.Until(time.Unix(expires, 0)) <= 10*time.Minute)
+		utils.AssertEqual(t, true, time.Until(time.Unix(expires, 0)) > 9*time.Minute)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should refuse to sign URLs outliving the TTL of their purpose", func(t *testing.T) {
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=download&expires="+expiresIn(time.Hour), nil))
+
+		utils.AssertEqual(t, `url expiry exceeds the 10m0s TTL policy of purpose "download"`, err.Error())
+	})
+
+	t.Run("it should leave purposes without a policy alone", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=share", nil))
+
+		u, _ := url.Parse(signedURL)
+		utils.AssertEqual(t, "", u.Query().Get("expires"))
+	})
+
+	t.Run("it should leave app params named purpose alone under a prefix", func(t *testing.T) {
+		current().PurposeQueryKey = "X-Sig-Purpose"
+		defer func() { current().PurposeQueryKey = "purpose" }()
+
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=download", nil))
+
+		u, _ := url.Parse(signedURL)
+		utils.AssertEqual(t, "", u.Query().Get("expires"))
+	})
+
+	t.Run("it should reject URLs outliving the TTL of their purpose", func(t *testing.T) {
+		// Sign without the policy, eg. from an instance configured before it
+		current().TTLPolicies = nil
+		longURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=download&expires="+expiresIn(time.Hour), nil))
+		foreverURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?purpose=download", nil))
+		current().TTLPolicies = map[string]time.Duration{"download": 10 * time.Minute}
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, longURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, `url expiry exceeds the 10m0s TTL policy of purpose "download"`, string(body))
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, foreverURL))
+		body, _ = ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "url must expire under the TTL policy of its purpose", string(body))
+	})
+
+	t.Run("it should govern token claims too", func(t *testing.T) {
+		_, err := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), Claims{
+			ClaimPurpose: "download",
+			ClaimExpires: time.Now().Add(time.Hour).Unix(),
+		})
+		utils.AssertEqual(t, `url expiry exceeds the 10m0s TTL policy of purpose "download"`, err.Error())
+
+		signedURL, err := GetSignedTokenURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), Claims{
+			ClaimPurpose: "download",
+		})
+		utils.AssertEqual(t, nil, err)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
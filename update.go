@@ -0,0 +1,100 @@
+package signed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CurrentConfig returns a snapshot of the config of the middleware, with
+// defaults applied
+func CurrentConfig() Config {
+	return current().Config
+}
+
+// UpdateConfig validates config and swaps it in for the config of New, eg.
+// to rotate keys, change expiry policies or skip lists from a control plane
+// without restarting. The config is swapped atomically: requests being
+// verified and URLs being signed finish with the config they started with,
+// and later ones use the new config. The config is defaulted like the config
+// of New, so fields left unset go back to their defaults. State tracked for
+// the previous config, eg. revocation cutoffs, use counts and analytics, is
+// kept, except for cached keys and circuit breakers.
+func UpdateConfig(config Config) error {
+	next := configDefault(config)
+	if err := validateConfig(next); err != nil {
+		return err
+	}
+
+	// Warm the key of the new config before it is swapped in
+	cfg := newInstance(next)
+
+	prev := swap(cfg, func(old *instance) {
+		cfg.keyAges, cfg.abuse, cfg.migrations, cfg.analytics = old.keyAges, old.abuse, old.migrations, old.analytics
+		cfg.revokedBefore, cfg.mints = old.revokedBefore, old.mints
+	})
+	prev.keys.reset()
+
+	return nil
+}
+
+// validateConfig returns an error if config can't be used to sign or verify
+// URLs
+func validateConfig(config Config) error {
+	switch config.Algorithm {
+	case AlgorithmSHA1, AlgorithmSHA256, AlgorithmMD5, AlgorithmHMACSHA256:
+	default:
+		return fmt.Errorf("unknown algorithm %q", config.Algorithm)
+	}
+
+	switch config.PreviousAlgorithm {
+	case "", AlgorithmSHA1, AlgorithmSHA256, AlgorithmMD5, AlgorithmHMACSHA256:
+	default:
+		return fmt.Errorf("unknown previous algorithm %q", config.PreviousAlgorithm)
+	}
+
+	switch config.TokenAlgorithm {
+	case TokenAlgorithmHS256, TokenAlgorithmEdDSA:
+	default:
+		return fmt.Errorf("unknown token algorithm %q", config.TokenAlgorithm)
+	}
+
+	if config.MultiValuePolicy < MultiValueSort || config.MultiValuePolicy > MultiValueReject {
+		return errors.New("unknown multi-value policy")
+	}
+	if config.GetHeadBodyPolicy < BodyHash || config.GetHeadBodyPolicy > BodyReject {
+		return errors.New("unknown GET and HEAD body policy")
+	}
+
+	if config.RequireNonce && config.ReplayCache == nil && config.Storage == nil {
+		return errors.New("RequireNonce needs ReplayCache or Storage to remember nonces")
+	}
+
+	if config.KeyTimeout < 0 || config.StorageTimeout < 0 || config.KeyCacheTTL < 0 || config.KeyRotationGrace < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+
+	// Make sure the new key can be loaded before requests depend on it
+	var privateKey string
+	err := callWithTimeout(config.KeyTimeout, func() error {
+		if config.GetPrivateKeyContextFunc == nil {
+			privateKey = config.GetPrivateKeyFunc()
+			return nil
+		}
+
+		var err error
+		privateKey, err = config.GetPrivateKeyContextFunc(context.Background())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %w", err)
+	}
+	if privateKey == "" {
+		return errors.New("private key must not be empty")
+	}
+	if config.GetPepperFunc != nil && config.GetPepperFunc() == "" {
+		return errors.New("pepper must not be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "old" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	})
+
+	oldURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	t.Run("it should reject invalid configs", func(t *testing.T) {
+		err := UpdateConfig(Config{
+			GetPrivateKeyFunc: func() string { return "new" },
+			Algorithm:         "SHA-3",
+		})
+		utils.AssertEqual(t, `unknown algorithm "SHA-3"`, err.Error())
+
+		err = UpdateConfig(Config{
+			GetPrivateKeyFunc: func() string { return "" },
+		})
+		utils.AssertEqual(t, "private key must not be empty", err.Error())
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, oldURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should swap keys and skip lists", func(t *testing.T) {
+		err := UpdateConfig(Config{
+			GetPrivateKeyFunc: func() string { return "new" },
+			Next: func(c *fiber.Ctx) bool {
+				return c.Path() == "/health"
+			},
+		})
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "new", CurrentConfig().GetPrivateKeyFunc())
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, oldURL))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+
+		newURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		resp, _ = app.Test(newTestRequest(http.MethodGet, newURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(newTestRequest(http.MethodGet, "/health"))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should swap while requests are verified", func(t *testing.T) {
+		newURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = app.Test(newTestRequest(http.MethodGet, newURL))
+			}()
+		}
+		for i := 0; i < 10; i++ {
+			_ = UpdateConfig(Config{
+				GetPrivateKeyFunc: func() string { return "new" },
+			})
+		}
+		wg.Wait()
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, newURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+	t.Run("it should swap while urls are signed", func(t *testing.T) {
+		var wg sync.WaitGroup
+		signedURLs := make(chan string, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+				utils.AssertEqual(t, nil, err)
+				signedURLs <- signedURL
+			}()
+		}
+		for i := 0; i < 10; i++ {
+			_ = UpdateConfig(Config{
+				GetPrivateKeyFunc: func() string { return "new" },
+			})
+		}
+		wg.Wait()
+		close(signedURLs)
+
+		for signedURL := range signedURLs {
+			resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package signed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// ErrURLTooLong is returned by GetSignedURLFromHTTPRequest when the signed URL
+// exceeds MaxURLLength and no fallback in URLLengthFallbacks fits it
+var ErrURLTooLong = errors.New("signed URL exceeds MaxURLLength")
+
+// URLLengthFallback is a way of signing URLs tried, in the order of
+// URLLengthFallbacks, when signed URLs exceed MaxURLLength
+type URLLengthFallback int
+
+// URLLengthFallback values
+const (
+	// FallbackToken signs a token URL, moving the expiry and the purpose,
+	// user and request ID claims out of the query into the token. It is
+	// skipped for URLs relying on params tokens don't enforce.
+	FallbackToken URLLengthFallback = iota
+
+	// FallbackShortURL stores the signed URL and returns a short URL
+	// resolving to it. It is skipped unless ShortURLs and Storage are set,
+	// and for template URLs.
+	FallbackShortURL
+)
+
+// defaultURLLengthFallbacks are tried when URLLengthFallbacks is nil
+var defaultURLLengthFallbacks = []URLLengthFallback{FallbackToken, FallbackShortURL}
+
+// deferredSigning holds the side effects of signing a URL while
+// signURLWithinBudget tries fallbacks, so only the URL handed out is counted
+// against MintLimit and reported as EventSigned
+type deferredSigning struct {
+	mintKey *string
+	event   *Event
+}
+
+// deferredSigningKey is the context key of the deferredSigning of a request
+type deferredSigningKey struct{}
+
+// getDeferredSigning returns the deferredSigning ctx carries, if any
+func getDeferredSigning(ctx context.Context) *deferredSigning {
+	d, _ := ctx.Value(deferredSigningKey{}).(*deferredSigning)
+	return d
+}
+
+// emitSigned emits e, an EventSigned for a URL signed with ctx, or defers it
+// to signURLWithinBudget
+func emitSigned(ctx context.Context, e Event) {
+	if d := getDeferredSigning(ctx); d != nil {
+		d.event = &e
+		return
+	}
+
+	events.emit(e)
+}
+
+// signURLWithinBudget signs r, falling back as configured when the signed URL
+// is longer than MaxURLLength. Each attempt defers its side effects, which
+// are applied once for the URL handed out.
+func (cfg *instance) signURLWithinBudget(r *http.Request) (string, error) {
+	d := &deferredSigning{}
+	r = r.WithContext(context.WithValue(r.Context(), deferredSigningKey{}, d))
+
+	// Keep the request as it was to sign it again for each fallback
+	original := *r.URL
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return "", err
+		}
+	}
+	restore := func() {
+		*r.URL = original
+		if r.Body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		*d = deferredSigning{}
+	}
+
+	restore()
+	signedURL, err := cfg.signURL(r)
+	if err != nil {
+		return "", err
+	}
+	if len(signedURL) <= cfg.MaxURLLength {
+		return cfg.commitSigning(r.Context(), d, signedURL)
+	}
+
+	fallbacks := cfg.URLLengthFallbacks
+	if fallbacks == nil {
+		fallbacks = defaultURLLengthFallbacks
+	}
+	for _, fallback := range fallbacks {
+		restore()
+
+		var ok bool
+		switch fallback {
+		case FallbackToken:
+			signedURL, ok, err = cfg.signCompactTokenURL(r)
+		case FallbackShortURL:
+			// Placeholders of template URLs can't be substituted into short URLs
+			if _, template := urlQuery(r.URL)[cfg.TemplateQueryKey]; cfg.ShortURLs && cfg.Storage != nil && !template {
+				signedURL, err = GetShortSignedURLFromHTTPRequest(r)
+				ok = true
+			}
+		default:
+			return "", fmt.Errorf("unknown url length fallback %d", fallback)
+		}
+		if err != nil {
+			return "", err
+		}
+		if ok && len(signedURL) <= cfg.MaxURLLength {
+			return cfg.commitSigning(r.Context(), d, signedURL)
+		}
+	}
+
+	return "", ErrURLTooLong
+}
+
+// commitSigning applies the side effects d deferred for signedURL, the URL
+// handed out
+func (cfg *instance) commitSigning(ctx context.Context, d *deferredSigning, signedURL string) (string, error) {
+	if d.mintKey != nil {
+		if err := cfg.countMint(ctx, *d.mintKey); err != nil {
+			return "", err
+		}
+	}
+	if d.event != nil {
+		events.emit(*d.event)
+	}
+
+	return signedURL, nil
+}
+
+// signCompactTokenURL signs r as a token URL, moving the expiry and claims of
+// its query into the token, and returns false if r relies on params token
+// URLs don't enforce
+func (cfg *instance) signCompactTokenURL(r *http.Request) (string, bool, error) {
+	if cfg.MaxAge > 0 || cfg.ReplayWindow > 0 || len(cfg.ClaimValidators) > 0 {
+		return "", false, nil
+	}
+
+	q := urlQuery(r.URL)
+	keys := []string{
+		cfg.ExpiresInQueryKey,
+		cfg.PolicyQueryKey,
+		cfg.TemplateQueryKey,
+		cfg.CaveatQueryKey,
+		cfg.DelegationQueryKey,
+	}
+	for _, p := range cfg.ExpiryParams {
+		keys = append(keys, p.Key)
+	}
+	for _, key := range keys {
+		if _, ok := q[key]; ok {
+			return "", false, nil
+		}
+	}
+
+	claims := Claims{}
+	if expires, ok := q[cfg.ExpiresQueryKey]; ok {
+		i, err := strconv.ParseInt(q.Get(cfg.ExpiresQueryKey), 10, 64)
+		if err != nil || len(expires) > 1 {
+			return "", false, fmt.Errorf("%s value must be valid integer", cfg.ExpiresQueryKey)
+		}
+		claims[ClaimExpires] = i
+		q.Del(cfg.ExpiresQueryKey)
+	}
+	for _, name := range []string{ClaimPurpose, ClaimUser, ClaimRequestID} {
+		key := cfg.getClaimQueryKey(name)
+		if values := q[key]; len(values) == 1 {
+			claims[name] = values[0]
+			q.Del(key)
+		}
+	}
+	r.URL.RawQuery = q.Encode()
+
+	signedURL, err := GetSignedTokenURLFromHTTPRequest(r, claims)
+	if err != nil {
+		return "", false, err
+	}
+
+	return signedURL, true, nil
+}
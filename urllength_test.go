@@ -0,0 +1,156 @@
+package signed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMaxURLLength(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+	app.Get("/downloads/:file", func(c *fiber.Ctx) error {
+		return c.SendString(current().getClaim(c, ClaimPurpose))
+	})
+
+	// Escaped in query params, purposes like this are far longer than in
+	// tokens
+	purpose := strings.Repeat("é", 100)
+	target := fmt.Sprintf("http://example.com/downloads/report.pdf?purpose=%s&expires=%d", url.QueryEscape(purpose), time.Now().Add(time.Hour).Unix())
+
+	t.Run("it should sign urls within the limit as usual", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxURLLength:      1000,
+		})
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+
+		u, _ := url.Parse(signedURL)
+		utils.AssertEqual(t, purpose, u.Query().Get(ClaimPurpose))
+		utils.AssertEqual(t, "", u.Query().Get("token"))
+	})
+
+	t.Run("it should fall back to a token url", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxURLLength:      600,
+		})
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, len(signedURL) <= 600)
+
+		u, _ := url.Parse(signedURL)
+		utils.AssertEqual(t, "", u.Query().Get(ClaimPurpose))
+		utils.AssertEqual(t, "", u.Query().Get("expires"))
+		utils.AssertEqual(t, true, u.Query().Get("token") != "")
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, purpose, string(body))
+	})
+
+	t.Run("it should fall back to a short url", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+			ShortURLs:         true,
+			MaxURLLength:      100,
+		})
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.HasPrefix(signedURL, "http://example.com/r/"))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, purpose, string(body))
+	})
+
+	t.Run("it should skip the token fallback for params tokens don't enforce", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			Storage:           newTestStorage(),
+			ShortURLs:         true,
+			MaxURLLength:      600,
+		})
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target+"&expiresIn=60&issued=1", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.HasPrefix(signedURL, "http://example.com/r/"))
+	})
+
+	t.Run("it should try fallbacks in the configured order", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc:  func() string { return "secret" },
+			Storage:            newTestStorage(),
+			ShortURLs:          true,
+			MaxURLLength:       500,
+			URLLengthFallbacks: []URLLengthFallback{FallbackShortURL, FallbackToken},
+		})
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.HasPrefix(signedURL, "http://example.com/r/"))
+	})
+
+	t.Run("it should count and report only the url handed out", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxURLLength:      600,
+			MintLimit:         1,
+		})
+
+		var signed []string
+		unsubscribe := Subscribe(func(e Event) {
+			if e.Type == EventSigned {
+				signed = append(signed, e.URL)
+			}
+		})
+		defer unsubscribe()
+
+		signedURL, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, []string{signedURL}, signed)
+
+		_, err = GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, ErrMintLimitExceeded, err)
+	})
+
+	t.Run("it should refuse urls no fallback fits", func(t *testing.T) {
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxURLLength:      100,
+		})
+
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, ErrURLTooLong, err)
+
+		New(Config{
+			GetPrivateKeyFunc:  func() string { return "secret" },
+			Storage:            newTestStorage(),
+			MaxURLLength:       500,
+			URLLengthFallbacks: []URLLengthFallback{},
+		})
+
+		_, err = GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, ErrURLTooLong, err)
+	})
+}
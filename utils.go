@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"hash"
@@ -13,27 +14,44 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bsandusky/fiber-signed/hostname"
+	"github.com/bsandusky/fiber-signed/internal/canon"
 	"github.com/gofiber/fiber/v2"
 )
 
-// getHash returns a hashed string based on the algorithm set in the config
-func getHash(hashString string) string {
+// errInvalidSignature rejects requests whose signature doesn't match the one
+// calculated for them
+var errInvalidSignature = errors.New("invalid signature")
+
+// getHashFunc returns the hash constructor for the algorithm set in the config
+func (cfg *instance) getHashFunc() func() hash.Hash {
+	return getHashFuncFor(cfg.Algorithm)
+}
 
-	// Get appropriate hash function from config
-	var hash hash.Hash
-	switch cfg.Algorithm {
+// getHashFuncFor returns the hash constructor for alg
+func getHashFuncFor(alg Algorithm) func() hash.Hash {
+	switch alg {
 	case AlgorithmSHA1:
-		hash = sha1.New()
-		break
-	case AlgorithmSHA256:
-		hash = sha256.New()
-		break
+		return sha1.New
+	case AlgorithmSHA256, AlgorithmHMACSHA256:
+		return sha256.New
 	case AlgorithmMD5:
-		hash = md5.New()
-		break
+		return md5.New
 	default:
-		hash = sha1.New()
+		return sha1.New
 	}
+}
+
+// getHash returns a hashed string based on the algorithm set in the config
+func (cfg *instance) getHash(hashString string) string {
+	return getHashFor(cfg.Algorithm, hashString)
+}
+
+// getHashFor returns a hashed string based on alg
+func getHashFor(alg Algorithm, hashString string) string {
+
+	// Get appropriate hash function for the algorithm
+	hash := getHashFuncFor(alg)()
 
 	// Run hash function
 	hash.Write([]byte(hashString))
@@ -42,76 +60,208 @@ func getHash(hashString string) string {
 	return fmt.Sprintf("%x", hash.Sum(nil))
 }
 
-// orderQueryParams alphatically reorders query params for hashing purposes
-func orderQueryParams(q url.Values) string {
+// orderQueryParams alphatically reorders query params for hashing purposes,
+// or encodes them with QueryEncoderFunc if set
+func (cfg *instance) orderQueryParams(q url.Values) string {
+	return cfg.canonParams(cfg.Algorithm).Query(q)
+}
+
+// canonParams returns the params of canonical strings and signatures computed
+// with alg, shared with the sign and verify packages so they can't drift
+func (cfg *instance) canonParams(alg Algorithm) canon.Params {
+	return canon.Params{
+		Algorithm:          string(alg),
+		SignatureBits:      cfg.SignatureBits,
+		PreserveValueOrder: cfg.MultiValuePolicy == MultiValuePreserve,
+		ForceScheme:        cfg.ForceScheme,
+		QueryEncoder:       cfg.QueryEncoderFunc,
+		SignatureQueryKey:  cfg.SignatureQueryKey,
+		CaveatQueryKey:     cfg.CaveatQueryKey,
+		TokenQueryKey:      cfg.TokenQueryKey,
+		BodyHashQueryKey:   cfg.BodyHashQueryKey,
+	}
+}
+
+// checkRepeatedParams rejects query params repeated in rawQuery under
+// MultiValueReject, except those owned by the middleware which may repeat
+func (cfg *instance) checkRepeatedParams(rawQuery string) error {
+	if cfg.MultiValuePolicy != MultiValueReject {
+		return nil
+	}
 
+	q, _ := parseQuery(rawQuery)
 	var keys []string
-	for k := range q {
-		if k == cfg.SignatureQueryKey {
-			continue // ignore signature query param when reconstructing query string for hashing
+	for k, v := range q {
+		if len(v) > 1 && k != cfg.SignatureQueryKey && k != cfg.CaveatQueryKey && k != cfg.DelegationQueryKey {
+			keys = append(keys, k)
 		}
-		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil
 	}
 	sort.Strings(keys)
 
-	var ordered []string
-	for _, key := range keys {
-		sort.Strings(q[key])
-		for _, val := range q[key] {
-			ordered = append(ordered, fmt.Sprintf("%s=%s", key, val))
+	return fmt.Errorf("%s must not be repeated in a signed URL", strings.Join(keys, ", "))
+}
+
+// matchWildcard reports whether s matches pattern, where "*" in pattern
+// matches any sequence of characters
+func matchWildcard(pattern, s string) bool {
+
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	// First and last segments are anchored to the start and end of s
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
 		}
+		s = s[i+len(part):]
 	}
-	joined := strings.Join(ordered, "&")
 
-	return joined
+	return strings.HasSuffix(s, parts[len(parts)-1])
 }
 
-// getSignature takes prepared paramters and returns hashed signature
-func getSignature(method, baseURL, originalURL string, body []byte) (string, error) {
+// getSignatureWithKey takes prepared paramters and returns hashed signature
+// calculated with the given private key, bound to the BindLocal value binding
+// if not empty
+func (cfg *instance) getSignatureWithKey(privateKey, binding, method, baseURL, originalURL string, body []byte) (string, error) {
+	return cfg.getSignatureFor(cfg.Algorithm, privateKey, binding, method, baseURL, originalURL, body)
+}
 
-	// Parse full request URL
-	parsed, err := url.ParseRequestURI(fmt.Sprintf("%s%s", baseURL, originalURL))
+// getSignatureFor is getSignatureWithKey with alg rather than the algorithm
+// set in the config
+func (cfg *instance) getSignatureFor(alg Algorithm, privateKey, binding, method, baseURL, originalURL string, body []byte) (string, error) {
+
+	hashString, err := cfg.getSigningStringFor(alg, privateKey, binding, method, baseURL, originalURL, body)
 	if err != nil {
-		return "", errors.New("cannot parse provided URL")
+		return "", err
 	}
 
-	// Add trailing slash to / if not alredy present
-	if len(parsed.Path) < 1 {
-		parsed.Path = fmt.Sprintf("%s/", parsed.Path)
+	// HMAC keys the hash with the private key rather than hashing it along
+	// with the canonical string
+	params := cfg.canonParams(alg)
+	params.PrivateKey = privateKey
+
+	return params.Sign(hashString), nil
+}
+
+// minSignatureBits is the shortest SignatureBits may truncate signatures to
+const minSignatureBits = 64
+
+// encodeSignature encodes a signature hex, or base64url encoded and
+// truncated to SignatureBits when set
+func (cfg *instance) encodeSignature(sum []byte) string {
+	if cfg.SignatureBits <= 0 {
+		return fmt.Sprintf("%x", sum)
 	}
 
-	// Get existing query params
-	var q url.Values
-	if strings.Contains(originalURL, "?") {
-		split := strings.Split(originalURL, "?")
-		q, _ = url.ParseQuery(split[1])
-	} else {
-		q, _ = url.ParseQuery(originalURL)
+	n := (cfg.SignatureBits + 7) / 8
+	if n < len(sum) {
+		sum = sum[:n]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sum)
+}
+
+// getSigningString returns the canonical string hashed to produce signatures
+// with the given private key and binding
+func (cfg *instance) getSigningString(privateKey, binding, method, baseURL, originalURL string, body []byte) (string, error) {
+	return cfg.getSigningStringFor(cfg.Algorithm, privateKey, binding, method, baseURL, originalURL, body)
+}
+
+// getSigningStringFor is getSigningString with alg rather than the algorithm
+// set in the config
+func (cfg *instance) getSigningStringFor(alg Algorithm, privateKey, binding, method, baseURL, originalURL string, body []byte) (string, error) {
+
+	// Add privateKey query param for use in calculating signature, unless
+	// it keys an HMAC instead
+	params := cfg.canonParams(alg)
+	params.PrivateKey, params.PrivateKeyQueryKey = privateKey, cfg.PrivateKeyQueryKey
+	if cfg.BindLocal != "" && binding != "" {
+		params.Extra = url.Values{cfg.BindLocal: []string{binding}}
 	}
 
-	// Add privateKey query param for use in calculating signature
-	privateKey := cfg.GetPrivateKeyFunc()
-	q.Set(cfg.PrivateKeyQueryKey, privateKey)
+	return cfg.canonicalString(params, method, baseURL, originalURL, body)
+}
+
+// NormalizeHost returns host, with any port, in the form it takes in the
+// string signatures are computed over: without userinfo, lowercased, with IPv6
+// literals in their shortest form and IDN labels in punycode. See the hostname
+// package, which clients can use to match it without depending on fiber.
+func NormalizeHost(host string) (string, error) {
+	return hostname.Normalize(host)
+}
+
+// getCanonicalString takes prepared paramters and returns the string which is
+// hashed to produce signatures, with extra params merged into the query
+func (cfg *instance) getCanonicalString(method, baseURL, originalURL string, body []byte, extra url.Values) (string, error) {
+	params := cfg.canonParams(cfg.Algorithm)
+	params.Extra = extra
+
+	return cfg.canonicalString(params, method, baseURL, originalURL, body)
+}
 
-	// Hash body if present in request
-	if len(body) > 0 {
-		bodyHash := getHash(string(body))
-		q.Set(cfg.BodyHashQueryKey, bodyHash)
+// canonicalString returns the string hashed to produce signatures with params
+// of a request to originalURL on baseURL, built by the canon package
+func (cfg *instance) canonicalString(params canon.Params, method, baseURL, originalURL string, body []byte) (string, error) {
+	scheme, host := baseURL, ""
+	if split := strings.Index(baseURL, "://"); split >= 0 {
+		scheme, host = baseURL[:split], baseURL[split+len("://"):]
 	}
 
-	// Order query params alphabetically
-	params := orderQueryParams(q)
+	return params.String(method, scheme, host, originalURL, body)
+}
 
-	// Get hashed signature
-	hashString := fmt.Sprintf("%s&%s://%s%s?%s", method, parsed.Scheme, parsed.Host, parsed.Path, params)
-	hashedSignature := getHash(hashString)
+// getIssued returns the issued time of the request URL, which option requires
+func (cfg *instance) getIssued(c *fiber.Ctx, option string) (time.Time, error) {
+	issued := c.Query(cfg.IssuedQueryKey)
+	if issued == "" {
+		return time.Time{}, fmt.Errorf("%s is a required query param when %s is set", cfg.IssuedQueryKey, option)
+	}
+	i, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s value must be valid integer", cfg.IssuedQueryKey)
+	}
 
-	return hashedSignature, nil
+	return time.Unix(i, 0), nil
 }
 
 // validateRequest handles middleware layer from fiber handlers to confirm
 // signatures match calculated values
-func validateRequest(c *fiber.Ctx) (bool, error) {
+func (cfg *instance) validateRequest(c *fiber.Ctx) (bool, error) {
+
+	// Reject plain HTTP requests if required
+	if cfg.RequireHTTPS && c.Protocol() != "https" {
+		return false, errors.New("signed URLs must be requested over https")
+	}
+
+	// Let infrastructure probes through on their probe token alone
+	if cfg.AllowProbes {
+		if token := cfg.getProbeToken(c); token != "" {
+			return cfg.validateProbe(c, token)
+		}
+	}
+
+	// Validate token mode requests on their claims instead
+	if token := c.Query(cfg.TokenQueryKey); token != "" {
+		return cfg.validateToken(c, token)
+	}
+
+	// Validate links generated by nginx on their secure_link_md5 hash instead
+	if cfg.NginxSecureLinkMD5 != "" {
+		if hash := c.Query(cfg.NginxMD5QueryKey); hash != "" {
+			return cfg.validateNginxSecureLink(c, hash)
+		}
+	}
 
 	// Check for existence of 'signature' query param in request
 	signature := c.Query(cfg.SignatureQueryKey)
@@ -119,32 +269,227 @@ func validateRequest(c *fiber.Ctx) (bool, error) {
 		return false, fmt.Errorf("%s is a required query param for a signed URL route", cfg.SignatureQueryKey)
 	}
 
-	// Check for existence of 'expires' query param in request and determine if
-	// url has passed expiration
-	expires := c.Query(cfg.ExpiresQueryKey)
-	if expires != "" {
-		i, err := strconv.ParseInt(expires, 10, 64)
+	// Reject repeated params if their order can't be trusted
+	if err := cfg.checkRepeatedParams(string(c.Request().URI().QueryString())); err != nil {
+		return false, err
+	}
+
+	// Determine if url has passed any of its expiries
+	var expired error
+	if err := cfg.checkQueryExpiry(c); err == errExpired {
+		expired = err
+	} else if err != nil {
+		return false, err
+	}
+
+	// Reject expired URLs once their signature is checked if they expired
+	// within ClockSkewThreshold, to tell clock skew from genuine expiry
+	var skew time.Duration
+	if expired != nil {
+		when, _ := cfg.getEarliestExpiry(ctxQuery(c))
+		var ok bool
+		if skew, ok = cfg.getClockSkew(when); !ok {
+			return false, expired
+		}
+	}
+
+	// Reject URLs issued too long ago, regardless of their expiry
+	if cfg.MaxAge > 0 {
+		issued, err := cfg.getIssued(c, "MaxAge")
+		if err != nil {
+			return false, err
+		}
+		if timeNow().Sub(issued) > cfg.MaxAge {
+			return false, errors.New("url signature is older than the maximum age")
+		}
+	}
+
+	// Reject requests signed outside the replay window, in either direction
+	if cfg.ReplayWindow > 0 {
+		issued, err := cfg.getIssued(c, "ReplayWindow")
 		if err != nil {
-			return false, fmt.Errorf("%s value must be valid integer", cfg.ExpiresQueryKey)
+			return false, err
+		}
+		if age := timeNow().Sub(issued); age > cfg.ReplayWindow || age < -cfg.ReplayWindow {
+			return false, errors.New("url signature is outside the replay window")
 		}
-		when := time.Unix(i, 0)
-		if when.Before(time.Now()) {
-			return false, errors.New("url signature has expired")
+	}
+
+	var caveats []string
+	for _, caveat := range c.Context().QueryArgs().PeekMulti(cfg.CaveatQueryKey) {
+		caveats = append(caveats, string(caveat))
+	}
+
+	// Verify the signature, reusing the result for retries of a request
+	// verified moments ago
+	delegations, err := cfg.verifySignatureCached(c, signature, caveats)
+	if expired != nil {
+		if err == nil {
+			cfg.reportClockSkew(c, skew)
 		}
+		return false, expired
+	}
+	if err != nil {
+		return false, err
 	}
 
+	// Enforce delegation constraints once the chain is known to be intact
+	for _, d := range delegations {
+		if err := validateDelegation(c, d); err != nil {
+			return false, err
+		}
+	}
+
+	// Enforce caveat conditions once the chain is known to be intact
+	for _, caveat := range caveats {
+		if err := validateCaveat(c, caveat); err != nil {
+			return false, err
+		}
+	}
+
+	// Enforce policy document conditions if present
+	if encoded := c.Query(cfg.PolicyQueryKey); encoded != "" {
+		policy, err := cfg.decodePolicy(encoded)
+		if err != nil {
+			return false, err
+		}
+		if err := cfg.validatePolicy(c, policy); err != nil {
+			return false, err
+		}
+	}
+
+	// Run application specific checks on the now trusted params
+	if err := cfg.validateClaims(c, cfg.getQueryClaims(c)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// verifySignature verifies the signature of the request, or its
+// co-signatures, returning the delegations the signing key was derived
+// through
+func (cfg *instance) verifySignature(c *fiber.Ctx, signature string, caveats []string) ([]Delegation, error) {
 	method := c.Method()
 	baseURL := c.BaseURL()
 	originalURL := c.OriginalURL()
-	body := c.Body()
+	body, err := cfg.signedBody(c.Method(), ctxHeader(c), c.Body(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify template URLs against the template they were signed for
+	if encoded := c.Query(cfg.TemplateQueryKey); encoded != "" {
+		if originalURL, err = cfg.getTemplateURL(c, encoded, originalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.RequiredSignatures > 0 {
+		// Co-signed URLs carry one signature per key rather than a chain
+		if err := cfg.validateCoSignatures(c, method, baseURL, originalURL, body); err != nil {
+			return nil, err
+		}
+	} else {
+		// Derive the signing key through any delegation grants
+		var grants []string
+		for _, grant := range c.Context().QueryArgs().PeekMulti(cfg.DelegationQueryKey) {
+			grants = append(grants, string(grant))
+		}
+		rootKey, err := cfg.getPrivateKey(requestContext(c))
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		// Bind the signature to the authenticated user if configured, while
+		// URLs signed without a binding stay valid for them
+		bound, err := cfg.getBinding(c)
+		if err != nil {
+			return nil, err
+		}
+		bindings := []string{bound}
+		if bound != "" {
+			bindings = append(bindings, "")
+		}
 
-	// Get hashed signture from context
-	hashedSignature, _ := getSignature(method, baseURL, originalURL, body)
+		// Combine the key with the requester's key fragment if escrowed
+		fragment, err := cfg.getEscrowFragment(c)
+		if err != nil {
+			return nil, err
+		}
 
-	// Compare signature given with calculated value
-	if hashedSignature != signature {
-		return false, errors.New("invalid signature")
+		// Use the algorithm named in the URL, if any
+		alg, named, err := cfg.getURLAlgorithm(c)
+		if err != nil {
+			return nil, err
+		}
+
+		// Try the current key and then the previous one, during a rotation
+		// grace period
+		var delegations []Delegation
+		valid := false
+		for _, key := range append([]string{rootKey}, cfg.getPreviousKeys()...) {
+			privateKey, derived, err := cfg.deriveDelegatedKey(key, grants)
+			if err != nil {
+				return nil, err
+			}
+			delegations = derived
+			if fragment != "" {
+				privateKey = getEscrowKey(privateKey, fragment)
+			}
+
+			// Try the request host and then any of its aliases
+			for _, base := range cfg.getAliasBaseURLs(baseURL) {
+				for _, binding := range bindings {
+					// Get hashed signture from context
+					rootSignature, _ := cfg.getSignatureFor(alg, privateKey, binding, method, base, originalURL, body)
+
+					// Chain any caveats appended by URL holders onto the calculated value
+					hashedSignature := cfg.chainCaveatsFor(alg, rootSignature, caveats)
+
+					// Compare signature given with calculated value, falling back to the
+					// previous algorithm while migrating unless the URL named one
+					matched := alg
+					if hashedSignature == signature {
+						cfg.migrations.record(alg != cfg.Algorithm)
+					} else if !named && cfg.matchesPreviousAlgorithm(signature, caveats, privateKey, binding, method, base, originalURL, body) {
+						matched = cfg.PreviousAlgorithm
+						rootSignature, _ = cfg.getSignatureFor(matched, privateKey, binding, method, base, originalURL, body)
+					} else {
+						continue
+					}
+					c.Locals(signatureChainLocal, cfg.getSignatureChain(matched, rootSignature, caveats))
+					setLabels(c, string(matched), func() string { return KeyFingerprint(privateKey) })
+					valid = true
+					break
+				}
+				if valid {
+					break
+				}
+			}
+			if valid {
+				break
+			}
+		}
+		if !valid {
+			return nil, errInvalidSignature
+		}
+
+		return delegations, nil
 	}
 
-	return true, nil
+	return nil, nil
+}
+
+// getResponseSize returns the number of body bytes in the response, using the
+// declared content length for streamed bodies
+func getResponseSize(c *fiber.Ctx) int {
+	if c.Response().IsBodyStream() {
+		if size := c.Response().Header.ContentLength(); size > 0 {
+			return size
+		}
+		return 0
+	}
+
+	return len(c.Response().Body())
 }
@@ -5,10 +5,15 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
 )
 
@@ -22,7 +27,7 @@ func TestGetHash(t *testing.T) {
 		hash.Write([]byte("test string"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got := getHash("test string")
+		got := current().getHash("test string")
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -35,7 +40,7 @@ func TestGetHash(t *testing.T) {
 		hash.Write([]byte("test string"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got := getHash("test string")
+		got := current().getHash("test string")
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -48,7 +53,7 @@ func TestGetHash(t *testing.T) {
 		hash.Write([]byte("test string"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got := getHash("test string")
+		got := current().getHash("test string")
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -61,7 +66,7 @@ func TestGetHash(t *testing.T) {
 		hash.Write([]byte("test string"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got := getHash("test string")
+		got := current().getHash("test string")
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -77,7 +82,7 @@ func TestOrderQueryParams(t *testing.T) {
 		v.Set("b", "456")
 		expected := "a=123&b=456&c=789"
 
-		got := orderQueryParams(v)
+		got := current().orderQueryParams(v)
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -91,10 +96,208 @@ func TestOrderQueryParams(t *testing.T) {
 		v.Set("signature", "something")
 		expected := "a=123&b=456&c=789"
 
-		got := orderQueryParams(v)
+		got := current().orderQueryParams(v)
 
 		utils.AssertEqual(t, expected, got)
 	})
+
+	t.Run("it should sort repeated values", func(t *testing.T) {
+
+		v := url.Values{"a": []string{"2", "1"}}
+		expected := "a=1&a=2"
+
+		got := current().orderQueryParams(v)
+
+		utils.AssertEqual(t, expected, got)
+	})
+
+	t.Run("it should preserve the order of repeated values if configured", func(t *testing.T) {
+		New(Config{MultiValuePolicy: MultiValuePreserve})
+		defer New()
+
+		v := url.Values{"a": []string{"2", "1"}}
+		expected := "a=2&a=1"
+
+		got := current().orderQueryParams(v)
+
+		utils.AssertEqual(t, expected, got)
+	})
+
+	t.Run("it should encode params with QueryEncoderFunc if configured", func(t *testing.T) {
+		var encoded url.Values
+		New(Config{QueryEncoderFunc: func(q url.Values) string {
+			encoded = q
+			return "encoded"
+		}})
+		defer New()
+
+		v := url.Values{"a": []string{"2", "1"}, "signature": []string{"something"}}
+
+		got := current().orderQueryParams(v)
+
+		utils.AssertEqual(t, "encoded", got)
+		utils.AssertEqual(t, url.Values{"a": []string{"2", "1"}}, encoded)
+	})
+
+	t.Run("it should ignore caveat and token query params with QueryEncoderFunc", func(t *testing.T) {
+		var encoded url.Values
+		New(Config{QueryEncoderFunc: func(q url.Values) string {
+			encoded = q
+			return "encoded"
+		}})
+		defer New()
+
+		v := url.Values{"a": []string{"1"}, "caveat": []string{"path:/a"}, "token": []string{"t"}}
+
+		current().orderQueryParams(v)
+
+		utils.AssertEqual(t, url.Values{"a": []string{"1"}}, encoded)
+	})
+}
+
+func TestQueryEncoderFunc(t *testing.T) {
+	// Sign PHP-style array params in the order sent, joined with ";"
+	encodeQuery := func(q url.Values) string {
+		var keys []string
+		for key := range q {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var pairs []string
+		for _, key := range keys {
+			for _, value := range q[key] {
+				pairs = append(pairs, key+"="+value)
+			}
+		}
+		return strings.Join(pairs, ";")
+	}
+
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		QueryEncoderFunc:  encodeQuery,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should hash the query built by QueryEncoderFunc", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?ids[]=2&ids[]=1&b=x", nil))
+
+		canonical, _ := current().getCanonicalString(http.MethodGet, "http://example.com", "/?ids[]=2&ids[]=1&b=x", nil, url.Values{"privateKey": []string{"secret"}})
+		utils.AssertEqual(t, "GET&http://example.com/?b=x;ids[]=2;ids[]=1;privateKey=secret", canonical)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject reordered values the encoder keeps in order", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?ids[]=2&ids[]=1", nil))
+		tampered := strings.Replace(signedURL, "ids%5B%5D=2&ids%5B%5D=1", "ids%5B%5D=1&ids%5B%5D=2", 1)
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, tampered))
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestRepeatedParams(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		MultiValuePolicy:  MultiValueReject,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should not sign urls repeating params", func(t *testing.T) {
+		_, err := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?b=1&a=1&a=2&b=2", nil))
+
+		utils.AssertEqual(t, "a, b must not be repeated in a signed URL", err.Error())
+	})
+
+	t.Run("it should reject urls repeating params", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1", nil))
+
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL+"&a=2"))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "a must not be repeated in a signed URL", string(body))
+	})
+}
+
+func TestNormalizeHost(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	t.Run("it should verify ipv6 literal hosts in any spelling", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://[0:0::1]:3000/", nil))
+
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Host = "[::1]:3000"
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should verify idn hosts signed in unicode", func(t *testing.T) {
+		signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://user:pass@Bücher.example/", nil))
+
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Host = "xn--bcher-kva.example"
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestForceScheme(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ForceScheme:       "HTTPS",
+		RequireHTTPS:      true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	t.Run("it should sign and verify urls with the forced scheme", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, signedURL)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		resp, _ := app.Test(req)
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("it should reject requests not made over https", func(t *testing.T) {
+		resp, _ := app.Test(newTestRequest(http.MethodGet, signedURL))
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, "signed URLs must be requested over https", string(body))
+	})
 }
 
 func TestGetSignature(t *testing.T) {
@@ -107,7 +310,7 @@ func TestGetSignature(t *testing.T) {
 
 		expected := "cannot parse provided URL"
 
-		_, err := getSignature("BAD", "something not a url", "also weird", nil)
+		_, err := current().getSignatureWithKey("secret", "", "BAD", "something not a url", "also weird", nil)
 
 		utils.AssertEqual(t, expected, err.Error())
 	})
@@ -117,7 +320,7 @@ func TestGetSignature(t *testing.T) {
 		hash.Write([]byte("GET&http://127.0.0.1:3000/?privateKey=secret"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got, _ := getSignature(http.MethodGet, "http://127.0.0.1:3000", "", nil)
+		got, _ := current().getSignatureWithKey("secret", "", http.MethodGet, "http://127.0.0.1:3000", "", nil)
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -128,7 +331,7 @@ func TestGetSignature(t *testing.T) {
 		hash.Write([]byte("GET&http://127.0.0.1:3000/signature?privateKey=secret&q=something"))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got, _ := getSignature(http.MethodGet, "http://127.0.0.1:3000", "/signature?q=something", nil)
+		got, _ := current().getSignatureWithKey("secret", "", http.MethodGet, "http://127.0.0.1:3000", "/signature?q=something", nil)
 
 		utils.AssertEqual(t, expected, got)
 	})
@@ -143,8 +346,23 @@ func TestGetSignature(t *testing.T) {
 		hash.Write([]byte(fmt.Sprintf("GET&http://127.0.0.1:3000/?bodyHash=%s&privateKey=secret&q=something", bodyHash)))
 		expected := fmt.Sprintf("%x", hash.Sum(nil))
 
-		got, _ := getSignature(http.MethodGet, "http://127.0.0.1:3000", "/?q=something", []byte("body"))
+		got, _ := current().getSignatureWithKey("secret", "", http.MethodGet, "http://127.0.0.1:3000", "/?q=something", []byte("body"))
 
 		utils.AssertEqual(t, expected, got)
 	})
 }
+
+func TestMatchWildcard(t *testing.T) {
+
+	t.Run("it should match patterns with wildcards", func(t *testing.T) {
+		utils.AssertEqual(t, true, matchWildcard("http://example.com/*", "http://example.com/a/b"))
+		utils.AssertEqual(t, true, matchWildcard("http://*.example.com/*.txt", "http://cdn.example.com/a.txt"))
+		utils.AssertEqual(t, true, matchWildcard("exact", "exact"))
+	})
+
+	t.Run("it should not match differing values", func(t *testing.T) {
+		utils.AssertEqual(t, false, matchWildcard("http://example.com/*", "https://example.com/a"))
+		utils.AssertEqual(t, false, matchWildcard("*.txt", "a.png"))
+		utils.AssertEqual(t, false, matchWildcard("a*a", "a"))
+	})
+}
@@ -0,0 +1,144 @@
+package signed
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxValidationResults bounds the results kept by ValidationCacheTTL, so
+// floods of distinct requests can't exhaust memory
+const maxValidationResults = 10000
+
+// validationResult is a signature verified for a request
+type validationResult struct {
+	expires     time.Time
+	delegations []Delegation
+	labels      interface{}
+	chain       interface{}
+}
+
+// validationCache keeps the signatures verified for requests for
+// ValidationCacheTTL, keyed by the SHA-256 of everything they cover
+type validationCache struct {
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]validationResult
+}
+
+// reset drops the results kept
+func (v *validationCache) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.results = nil
+}
+
+// get returns the result kept for key, if it hasn't expired
+func (v *validationCache) get(key [sha256.Size]byte) (validationResult, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	result, ok := v.results[key]
+	if !ok || !timeNow().Before(result.expires) {
+		return validationResult{}, false
+	}
+
+	return result, true
+}
+
+// add keeps result for key, dropping expired results once the cache is full.
+// Results are not kept while it is still full.
+func (v *validationCache) add(key [sha256.Size]byte, result validationResult) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.results == nil {
+		v.results = make(map[[sha256.Size]byte]validationResult)
+	}
+
+	if len(v.results) >= maxValidationResults {
+		now := timeNow()
+		for k, r := range v.results {
+			if !now.Before(r.expires) {
+				delete(v.results, k)
+			}
+		}
+		if len(v.results) >= maxValidationResults {
+			return
+		}
+	}
+
+	v.results[key] = result
+}
+
+// getValidationKey returns the identity of the request: everything its
+// signature covers, and the client it comes from
+func getValidationKey(c *fiber.Ctx, binding, fragment string) [sha256.Size]byte {
+	h := sha256.New()
+	for _, part := range []string{c.Method(), c.BaseURL(), c.OriginalURL(), c.Get(fiber.HeaderContentType), c.IP(), binding, fragment, c.Get(HeaderContentDigest), c.Get(HeaderReprDigest)} {
+		writeValidationKeyPart(h, []byte(part))
+	}
+	writeValidationKeyPart(h, c.Body())
+
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	return key
+}
+
+// writeValidationKeyPart writes part to h, length-prefixed so parts can't run
+// into each other
+func writeValidationKeyPart(h hash.Hash, part []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(part)))
+	h.Write(length[:])
+	h.Write(part)
+}
+
+// verifySignatureCached verifies the signature of the request like
+// verifySignature, reusing the result for the same request from the same
+// client within ValidationCacheTTL
+func (cfg *instance) verifySignatureCached(c *fiber.Ctx, signature string, caveats []string) ([]Delegation, error) {
+	if cfg.ValidationCacheTTL <= 0 {
+		return cfg.verifySignature(c, signature, caveats)
+	}
+
+	binding, err := cfg.getBinding(c)
+	if err != nil {
+		return nil, err
+	}
+
+	fragment, err := cfg.getEscrowFragment(c)
+	if err != nil {
+		return nil, err
+	}
+
+	key := getValidationKey(c, binding, fragment)
+	if result, ok := cfg.validations.get(key); ok {
+		if result.labels != nil {
+			c.Locals(labelsLocal, result.labels)
+		}
+		if result.chain != nil {
+			c.Locals(signatureChainLocal, result.chain)
+		}
+		return result.delegations, nil
+	}
+
+	delegations, err := cfg.verifySignature(c, signature, caveats)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.validations.add(key, validationResult{
+		expires:     timeNow().Add(cfg.ValidationCacheTTL),
+		delegations: delegations,
+		labels:      c.Locals(labelsLocal),
+		chain:       c.Locals(signatureChainLocal),
+	})
+
+	return delegations, nil
+}
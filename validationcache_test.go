@@ -0,0 +1,74 @@
+package signed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestValidationCache(t *testing.T) {
+	// Initalize config
+	app := fiber.New()
+
+	app.Use(New(Config{
+		GetPrivateKeyFunc:  func() string { return "secret" },
+		ValidationCacheTTL: 5 * time.Second,
+	}))
+
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, world!")
+	})
+
+	// Count how often bodies are canonicalized
+	canonicalized := 0
+	RegisterBodyCanonicalizer("application/x-upload", func(body []byte) ([]byte, error) {
+		canonicalized++
+		return body, nil
+	})
+	defer RegisterBodyCanonicalizer("application/x-upload", nil)
+
+	newRequest := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.RequestURI = ""
+		req.Header.Set(fiber.HeaderContentType, "application/x-upload")
+		return req
+	}
+
+	signedURL, _ := GetSignedURLFromHTTPRequest(newRequest("http://example.com/upload", "large upload"))
+
+	t.Run("it should verify retries of a request once", func(t *testing.T) {
+		canonicalized = 0
+
+		for i := 0; i < 3; i++ {
+			resp, _ := app.Test(newRequest(signedURL, "large upload"))
+			utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		}
+		utils.AssertEqual(t, 1, canonicalized)
+	})
+
+	t.Run("it should verify requests with a different body", func(t *testing.T) {
+		canonicalized = 0
+
+		resp, _ := app.Test(newRequest(signedURL, "tampered upload"))
+
+		utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+		utils.AssertEqual(t, 1, canonicalized)
+	})
+
+	t.Run("it should verify retries again once the result expires", func(t *testing.T) {
+		canonicalized = 0
+
+		restore := InjectFaults(Faults{ClockSkew: 10 * time.Second})
+		defer restore()
+
+		resp, _ := app.Test(newRequest(signedURL, "large upload"))
+
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, 1, canonicalized)
+	})
+}
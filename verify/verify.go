@@ -0,0 +1,459 @@
+// Package verify checks fiber-signed URL signatures without depending on
+// fiber, for embedding in Go-based proxies (eg. Caddy or Traefik plugins) so
+// edge layers can reject invalid signed URLs before they reach the app.
+//
+// It only verifies plain signatures and expiries, including relative ones,
+// with the middleware's pepper, host aliases and escrowed keys. Stateful and
+// conditional checks (nonces, rate limits, policies, ...) are left to the
+// middleware, and URLs using features it cannot verify (eg. bound URLs, or
+// algorithms named in URLs other than Algorithm) are reported with
+// ErrUnverifiable so they can be passed through.
+package verify
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bsandusky/fiber-signed/hostname"
+	"github.com/bsandusky/fiber-signed/internal/canon"
+)
+
+// Hash function algorithmic option values, matching signed.Algorithm
+const (
+	AlgorithmSHA1   = "SHA-1"
+	AlgorithmSHA256 = "SHA-256"
+	AlgorithmMD5    = "MD-5"
+
+	AlgorithmHMACSHA256 = "HMAC-SHA-256"
+)
+
+// GET and HEAD body policy values, matching signed.BodyPolicy
+const (
+	BodyHash = iota
+	BodyIgnore
+	BodyReject
+)
+
+// ErrUnverifiable is returned for URLs using features only the middleware can
+// verify, eg. caveats, delegation, co-signatures, token mode or BindLocal
+var ErrUnverifiable = errors.New("url signature cannot be verified outside the middleware")
+
+// errExpired rejects URLs past their expiry
+var errExpired = errors.New("url signature has expired")
+
+// Config defines the config for Verifier, matching the middleware's config
+type Config struct {
+	// Algorithm defines the hash function used to create signatures.
+	//
+	// Optional. Default: AlgorithmSHA1
+	Algorithm string
+
+	// GetPrivateKeyFunc defines a function to retrieve the private key.
+	//
+	// Required.
+	GetPrivateKeyFunc func() string
+
+	// GetPepperFunc defines a function to obtain the pepper mixed into the
+	// private key like the middleware's GetPepperFunc.
+	//
+	// Optional. Default: nil
+	GetPepperFunc func() string
+
+	// SignatureBits truncates signatures like the middleware's SignatureBits.
+	//
+	// Optional. Default: 0
+	SignatureBits int
+
+	// PreserveValueOrder signs repeated query params in the order they
+	// appear, matching the middleware's MultiValuePreserve.
+	//
+	// Optional. Default: false
+	PreserveValueOrder bool
+
+	// QueryEncoderFunc builds the query string hashed into signatures like
+	// the middleware's QueryEncoderFunc.
+	//
+	// Optional. Default: nil
+	QueryEncoderFunc func(q url.Values) string
+
+	// GetHeadBodyPolicy defines how bodies of GET and HEAD requests are
+	// signed like the middleware's GetHeadBodyPolicy.
+	//
+	// Optional. Default: BodyHash
+	GetHeadBodyPolicy int
+
+	// ContentDigest signs the Content-Digest (or Repr-Digest) header of
+	// requests in place of their body like the middleware's ContentDigest.
+	//
+	// Optional. Default: false
+	ContentDigest bool
+
+	// MaxBodyBytes rejects requests whose body is larger, as it is read
+	// whole to be hashed. The middleware relies on fiber's BodyLimit.
+	//
+	// Optional. Default: 4 * 1024 * 1024
+	MaxBodyBytes int
+
+	// MaxDecodedBodyBytes rejects requests whose body, once its
+	// Content-Encoding is decoded for hashing, is larger, like the
+	// middleware's MaxDecodedBodyBytes.
+	//
+	// Optional. Default: 4 * 1024 * 1024
+	MaxDecodedBodyBytes int
+
+	// ForceScheme replaces the scheme of URLs like the middleware's
+	// ForceScheme.
+	//
+	// Optional. Default: ""
+	ForceScheme string
+
+	// RequireHTTPS rejects requests not made over HTTPS like the
+	// middleware's RequireHTTPS.
+	//
+	// Optional. Default: false
+	RequireHTTPS bool
+
+	// HostAliases declares groups of interchangeable hosts like the
+	// middleware's HostAliases.
+	//
+	// Optional. Default: nil
+	HostAliases [][]string
+
+	// BindLocal is the middleware's BindLocal. Bound URLs are signed with a
+	// local only the middleware knows, so when set, URLs not signed unbound
+	// are reported with ErrUnverifiable rather than rejected.
+	//
+	// Optional. Default: ""
+	BindLocal string
+
+	// EscrowHeader is the request header carrying the requester's key
+	// fragment like the middleware's EscrowHeader. Requests without it are
+	// rejected.
+	//
+	// Optional. Default: ""
+	EscrowHeader string
+
+	// QueryKeyPrefix namespaces the default query keys like the
+	// middleware's QueryKeyPrefix.
+	//
+	// Optional. Default: ""
+	QueryKeyPrefix string
+
+	// Optional. Default: "signature"
+	SignatureQueryKey string
+
+	// Optional. Default: "privateKey"
+	PrivateKeyQueryKey string
+
+	// Optional. Default: "expires"
+	ExpiresQueryKey string
+
+	// Optional. Default: "bodyHash"
+	BodyHashQueryKey string
+
+	// Optional. Default: "expiresIn"
+	ExpiresInQueryKey string
+
+	// Optional. Default: "issued"
+	IssuedQueryKey string
+
+	// Optional. Default: "alg"
+	AlgorithmQueryKey string
+
+	// UnverifiableQueryKeys are the query params of features reported with
+	// ErrUnverifiable.
+	//
+	// Optional. Default: []string{"caveat", "delegation", "token"}
+	UnverifiableQueryKeys []string
+}
+
+// Verifier checks signed URLs
+type Verifier struct {
+	config Config
+}
+
+// New creates a Verifier
+func New(config Config) *Verifier {
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmSHA1
+	}
+	if config.SignatureBits > 0 && config.SignatureBits < 64 {
+		config.SignatureBits = 64
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 4 * 1024 * 1024
+	}
+	if config.MaxDecodedBodyBytes <= 0 {
+		config.MaxDecodedBodyBytes = 4 * 1024 * 1024
+	}
+	if config.SignatureQueryKey == "" {
+		config.SignatureQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "signature")
+	}
+	if config.PrivateKeyQueryKey == "" {
+		config.PrivateKeyQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "privateKey")
+	}
+	if config.ExpiresQueryKey == "" {
+		config.ExpiresQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "expires")
+	}
+	if config.BodyHashQueryKey == "" {
+		config.BodyHashQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "bodyHash")
+	}
+	if config.ExpiresInQueryKey == "" {
+		config.ExpiresInQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "expiresIn")
+	}
+	if config.IssuedQueryKey == "" {
+		config.IssuedQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "issued")
+	}
+	if config.AlgorithmQueryKey == "" {
+		config.AlgorithmQueryKey = canon.PrefixQueryKey(config.QueryKeyPrefix, "alg")
+	}
+	if config.UnverifiableQueryKeys == nil {
+		config.UnverifiableQueryKeys = []string{
+			canon.PrefixQueryKey(config.QueryKeyPrefix, "caveat"),
+			canon.PrefixQueryKey(config.QueryKeyPrefix, "delegation"),
+			canon.PrefixQueryKey(config.QueryKeyPrefix, "token"),
+		}
+	}
+
+	config.HostAliases = normalizeHostAliases(config.HostAliases)
+
+	return &Verifier{config: config}
+}
+
+// normalizeHostAliases returns the groups of aliases with every host
+// normalized, dropping hosts which can't be
+func normalizeHostAliases(groups [][]string) [][]string {
+	var normalized [][]string
+	for _, group := range groups {
+		var hosts []string
+		for _, host := range group {
+			if h, err := hostname.Normalize(host); err == nil {
+				hosts = append(hosts, h)
+			}
+		}
+		if len(hosts) > 1 {
+			normalized = append(normalized, hosts)
+		}
+	}
+
+	return normalized
+}
+
+// getHosts returns host followed by each of its aliases
+func (v *Verifier) getHosts(host string) []string {
+	hosts := []string{host}
+	normalized, err := hostname.Normalize(host)
+	if err != nil {
+		return hosts
+	}
+
+	seen := map[string]bool{normalized: true}
+	for _, group := range v.config.HostAliases {
+		member := false
+		for _, h := range group {
+			member = member || h == normalized
+		}
+		if !member {
+			continue
+		}
+		for _, alias := range group {
+			if !seen[alias] {
+				seen[alias] = true
+				hosts = append(hosts, alias)
+			}
+		}
+	}
+
+	return hosts
+}
+
+// getPrivateKey returns the key signatures of r are computed with: the
+// private key, peppered and combined with the escrowed key fragment of r if
+// configured
+func (v *Verifier) getPrivateKey(r *http.Request) (string, error) {
+	privateKey := v.config.GetPrivateKeyFunc()
+
+	if v.config.GetPepperFunc != nil {
+		pepper := v.config.GetPepperFunc()
+		if pepper == "" {
+			return "", errors.New("pepper must not be empty")
+		}
+		privateKey = canon.PepperKey(privateKey, pepper)
+	}
+
+	if v.config.EscrowHeader != "" {
+		fragment := r.Header.Get(v.config.EscrowHeader)
+		if fragment == "" {
+			return "", fmt.Errorf("%s header is required for a signed URL route", v.config.EscrowHeader)
+		}
+		privateKey = canon.EscrowKey(privateKey, fragment)
+	}
+
+	return privateKey, nil
+}
+
+// checkExpiry rejects expired URLs, whether they expire at an absolute time
+// or a number of seconds after they were issued
+func (v *Verifier) checkExpiry(q url.Values) error {
+	if expires := q.Get(v.config.ExpiresQueryKey); expires != "" {
+		i, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s value must be valid integer", v.config.ExpiresQueryKey)
+		}
+		if time.Unix(i, 0).Before(time.Now()) {
+			return errExpired
+		}
+	}
+
+	if expiresIn := q.Get(v.config.ExpiresInQueryKey); expiresIn != "" {
+		seconds, err := strconv.ParseInt(expiresIn, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s value must be valid integer", v.config.ExpiresInQueryKey)
+		}
+		issued, err := strconv.ParseInt(q.Get(v.config.IssuedQueryKey), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s value must be a valid time", v.config.IssuedQueryKey)
+		}
+		if time.Unix(issued, 0).Add(time.Duration(seconds) * time.Second).Before(time.Now()) {
+			return errExpired
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the signature and expiry of r, returning nil if it is valid.
+// The body of r is read and replaced so it can still be forwarded.
+func (v *Verifier) Verify(r *http.Request) error {
+	scheme := getScheme(r)
+	if v.config.RequireHTTPS && scheme != "https" {
+		return errors.New("signed URLs must be requested over https")
+	}
+
+	q, err := canon.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range v.config.UnverifiableQueryKeys {
+		if q.Get(key) != "" {
+			return ErrUnverifiable
+		}
+	}
+
+	// Co-signed URLs carry signatures prefixed with their key ID
+	signature := q.Get(v.config.SignatureQueryKey)
+	if signature == "" {
+		return fmt.Errorf("%s is a required query param for a signed URL route", v.config.SignatureQueryKey)
+	} else if len(q[v.config.SignatureQueryKey]) > 1 || strings.Contains(signature, ":") {
+		return ErrUnverifiable
+	}
+
+	// URLs naming another algorithm may be allowed by the middleware
+	if alg := q.Get(v.config.AlgorithmQueryKey); alg != "" && alg != canon.AlgorithmIDs[v.config.Algorithm] {
+		return ErrUnverifiable
+	}
+
+	if err := v.checkExpiry(q); err != nil {
+		return err
+	}
+
+	privateKey, err := v.getPrivateKey(r)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(io.LimitReader(r.Body, int64(v.config.MaxBodyBytes)+1)); err != nil {
+			return err
+		}
+		if len(body) > v.config.MaxBodyBytes {
+			return errors.New("body is too large")
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if !v.config.ContentDigest || !canon.HasDigest(r.Header.Get) {
+		var err error
+		if body, err = canon.DecodeBody(r.Header.Get("Content-Encoding"), body, v.config.MaxDecodedBodyBytes); err != nil {
+			return err
+		}
+	}
+	if len(body) > 0 && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		switch v.config.GetHeadBodyPolicy {
+		case BodyIgnore:
+			body = nil
+		case BodyReject:
+			return fmt.Errorf("%s requests must not have a body", r.Method)
+		}
+	}
+	if v.config.ContentDigest {
+		var err error
+		if body, err = canon.DigestBody(r.Header.Get, body, true); err != nil {
+			return err
+		}
+	}
+
+	params := canon.Params{
+		Algorithm:          v.config.Algorithm,
+		PrivateKey:         privateKey,
+		SignatureBits:      v.config.SignatureBits,
+		PreserveValueOrder: v.config.PreserveValueOrder,
+		QueryEncoder:       v.config.QueryEncoderFunc,
+		ForceScheme:        v.config.ForceScheme,
+		SignatureQueryKey:  v.config.SignatureQueryKey,
+		PrivateKeyQueryKey: v.config.PrivateKeyQueryKey,
+		BodyHashQueryKey:   v.config.BodyHashQueryKey,
+	}
+
+	// Try the request host and then any of its aliases
+	for _, host := range v.getHosts(r.Host) {
+		canonical, err := params.String(r.Method, scheme, host, r.URL.RequestURI(), body)
+		if err != nil {
+			return err
+		}
+
+		hashed := params.Sign(canonical)
+		if subtle.ConstantTimeCompare([]byte(hashed), []byte(signature)) == 1 {
+			return nil
+		}
+	}
+
+	// URLs signed for a user can only be checked by the middleware
+	if v.config.BindLocal != "" {
+		return ErrUnverifiable
+	}
+
+	return errors.New("invalid signature")
+}
+
+// getScheme returns the scheme r was made with, as fiber determines it
+func getScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if proto := r.Header.Get("X-Forwarded-Protocol"); proto != "" {
+		return proto
+	}
+	if r.Header.Get("X-Forwarded-Ssl") == "on" {
+		return "https"
+	}
+	if scheme := r.Header.Get("X-Url-Scheme"); scheme != "" {
+		return scheme
+	}
+
+	return "http"
+}
@@ -0,0 +1,379 @@
+package verify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	signed "github.com/bsandusky/fiber-signed"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestVerify(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         signed.AlgorithmSHA256,
+	})
+
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmSHA256,
+	})
+
+	sign := func(method, target, body string) string {
+		signedURL, err := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(method, target, strings.NewReader(body)))
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should accept urls signed by the middleware", func(t *testing.T) {
+		for _, target := range []string{
+			"http://example.com",
+			"http://example.com/files/a%20b.txt?tag=b&tag=a&empty=",
+			"http://example.com/?name=Zo%C3%AB&q=a+b",
+			fmt.Sprintf("http://example.com/?expires=%d", time.Now().Add(time.Hour).Unix()),
+		} {
+			r := httptest.NewRequest(http.MethodGet, sign(http.MethodGet, target, ""), nil)
+
+			utils.AssertEqual(t, nil, v.Verify(r), target)
+		}
+	})
+
+	t.Run("it should accept signed bodies and leave them readable", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, sign(http.MethodPost, "http://example.com/submit", "body"), strings.NewReader("body"))
+
+		utils.AssertEqual(t, nil, v.Verify(r))
+		utils.AssertEqual(t, nil, v.Verify(r))
+	})
+
+	t.Run("it should reject tampered urls", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, sign(http.MethodGet, "http://example.com/?a=1", "")+"&b=2", nil)
+
+		utils.AssertEqual(t, "invalid signature", v.Verify(r).Error())
+	})
+
+	t.Run("it should reject expired urls", func(t *testing.T) {
+		target := fmt.Sprintf("http://example.com/?expires=%d", time.Now().Add(-time.Hour).Unix())
+		r := httptest.NewRequest(http.MethodGet, sign(http.MethodGet, target, ""), nil)
+
+		utils.AssertEqual(t, "url signature has expired", v.Verify(r).Error())
+	})
+
+	t.Run("it should use the forwarded scheme", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, strings.Replace(sign(http.MethodGet, "https://example.com/", ""), "https:", "http:", 1), nil)
+		r.Header.Set("X-Forwarded-Proto", "https")
+
+		utils.AssertEqual(t, nil, v.Verify(r))
+	})
+
+	t.Run("it should report urls using middleware only features", func(t *testing.T) {
+		signedURL, _ := signed.AddCaveat(sign(http.MethodGet, "http://example.com/", ""), signed.PathCaveat("/*"))
+		r := httptest.NewRequest(http.MethodGet, signedURL, nil)
+
+		utils.AssertEqual(t, ErrUnverifiable, v.Verify(r))
+	})
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         signed.AlgorithmHMACSHA256,
+	})
+
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		Algorithm:         AlgorithmHMACSHA256,
+	})
+
+	t.Run("it should accept urls signed by the middleware with hmac", func(t *testing.T) {
+		signedURL, err := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodPost, "http://example.com/?a=1", strings.NewReader("body")))
+		utils.AssertEqual(t, nil, err)
+
+		r := httptest.NewRequest(http.MethodPost, signedURL, strings.NewReader("body"))
+
+		utils.AssertEqual(t, nil, v.Verify(r))
+	})
+}
+
+func TestVerifySignatureBits(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		SignatureBits:     128,
+	})
+
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		SignatureBits:     128,
+	})
+
+	t.Run("it should accept truncated signatures", func(t *testing.T) {
+		signedURL, err := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1", nil))
+		utils.AssertEqual(t, nil, err)
+
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+	})
+}
+
+func TestVerifyGetHeadBodyPolicy(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		GetHeadBodyPolicy: signed.BodyIgnore,
+	})
+
+	signedURL, _ := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", strings.NewReader("body")))
+
+	t.Run("it should ignore get bodies like the middleware", func(t *testing.T) {
+		v := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			GetHeadBodyPolicy: BodyIgnore,
+		})
+
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+	})
+
+	t.Run("it should reject get bodies like the middleware", func(t *testing.T) {
+		v := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			GetHeadBodyPolicy: BodyReject,
+		})
+
+		err := v.Verify(httptest.NewRequest(http.MethodGet, signedURL, strings.NewReader("body")))
+		utils.AssertEqual(t, "GET requests must not have a body", err.Error())
+	})
+}
+
+func TestVerifyContentDigest(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ContentDigest:     true,
+	})
+
+	sum := sha256.Sum256([]byte("body"))
+	digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	newRequest := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Digest", digest)
+		return req
+	}
+
+	signedURL, _ := signed.GetSignedURLFromHTTPRequest(newRequest("http://example.com/", ""))
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		ContentDigest:     true,
+	})
+
+	t.Run("it should verify digests like the middleware", func(t *testing.T) {
+		utils.AssertEqual(t, nil, v.Verify(newRequest(signedURL, "body")))
+	})
+
+	t.Run("it should reject bodies not matching the digest", func(t *testing.T) {
+		err := v.Verify(newRequest(signedURL, "tampered"))
+		utils.AssertEqual(t, "body does not match digest header", err.Error())
+	})
+}
+
+func TestVerifyCompressedBody(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	})
+
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte("body"))
+	w.Close()
+
+	signedURL, _ := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("body")))
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, signedURL, bytes.NewReader(gzipped.Bytes()))
+		r.Header.Set("Content-Encoding", "gzip")
+		return r
+	}
+
+	t.Run("it should hash compressed bodies as decoded content like the middleware", func(t *testing.T) {
+		v := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		})
+
+		utils.AssertEqual(t, nil, v.Verify(newRequest()))
+	})
+
+	t.Run("it should reject bodies decoding larger than MaxDecodedBodyBytes", func(t *testing.T) {
+		v := New(Config{
+			GetPrivateKeyFunc:   func() string { return "secret" },
+			MaxDecodedBodyBytes: 2,
+		})
+
+		utils.AssertEqual(t, "decoded body is too large", v.Verify(newRequest()).Error())
+	})
+}
+
+func TestVerifyMaxBodyBytes(t *testing.T) {
+	// Initalize config
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		MaxBodyBytes:      4,
+	})
+
+	t.Run("it should reject bodies larger than MaxBodyBytes", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/?signature=abc", strings.NewReader("hello"))
+
+		utils.AssertEqual(t, "body is too large", v.Verify(r).Error())
+	})
+
+	t.Run("it should read bodies within MaxBodyBytes", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/?signature=abc", strings.NewReader("hell"))
+
+		utils.AssertEqual(t, "invalid signature", v.Verify(r).Error())
+	})
+}
+
+func TestVerifyQueryEncoderFunc(t *testing.T) {
+	// Keep repeated values in the order sent
+	encodeQuery := func(q url.Values) string {
+		var pairs []string
+		for key, values := range q {
+			for _, value := range values {
+				pairs = append(pairs, key+"="+value)
+			}
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return strings.SplitN(pairs[i], "=", 2)[0] < strings.SplitN(pairs[j], "=", 2)[0]
+		})
+		return strings.Join(pairs, ";")
+	}
+
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		QueryEncoderFunc:  encodeQuery,
+	})
+
+	signedURL, _ := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?ids[]=2&ids[]=1", nil))
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+		QueryEncoderFunc:  encodeQuery,
+	})
+
+	t.Run("it should encode queries like the middleware", func(t *testing.T) {
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+	})
+}
+
+func TestVerifySemicolonQuery(t *testing.T) {
+	signed.New(signed.Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	})
+
+	signedURL, _ := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/?a=1;b=2&flag", nil))
+	v := New(Config{
+		GetPrivateKeyFunc: func() string { return "secret" },
+	})
+
+	t.Run("it should parse queries like the middleware", func(t *testing.T) {
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+	})
+
+	t.Run("it should reject appended params holding semicolons", func(t *testing.T) {
+		utils.AssertEqual(t, true, v.Verify(httptest.NewRequest(http.MethodGet, signedURL+"&a;x=1", nil)) != nil)
+	})
+}
+
+func TestVerifyMiddlewareOptions(t *testing.T) {
+	defer signed.New()
+
+	sign := func(config signed.Config, target string) string {
+		config.GetPrivateKeyFunc = func() string { return "secret" }
+		signed.New(config)
+
+		signedURL, err := signed.GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+		return signedURL
+	}
+
+	t.Run("it should accept urls signed with a pepper", func(t *testing.T) {
+		signedURL := sign(signed.Config{GetPepperFunc: func() string { return "pepper" }}, "http://example.com/")
+
+		v := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			GetPepperFunc:     func() string { return "pepper" },
+		})
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+
+		v = New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+		utils.AssertEqual(t, "invalid signature", v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)).Error())
+	})
+
+	t.Run("it should accept urls naming Algorithm and report others", func(t *testing.T) {
+		signedURL := sign(signed.Config{Algorithm: signed.AlgorithmSHA256, EmbedAlgorithm: true}, "http://example.com/")
+
+		v := New(Config{GetPrivateKeyFunc: func() string { return "secret" }, Algorithm: AlgorithmSHA256})
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+
+		v = New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+		utils.AssertEqual(t, ErrUnverifiable, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+	})
+
+	t.Run("it should accept urls signed for an alias of the host", func(t *testing.T) {
+		signedURL := sign(signed.Config{}, "http://cdn.example.com/")
+		r := httptest.NewRequest(http.MethodGet, strings.Replace(signedURL, "cdn.example.com", "assets.example.com", 1), nil)
+
+		v := New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			HostAliases:       [][]string{{"CDN.example.com", "assets.example.com"}},
+		})
+		utils.AssertEqual(t, nil, v.Verify(r))
+
+		v = New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+		utils.AssertEqual(t, "invalid signature", v.Verify(r).Error())
+	})
+
+	t.Run("it should report bound urls", func(t *testing.T) {
+		signed.New(signed.Config{GetPrivateKeyFunc: func() string { return "secret" }, BindLocal: "userID"})
+		bound, err := signed.GetBoundSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), "42")
+		utils.AssertEqual(t, nil, err)
+		unbound := sign(signed.Config{BindLocal: "userID"}, "http://example.com/")
+
+		v := New(Config{GetPrivateKeyFunc: func() string { return "secret" }, BindLocal: "userID"})
+		utils.AssertEqual(t, ErrUnverifiable, v.Verify(httptest.NewRequest(http.MethodGet, bound, nil)))
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, unbound, nil)))
+	})
+
+	t.Run("it should accept escrowed urls with their key fragment", func(t *testing.T) {
+		signed.New(signed.Config{GetPrivateKeyFunc: func() string { return "secret" }, EscrowHeader: "X-Key-Fragment"})
+		signedURL, err := signed.GetEscrowedSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), "fragment")
+		utils.AssertEqual(t, nil, err)
+
+		v := New(Config{GetPrivateKeyFunc: func() string { return "secret" }, EscrowHeader: "X-Key-Fragment"})
+
+		r := httptest.NewRequest(http.MethodGet, signedURL, nil)
+		r.Header.Set("X-Key-Fragment", "fragment")
+		utils.AssertEqual(t, nil, v.Verify(r))
+
+		r = httptest.NewRequest(http.MethodGet, signedURL, nil)
+		utils.AssertEqual(t, "X-Key-Fragment header is required for a signed URL route", v.Verify(r).Error())
+
+		r.Header.Set("X-Key-Fragment", "other")
+		utils.AssertEqual(t, "invalid signature", v.Verify(r).Error())
+	})
+
+	t.Run("it should enforce relative expiries", func(t *testing.T) {
+		signed.New(signed.Config{GetPrivateKeyFunc: func() string { return "secret" }})
+		v := New(Config{GetPrivateKeyFunc: func() string { return "secret" }})
+
+		signedURL, err := signed.GetSignedURLWithTTLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), time.Hour)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, nil, v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)))
+
+		target := fmt.Sprintf("http://example.com/?expiresIn=60&issued=%d", time.Now().Add(-time.Hour).Unix())
+		signedURL = sign(signed.Config{}, target)
+		utils.AssertEqual(t, "url signature has expired", v.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil)).Error())
+	})
+}
@@ -0,0 +1,76 @@
+package signed
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// maxPortableURLLength is the length beyond which some browsers, proxies and
+// email clients truncate URLs
+const maxPortableURLLength = 2000
+
+// WarningCode identifies the kind of a Warning
+type WarningCode string
+
+// Warning code values
+const (
+	// WarningNoExpiry warns the URL never expires
+	WarningNoExpiry WarningCode = "noExpiry"
+
+	// WarningMaxAge warns the URL will be rejected for its age (see MaxAge)
+	// before it expires, or at once if it has no issued time
+	WarningMaxAge WarningCode = "maxAge"
+
+	// WarningInsecureScheme warns the URL uses http while RequireHTTPS is set
+	WarningInsecureScheme WarningCode = "insecureScheme"
+
+	// WarningURLLength warns the URL is longer than 2000 characters, which
+	// some clients truncate
+	WarningURLLength WarningCode = "urlLength"
+)
+
+// Warning is a non-fatal problem with a signed URL, found when it is signed
+// rather than when requests for it are rejected
+type Warning struct {
+	// Code is the kind of warning, eg. WarningNoExpiry
+	Code WarningCode
+
+	// Message describes the problem, eg. "url never expires"
+	Message string
+}
+
+// String returns the message of the warning
+func (w Warning) String() string {
+	return w.Message
+}
+
+// getWarnings returns the warnings for signed URL u under the current config
+func (cfg *instance) getWarnings(u *url.URL) []Warning {
+	var warnings []Warning
+	q := urlQuery(u)
+
+	expires, issued := cfg.getURLLifetimeFrom(context.Background(), q.Get)
+	if expires.IsZero() {
+		warnings = append(warnings, Warning{Code: WarningNoExpiry, Message: "url never expires"})
+	}
+
+	// Tokens and nginx secure links are not subject to MaxAge
+	if cfg.MaxAge > 0 && q.Get(cfg.TokenQueryKey) == "" && q.Get(cfg.NginxMD5QueryKey) == "" {
+		if issued.IsZero() {
+			warnings = append(warnings, Warning{Code: WarningMaxAge, Message: "url has no issued time, so MaxAge rejects it"})
+		} else if expires.IsZero() || expires.Sub(issued) > cfg.MaxAge {
+			warnings = append(warnings, Warning{Code: WarningMaxAge, Message: fmt.Sprintf("url is rejected %s after it is issued by MaxAge, before it expires", cfg.MaxAge)})
+		}
+	}
+
+	if cfg.RequireHTTPS && u.Scheme == "http" {
+		warnings = append(warnings, Warning{Code: WarningInsecureScheme, Message: "url uses http, but RequireHTTPS is set"})
+	}
+
+	if n := len(u.String()); n > maxPortableURLLength {
+		warnings = append(warnings, Warning{Code: WarningURLLength, Message: fmt.Sprintf("url is %d characters long, over the %d some clients truncate to", n, maxPortableURLLength)})
+	}
+
+	return warnings
+}
@@ -0,0 +1,95 @@
+package signed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestWarnings(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+
+	// codes returns the codes of the warnings for the URL signed for target
+	codes := func(target string) []WarningCode {
+		signedURL, err := SignURL(httptest.NewRequest(http.MethodGet, target, nil))
+		utils.AssertEqual(t, nil, err)
+
+		var codes []WarningCode
+		for _, w := range signedURL.Warnings() {
+			codes = append(codes, w.Code)
+		}
+		return codes
+	}
+
+	t.Run("it should warn of urls which never expire", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		})
+
+		utils.AssertEqual(t, []WarningCode{WarningNoExpiry}, codes("http://example.com/"))
+		utils.AssertEqual(t, []WarningCode(nil), codes(fmt.Sprintf("http://example.com/?expires=%d", expires)))
+	})
+
+	t.Run("it should warn of urls rejected by MaxAge before they expire", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxAge:            time.Minute,
+		})
+		utils.AssertEqual(t, []WarningCode{WarningMaxAge}, codes(fmt.Sprintf("http://example.com/?expires=%d", expires)))
+
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			MaxAge:            2 * time.Hour,
+			StampIssued:       true,
+		})
+		utils.AssertEqual(t, []WarningCode(nil), codes(fmt.Sprintf("http://example.com/?expires=%d", expires)))
+		utils.AssertEqual(t, []WarningCode{WarningNoExpiry, WarningMaxAge}, codes("http://example.com/"))
+	})
+
+	t.Run("it should warn of http urls when https is required", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+			RequireHTTPS:      true,
+		})
+
+		utils.AssertEqual(t, []WarningCode{WarningInsecureScheme}, codes(fmt.Sprintf("http://example.com/?expires=%d", expires)))
+		utils.AssertEqual(t, []WarningCode(nil), codes(fmt.Sprintf("https://example.com/?expires=%d", expires)))
+	})
+
+	t.Run("it should warn of long urls", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		})
+
+		signedURL, _ := SignURL(httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/?expires=%d&q=%s", expires, strings.Repeat("a", 2000)), nil))
+		utils.AssertEqual(t, 1, len(signedURL.Warnings()))
+		utils.AssertEqual(t, WarningURLLength, signedURL.Warnings()[0].Code)
+		utils.AssertEqual(t, fmt.Sprintf("url is %d characters long, over the 2000 some clients truncate to", len(signedURL.String())), signedURL.Warnings()[0].String())
+	})
+
+	t.Run("it should report warnings in signed events", func(t *testing.T) {
+		// Initalize config
+		New(Config{
+			GetPrivateKeyFunc: func() string { return "secret" },
+		})
+
+		var signed Event
+		unsubscribe := Subscribe(func(e Event) {
+			signed = e
+		})
+		defer unsubscribe()
+
+		GetSignedURLFromHTTPRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		utils.AssertEqual(t, []Warning{{Code: WarningNoExpiry, Message: "url never expires"}}, signed.Warnings)
+	})
+}